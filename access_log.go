@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxScrapeRemotes is the number of scraper addresses counted on their own;
+// requests from any further address are counted as otherScrapeRemote.
+const (
+	maxScrapeRemotes  = 32
+	otherScrapeRemote = "other"
+)
+
+var scrapesTotal = newRemoteCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exporter_scrapes_total",
+		Help:      "Number of metrics requests per remote address of the scraper, with addresses beyond the first 32 counted as other.",
+	},
+	maxScrapeRemotes,
+)
+
+// remoteCounter is a counter by remote address that keeps the first max
+// addresses it sees and folds the rest into otherScrapeRemote, so that
+// clients connecting from ever new addresses cannot grow it without bound.
+type remoteCounter struct {
+	*prometheus.CounterVec
+	max int
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newRemoteCounter(opts prometheus.CounterOpts, max int) *remoteCounter {
+	return &remoteCounter{
+		CounterVec: prometheus.NewCounterVec(opts, []string{"remote"}),
+		max:        max,
+		seen:       make(map[string]bool),
+	}
+}
+
+// inc counts a request from remote.
+func (c *remoteCounter) inc(remote string) {
+	c.mu.Lock()
+	if !c.seen[remote] {
+		if len(c.seen) < c.max {
+			c.seen[remote] = true
+		} else {
+			remote = otherScrapeRemote
+		}
+	}
+	c.mu.Unlock()
+	c.WithLabelValues(remote).Inc()
+}
+
+// Reset deletes all series and forgets the addresses seen.
+func (c *remoteCounter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen = make(map[string]bool)
+	c.CounterVec.Reset()
+}
+
+// scrapeLogger counts every request to next by the IP address of the client
+// in nvgpu_exporter_scrapes_total and, when accessLog is set, logs it, so
+// that operators can tell which of several scrapers polls the node and how
+// often.
+func scrapeLogger(next http.Handler, accessLog bool, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote := remoteHost(r.RemoteAddr)
+		scrapesTotal.inc(remote)
+		if !accessLog {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logger.Info("scrape",
+			"remote", remote,
+			"path", r.URL.Path,
+			"user_agent", r.UserAgent(),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start))
+	})
+}
+
+// remoteHost returns the host of a host:port remote address, or the address
+// itself when it has no port.
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// statusRecorder records the status code and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Heavy collection work the adaptive throttle slows down: the topology
+// collector, which queries every GPU pair, and the 16 FEC history fields per
+// link of the NVLink collector.
+const (
+	heavyTopology  = "topology"
+	heavyNVLinkFec = "nvlink_fec"
+)
+
+var effectiveCollectionInterval = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_effective_collection_interval_seconds",
+		Help:      "Interval at which heavy collection work currently runs; longer than -collection-interval while the GPUs are busier than -adaptive-utilization-threshold.",
+	},
+	[]string{"collector"},
+)
+
+// adaptiveThrottle runs heavy collection work only every slowdown-th round
+// while the busiest GPU is above a utilization threshold, so that the exporter
+// interferes less with latency-sensitive inference. The light collectors,
+// including all health signals, keep their interval. A nil *adaptiveThrottle
+// runs everything every round. It is only used by the collection goroutine.
+type adaptiveThrottle struct {
+	threshold float64
+	slowdown  int
+	interval  time.Duration
+
+	busy bool
+	// skipped holds the rounds skipped in a row per heavy work
+	skipped map[string]int
+}
+
+// newAdaptiveThrottle returns nil when threshold is 0.
+func newAdaptiveThrottle(threshold float64, slowdown int, interval time.Duration) *adaptiveThrottle {
+	if threshold <= 0 {
+		return nil
+	}
+	return &adaptiveThrottle{threshold: threshold, slowdown: slowdown, interval: interval, skipped: make(map[string]int)}
+}
+
+// update measures the utilization of the GPUs at the start of a round.
+func (a *adaptiveThrottle) update(devices []Device, logger *slog.Logger) {
+	if a == nil {
+		return
+	}
+
+	var busiest float64
+	for _, device := range devices {
+		rates, ret := device.GetUtilizationRates()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			continue
+		}
+		busiest = max(busiest, float64(rates.Gpu)/100)
+	}
+
+	busy := busiest > a.threshold
+	if busy != a.busy {
+		logger.Info("adapting heavy collection interval", "busy", busy, "utilization", busiest, "threshold", a.threshold)
+	}
+	a.busy = busy
+}
+
+// due reports whether the heavy work name runs this round and exports its
+// effective interval.
+func (a *adaptiveThrottle) due(name string) bool {
+	if a == nil {
+		return true
+	}
+
+	interval := a.interval
+	if a.busy {
+		interval *= time.Duration(a.slowdown)
+	}
+	effectiveCollectionInterval.WithLabelValues(name).Set(interval.Seconds())
+
+	if !a.busy || a.skipped[name] >= a.slowdown-1 {
+		a.skipped[name] = 0
+		return true
+	}
+	a.skipped[name]++
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAdaptiveThrottle(t *testing.T) {
+	assert := hammy.New(t)
+	effectiveCollectionInterval.Reset()
+	t.Cleanup(effectiveCollectionInterval.Reset)
+
+	idle := &utilizationDevice{rates: nvml.Utilization{Gpu: 20}}
+	busy := &utilizationDevice{rates: nvml.Utilization{Gpu: 95}}
+	throttle := newAdaptiveThrottle(0.8, 3, 10*time.Second)
+	interval := func() float64 {
+		return testutil.ToFloat64(effectiveCollectionInterval.WithLabelValues(heavyTopology))
+	}
+	runs := func(rounds int, devices ...Device) int {
+		n := 0
+		for range rounds {
+			throttle.update(devices, discardLogger())
+			if throttle.due(heavyTopology) {
+				n++
+			}
+		}
+		return n
+	}
+
+	assert.Is(hammy.Number(runs(6, idle)).EqualTo(6))
+	assert.Is(hammy.Number(interval()).EqualTo(10))
+
+	// The busiest GPU decides
+	assert.Is(hammy.Number(runs(6, idle, busy)).EqualTo(2))
+	assert.Is(hammy.Number(interval()).EqualTo(30))
+
+	assert.Is(hammy.Number(runs(1, idle)).EqualTo(1))
+	assert.Is(hammy.Number(interval()).EqualTo(10))
+}
+
+func TestAdaptiveThrottleDisabled(t *testing.T) {
+	assert := hammy.New(t)
+	throttle := newAdaptiveThrottle(0, 4, time.Second)
+	throttle.update([]Device{&utilizationDevice{rates: nvml.Utilization{Gpu: 100}}}, discardLogger())
+	assert.Is(hammy.True(throttle.due(heavyNVLinkFec)))
+}
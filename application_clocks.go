@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	applicationsClock = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "applications_clock_mhz",
+			Help:      "Configured application clock (MHz) per clock domain.",
+		},
+		[]string{"UUID", "pci_bus_id", "clock"},
+	)
+
+	defaultApplicationsClock = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "default_applications_clock_mhz",
+			Help:      "Default application clock (MHz) per clock domain.",
+		},
+		[]string{"UUID", "pci_bus_id", "clock"},
+	)
+
+	autoBoostEnabled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "auto_boost_enabled",
+			Help:      "Whether auto boosted clocks are currently enabled (1 = enabled, 0 = disabled).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	autoBoostDefaultEnabled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "auto_boost_default_enabled",
+			Help:      "Whether auto boosted clocks are enabled by default (1 = enabled, 0 = disabled).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	clockOffset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clock_offset_mhz",
+			Help:      "Clock offset (MHz) applied to the voltage/frequency curve per clock domain (gpc, memory).",
+		},
+		[]string{"UUID", "pci_bus_id", "clock"},
+	)
+
+	clocksNonDefault = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clocks_non_default",
+			Help:      "Whether clock settings deviate from stock: non-zero clock offsets, application clocks or auto boost differing from their defaults (1 = modified).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	applicationClockTypes = []struct {
+		clockType nvml.ClockType
+		name      string
+	}{
+		{nvml.CLOCK_GRAPHICS, "graphics"},
+		{nvml.CLOCK_SM, "sm"},
+		{nvml.CLOCK_MEM, "memory"},
+		{nvml.CLOCK_VIDEO, "video"},
+	}
+)
+
+// applicationClockReadings holds the applications clocks (MHz) read in a
+// collection round, by UUID and clock name.
+type applicationClockReadings map[string]map[string]uint32
+
+// collectApplicationClocks collects configured/default application clocks, clock
+// offsets and auto boost policy for all devices, and flags devices whose clock
+// settings deviate from stock. It returns the applications clocks it read.
+func collectApplicationClocks(devices []Device, logger *slog.Logger) applicationClockReadings {
+	readings := make(applicationClockReadings)
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		// checked is set once any setting could be compared with its default
+		var checked, modified bool
+
+		for _, clock := range applicationClockTypes {
+			mhz, ret := device.GetApplicationsClock(clock.clockType)
+			current := errors.Is(ret, nvml.SUCCESS)
+			if current {
+				applicationsClock.WithLabelValues(uuid, pciBusId, clock.name).Set(float64(mhz))
+				if readings[uuid] == nil {
+					readings[uuid] = make(map[string]uint32)
+				}
+				readings[uuid][clock.name] = mhz
+			} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get applications clock", "clock", clock.name, "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+
+			defaultMhz, ret := device.GetDefaultApplicationsClock(clock.clockType)
+			if errors.Is(ret, nvml.SUCCESS) {
+				defaultApplicationsClock.WithLabelValues(uuid, pciBusId, clock.name).Set(float64(defaultMhz))
+				if current {
+					checked = true
+					modified = modified || mhz != defaultMhz
+				}
+			} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get default applications clock", "clock", clock.name, "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+		}
+
+		// VF offsets are only supported on GPUs that allow overclocking
+		for _, offset := range []struct {
+			name string
+			get  func() (int, nvml.Return)
+		}{
+			{"gpc", device.GetGpcClkVfOffset},
+			{"memory", device.GetMemClkVfOffset},
+		} {
+			mhz, ret := offset.get()
+			if errors.Is(ret, nvml.SUCCESS) {
+				clockOffset.WithLabelValues(uuid, pciBusId, offset.name).Set(float64(mhz))
+				checked = true
+				modified = modified || mhz != 0
+			} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get clock offset", "clock", offset.name, "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+		}
+
+		// Auto boost is not supported on most datacenter GPUs
+		enabled, defaultEnabled, ret := device.GetAutoBoostedClocksEnabled()
+		if errors.Is(ret, nvml.SUCCESS) {
+			autoBoostEnabled.WithLabelValues(uuid, pciBusId).Set(flagToGauge(enabled == nvml.FEATURE_ENABLED))
+			autoBoostDefaultEnabled.WithLabelValues(uuid, pciBusId).Set(flagToGauge(defaultEnabled == nvml.FEATURE_ENABLED))
+			checked = true
+			modified = modified || enabled != defaultEnabled
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get auto boost state", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		if checked {
+			clocksNonDefault.WithLabelValues(uuid, pciBusId).Set(flagToGauge(modified))
+		}
+	}
+	return readings
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type clockDevice struct {
+	fakeDevice
+	appMhz     uint32
+	defaultMhz uint32
+	clocksRet  nvml.Return
+	gpcOffset  int
+	offsetRet  nvml.Return
+}
+
+func (d *clockDevice) GetApplicationsClock(nvml.ClockType) (uint32, nvml.Return) {
+	return d.appMhz, d.clocksRet
+}
+
+func (d *clockDevice) GetDefaultApplicationsClock(nvml.ClockType) (uint32, nvml.Return) {
+	return d.defaultMhz, d.clocksRet
+}
+
+func (d *clockDevice) GetGpcClkVfOffset() (int, nvml.Return) {
+	return d.gpcOffset, d.offsetRet
+}
+
+func (d *clockDevice) GetMemClkVfOffset() (int, nvml.Return) {
+	return 0, d.offsetRet
+}
+
+func (d *clockDevice) GetAutoBoostedClocksEnabled() (nvml.EnableState, nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+// appClockDevice reports application clocks per clock domain and its auto
+// boost policy.
+type appClockDevice struct {
+	fakeDevice
+	app, defaults map[nvml.ClockType]uint32
+	boost         nvml.EnableState
+	boostRet      nvml.Return
+}
+
+func (d *appClockDevice) GetApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return) {
+	mhz, ok := d.app[clockType]
+	if !ok {
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+	return mhz, nvml.SUCCESS
+}
+
+func (d *appClockDevice) GetDefaultApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return) {
+	mhz, ok := d.defaults[clockType]
+	if !ok {
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+	return mhz, nvml.SUCCESS
+}
+
+func (d *appClockDevice) GetGpcClkVfOffset() (int, nvml.Return) {
+	return 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *appClockDevice) GetMemClkVfOffset() (int, nvml.Return) {
+	return 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *appClockDevice) GetAutoBoostedClocksEnabled() (nvml.EnableState, nvml.EnableState, nvml.Return) {
+	return d.boost, nvml.FEATURE_ENABLED, d.boostRet
+}
+
+func TestCollectApplicationClocks(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		applicationsClock.Reset()
+		defaultApplicationsClock.Reset()
+		autoBoostEnabled.Reset()
+		autoBoostDefaultEnabled.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	devices := []Device{
+		&appClockDevice{
+			fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+			app:        map[nvml.ClockType]uint32{nvml.CLOCK_GRAPHICS: 1410, nvml.CLOCK_SM: 1410, nvml.CLOCK_MEM: 1593, nvml.CLOCK_VIDEO: 1275},
+			defaults:   map[nvml.ClockType]uint32{nvml.CLOCK_GRAPHICS: 1755, nvml.CLOCK_SM: 1755, nvml.CLOCK_MEM: 1593, nvml.CLOCK_VIDEO: 1275},
+			boost:      nvml.FEATURE_DISABLED,
+			boostRet:   nvml.SUCCESS,
+		},
+		// Neither application clocks nor auto boost are supported
+		&appClockDevice{fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:2A:00.0"}, boostRet: nvml.ERROR_NOT_SUPPORTED},
+	}
+	readings := collectApplicationClocks(devices, discardLogger())
+
+	clock := func(vec *prometheus.GaugeVec, name string) float64 {
+		return testutil.ToFloat64(vec.WithLabelValues("GPU-0", "0000:18:00.0", name))
+	}
+	assert.Is(hammy.Number(testutil.CollectAndCount(applicationsClock)).EqualTo(4))
+	assert.Is(hammy.Number(testutil.CollectAndCount(defaultApplicationsClock)).EqualTo(4))
+	assert.Is(hammy.Number(clock(applicationsClock, "graphics")).EqualTo(1410))
+	assert.Is(hammy.Number(clock(applicationsClock, "memory")).EqualTo(1593))
+	assert.Is(hammy.Number(clock(defaultApplicationsClock, "sm")).EqualTo(1755))
+	assert.Is(hammy.Number(clock(defaultApplicationsClock, "video")).EqualTo(1275))
+	assert.Is(hammy.Number(readings["GPU-0"]["sm"]).EqualTo(1410))
+	assert.Is(hammy.Number(len(readings)).EqualTo(1))
+
+	assert.Is(hammy.Number(testutil.CollectAndCount(autoBoostEnabled)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(autoBoostEnabled.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(autoBoostDefaultEnabled.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1))
+}
+
+func TestCollectApplicationClocksNonDefault(t *testing.T) {
+	tests := []struct {
+		name       string
+		device     *clockDevice
+		wantCount  int
+		wantFlag   float64
+		wantOffset float64
+	}{
+		{
+			name:      "stock",
+			device:    &clockDevice{appMhz: 1755, defaultMhz: 1755, clocksRet: nvml.SUCCESS, offsetRet: nvml.SUCCESS},
+			wantCount: 1,
+			wantFlag:  0,
+		},
+		{
+			name:       "gpc offset",
+			device:     &clockDevice{appMhz: 1755, defaultMhz: 1755, clocksRet: nvml.SUCCESS, gpcOffset: 150, offsetRet: nvml.SUCCESS},
+			wantCount:  1,
+			wantFlag:   1,
+			wantOffset: 150,
+		},
+		{
+			name:      "application clocks lowered",
+			device:    &clockDevice{appMhz: 1410, defaultMhz: 1755, clocksRet: nvml.SUCCESS, offsetRet: nvml.ERROR_NOT_SUPPORTED},
+			wantCount: 1,
+			wantFlag:  1,
+		},
+		{
+			name:      "nothing comparable",
+			device:    &clockDevice{clocksRet: nvml.ERROR_NOT_SUPPORTED, offsetRet: nvml.ERROR_NOT_SUPPORTED},
+			wantCount: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			reset := func() {
+				applicationsClock.Reset()
+				defaultApplicationsClock.Reset()
+				clockOffset.Reset()
+				clocksNonDefault.Reset()
+			}
+			reset()
+			t.Cleanup(reset)
+
+			tc.device.fakeDevice = fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}
+			collectApplicationClocks([]Device{tc.device}, discardLogger())
+
+			assert.Is(hammy.Number(testutil.CollectAndCount(clocksNonDefault)).EqualTo(tc.wantCount))
+			if tc.wantCount == 0 {
+				return
+			}
+			assert.Is(hammy.Number(testutil.ToFloat64(clocksNonDefault.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(tc.wantFlag))
+			if tc.device.offsetRet == nvml.SUCCESS {
+				assert.Is(hammy.Number(testutil.ToFloat64(clockOffset.WithLabelValues("GPU-0", "0000:18:00.0", "gpc"))).EqualTo(tc.wantOffset))
+			}
+		})
+	}
+}
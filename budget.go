@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	seriesCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_series_count",
+			Help:      "Number of device series the last /metrics request gathered, before -max-series was applied.",
+		},
+	)
+
+	seriesDropped = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_series_dropped",
+			Help:      "Number of device series the last /metrics request left out to stay within -max-series.",
+		},
+	)
+
+	memoryLimit = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_memory_limit_bytes",
+			Help:      "Soft memory limit of the exporter from -memory-limit or GOMEMLIMIT; 9.223372036854776e+18 when unlimited.",
+		},
+		func() float64 { return float64(debug.SetMemoryLimit(-1)) },
+	)
+)
+
+// applyMemoryLimit sets the soft memory limit of -memory-limit. The garbage
+// collector then runs more often as the heap approaches the limit, keeping
+// the exporter within a pod's memory budget instead of letting the heap grow
+// to twice the live data. Without the flag, GOMEMLIMIT applies as usual.
+func applyMemoryLimit(limit byteSize, logger *slog.Logger) {
+	if limit <= 0 {
+		return
+	}
+	debug.SetMemoryLimit(int64(limit))
+	logger.Info("set soft memory limit", "limit", limit.String())
+}
+
+// seriesCapGatherer bounds the cardinality of /metrics for node agents with
+// strict resource budgets. Once the gathered families hold more than max
+// series, whole families are left out, largest first, until the rest fits;
+// the /metrics-lite families are always kept. Partial families would
+// silently skew aggregations, while a missing family is obvious. A max of 0
+// only counts the series.
+type seriesCapGatherer struct {
+	gatherer  prometheus.Gatherer
+	max       int
+	protected map[string]bool
+	logger    *slog.Logger
+
+	mu sync.Mutex
+	// dropped holds the families left out by the last request, to log changes
+	dropped []string
+}
+
+func newSeriesCapGatherer(gatherer prometheus.Gatherer, max int, logger *slog.Logger) *seriesCapGatherer {
+	return &seriesCapGatherer{gatherer: gatherer, max: max, protected: liteMetricNames(), logger: logger}
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *seriesCapGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	total := 0
+	for _, mf := range families {
+		total += len(mf.GetMetric())
+	}
+	seriesCount.Set(float64(total))
+	if g.max <= 0 {
+		return families, err
+	}
+
+	candidates := make([]*dto.MetricFamily, 0, len(families))
+	for _, mf := range families {
+		if !g.protected[mf.GetName()] {
+			candidates = append(candidates, mf)
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b *dto.MetricFamily) int {
+		return len(b.GetMetric()) - len(a.GetMetric())
+	})
+
+	drop := make(map[string]bool)
+	served := total
+	for _, mf := range candidates {
+		if served <= g.max {
+			break
+		}
+		drop[mf.GetName()] = true
+		served -= len(mf.GetMetric())
+	}
+	seriesDropped.Set(float64(total - served))
+
+	kept := make([]*dto.MetricFamily, 0, len(families))
+	var dropped []string
+	for _, mf := range families {
+		if drop[mf.GetName()] {
+			dropped = append(dropped, mf.GetName())
+			continue
+		}
+		kept = append(kept, mf)
+	}
+	g.logChanges(dropped, total)
+	return kept, err
+}
+
+// logChanges warns when the set of left out families changes.
+func (g *seriesCapGatherer) logChanges(dropped []string, total int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if slices.Equal(dropped, g.dropped) {
+		return
+	}
+	g.dropped = dropped
+	if len(dropped) == 0 {
+		g.logger.Info("device series back within -max-series", "series", total, "max_series", g.max)
+		return
+	}
+	g.logger.Warn("device series exceed -max-series, leaving out metric families", "series", total, "max_series", g.max, "families", dropped)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSeriesCapGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	big := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "big"}, []string{"i"})
+	small := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "small"}, []string{"i"})
+	for _, i := range []string{"0", "1", "2", "3"} {
+		big.WithLabelValues(i).Set(1)
+		xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", i).Inc()
+	}
+	small.WithLabelValues("0").Set(1)
+	reg.MustRegister(big, small, xidErrors)
+	t.Cleanup(xidErrors.Reset)
+
+	tests := []struct {
+		name     string
+		max      int
+		families int
+		dropped  float64
+	}{
+		{name: "unlimited", max: 0, families: 3, dropped: 0},
+		{name: "fits", max: 9, families: 3, dropped: 0},
+		{name: "largest dropped first", max: 8, families: 2, dropped: 4},
+		{name: "lite families kept", max: 1, families: 1, dropped: 5},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			families, err := newSeriesCapGatherer(reg, tc.max, discardLogger()).Gather()
+
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.Number(len(families)).EqualTo(tc.families))
+			assert.Is(hammy.Number(testutil.ToFloat64(seriesCount)).EqualTo(9))
+			if tc.max > 0 {
+				assert.Is(hammy.Number(testutil.ToFloat64(seriesDropped)).EqualTo(tc.dropped))
+			}
+		})
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected byteSize
+		str      string
+	}{
+		{value: "0", expected: 0, str: "0"},
+		{value: "1000", expected: 1000, str: "1000B"},
+		{value: "128MiB", expected: 128 << 20, str: "128MiB"},
+		{value: "2GiB", expected: 2 << 30, str: "2GiB"},
+		{value: "64KiB", expected: 64 << 10, str: "64KiB"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			assert := hammy.New(t)
+			var b byteSize
+			assert.Is(hammy.NilError(b.Set(tc.value)))
+			assert.Is(hammy.Number(b).EqualTo(tc.expected))
+			assert.Is(hammy.String(b.String()).EqualTo(tc.str))
+		})
+	}
+
+	var b byteSize
+	hammy.New(t).Is(hammy.Error(b.Set("12MB")))
+}
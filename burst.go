@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons of a collection burst.
+const (
+	burstCriticalXid     = "critical_xid"
+	burstFabricUnhealthy = "fabric_unhealthy"
+)
+
+var collectionBursts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exporter_collection_bursts_total",
+		Help:      "Number of out-of-cycle collections triggered by a critical Xid or a GPU turning fabric unhealthy, by reason.",
+	},
+	[]string{"reason"},
+)
+
+// burstEvent is the GPU and reason that triggered a burst.
+type burstEvent struct {
+	uuid   string
+	reason string
+}
+
+// collectionBurst collects out of cycle when a GPU fails, and then every
+// interval for duration, so that the metrics around a failure have a finer
+// resolution than -collection-interval. A nil *collectionBurst never triggers.
+type collectionBurst struct {
+	interval time.Duration
+	duration time.Duration
+	now      func() time.Time
+	// events holds at most one pending trigger; later ones only extend the
+	// burst
+	events chan burstEvent
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+// newCollectionBurst returns nil when interval is 0.
+func newCollectionBurst(interval, duration time.Duration) *collectionBurst {
+	if interval <= 0 {
+		return nil
+	}
+	return &collectionBurst{interval: interval, duration: duration, now: time.Now, events: make(chan burstEvent, 1)}
+}
+
+// trigger starts or extends the burst and requests an immediate collection.
+// It never blocks, so it is safe to call from the collectors and the Xid
+// event loops.
+func (b *collectionBurst) trigger(uuid, reason string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.until = b.now().Add(b.duration)
+	b.mu.Unlock()
+	collectionBursts.WithLabelValues(reason).Inc()
+
+	select {
+	case b.events <- burstEvent{uuid: uuid, reason: reason}:
+	default:
+	}
+}
+
+// triggered returns the channel of pending triggers, nil (blocking forever)
+// for a nil *collectionBurst.
+func (b *collectionBurst) triggered() <-chan burstEvent {
+	if b == nil {
+		return nil
+	}
+	return b.events
+}
+
+// wake returns when the next round is due: the scheduled round due, or sooner
+// while a burst lasts.
+func (b *collectionBurst) wake(due, now time.Time) time.Time {
+	if b == nil {
+		return due
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !now.Before(b.until) {
+		return due
+	}
+	if next := now.Add(b.interval); next.Before(due) {
+		return next
+	}
+	return due
+}
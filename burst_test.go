@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectionBurstWake(t *testing.T) {
+	assert := hammy.New(t)
+	collectionBursts.Reset()
+	t.Cleanup(collectionBursts.Reset)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	due := now.Add(60 * time.Second)
+	burst := newCollectionBurst(5*time.Second, time.Minute)
+	burst.now = func() time.Time { return now }
+
+	// No burst: the scheduled round
+	assert.Is(hammy.True(burst.wake(due, now).Equal(due)))
+
+	burst.trigger("GPU-0", burstCriticalXid)
+	burst.trigger("GPU-1", burstFabricUnhealthy)
+	assert.Is(hammy.True(burst.wake(due, now).Equal(now.Add(5 * time.Second))))
+	// A scheduled round sooner than the burst interval keeps its place
+	assert.Is(hammy.True(burst.wake(now.Add(time.Second), now).Equal(now.Add(time.Second))))
+	// The burst ends after its duration
+	assert.Is(hammy.True(burst.wake(due, now.Add(time.Minute)).Equal(due)))
+
+	// Only one trigger is pending, the second one extended the burst
+	event := <-burst.triggered()
+	assert.Is(hammy.String(event.uuid).EqualTo("GPU-0"))
+	assert.Is(hammy.Number(len(burst.triggered())).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(collectionBursts.WithLabelValues(burstFabricUnhealthy))).EqualTo(1))
+
+	// A nil burst never triggers
+	var disabled *collectionBurst
+	disabled.trigger("GPU-0", burstCriticalXid)
+	assert.Is(hammy.True(disabled.triggered() == nil))
+	assert.Is(hammy.True(disabled.wake(due, now).Equal(due)))
+}
+
+func TestGpuHealthTrackerTriggersBurst(t *testing.T) {
+	assert := hammy.New(t)
+	collectionBursts.Reset()
+	t.Cleanup(collectionBursts.Reset)
+
+	tracker := newGpuHealthTracker([]*GpuInfo{{UUID: "GPU-0"}}, nil, []uint64{79}, time.Hour)
+	tracker.burst = newCollectionBurst(5*time.Second, time.Minute)
+	bursts := func(reason string) float64 {
+		return testutil.ToFloat64(collectionBursts.WithLabelValues(reason))
+	}
+
+	tracker.reportXid("GPU-0", 13)
+	assert.Is(hammy.Number(bursts(burstCriticalXid)).EqualTo(0))
+	tracker.reportXid("GPU-0", 79)
+	assert.Is(hammy.Number(bursts(burstCriticalXid)).EqualTo(1))
+
+	// Only the transition to unhealthy triggers
+	tracker.reportFabricHealth("GPU-0", nvml.GPU_FABRIC_HEALTH_SUMMARY_HEALTHY)
+	tracker.reportFabricHealth("GPU-0", nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY)
+	tracker.reportFabricHealth("GPU-0", nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY)
+	assert.Is(hammy.Number(bursts(burstFabricUnhealthy)).EqualTo(1))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var collectorPanics = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exporter_collector_panics_total",
+		Help:      "Number of panics recovered from a collector.",
+	},
+	[]string{"collector"},
+)
+
+var collectorLastSuccess = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_last_collection_timestamp_seconds",
+		Help:      "Unix time at which a collector last completed without panicking.",
+	},
+	[]string{"collector"},
+)
+
+var collectorGoroutines = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_goroutines",
+		Help:      "Number of goroutines running per collector: the collection loop, the Xid event loops, Kubernetes node labeling and events, and health watch hooks.",
+	},
+	[]string{"collector"},
+)
+
+// goCollector runs run in a goroutine counted against name in
+// nvgpu_exporter_goroutines, so that a collector that leaks goroutines shows
+// up before the exporter runs out of memory.
+func goCollector(name string, run func()) {
+	goroutines := collectorGoroutines.WithLabelValues(name)
+	goroutines.Inc()
+	go func() {
+		defer goroutines.Dec()
+		run()
+	}()
+}
+
+// namedCollector is one periodic collection step, isolated from the others so
+// that a failure in one does not stop the rest.
+type namedCollector struct {
+	name    string
+	collect func()
+}
+
+// runCollectors runs every collector in order, recovering from panics. The
+// rounds are reported to tracker, which may be nil.
+func runCollectors(collectors []namedCollector, tracker *deviceCollectionTracker, logger *slog.Logger) {
+	for _, c := range collectors {
+		tracker.begin(c.name)
+		start := time.Now()
+		runCollector(c.name, c.collect, logger)
+		collectionDuration.WithLabelValues(c.name).Observe(time.Since(start).Seconds())
+		tracker.end()
+	}
+}
+
+// runCollector runs collect and recovers from any panic, logging the stack and
+// counting it against name instead of crashing the exporter. The completion
+// time is recorded so that a collector stuck inside an NVML call can be
+// detected while the HTTP endpoint keeps serving stale values.
+func runCollector(name string, collect func(), logger *slog.Logger) {
+	defer func() {
+		if r := recover(); r != nil {
+			collectorPanics.WithLabelValues(name).Inc()
+			logger.Error("collector panicked", "collector", name, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	collect()
+	collectorLastSuccess.WithLabelValues(name).SetToCurrentTime()
+}
+
+// collectionSchedule places the periodic collection rounds in time. Rounds are
+// shifted by a random offset chosen once at startup, so that many exporters
+// spread their NVML (and fabric manager) load while each keeps a stable
+// interval.
+type collectionSchedule struct {
+	interval time.Duration
+	// align places rounds on wall-clock multiples of interval
+	align  bool
+	offset time.Duration
+}
+
+func newCollectionSchedule(interval time.Duration, align bool, jitter time.Duration) collectionSchedule {
+	s := collectionSchedule{interval: interval, align: align}
+	if jitter > 0 {
+		s.offset = rand.N(jitter)
+	}
+	return s
+}
+
+// first returns when the round following the one run at startup is due.
+func (s collectionSchedule) first(start time.Time) time.Time {
+	return s.next(start.Add(s.offset), start)
+}
+
+// next returns when the round after the one scheduled at prev is due. Rounds
+// missed because a collection overran are skipped rather than run back to back.
+func (s collectionSchedule) next(prev, now time.Time) time.Time {
+	if s.align {
+		return now.Add(-s.offset).Truncate(s.interval).Add(s.interval + s.offset)
+	}
+	next := prev.Add(s.interval)
+	if next.Before(now) {
+		return now
+	}
+	return next
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunCollectorsRecoversFromPanics(t *testing.T) {
+	assert := hammy.New(t)
+	collectorPanics.Reset()
+	collectorLastSuccess.Reset()
+	t.Cleanup(collectorPanics.Reset)
+	t.Cleanup(collectorLastSuccess.Reset)
+
+	var ran []string
+	collectors := []namedCollector{
+		{"first", func() { ran = append(ran, "first") }},
+		{"faulty", func() {
+			var values []float64
+			_ = values[3]
+		}},
+		{"last", func() { ran = append(ran, "last") }},
+	}
+
+	runCollectors(collectors, nil, discardLogger())
+	runCollectors(collectors, nil, discardLogger())
+
+	assert.Is(hammy.Number(len(ran)).EqualTo(4))
+	assert.Is(hammy.String(ran[1]).EqualTo("last"))
+	assert.Is(hammy.Number(testutil.ToFloat64(collectorPanics.WithLabelValues("faulty"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.CollectAndCount(collectorPanics)).EqualTo(1))
+
+	// Only collectors that completed have a last collection timestamp
+	assert.Is(hammy.False(collectorLastSuccess.DeleteLabelValues("faulty")))
+	assert.Is(hammy.True(testutil.ToFloat64(collectorLastSuccess.WithLabelValues("last")) > 0))
+}
+
+func TestGoCollectorCountsGoroutines(t *testing.T) {
+	assert := hammy.New(t)
+	collectorGoroutines.Reset()
+	t.Cleanup(collectorGoroutines.Reset)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	goCollector("hook", func() { <-release })
+	goCollector("hook", func() {
+		<-release
+		close(done)
+	})
+	assert.Is(hammy.Number(testutil.ToFloat64(collectorGoroutines.WithLabelValues("hook"))).EqualTo(2))
+
+	close(release)
+	<-done
+	for testutil.ToFloat64(collectorGoroutines.WithLabelValues("hook")) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCollectionScheduleNext(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 17, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule collectionSchedule
+		prev     time.Time
+		now      time.Time
+		want     time.Time
+	}{
+		{
+			name:     "interval after the previous round",
+			schedule: collectionSchedule{interval: time.Minute},
+			prev:     start,
+			now:      start.Add(2 * time.Second),
+			want:     start.Add(time.Minute),
+		},
+		{
+			name:     "overrun skips missed rounds",
+			schedule: collectionSchedule{interval: time.Minute},
+			prev:     start,
+			now:      start.Add(90 * time.Second),
+			want:     start.Add(90 * time.Second),
+		},
+		{
+			name:     "aligned to the next minute",
+			schedule: collectionSchedule{interval: time.Minute, align: true},
+			prev:     start,
+			now:      start.Add(2 * time.Second),
+			want:     time.Date(2025, 1, 1, 12, 1, 0, 0, time.UTC),
+		},
+		{
+			name:     "aligned with offset",
+			schedule: collectionSchedule{interval: time.Minute, align: true, offset: 5 * time.Second},
+			prev:     start,
+			now:      start.Add(2 * time.Second),
+			want:     time.Date(2025, 1, 1, 12, 1, 5, 0, time.UTC),
+		},
+		{
+			name:     "aligned offset still ahead in this minute",
+			schedule: collectionSchedule{interval: time.Minute, align: true, offset: 30 * time.Second},
+			prev:     start,
+			now:      start.Add(2 * time.Second),
+			want:     time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			got := tc.schedule.next(tc.prev, tc.now)
+			assert.Is(hammy.True(got.Equal(tc.want)))
+		})
+	}
+}
+
+func TestCollectionScheduleJitter(t *testing.T) {
+	assert := hammy.New(t)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		s := newCollectionSchedule(time.Minute, false, 10*time.Second)
+		assert.Is(hammy.True(s.offset >= 0 && s.offset < 10*time.Second))
+		assert.Is(hammy.True(s.first(start).Equal(start.Add(time.Minute + s.offset))))
+	}
+	assert.Is(hammy.Number(newCollectionSchedule(time.Minute, false, 0).offset).EqualTo(0))
+}
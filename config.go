@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCriticalXids are the Xids that indicate a GPU needs to be drained:
+// uncorrectable ECC, NVLink and GSP failures and the GPU falling off the bus.
+var defaultCriticalXids = []uint64{48, 74, 79, 94, 95, 119, 120, 140}
+
+// Config holds the runtime options parsed from the command line.
+type Config struct {
+	Addr               string
+	InternalAddr       string
+	InternalPath       string
+	MaxRequests        int
+	MaxSeries          int
+	MaxHeaderSize      byteSize
+	Pprof              bool
+	MemoryLimit        byteSize
+	GoMetrics          bool
+	ProcessMetrics     bool
+	ScrapeTimeout      time.Duration
+	MetricsCache       bool
+	MetricsTimestamps  bool
+	AccessLog          bool
+	CollectionInterval time.Duration
+	CollectionAlign    bool
+	CollectionJitter   time.Duration
+	// Collect every BurstInterval for BurstDuration after a GPU fails
+	BurstInterval time.Duration
+	BurstDuration time.Duration
+	// Heavy collection work runs every AdaptiveSlowdown rounds while a GPU is
+	// busier than AdaptiveUtilizationThreshold
+	AdaptiveUtilizationThreshold float64
+	AdaptiveSlowdown             int
+	NVLinkLegacyBER              bool
+	NVLinkFecHistogram           bool
+	NVLinkUtilization            bool
+	MPSClients                   bool
+	ExportDeltas                 bool
+	NativeHistograms             bool
+	// NVLink BER thresholds of nvgpu_nvlink_ber_threshold_exceeded
+	NVLinkEffectiveBERThreshold float64
+	NVLinkSymbolBERThreshold    float64
+	NVLinkHistory               time.Duration
+	NVLinkHistoryFile           string
+	Grace                       bool
+	ProcPath                    string
+	FabricManagerAddr           string
+	ContainerRuntimeRoot        string
+	SysPath                     string
+	PCIeTopology                bool
+	Probe                       bool
+	ProbeOnly                   bool
+	ProbeTimeout                time.Duration
+	ProbeTargets                stringList
+	RackTargets                 stringList
+	RackCliqueSize              int
+	K8sNodeLabels               bool
+	K8sNodeName                 string
+	K8sNodeEvents               bool
+	K8sEventInterval            time.Duration
+	CriticalXids                xidList
+	HealthXidWindow             time.Duration
+	NVML                        string
+	NVMLLibrary                 string
+	NVMLRetryInterval           time.Duration
+	Simulate                    string
+	RespectVisibility           bool
+	XidWaitTimeout              time.Duration
+	XidEventShards              int
+	XidExemplar                 string
+	RedactAssetLabels           redactMode
+	LocationLabels              stringList
+	TopologyGpuID               topologyIDMode
+	ClockEventReasons           clockEventReasonList
+	ExpectedProfiles            string
+	CustomFields                string
+	HealthWatches               string
+	HealthWatchHook             string
+	InstanceLock                string
+	InstanceID                  string
+	DebugDumpFields             fieldDumpList
+}
+
+// registerFlags binds every Config option to a command line flag on fs.
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", ":9400", "HTTP server address")
+	fs.StringVar(&c.InternalAddr, "internal-metrics-addr", "", "Serve exporter-internal metrics (Go runtime, process, self-telemetry) separately on this address; empty serves them on -addr /metrics alongside device metrics")
+	fs.StringVar(&c.InternalPath, "internal-metrics-path", "/metrics", "HTTP path for exporter-internal metrics when -internal-metrics-addr is set")
+	fs.IntVar(&c.MaxRequests, "web.max-requests", 40, "Maximum number of concurrent /metrics requests; further requests get 503 Service Unavailable. 0 means no limit")
+	c.MaxHeaderSize = http.DefaultMaxHeaderBytes
+	fs.Var(&c.MaxHeaderSize, "http-max-header-size", "Largest HTTP request header accepted (e.g. 64KiB); larger requests get 431 Request Header Fields Too Large")
+	fs.BoolVar(&c.Pprof, "pprof", false, "Serve the Go profiler under /debug/pprof/ on -addr")
+	fs.DurationVar(&c.ScrapeTimeout, "scrape-timeout", 0, "Answer /metrics requests that take longer than this with 503 Service Unavailable; 0 means no timeout")
+	fs.BoolVar(&c.MetricsCache, "metrics-cache", false, "Serve device metrics gathered once per collection round instead of on every scrape; Xid counters then update with the next round")
+	fs.BoolVar(&c.MetricsTimestamps, "metrics-timestamps", false, "Attach the completion time of the last collection round to every device metric as its sample timestamp")
+	fs.BoolVar(&c.AccessLog, "access-log", false, "Log every metrics request with the remote address, user agent, status and duration")
+	fs.DurationVar(&c.CollectionInterval, "collection-interval", 60*time.Second, "Interval for collecting GPU fabric health metrics")
+	fs.BoolVar(&c.CollectionAlign, "collection-align", false, "Align collection rounds to wall-clock multiples of -collection-interval (e.g. the start of every minute)")
+	fs.Float64Var(&c.AdaptiveUtilizationThreshold, "adaptive-utilization-threshold", 0, "GPU utilization (0-1) above which heavy collection work (topology, NVLink FEC history) only runs every -adaptive-slowdown collections; 0 disables")
+	fs.IntVar(&c.AdaptiveSlowdown, "adaptive-slowdown", 4, "Factor by which -adaptive-utilization-threshold lengthens the interval of heavy collection work")
+	fs.IntVar(&c.MaxSeries, "max-series", 0, "Leave the largest device metric families out of /metrics once it would serve more than this many series, keeping the /metrics-lite families; 0 disables")
+	fs.BoolVar(&c.GoMetrics, "go-metrics", true, "Export the Go runtime metrics of the exporter (go_*: goroutines, heap, GC) with the internal metrics")
+	fs.BoolVar(&c.ProcessMetrics, "process-metrics", true, "Export the process metrics of the exporter (process_*: open file descriptors, resident memory, CPU time) with the internal metrics")
+	fs.Var(&c.MemoryLimit, "memory-limit", "Soft memory limit of the exporter (e.g. 128MiB), past which the Go garbage collector works harder; overrides GOMEMLIMIT, 0 keeps it")
+	fs.DurationVar(&c.CollectionJitter, "collection-jitter", 0, "Shift collection rounds by a random offset below this duration, chosen once at startup, so that exporters across a rack do not query NVML and the fabric manager at the same instant")
+	fs.DurationVar(&c.BurstInterval, "burst-interval", 0, "Collect immediately when a critical Xid fires or a GPU turns fabric unhealthy, then at this interval for -burst-duration; 0 disables")
+	fs.DurationVar(&c.BurstDuration, "burst-duration", 5*time.Minute, "How long collections run at -burst-interval after a failure")
+	fs.BoolVar(&c.NVLinkLegacyBER, "nvlink-legacy-ber", true, "Also emit BER values under nvgpu_nvlink_errors_total for backwards compatibility")
+	fs.Float64Var(&c.NVLinkEffectiveBERThreshold, "nvlink-effective-ber-threshold", 1e-12, "Effective (post-FEC) NVLink BER above which nvgpu_nvlink_ber_threshold_exceeded is 1")
+	fs.Float64Var(&c.NVLinkSymbolBERThreshold, "nvlink-symbol-ber-threshold", 1e-6, "Symbol (pre-FEC) NVLink BER above which nvgpu_nvlink_ber_threshold_exceeded is 1")
+	c.CriticalXids = append(xidList(nil), defaultCriticalXids...)
+	fs.Var(&c.CriticalXids, "critical-xids", "Comma separated Xids that mark a GPU as failed in nvgpu_gpu_health_summary and, with -k8s-node-labels, label the node")
+	fs.DurationVar(&c.HealthXidWindow, "health-xid-window", 24*time.Hour, "How long a critical Xid keeps nvgpu_gpu_health_summary at failed")
+	fs.StringVar(&c.HealthWatches, "health-watches", "", "JSON file with health watch conditions (Xids, ECC double bit errors, temperature, NVLink down) evaluated on every collection; violations are counted in nvgpu_health_watch_violations_total")
+	fs.StringVar(&c.HealthWatchHook, "health-watch-hook", "", "Executable run with the watch name and GPU UUID as arguments on every health watch violation")
+	fs.BoolVar(&c.K8sNodeLabels, "k8s-node-labels", false, "Label the Kubernetes node when GPU fabric health or critical Xids indicate a bad GPU (requires in-cluster service account)")
+	fs.StringVar(&c.K8sNodeName, "k8s-node-name", os.Getenv("NODE_NAME"), "Kubernetes node name to label or post events to (defaults to $NODE_NAME)")
+	fs.BoolVar(&c.K8sNodeEvents, "k8s-node-events", false, "Post Kubernetes events to the node for critical Xids and fabric health transitions (requires in-cluster service account)")
+	fs.DurationVar(&c.K8sEventInterval, "k8s-event-interval", 10*time.Minute, "Minimum time between two -k8s-node-events events with the same reason for the same GPU")
+	fs.Var(&c.CriticalXids, "k8s-critical-xids", "Deprecated alias of -critical-xids")
+	fs.BoolVar(&c.Probe, "probe", false, "Enable /probe?target=<host:port> which scrapes a remote nvgpu-exporter agent")
+	fs.BoolVar(&c.ProbeOnly, "probe-only", false, "Run only the /probe endpoint without initializing NVML (central deployment without GPUs)")
+	fs.DurationVar(&c.ProbeTimeout, "probe-timeout", 10*time.Second, "Timeout for scraping a /probe target")
+	fs.Var(&c.ProbeTargets, "probe-targets", "Comma separated exporters (host:port or URL) /probe may scrape, in addition to -rack-targets; /probe refuses any other target")
+	fs.Var(&c.RackTargets, "rack-targets", "Comma separated exporters (host:port or URL) of the nodes in a rack; enables /rack, which scrapes them and exports rack-level fabric health")
+	fs.IntVar(&c.RackCliqueSize, "rack-clique-size", 72, "Number of GPUs in a complete NVLink clique, used by /rack to count incomplete cliques")
+	fs.DurationVar(&c.XidWaitTimeout, "xid-wait-timeout", 5*time.Second, "How long each Xid event loop blocks in NVML waiting for events")
+	fs.IntVar(&c.XidEventShards, "xid-event-shards", 1, "Spread GPUs over this many NVML event sets, each with its own goroutine, so a burst of Xids on one GPU does not delay the others")
+	fs.StringVar(&c.XidExemplar, "xid-exemplar", "", "Go template of the exemplar attached to nvgpu_xid_errors_total increments as comma separated name=value pairs, e.g. 'uptime={{.Uptime}},incident_id={{env \"INCIDENT_ID\"}}' (fields: UUID, PciBusID, Xid, Time, Uptime); enables OpenMetrics, which exemplars require")
+	fs.StringVar(&c.InstanceLock, "instance-lock", "", "Lock file that allows only one exporter per node to collect Xid events; a second instance waits for the lock and reports nvgpu_exporter_duplicate_instance_detected 1")
+	fs.StringVar(&c.InstanceID, "instance-id", os.Getenv("POD_NAME"), "ID of this exporter instance in nvgpu_exporter_duplicate_instance_detected and the lock file (defaults to $POD_NAME, then <hostname>-<pid>)")
+	fs.StringVar(&c.ProcPath, "proc-path", "/proc", "Path to the host proc filesystem, used to detect GPU processes whose PID no longer exists")
+	fs.StringVar(&c.FabricManagerAddr, "fabric-manager-address", "", "Address of the fabric manager (host:port, e.g. 127.0.0.1:6666, or unix:<path>) whose connection check sets nvgpu_fabric_manager_up; empty looks for the nv-fabricmanager process under -proc-path")
+	fs.StringVar(&c.ContainerRuntimeRoot, "container-runtime-root", "", "Path of the host root filesystem (e.g. /host, or / outside a container) to check the NVIDIA container toolkit and the kubelet device plugin registration under; empty disables the checks")
+	fs.StringVar(&c.SysPath, "sys-path", "/sys", "Path to the host sys filesystem, used to read PCIe AER counters and link state of each GPU")
+	fs.BoolVar(&c.PCIeTopology, "pcie-topology", false, "Serve the PCIe tree from the root ports to every GPU and NIC, read from -sys-path, at /api/v1/topology/pcie")
+	fs.StringVar(&c.NVML, "nvml", "", "NVML source: empty for the system library, record:<file> to record its responses, or replay:<file> to replay a recording without GPUs")
+	fs.StringVar(&c.NVMLLibrary, "nvml-library", os.Getenv("NVML_LIBRARY"), "Path to libnvidia-ml.so, or a directory containing libnvidia-ml.so.1, for driver libraries outside the loader search path (defaults to $NVML_LIBRARY)")
+	fs.DurationVar(&c.NVMLRetryInterval, "nvml-retry-interval", 30*time.Second, "When NVML fails to initialize, keep serving nvgpu_up and nvgpu_nvml_initialized 0 and retry this often; 0 exits instead")
+	fs.BoolVar(&c.RespectVisibility, "respect-visibility", false, "Export only the GPUs selected by $NVIDIA_VISIBLE_DEVICES (or $CUDA_VISIBLE_DEVICES) and skip GPUs the container cannot access")
+	fs.StringVar(&c.Simulate, "simulate", "", "Serve synthetic GPUs instead of NVML, e.g. 8xH100 (models: A100, H100, H200, B200, GB200)")
+	fs.Var(&c.LocationLabels, "location-labels", "Comma separated platform info labels of nvgpu_gpu_info (e.g. rack_guid,tray_index,slot_number) to also add to the fabric, NVLink error and Xid metrics")
+	c.TopologyGpuID = topologyIDIndex
+	fs.Var(&c.TopologyGpuID, "topology-gpu-id", "Identity of GPUs in the gpu_id label of nvgpu_gpu_topology: index (GPU0, GPU1, ...), pci (PCI bus ID) or module (platform module ID, falling back to the PCI bus ID), the latter two stable when a GPU falls off the bus")
+	c.ClockEventReasons = newClockEventReasonList(defaultClockEventReasons...)
+	fs.Var(&c.ClockEventReasons, "clock-event-reasons", "Comma separated clock event reasons to collect: names of sw_power_capping, sync_boost, sw_thermal_slowdown, hw_thermal_slowdown, hw_power_braking, hw_slowdown, gpu_idle, applications_clocks_setting and display_clocks_setting, all for every one of them, name=<field ID> for a duration field of a newer driver, or name@sm=<field ID> or name@memory=<field ID> for its duration on one clock domain (NVML defines no per-domain field IDs, so the per-domain metric is only exported for IDs given here)")
+	fs.StringVar(&c.ExpectedProfiles, "expected-profiles", "", "JSON file with the expected power limit, applications clocks and NVLink count per GPU model; GPUs that differ report nvgpu_config_drift 1")
+	fs.StringVar(&c.CustomFields, "custom-fields", "", "JSON file with NVML field IDs to export under metric names of your choosing, per GPU or per NVLink, e.g. fields of a newer driver")
+	fs.Var(&c.RedactAssetLabels, "redact-asset-labels", "Redact asset identifiers (serial, chassis_serial_number, ib_guid) in nvgpu_gpu_info: empty exports them as is, hash replaces them with a truncated SHA-256, omit leaves them empty")
+	fs.BoolVar(&c.NVLinkUtilization, "nvlink-utilization", false, "Export per-link NVLink throughput and utilization; on GPUs without throughput field values this reconfigures NVLink utilization counter 0 to count bytes")
+	fs.BoolVar(&c.MPSClients, "mps-clients", false, "Export the memory and utilization of each MPS client process, labeled by PID and process name")
+	fs.BoolVar(&c.ExportDeltas, "export-deltas", false, "Also export the increase of NVLink error and ECC counters over the last collection round as <name>_delta gauges, for consumers that cannot run PromQL")
+	fs.BoolVar(&c.Grace, "grace", false, "Export Grace CPU companion telemetry on Grace-based systems (GB200, GH200): module power, NVLink-C2C link state and EGM support")
+	fs.DurationVar(&c.NVLinkHistory, "nvlink-history", 0, "Keep per-link BER and FEC readings for this long and export their min, max and percentiles as nvgpu_nvlink_history; 0 disables")
+	fs.StringVar(&c.NVLinkHistoryFile, "nvlink-history-file", "", "Persist the -nvlink-history readings to this file after every collection and restore them at startup")
+	fs.BoolVar(&c.NativeHistograms, "native-histograms", false, "Expose distributions as Prometheus native histograms: nvgpu_nvlink_fec_errors (with -nvlink-fec-histogram), and the per-sample nvgpu_utilization_samples_ratio and nvgpu_exporter_collection_duration_seconds, which are only exported this way")
+	fs.Var(&c.DebugDumpFields, "debug.dump-fields", "Comma separated NVML field IDs, each with an optional :<scope ID> or :* for every NVLink, whose raw field values are logged every collection round and served on /-/debug/fields")
+	fs.BoolVar(&c.NVLinkFecHistogram, "nvlink-fec-histogram", false, "Expose NVLink FEC history as the nvgpu_nvlink_fec_errors histogram instead of fec_errors_N error types")
+}
+
+// xidList is a comma separated list of Xid codes usable as a flag.Value.
+type xidList []uint64
+
+func (x *xidList) String() string {
+	parts := make([]string, 0, len(*x))
+	for _, xid := range *x {
+		parts = append(parts, strconv.FormatUint(xid, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (x *xidList) Set(value string) error {
+	var xids xidList
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		xid, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid xid %q: %w", part, err)
+		}
+		xids = append(xids, xid)
+	}
+	*x = xids
+	return nil
+}
+
+// stringList is a comma separated list of strings usable as a flag.Value.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	var values stringList
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	*s = values
+	return nil
+}
+
+// byteSize is a number of bytes usable as a flag.Value, with an optional
+// KiB, MiB or GiB suffix.
+type byteSize int64
+
+var byteSizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"B", 1},
+}
+
+func (b *byteSize) String() string {
+	for _, unit := range byteSizeUnits {
+		if *b != 0 && int64(*b)%unit.bytes == 0 {
+			return strconv.FormatInt(int64(*b)/unit.bytes, 10) + unit.suffix
+		}
+	}
+	return "0"
+}
+
+func (b *byteSize) Set(value string) error {
+	number, scale := value, int64(1)
+	for _, unit := range byteSizeUnits {
+		if n, ok := strings.CutSuffix(value, unit.suffix); ok {
+			number, scale = n, unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q, expected bytes with an optional KiB, MiB or GiB suffix", value)
+	}
+	*b = byteSize(n * scale)
+	return nil
+}
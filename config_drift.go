@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	powerLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "power_limit_watts",
+			Help:      "Power management limit currently configured on the GPU.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	configDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_drift",
+			Help:      "Whether a setting (power_limit, applications_clock_<clock>) differs from the expected profile of the GPU model (1 = differs, 0 = as expected).",
+		},
+		[]string{"UUID", "pci_bus_id", "setting"},
+	)
+)
+
+// expectedProfile is the power limit and clocks a GPU model is expected to
+// run with. Unset settings are not compared.
+type expectedProfile struct {
+	PowerLimitWatts *float64 `json:"power_limit_watts,omitempty"`
+	// ApplicationsClocksMHz is keyed by clock (graphics, sm, memory, video)
+	ApplicationsClocksMHz map[string]uint32 `json:"applications_clocks_mhz,omitempty"`
+	// NVLinks is the number of active NVLinks, exported as
+	// nvgpu_nvlinks_expected rather than compared here
+	NVLinks *int `json:"nvlinks,omitempty"`
+}
+
+// expectedProfilesFile is the format of -expected-profiles.
+type expectedProfilesFile struct {
+	// Profiles is keyed by GPU model name as reported by NVML (the name label
+	// of nvgpu_gpu_info), e.g. "NVIDIA H100 80GB HBM3"
+	Profiles map[string]expectedProfile `json:"profiles"`
+}
+
+// loadExpectedProfiles reads the expected profile of every GPU model from path.
+func loadExpectedProfiles(path string) (map[string]expectedProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file expectedProfilesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	for model, profile := range file.Profiles {
+		if profile.NVLinks != nil && (*profile.NVLinks < 0 || *profile.NVLinks > nvml.NVLINK_MAX_LINKS) {
+			return nil, fmt.Errorf("invalid NVLink count %d for %q in %s (expected 0-%d)", *profile.NVLinks, model, path, nvml.NVLINK_MAX_LINKS)
+		}
+		for clock := range profile.ApplicationsClocksMHz {
+			var known bool
+			for _, c := range applicationClockTypes {
+				known = known || c.name == clock
+			}
+			if !known {
+				return nil, fmt.Errorf("unknown applications clock %q for %q in %s", clock, model, path)
+			}
+		}
+	}
+	return file.Profiles, nil
+}
+
+// collectConfigDrift exports the power limit of every GPU and compares it and
+// the applications clocks with the expected profile of the GPU model, so that
+// settings lost or changed by a driver update or a manual tweak surface
+// across a fleet. NVML has no getter for locked clocks, so the clocks compared
+// are the applications clocks in clocks, as read by collectApplicationClocks
+// in the same round.
+func collectConfigDrift(devices []Device, profiles map[string]expectedProfile, clocks applicationClockReadings, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		var profile expectedProfile
+		var hasProfile bool
+		if name, ret := device.GetName(); errors.Is(ret, nvml.SUCCESS) {
+			profile, hasProfile = profiles[name]
+		} else {
+			logger.Warn("failed to get device name", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		milliwatts, ret := device.GetPowerManagementLimit()
+		if errors.Is(ret, nvml.SUCCESS) {
+			powerLimit.WithLabelValues(uuid, pciBusId).Set(float64(milliwatts) / 1000)
+			if hasProfile && profile.PowerLimitWatts != nil {
+				drift := float64(milliwatts) != math.Round(*profile.PowerLimitWatts*1000)
+				configDrift.WithLabelValues(uuid, pciBusId, "power_limit").Set(flagToGauge(drift))
+			}
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get power limit", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		if !hasProfile {
+			continue
+		}
+		for _, clock := range applicationClockTypes {
+			expected, ok := profile.ApplicationsClocksMHz[clock.name]
+			if !ok {
+				continue
+			}
+			mhz, ok := clocks[uuid][clock.name]
+			if !ok {
+				continue
+			}
+			configDrift.WithLabelValues(uuid, pciBusId, "applications_clock_"+clock.name).Set(flagToGauge(mhz != expected))
+		}
+	}
+}
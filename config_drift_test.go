@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type driftDevice struct {
+	fakeDevice
+	name       string
+	milliwatts uint32
+	limitRet   nvml.Return
+}
+
+func (d *driftDevice) GetName() (string, nvml.Return) {
+	return d.name, nvml.SUCCESS
+}
+
+func (d *driftDevice) GetPowerManagementLimit() (uint32, nvml.Return) {
+	return d.milliwatts, d.limitRet
+}
+
+func TestCollectConfigDrift(t *testing.T) {
+	assert := hammy.New(t)
+	powerLimit.Reset()
+	configDrift.Reset()
+
+	limit := 700.0
+	profiles := map[string]expectedProfile{
+		"NVIDIA H100 80GB HBM3": {
+			PowerLimitWatts:       &limit,
+			ApplicationsClocksMHz: map[string]uint32{"sm": 1980, "memory": 2619},
+		},
+	}
+	devices := []Device{
+		// As expected
+		&driftDevice{
+			fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+			name:       "NVIDIA H100 80GB HBM3", milliwatts: 700000, limitRet: nvml.SUCCESS,
+		},
+		// Power capped and SM clock lowered
+		&driftDevice{
+			fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:2A:00.0"},
+			name:       "NVIDIA H100 80GB HBM3", milliwatts: 500000, limitRet: nvml.SUCCESS,
+		},
+		// No profile for the model: only the power limit is exported
+		&driftDevice{
+			fakeDevice: fakeDevice{uuid: "GPU-2", pciBusId: "0000:3A:00.0"},
+			name:       "NVIDIA A100-SXM4-80GB", milliwatts: 400000, limitRet: nvml.SUCCESS,
+		},
+		&driftDevice{
+			fakeDevice: fakeDevice{uuid: "GPU-3", pciBusId: "0000:5D:00.0"},
+			name:       "NVIDIA H100 80GB HBM3", limitRet: nvml.ERROR_NOT_SUPPORTED,
+		},
+	}
+
+	// The memory clock of GPU-0 could not be read and is not compared
+	clocks := applicationClockReadings{
+		"GPU-0": {"sm": 1980},
+		"GPU-1": {"sm": 1410},
+		"GPU-3": {"sm": 1980},
+	}
+
+	collectConfigDrift(devices, profiles, clocks, discardLogger())
+
+	err := testutil.CollectAndCompare(configDrift, strings.NewReader(`
+# HELP nvgpu_config_drift Whether a setting (power_limit, applications_clock_<clock>) differs from the expected profile of the GPU model (1 = differs, 0 = as expected).
+# TYPE nvgpu_config_drift gauge
+nvgpu_config_drift{UUID="GPU-0",pci_bus_id="0000:18:00.0",setting="applications_clock_sm"} 0
+nvgpu_config_drift{UUID="GPU-0",pci_bus_id="0000:18:00.0",setting="power_limit"} 0
+nvgpu_config_drift{UUID="GPU-1",pci_bus_id="0000:2A:00.0",setting="applications_clock_sm"} 1
+nvgpu_config_drift{UUID="GPU-1",pci_bus_id="0000:2A:00.0",setting="power_limit"} 1
+nvgpu_config_drift{UUID="GPU-3",pci_bus_id="0000:5D:00.0",setting="applications_clock_sm"} 0
+`))
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(testutil.CollectAndCount(powerLimit)).EqualTo(3))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerLimit.WithLabelValues("GPU-1", "0000:2A:00.0"))).EqualTo(500))
+}
+
+func TestLoadExpectedProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"profiles": {"NVIDIA H100 80GB HBM3": {"power_limit_watts": 700, "applications_clocks_mhz": {"sm": 1980}}}}`, false},
+		{"NVLinks", `{"profiles": {"NVIDIA H100 80GB HBM3": {"power_limit_watts": 700, "nvlinks": 18}}}`, false},
+		{"too many NVLinks", `{"profiles": {"NVIDIA H100 80GB HBM3": {"nvlinks": 19}}}`, true},
+		{"unknown clock", `{"profiles": {"NVIDIA H100 80GB HBM3": {"applications_clocks_mhz": {"shader": 1980}}}}`, true},
+		{"malformed", `{"profiles": [`, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			path := filepath.Join(t.TempDir(), "profiles.json")
+			assert.Is(hammy.NilError(os.WriteFile(path, []byte(tc.content), 0o644)))
+
+			profiles, err := loadExpectedProfiles(path)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+			if !tc.wantErr {
+				assert.Is(hammy.Number(*profiles["NVIDIA H100 80GB HBM3"].PowerLimitWatts).EqualTo(700))
+			}
+		})
+	}
+}
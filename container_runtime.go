@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// devicePluginDir is the kubelet's device plugin directory relative to the
+	// host root; plugins serve their socket in it and the kubelet checkpoints
+	// the registered devices there
+	devicePluginDir = "var/lib/kubelet/device-plugins"
+	// kubeletCheckpoint is the kubelet's device manager checkpoint
+	kubeletCheckpoint = "kubelet_internal_checkpoint"
+	// nvidiaResourcePrefix is the prefix of the extended resources of the
+	// NVIDIA device plugin, e.g. nvidia.com/gpu or nvidia.com/mig-1g.10gb
+	nvidiaResourcePrefix = "nvidia.com/"
+)
+
+var (
+	// containerToolkitBinaries are the executables of the NVIDIA container
+	// toolkit, any of which marks it as installed
+	containerToolkitBinaries = []string{"usr/bin/nvidia-container-cli", "usr/bin/nvidia-container-runtime", "usr/bin/nvidia-ctk"}
+	// containerToolkitLibraries match libnvidia-container, whose file name
+	// carries the toolkit version
+	containerToolkitLibraries = []string{"usr/lib/*/libnvidia-container.so.*.*.*", "usr/lib64/libnvidia-container.so.*.*.*", "usr/lib/libnvidia-container.so.*.*.*"}
+)
+
+var (
+	containerToolkitInstalled = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "container_toolkit_installed",
+			Help:      "Whether the NVIDIA container toolkit is installed on the host (1 = installed).",
+		},
+	)
+
+	containerToolkitInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "container_toolkit_info",
+			Help:      "Version of the installed NVIDIA container toolkit, from the libnvidia-container library; empty when unknown. Always 1.",
+		},
+		[]string{"version"},
+	)
+
+	devicePluginUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "device_plugin_up",
+			Help:      "Whether the NVIDIA device plugin socket in the kubelet device plugin directory accepts connections (1 = up).",
+		},
+		[]string{"socket"},
+	)
+
+	devicePluginRegisteredDevices = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "device_plugin_registered_devices",
+			Help:      "Number of devices the kubelet has registered per NVIDIA extended resource, from its device manager checkpoint.",
+		},
+		[]string{"resource"},
+	)
+)
+
+// collectContainerRuntime checks, under the host root, the pieces between a
+// healthy GPU and a pod that can use it: the NVIDIA container toolkit, which
+// injects the GPUs into containers, and the device plugin, which advertises
+// them to the kubelet. Without either the GPUs look healthy but are not
+// schedulable.
+func collectContainerRuntime(root string, logger *slog.Logger) {
+	installed, version := containerToolkit(root)
+	containerToolkitInstalled.Set(flagToGauge(installed))
+	containerToolkitInfo.Reset()
+	if installed {
+		containerToolkitInfo.WithLabelValues(version).Set(1)
+	}
+
+	// Reset before reading, so that sockets and a checkpoint that disappeared
+	// are no longer reported.
+	devicePluginUp.Reset()
+	devicePluginRegisteredDevices.Reset()
+
+	dir := filepath.Join(root, devicePluginDir)
+	sockets, err := filepath.Glob(filepath.Join(dir, "nvidia*.sock"))
+	if err != nil {
+		logger.Warn("failed to list device plugin sockets", "path", dir, "err", err)
+		return
+	}
+	for _, socket := range sockets {
+		devicePluginUp.WithLabelValues(filepath.Base(socket)).Set(flagToGauge(socketAccepts(socket)))
+	}
+
+	registered, err := readRegisteredDevices(filepath.Join(dir, kubeletCheckpoint))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("failed to read kubelet device checkpoint", "path", dir, "err", err)
+		}
+		return
+	}
+	for resource, devices := range registered {
+		if strings.HasPrefix(resource, nvidiaResourcePrefix) {
+			devicePluginRegisteredDevices.WithLabelValues(resource).Set(float64(len(devices)))
+		}
+	}
+}
+
+// containerToolkit reports whether the container toolkit is installed under
+// root and its version, if known.
+func containerToolkit(root string) (installed bool, version string) {
+	for _, binary := range containerToolkitBinaries {
+		if _, err := os.Stat(filepath.Join(root, binary)); err == nil {
+			installed = true
+			break
+		}
+	}
+	for _, pattern := range containerToolkitLibraries {
+		if matches, _ := filepath.Glob(filepath.Join(root, pattern)); len(matches) > 0 {
+			return true, strings.TrimPrefix(filepath.Base(matches[0]), "libnvidia-container.so.")
+		}
+	}
+	return installed, ""
+}
+
+// socketAccepts reports whether a unix socket accepts connections.
+func socketAccepts(path string) bool {
+	conn, err := net.DialTimeout("unix", path, livenessDialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// readRegisteredDevices returns the device IDs per resource of the kubelet's
+// device manager checkpoint.
+func readRegisteredDevices(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint struct {
+		Data struct {
+			RegisteredDevices map[string][]string
+		}
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint.Data.RegisteredDevices, nil
+}
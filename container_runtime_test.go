@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeHostFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectContainerRuntime(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		containerToolkitInfo.Reset()
+		devicePluginUp.Reset()
+		devicePluginRegisteredDevices.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	// Unix socket paths are limited to about 100 bytes
+	root, err := os.MkdirTemp("", "host")
+	assert.Is(hammy.NilError(err))
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	writeHostFile(t, root, "usr/bin/nvidia-ctk", "")
+	writeHostFile(t, root, "usr/lib/x86_64-linux-gnu/libnvidia-container.so.1.17.4", "")
+	writeHostFile(t, root, filepath.Join(devicePluginDir, kubeletCheckpoint),
+		`{"Data":{"PodDeviceEntries":null,"RegisteredDevices":{"nvidia.com/gpu":["GPU-0","GPU-1"],"example.com/fpga":["0"]}},"Checksum":1}`)
+	listener, err := net.Listen("unix", filepath.Join(root, devicePluginDir, "nvidia-gpu.sock"))
+	assert.Is(hammy.NilError(err))
+	defer listener.Close()
+
+	collectContainerRuntime(root, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(containerToolkitInstalled)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(containerToolkitInfo.WithLabelValues("1.17.4"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(devicePluginUp.WithLabelValues("nvidia-gpu.sock"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(devicePluginRegisteredDevices.WithLabelValues("nvidia.com/gpu"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.CollectAndCount(devicePluginRegisteredDevices)).EqualTo(1))
+
+	// The checkpoint disappears, e.g. while the kubelet restarts
+	assert.Is(hammy.NilError(os.Remove(filepath.Join(root, devicePluginDir, kubeletCheckpoint))))
+	collectContainerRuntime(root, discardLogger())
+
+	assert.Is(hammy.Number(testutil.CollectAndCount(devicePluginRegisteredDevices)).EqualTo(0))
+}
+
+func TestCollectContainerRuntimeMissing(t *testing.T) {
+	assert := hammy.New(t)
+	containerToolkitInfo.Reset()
+	devicePluginUp.Reset()
+	t.Cleanup(containerToolkitInfo.Reset)
+
+	collectContainerRuntime(t.TempDir(), discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(containerToolkitInstalled)).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(containerToolkitInfo)).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(devicePluginUp)).EqualTo(0))
+}
@@ -1,17 +1,31 @@
 package main
 
-// pciBusIdToString converts a PCI bus ID byte array to a human-readable string
-// Standard PCI address format is: DDDD:BB:DD.F (e.g., 0000:00:1e.0)
-// This is typically 12-13 characters long
+import (
+	"fmt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// pciBusID formats the PCI address of info as domain:bus:device.function, e.g.
+// 0000:18:00.0. The address is built from the structured fields rather than
+// BusIdLegacy, which only has room for a 16-bit domain: extended domains such
+// as 0x10002 on some ARM servers print in full (10002:01:00.0), the way sysfs
+// names the device. Functions are always 0 for GPUs.
+func pciBusID(info nvml.PciInfo) string {
+	// NVML always fills in BusId; without it the structured fields are not
+	// trustworthy, so fall back to the legacy string.
+	if info.BusId[0] == 0 {
+		return sanitizePciBusID(pciBusIdToString(info.BusIdLegacy))
+	}
+	return fmt.Sprintf("%04X:%02X:%02X.0", info.Domain, info.Bus, info.Device)
+}
+
+// pciBusIdToString converts a NUL-terminated PCI bus ID byte array to a string.
 func pciBusIdToString(busId [16]uint8) string {
-	// Standard PCI address is domain:bus:device.function (12-13 chars)
-	// Find the end by looking for common PCI address length
-	str := string(busId[:])
-	// Find the last digit or period in the expected PCI format
-	for i := 12; i < len(busId) && i < 14; i++ {
-		if busId[i] == 0 || busId[i] < 32 || busId[i] > 126 {
-			return str[:i]
+	for i, b := range busId {
+		if b == 0 || b < 32 || b > 126 {
+			return string(busId[:i])
 		}
 	}
-	return str[:13]
+	return string(busId[:])
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestPciBusID(t *testing.T) {
+	legacy := func(s string) (b [16]uint8) {
+		copy(b[:], s)
+		return b
+	}
+	full := func(s string) (b [32]uint8) {
+		copy(b[:], s)
+		return b
+	}
+
+	tests := []struct {
+		name string
+		info nvml.PciInfo
+		want string
+	}{
+		{
+			name: "16-bit domain",
+			info: nvml.PciInfo{Domain: 0, Bus: 0x18, BusIdLegacy: legacy("0000:18:00.0"), BusId: full("00000000:18:00.0")},
+			want: "0000:18:00.0",
+		},
+		{
+			name: "extended domain",
+			info: nvml.PciInfo{Domain: 0x10002, Bus: 0x01, BusIdLegacy: legacy("0002:01:00.0"), BusId: full("00010002:01:00.0")},
+			want: "10002:01:00.0",
+		},
+		{
+			name: "device number",
+			info: nvml.PciInfo{Domain: 0x0009, Bus: 0xAB, Device: 0x1F, BusId: full("00000009:AB:1F.0")},
+			want: "0009:AB:1F.0",
+		},
+		{
+			name: "legacy only",
+			info: nvml.PciInfo{BusIdLegacy: legacy("0000:9A:00.0")},
+			want: "0000:9A:00.0",
+		},
+		{
+			name: "legacy without terminator",
+			info: nvml.PciInfo{BusIdLegacy: legacy("0000:9A:00.0abcd")},
+			want: "0000:9A:00.0ABCD",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			assert.Is(hammy.String(pciBusID(tc.info)).EqualTo(tc.want))
+		})
+	}
+}
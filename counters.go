@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// counterTracker keeps the last raw reading of cumulative hardware counters so
+// that values exported to Prometheus never decrease. NVML counters restart
+// from zero after a driver reload or GPU reset; when that happens the previous
+// reading is folded into an offset and the new raw value is added on top.
+type counterTracker struct {
+	mu      sync.Mutex
+	entries map[string]*counterState
+}
+
+type counterState struct {
+	last   float64
+	offset float64
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{
+		entries: make(map[string]*counterState),
+	}
+}
+
+// observe records a raw reading for key and returns the monotonic value along
+// with whether a counter reset was detected.
+func (t *counterTracker) observe(key string, raw float64) (float64, bool) {
+	value, reset, _ := t.observeWrapping(key, raw, 0)
+	return value, reset
+}
+
+// observeWrapping is observe for a counter that is bits wide in hardware and
+// wraps around to zero after 2^bits-1. A decrease from the top quarter of the
+// range into the bottom quarter is taken as a rollover and adds 2^bits instead
+// of restarting the count; any other decrease is a reset. bits of 0 means the
+// counter does not wrap.
+func (t *counterTracker) observeWrapping(key string, raw float64, bits uint) (value float64, reset, rollover bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.entries[key]
+	if !ok {
+		t.entries[key] = &counterState{last: raw}
+		return raw, false, false
+	}
+
+	if raw < state.last {
+		span := math.Ldexp(1, int(bits))
+		if bits > 0 && state.last >= span*3/4 && raw < span/4 {
+			rollover = true
+			state.offset += span
+		} else {
+			reset = true
+			state.offset += state.last
+		}
+	}
+	state.last = raw
+
+	return state.offset + raw, reset, rollover
+}
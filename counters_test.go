@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestCounterTrackerObserve(t *testing.T) {
+	tests := []struct {
+		name       string
+		readings   []float64
+		want       float64
+		wantResets int
+	}{
+		{name: "first reading", readings: []float64{42}, want: 42},
+		{name: "increasing", readings: []float64{1, 5, 9}, want: 9},
+		{name: "unchanged", readings: []float64{7, 7}, want: 7},
+		{name: "reset", readings: []float64{10, 3}, want: 13, wantResets: 1},
+		{name: "reset to zero", readings: []float64{10, 0, 4}, want: 14, wantResets: 1},
+		{name: "multiple resets", readings: []float64{10, 2, 8, 1}, want: 19, wantResets: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			tracker := newCounterTracker()
+
+			var got float64
+			resets := 0
+			for _, r := range tc.readings {
+				var reset bool
+				got, reset = tracker.observe("key", r)
+				if reset {
+					resets++
+				}
+			}
+
+			assert.Is(hammy.Number(got).EqualTo(tc.want))
+			assert.Is(hammy.Number(resets).EqualTo(tc.wantResets))
+		})
+	}
+}
+
+func TestCounterTrackerObserveWrapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		readings      []float64
+		want          float64
+		wantResets    int
+		wantRollovers int
+	}{
+		{name: "increasing", readings: []float64{1, 5}, want: 5},
+		{name: "rollover", readings: []float64{math.MaxUint32 - 4, 10}, want: math.MaxUint32 + 11, wantRollovers: 1},
+		{name: "rollover to zero", readings: []float64{math.MaxUint32, 0, 3}, want: math.MaxUint32 + 4, wantRollovers: 1},
+		{name: "reset from low value", readings: []float64{1000, 10}, want: 1010, wantResets: 1},
+		{name: "reset to high value", readings: []float64{math.MaxUint32, 1 << 31}, want: math.MaxUint32 + 1<<31, wantResets: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			tracker := newCounterTracker()
+
+			var got float64
+			resets, rollovers := 0, 0
+			for _, r := range tc.readings {
+				var reset, rollover bool
+				got, reset, rollover = tracker.observeWrapping("key", r, 32)
+				if reset {
+					resets++
+				}
+				if rollover {
+					rollovers++
+				}
+			}
+
+			assert.Is(hammy.Number(got).EqualTo(tc.want))
+			assert.Is(hammy.Number(resets).EqualTo(tc.wantResets))
+			assert.Is(hammy.Number(rollovers).EqualTo(tc.wantRollovers))
+		})
+	}
+}
+
+func TestCounterTrackerKeysAreIndependent(t *testing.T) {
+	assert := hammy.New(t)
+	tracker := newCounterTracker()
+
+	tracker.observe("a", 10)
+	tracker.observe("b", 1)
+	a, resetA := tracker.observe("a", 12)
+	b, resetB := tracker.observe("b", 0)
+
+	assert.Is(hammy.Number(a).EqualTo(12))
+	assert.Is(hammy.False(resetA))
+	assert.Is(hammy.Number(b).EqualTo(1))
+	assert.Is(hammy.True(resetB))
+}
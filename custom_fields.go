@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// customField is an NVML field exported under a name of the user's choosing,
+// e.g. a field ID a new driver added before the exporter knows it.
+type customField struct {
+	// Name is the metric name after the nvgpu_ prefix
+	Name    string `json:"name"`
+	Help    string `json:"help,omitempty"`
+	FieldID uint32 `json:"field_id"`
+	// Type is gauge (default) or counter, for fields that only grow
+	Type string `json:"type,omitempty"`
+	// Scope is device (default), read with ScopeId, or link, read on every
+	// NVLink with the link as scope
+	Scope   string `json:"scope,omitempty"`
+	ScopeID uint32 `json:"scope_id,omitempty"`
+	// Scale multiplies the value, e.g. 0.001 for milliwatts to watts; 0 keeps it
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// customFieldsFile is the format of -custom-fields.
+type customFieldsFile struct {
+	Fields []customField `json:"fields"`
+}
+
+var customFieldName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// loadCustomFields reads the fields from path.
+func loadCustomFields(path string) ([]customField, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file customFieldsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(file.Fields))
+	for i, f := range file.Fields {
+		switch {
+		case !customFieldName.MatchString(f.Name):
+			return nil, fmt.Errorf("field %d in %s has an invalid metric name %q", i, path, f.Name)
+		case names[f.Name]:
+			return nil, fmt.Errorf("duplicate field %q in %s", f.Name, path)
+		case f.FieldID == 0:
+			return nil, fmt.Errorf("field %q in %s has no field_id", f.Name, path)
+		case f.Type != "" && f.Type != "gauge" && f.Type != "counter":
+			return nil, fmt.Errorf("field %q in %s has unknown type %q (expected gauge or counter)", f.Name, path, f.Type)
+		case f.Scope != "" && f.Scope != "device" && f.Scope != "link":
+			return nil, fmt.Errorf("field %q in %s has unknown scope %q (expected device or link)", f.Name, path, f.Scope)
+		}
+		names[f.Name] = true
+	}
+	return file.Fields, nil
+}
+
+// customFieldCollector exports the -custom-fields read in the last collection
+// round.
+type customFieldCollector struct {
+	fields []customField
+	descs  []*prometheus.Desc
+
+	mu      sync.Mutex
+	metrics []prometheus.Metric
+}
+
+func newCustomFieldCollector(fields []customField) *customFieldCollector {
+	c := &customFieldCollector{fields: fields}
+	for _, f := range fields {
+		help := f.Help
+		if help == "" {
+			help = fmt.Sprintf("NVML field %d.", f.FieldID)
+		}
+		labels := []string{"UUID", "pci_bus_id"}
+		if f.Scope == "link" {
+			labels = append(labels, "link")
+		}
+		c.descs = append(c.descs, prometheus.NewDesc(prometheus.BuildFQName(namespace, "", f.Name), help, labels, nil))
+	}
+	return c
+}
+
+// collectCustomFields reads every field on every GPU in a single
+// GetFieldValues call per GPU. Fields the GPU does not report are left out.
+func (c *customFieldCollector) collectCustomFields(devices []Device, logger *slog.Logger) {
+	var metrics []prometheus.Metric
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		var links []int
+		for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+			if _, ret := device.GetNvLinkState(link); errors.Is(ret, nvml.SUCCESS) {
+				links = append(links, link)
+			}
+		}
+
+		// index holds the field of every requested value and, for link
+		// fields, the link label
+		type request struct {
+			field int
+			link  string
+		}
+		var values []nvml.FieldValue
+		var index []request
+		for i, f := range c.fields {
+			if f.Scope != "link" {
+				values = append(values, nvml.FieldValue{FieldId: f.FieldID, ScopeId: f.ScopeID})
+				index = append(index, request{field: i})
+				continue
+			}
+			for _, link := range links {
+				values = append(values, nvml.FieldValue{FieldId: f.FieldID, ScopeId: uint32(link)})
+				index = append(index, request{field: i, link: strconv.Itoa(link)})
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		ret = device.GetFieldValues(values)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get custom fields", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		for i, fv := range values {
+			if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
+				continue
+			}
+			field := c.fields[index[i].field]
+			value, err := fieldValueToFloat64(fv)
+			if err != nil {
+				logger.Debug("failed to decode custom field", "uuid", uuid, "field", field.Name, "err", err)
+				continue
+			}
+			if field.Scale != 0 {
+				value *= field.Scale
+			}
+
+			valueType := prometheus.GaugeValue
+			if field.Type == "counter" {
+				valueType = prometheus.CounterValue
+			}
+			labels := []string{uuid, pciBusId}
+			if field.Scope == "link" {
+				labels = append(labels, index[i].link)
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(c.descs[index[i].field], valueType, value, labels...))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+}
+
+func (c *customFieldCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+func (c *customFieldCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestLoadCustomFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"fields": [{"name": "power_module_watts", "field_id": 186, "scope_id": 1, "scale": 0.001}, {"name": "nvlink_replays_total", "field_id": 300, "type": "counter", "scope": "link"}]}`, false},
+		{"invalid name", `{"fields": [{"name": "power-watts", "field_id": 186}]}`, true},
+		{"no field ID", `{"fields": [{"name": "power_watts"}]}`, true},
+		{"duplicate", `{"fields": [{"name": "power", "field_id": 186}, {"name": "power", "field_id": 187}]}`, true},
+		{"unknown type", `{"fields": [{"name": "power", "field_id": 186, "type": "histogram"}]}`, true},
+		{"unknown scope", `{"fields": [{"name": "power", "field_id": 186, "scope": "gpc"}]}`, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			path := filepath.Join(t.TempDir(), "fields.json")
+			assert.Is(hammy.NilError(os.WriteFile(path, []byte(tc.content), 0o644)))
+
+			_, err := loadCustomFields(path)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+		})
+	}
+}
+
+func TestCollectCustomFields(t *testing.T) {
+	assert := hammy.New(t)
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true, 1: false},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_POWER_INSTANT, link: nvml.POWER_SCOPE_MODULE}: 1850500,
+			{fieldId: 300, link: 0}: 7,
+			{fieldId: 300, link: 1}: 2,
+		},
+	}
+	collector := newCustomFieldCollector([]customField{
+		{Name: "module_power_instant_watts", FieldID: nvml.FI_DEV_POWER_INSTANT, ScopeID: nvml.POWER_SCOPE_MODULE, Scale: 0.001},
+		{Name: "nvlink_replays_total", FieldID: 300, Type: "counter", Scope: "link"},
+		{Name: "unsupported", FieldID: 301},
+	})
+	collector.collectCustomFields([]Device{device, &fakeDevice{uuid: "GPU-1", fieldsRet: nvml.ERROR_NOT_SUPPORTED}}, discardLogger())
+
+	reg := prometheus.NewRegistry()
+	assert.Is(hammy.NilError(reg.Register(collector)))
+	families, err := reg.Gather()
+	assert.Is(hammy.NilError(err))
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, f := range families {
+		byName[f.GetName()] = f
+	}
+	assert.Is(hammy.Number(len(byName)).EqualTo(2))
+
+	power := byName["nvgpu_module_power_instant_watts"]
+	assert.Is(hammy.True(power.GetType() == dto.MetricType_GAUGE))
+	assert.Is(hammy.Number(power.Metric[0].Gauge.GetValue()).EqualTo(1850.5))
+	assert.Is(hammy.String(metricLabel(power.Metric[0], "pci_bus_id")).EqualTo("0000:18:00.0"))
+
+	replays := byName["nvgpu_nvlink_replays_total"]
+	assert.Is(hammy.True(replays.GetType() == dto.MetricType_COUNTER))
+	assert.Is(hammy.Number(len(replays.Metric)).EqualTo(2))
+	assert.Is(hammy.String(metricLabel(replays.Metric[1], "link")).EqualTo("1"))
+	assert.Is(hammy.Number(replays.Metric[1].Counter.GetValue()).EqualTo(2))
+	assert.Is(hammy.Number(testutil.CollectAndCount(collector, "nvgpu_nvlink_replays_total")).EqualTo(2))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// deltaSources are the cumulative metrics exported as per-round deltas with
+// -export-deltas.
+var deltaSources = []deltaSource{nvlinkErrorsDelta, eccEventsDelta, eccSramAggregateUncorrectableDelta}
+
+// deltaSource is a cumulative metric and the name, help and labels of its
+// deltas, which are declared next to the metric. labels must be the variable
+// labels of the metric.
+type deltaSource struct {
+	collector prometheus.Collector
+	name      string
+	help      string
+	labels    []string
+	// skip, if set, reports series of the metric that are not counts and
+	// have no delta
+	skip func(m *dto.Metric) bool
+}
+
+// deltaCollector exports how much cumulative metrics grew over the last
+// collection round, as <name>_delta gauges with the labels of the source
+// series, for consumers that cannot run rate() or increase(), such as edge
+// agents and Nagios-style checks that read a single scrape.
+type deltaCollector struct {
+	sources []deltaSource
+	descs   []*prometheus.Desc
+
+	mu sync.Mutex
+	// last holds the value of every source series at the end of the last
+	// round, by name and label values
+	last   map[string]float64
+	deltas []prometheus.Metric
+}
+
+// newDeltaCollector returns a collector for the deltas of sources. It fails
+// when a source does not describe a single metric or the name or labels of
+// its deltas are invalid.
+func newDeltaCollector(sources []deltaSource) (*deltaCollector, error) {
+	c := &deltaCollector{last: make(map[string]float64)}
+	for _, source := range sources {
+		if n := len(describe(source.collector)); n != 1 {
+			return nil, fmt.Errorf("source of %s describes %d metrics, want 1", source.name, n)
+		}
+		desc := prometheus.NewDesc(source.name, source.help, source.labels, nil)
+		// NewDesc keeps its error for registration; a const metric reports it
+		if _, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 0, make([]string, len(source.labels))...); err != nil {
+			return nil, fmt.Errorf("invalid delta metric %s: %w", source.name, err)
+		}
+		c.sources = append(c.sources, source)
+		c.descs = append(c.descs, desc)
+	}
+	return c, nil
+}
+
+// update computes the deltas since the previous call. A series is exported
+// from its second round on; a series that went backwards, e.g. after it was
+// deleted and recreated, counts from zero.
+func (c *deltaCollector) update() {
+	last := make(map[string]float64, len(c.last))
+	var deltas []prometheus.Metric
+	for i, source := range c.sources {
+		metrics := make(chan prometheus.Metric)
+		go func() {
+			source.collector.Collect(metrics)
+			close(metrics)
+		}()
+
+		for m := range metrics {
+			var pb dto.Metric
+			if err := m.Write(&pb); err != nil {
+				continue
+			}
+			if source.skip != nil && source.skip(&pb) {
+				continue
+			}
+			var value float64
+			switch {
+			case pb.Counter != nil:
+				value = pb.Counter.GetValue()
+			case pb.Gauge != nil:
+				value = pb.Gauge.GetValue()
+			default:
+				continue
+			}
+
+			values := make([]string, 0, len(source.labels))
+			for _, name := range source.labels {
+				values = append(values, metricLabel(&pb, name))
+			}
+			key := source.name + "\xff" + strings.Join(values, "\xff")
+			last[key] = value
+
+			prev, ok := c.last[key]
+			if !ok {
+				continue
+			}
+			delta := value - prev
+			if delta < 0 {
+				delta = value
+			}
+			deltas = append(deltas, prometheus.MustNewConstMetric(c.descs[i], prometheus.GaugeValue, delta, values...))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last = last
+	c.deltas = deltas
+}
+
+func (c *deltaCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+func (c *deltaCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.deltas {
+		ch <- m
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeltaCollector(t *testing.T) {
+	assert := hammy.New(t)
+	errors := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_errors_total", Help: "Errors."}, []string{"UUID", "link"})
+	events := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_events_total", Help: "Events."}, []string{"UUID"})
+	deltas, err := newDeltaCollector([]deltaSource{
+		{collector: errors, name: "test_errors_delta", help: "Increase of test_errors_total over the last collection round.", labels: []string{"UUID", "link"}},
+		{collector: events, name: "test_events_delta", help: "Increase of test_events_total over the last collection round.", labels: []string{"UUID"}},
+	})
+	assert.Is(hammy.NilError(err))
+
+	// The first round only records the values
+	errors.WithLabelValues("GPU-0", "0").Set(5)
+	events.WithLabelValues("GPU-0").Add(2)
+	deltas.update()
+	assert.Is(hammy.Number(testutil.CollectAndCount(deltas)).EqualTo(0))
+
+	errors.WithLabelValues("GPU-0", "0").Set(8)
+	errors.WithLabelValues("GPU-0", "1").Set(1)
+	events.WithLabelValues("GPU-0").Add(1)
+	deltas.update()
+	err = testutil.CollectAndCompare(deltas, strings.NewReader(`
+# HELP test_errors_delta Increase of test_errors_total over the last collection round.
+# TYPE test_errors_delta gauge
+test_errors_delta{UUID="GPU-0",link="0"} 3
+# HELP test_events_delta Increase of test_events_total over the last collection round.
+# TYPE test_events_delta gauge
+test_events_delta{UUID="GPU-0"} 1
+`))
+	assert.Is(hammy.NilError(err))
+
+	// A series that went backwards counts from zero
+	errors.WithLabelValues("GPU-0", "0").Set(2)
+	deltas.update()
+	err = testutil.CollectAndCompare(deltas, strings.NewReader(`
+# HELP test_errors_delta Increase of test_errors_total over the last collection round.
+# TYPE test_errors_delta gauge
+test_errors_delta{UUID="GPU-0",link="0"} 2
+test_errors_delta{UUID="GPU-0",link="1"} 0
+`), "test_errors_delta")
+	assert.Is(hammy.NilError(err))
+}
+
+func TestDeltaCollectorInvalid(t *testing.T) {
+	assert := hammy.New(t)
+	events := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_events_total", Help: "Events."}, []string{"UUID"})
+
+	_, err := newDeltaCollector([]deltaSource{{collector: events, name: "test_events_delta", help: "Events.", labels: []string{"UUID", "UUID"}}})
+	assert.Is(hammy.Error(err))
+}
+
+func TestNVLinkErrorsDeltaSkipsLegacyBER(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+	deltas, err := newDeltaCollector([]deltaSource{nvlinkErrorsDelta})
+	assert.Is(hammy.NilError(err))
+
+	for _, value := range []float64{1, 3} {
+		nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "switch", "symbol_errors", "").Set(value)
+		nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "switch", "symbol_ber", "").Set(value * 1e-12)
+		deltas.update()
+	}
+
+	err = testutil.CollectAndCompare(deltas, strings.NewReader(`
+# HELP nvgpu_nvlink_errors_delta Increase of nvgpu_nvlink_errors_total over the last collection round. BER values are left out.
+# TYPE nvgpu_nvlink_errors_delta gauge
+nvgpu_nvlink_errors_delta{UUID="GPU-0",direction="",error_type="symbol_errors",link="0",pci_bus_id="0000:18:00.0",peer="switch"} 2
+`))
+	assert.Is(hammy.NilError(err))
+}
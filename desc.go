@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descPattern matches the String form of a *prometheus.Desc, which is the only
+// way client_golang exposes the name, help and labels of a descriptor.
+var descPattern = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{(.*)\}, variableLabels: \{(.*)\}\}$`)
+
+// parsedDesc holds the parts of a descriptor.
+type parsedDesc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// parseDesc splits desc into its name, help and variable labels. Descriptors
+// with constant labels are not supported.
+func parseDesc(desc *prometheus.Desc) (parsedDesc, error) {
+	m := descPattern.FindStringSubmatch(desc.String())
+	if m == nil {
+		return parsedDesc{}, fmt.Errorf("unexpected descriptor %s", desc)
+	}
+	if m[3] != "" {
+		return parsedDesc{}, fmt.Errorf("descriptor %s has constant labels", desc)
+	}
+
+	var p parsedDesc
+	var err error
+	if p.name, err = strconv.Unquote(m[1]); err != nil {
+		return parsedDesc{}, fmt.Errorf("invalid name in descriptor %s: %w", desc, err)
+	}
+	if p.help, err = strconv.Unquote(m[2]); err != nil {
+		return parsedDesc{}, fmt.Errorf("invalid help in descriptor %s: %w", desc, err)
+	}
+	if m[4] != "" {
+		p.labels = strings.Split(m[4], ",")
+	}
+	return p, nil
+}
+
+// descName returns the fully qualified metric name of desc.
+func descName(desc *prometheus.Desc) string {
+	p, err := parseDesc(desc)
+	if err != nil {
+		panic(err)
+	}
+	return p.name
+}
+
+// metricName returns the fully qualified name of the metric exported by c,
+// which must describe exactly one metric.
+func metricName(c prometheus.Collector) string {
+	descs := describe(c)
+	if len(descs) != 1 {
+		panic(fmt.Sprintf("collector describes %d metrics, want 1", len(descs)))
+	}
+	return descName(descs[0])
+}
+
+// describe returns the descriptors of c.
+func describe(c prometheus.Collector) []*prometheus.Desc {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+
+	var descs []*prometheus.Desc
+	for desc := range ch {
+		descs = append(descs, desc)
+	}
+	return descs
+}
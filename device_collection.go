@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var deviceCollectionSuccess = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "device_collection_success",
+		Help:      "Whether the last round of a collector reached the GPU and its UUID, PCI info and field value queries succeeded (1) or not (0).",
+	},
+	[]string{"UUID", "collector"},
+)
+
+// deviceCollectionTracker records, per collector round, which GPUs the
+// collector reached and whether their NVML queries failed. A GPU whose driver
+// stops answering (e.g. a hung GSP) only makes its collectors log warnings and
+// its series go stale; tracking it per GPU makes that alertable. Collectors
+// query the devices returned by devices, which report to the tracker.
+// Collectors that never query a GPU, such as those reading node files or the
+// fabric manager, are not tracked.
+type deviceCollectionTracker struct {
+	devices []Device
+	uuids   []string
+
+	mu        sync.Mutex
+	collector string
+	reached   map[string]bool
+	failed    map[string]bool
+	// deviceScoped holds the collectors that reached a GPU in any round
+	deviceScoped map[string]bool
+}
+
+func newDeviceCollectionTracker(devices []Device, logger *slog.Logger) *deviceCollectionTracker {
+	t := &deviceCollectionTracker{
+		reached:      make(map[string]bool),
+		failed:       make(map[string]bool),
+		deviceScoped: make(map[string]bool),
+	}
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device, collection success is not tracked", "error", nvml.ErrorString(ret))
+			t.devices = append(t.devices, device)
+			continue
+		}
+		t.uuids = append(t.uuids, uuid)
+		t.devices = append(t.devices, trackedDevice{Device: device, uuid: uuid, tracker: t})
+	}
+	return t
+}
+
+// begin starts a round of collector. A nil tracker is valid and ignored.
+func (t *deviceCollectionTracker) begin(collector string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.collector = collector
+	clear(t.reached)
+	clear(t.failed)
+}
+
+// end exports the outcome of the round started by begin. Once a collector
+// reached a GPU, the GPUs it did not reach in a round, e.g. because it
+// panicked, count as failed. A collector that never reached a GPU is not
+// exported, so that node-scoped collectors do not report every GPU as failed.
+func (t *deviceCollectionTracker) end() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.reached) > 0 {
+		t.deviceScoped[t.collector] = true
+	}
+	if !t.deviceScoped[t.collector] {
+		t.collector = ""
+		return
+	}
+	for _, uuid := range t.uuids {
+		deviceCollectionSuccess.WithLabelValues(uuid, t.collector).Set(flagToGauge(t.reached[uuid] && !t.failed[uuid]))
+	}
+	t.collector = ""
+}
+
+// observe records the outcome of an NVML query on the GPU uuid.
+func (t *deviceCollectionTracker) observe(uuid string, ret nvml.Return) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reached[uuid] = true
+	if !errors.Is(ret, nvml.SUCCESS) && !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		t.failed[uuid] = true
+	}
+}
+
+// trackedDevice reports the queries every collector makes to its tracker.
+type trackedDevice struct {
+	Device
+	uuid    string
+	tracker *deviceCollectionTracker
+}
+
+func (d trackedDevice) unwrap() Device {
+	return d.Device
+}
+
+func (d trackedDevice) GetUUID() (string, nvml.Return) {
+	uuid, ret := d.Device.GetUUID()
+	d.tracker.observe(d.uuid, ret)
+	return uuid, ret
+}
+
+func (d trackedDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	info, ret := d.Device.GetPciInfo()
+	d.tracker.observe(d.uuid, ret)
+	return info, ret
+}
+
+// GetFieldValues counts a query as failed when the call fails or any field
+// times out or finds the GPU lost; other per-field errors mean the field is
+// not available on the GPU.
+func (d trackedDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	ret := d.Device.GetFieldValues(values)
+	d.tracker.observe(d.uuid, ret)
+	if errors.Is(ret, nvml.SUCCESS) {
+		for _, fv := range values {
+			if fieldRet := nvml.Return(fv.NvmlReturn); errors.Is(fieldRet, nvml.ERROR_TIMEOUT) || errors.Is(fieldRet, nvml.ERROR_GPU_IS_LOST) {
+				d.tracker.observe(d.uuid, fieldRet)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeviceCollectionTracker(t *testing.T) {
+	assert := hammy.New(t)
+	deviceCollectionSuccess.Reset()
+	t.Cleanup(deviceCollectionSuccess.Reset)
+
+	healthy := &fakeDevice{uuid: "GPU-1", pciBusId: "00000000:01:00.0"}
+	hung := &fakeDevice{uuid: "GPU-2", pciBusId: "00000000:02:00.0", fieldsRet: nvml.ERROR_TIMEOUT}
+	tracker := newDeviceCollectionTracker([]Device{healthy, hung}, discardLogger())
+
+	query := func() {
+		for _, device := range tracker.devices {
+			device.GetUUID()
+			device.GetFieldValues([]nvml.FieldValue{{FieldId: nvml.FI_DEV_NVLINK_LINK_COUNT}})
+		}
+	}
+	collectors := []namedCollector{
+		{"fields", query},
+		{"uuid_only", func() {
+			for _, device := range tracker.devices {
+				device.GetUUID()
+			}
+		}},
+		{"faulty", func() {
+			tracker.devices[0].GetUUID()
+			panic("collector bug")
+		}},
+		// Reads node files only and never queries a GPU
+		{"node_only", func() {}},
+	}
+	runCollectors(collectors, tracker, discardLogger())
+
+	tests := []struct {
+		uuid      string
+		collector string
+		want      float64
+	}{
+		{"GPU-1", "fields", 1},
+		{"GPU-2", "fields", 0},
+		{"GPU-1", "uuid_only", 1},
+		{"GPU-2", "uuid_only", 1},
+		{"GPU-1", "faulty", 1},
+		{"GPU-2", "faulty", 0},
+	}
+	for _, tc := range tests {
+		assert.Is(hammy.Number(testutil.ToFloat64(deviceCollectionSuccess.WithLabelValues(tc.uuid, tc.collector))).EqualTo(tc.want))
+	}
+	assert.Is(hammy.Number(testutil.CollectAndCount(deviceCollectionSuccess)).EqualTo(6))
+
+	// The GPU recovers on the next round
+	hung.fieldsRet = nvml.SUCCESS
+	runCollectors(collectors[:1], tracker, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(deviceCollectionSuccess.WithLabelValues("GPU-2", "fields"))).EqualTo(1))
+
+	// A collector that reached GPUs before fails them all when it panics early
+	runCollectors([]namedCollector{{"uuid_only", func() { panic("collector bug") }}}, tracker, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(deviceCollectionSuccess.WithLabelValues("GPU-1", "uuid_only"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(deviceCollectionSuccess.WithLabelValues("GPU-2", "uuid_only"))).EqualTo(0))
+}
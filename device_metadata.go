@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// deviceMetadataCache remembers the UUID, PCI info and NVLink states of every
+// GPU for the length of a collection round. Every collector labels its series
+// with the UUID and PCI bus ID and several check the links, so without it each
+// collector queries them again; with it the first query of a round goes to
+// NVML and the others, in the same round, get the same answer, so that the
+// series of all collectors agree even when a GPU changes during the round.
+// Between rounds, e.g. for HTTP handlers, queries go to NVML.
+type deviceMetadataCache struct {
+	devices []*cachedDevice
+}
+
+func newDeviceMetadataCache(devices []Device) *deviceMetadataCache {
+	c := &deviceMetadataCache{}
+	for _, device := range devices {
+		c.devices = append(c.devices, &cachedDevice{Device: device})
+	}
+	return c
+}
+
+// handles returns the devices the collectors query, in the order of the
+// devices passed to newDeviceMetadataCache.
+func (c *deviceMetadataCache) handles() []Device {
+	handles := make([]Device, len(c.devices))
+	for i, d := range c.devices {
+		handles[i] = d
+	}
+	return handles
+}
+
+// begin starts caching for a round.
+func (c *deviceMetadataCache) begin() {
+	for _, d := range c.devices {
+		d.mu.Lock()
+		d.round = &deviceMetadata{links: make(map[int]cachedResult[nvml.EnableState])}
+		d.mu.Unlock()
+	}
+}
+
+// end discards the metadata of the round.
+func (c *deviceMetadataCache) end() {
+	for _, d := range c.devices {
+		d.mu.Lock()
+		d.round = nil
+		d.mu.Unlock()
+	}
+}
+
+// cachedResult is the outcome of an NVML query.
+type cachedResult[T any] struct {
+	value T
+	ret   nvml.Return
+}
+
+// deviceMetadata holds the queries answered in the current round. Failed
+// queries are kept too, so that every collector skips a GPU whose UUID could
+// not be read.
+type deviceMetadata struct {
+	uuid    *cachedResult[string]
+	pciInfo *cachedResult[nvml.PciInfo]
+	links   map[int]cachedResult[nvml.EnableState]
+}
+
+// cachedDevice serves metadata queries from the current round.
+type cachedDevice struct {
+	Device
+
+	mu sync.Mutex
+	// round is nil outside a round
+	round *deviceMetadata
+}
+
+func (d *cachedDevice) unwrap() Device {
+	return d.Device
+}
+
+func (d *cachedDevice) GetUUID() (string, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.round == nil {
+		return d.Device.GetUUID()
+	}
+	if d.round.uuid == nil {
+		uuid, ret := d.Device.GetUUID()
+		d.round.uuid = &cachedResult[string]{uuid, ret}
+	}
+	return d.round.uuid.value, d.round.uuid.ret
+}
+
+func (d *cachedDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.round == nil {
+		return d.Device.GetPciInfo()
+	}
+	if d.round.pciInfo == nil {
+		info, ret := d.Device.GetPciInfo()
+		d.round.pciInfo = &cachedResult[nvml.PciInfo]{info, ret}
+	}
+	return d.round.pciInfo.value, d.round.pciInfo.ret
+}
+
+func (d *cachedDevice) GetNvLinkState(link int) (nvml.EnableState, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.round == nil {
+		return d.Device.GetNvLinkState(link)
+	}
+	state, ok := d.round.links[link]
+	if !ok {
+		state.value, state.ret = d.Device.GetNvLinkState(link)
+		d.round.links[link] = state
+	}
+	return state.value, state.ret
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+// metadataCountingDevice counts the metadata queries that reach the device.
+type metadataCountingDevice struct {
+	*fakeDevice
+	uuidCalls, pciCalls, linkCalls int
+}
+
+func (d *metadataCountingDevice) GetUUID() (string, nvml.Return) {
+	d.uuidCalls++
+	return d.fakeDevice.GetUUID()
+}
+
+func (d *metadataCountingDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	d.pciCalls++
+	return d.fakeDevice.GetPciInfo()
+}
+
+func (d *metadataCountingDevice) GetNvLinkState(link int) (nvml.EnableState, nvml.Return) {
+	d.linkCalls++
+	return d.fakeDevice.GetNvLinkState(link)
+}
+
+func TestDeviceMetadataCacheServesRoundFromOneQuery(t *testing.T) {
+	assert := hammy.New(t)
+	device := &metadataCountingDevice{fakeDevice: &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", links: map[int]bool{0: true}}}
+	cache := newDeviceMetadataCache([]Device{device})
+	handle := cache.handles()[0]
+
+	cache.begin()
+	for range 3 {
+		uuid, _ := handle.GetUUID()
+		assert.Is(hammy.String(uuid).EqualTo("GPU-0"))
+		info, _ := handle.GetPciInfo()
+		assert.Is(hammy.String(pciBusID(info)).EqualTo("0000:18:00.0"))
+		state, _ := handle.GetNvLinkState(0)
+		assert.Is(hammy.Number(state).EqualTo(nvml.FEATURE_ENABLED))
+	}
+	handle.GetNvLinkState(1)
+	assert.Is(hammy.Number(device.uuidCalls).EqualTo(1))
+	assert.Is(hammy.Number(device.pciCalls).EqualTo(1))
+	assert.Is(hammy.Number(device.linkCalls).EqualTo(2))
+
+	// A link that goes down during the round keeps its state until the next one
+	device.links[0] = false
+	state, _ := handle.GetNvLinkState(0)
+	assert.Is(hammy.Number(state).EqualTo(nvml.FEATURE_ENABLED))
+	cache.end()
+
+	// Between rounds, queries go to NVML
+	state, _ = handle.GetNvLinkState(0)
+	assert.Is(hammy.Number(state).EqualTo(nvml.FEATURE_DISABLED))
+	handle.GetUUID()
+	assert.Is(hammy.Number(device.uuidCalls).EqualTo(2))
+
+	cache.begin()
+	handle.GetUUID()
+	cache.end()
+	assert.Is(hammy.Number(device.uuidCalls).EqualTo(3))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var dramActivity = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dram_activity_ratio",
+		Help:      "Fraction (0-1) of the driver's last sample period during which device memory was being read or written.",
+	},
+	[]string{"UUID", "pci_bus_id"},
+)
+
+// collectDramActivity exports the memory utilization of every GPU. GPU
+// utilization alone reads 100% whenever a kernel runs, even when the kernel
+// spends its time waiting on memory; a high DRAM activity next to it marks the
+// job as memory-bound.
+func collectDramActivity(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		rates, ret := device.GetUtilizationRates()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get utilization rates", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+		dramActivity.WithLabelValues(uuid, pciBusId).Set(float64(rates.Memory) / 100)
+	}
+}
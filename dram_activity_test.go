@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// utilizationDevice reports fixed utilization rates.
+type utilizationDevice struct {
+	fakeDevice
+	rates nvml.Utilization
+	ret   nvml.Return
+}
+
+func (d *utilizationDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	return d.rates, d.ret
+}
+
+func TestCollectDramActivity(t *testing.T) {
+	assert := hammy.New(t)
+	dramActivity.Reset()
+	t.Cleanup(dramActivity.Reset)
+
+	devices := []Device{
+		&utilizationDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, rates: nvml.Utilization{Gpu: 100, Memory: 85}},
+		&utilizationDevice{fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:2a:00.0"}, ret: nvml.ERROR_NOT_SUPPORTED},
+	}
+	collectDramActivity(devices, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(dramActivity.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(0.85))
+	assert.Is(hammy.Number(testutil.CollectAndCount(dramActivity)).EqualTo(1))
+}
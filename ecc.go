@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eccSramAggregateUncorrectable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ecc_sram_aggregate_uncorrectable_errors",
+			Help:      "Lifetime SRAM uncorrectable ECC error count by error type (parity, sec_ded).",
+		},
+		[]string{"UUID", "pci_bus_id", "error_type"},
+	)
+
+	eccSramAggregateUncorrectableDelta = deltaSource{
+		collector: eccSramAggregateUncorrectable,
+		name:      namespace + "_ecc_sram_aggregate_uncorrectable_errors_delta",
+		help:      "Increase of nvgpu_ecc_sram_aggregate_uncorrectable_errors over the last collection round.",
+		labels:    []string{"UUID", "pci_bus_id", "error_type"},
+	}
+
+	eccSramAggregateUncorrectableBucket = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ecc_sram_aggregate_uncorrectable_bucket_errors",
+			Help:      "Lifetime SRAM uncorrectable ECC error count by hardware unit (l2, sm, pcie, mcu, other).",
+		},
+		[]string{"UUID", "pci_bus_id", "bucket"},
+	)
+
+	eccSramThresholdExceeded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ecc_sram_threshold_exceeded",
+			Help:      "Whether the SRAM uncorrectable error threshold used for RMA has been exceeded (1 = exceeded, 0 = not exceeded).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+)
+
+// collectEccSramStatus collects the SRAM ECC error status (Hopper and newer) for all devices
+func collectEccSramStatus(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		status, ret := device.GetSramEccErrorStatus()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get SRAM ECC error status", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		eccSramAggregateUncorrectable.WithLabelValues(uuid, pciBusId, "parity").Set(float64(status.AggregateUncParity))
+		eccSramAggregateUncorrectable.WithLabelValues(uuid, pciBusId, "sec_ded").Set(float64(status.AggregateUncSecDed))
+
+		eccSramAggregateUncorrectableBucket.WithLabelValues(uuid, pciBusId, "l2").Set(float64(status.AggregateUncBucketL2))
+		eccSramAggregateUncorrectableBucket.WithLabelValues(uuid, pciBusId, "sm").Set(float64(status.AggregateUncBucketSm))
+		eccSramAggregateUncorrectableBucket.WithLabelValues(uuid, pciBusId, "pcie").Set(float64(status.AggregateUncBucketPcie))
+		eccSramAggregateUncorrectableBucket.WithLabelValues(uuid, pciBusId, "mcu").Set(float64(status.AggregateUncBucketMcu))
+		eccSramAggregateUncorrectableBucket.WithLabelValues(uuid, pciBusId, "other").Set(float64(status.AggregateUncBucketOther))
+
+		eccSramThresholdExceeded.WithLabelValues(uuid, pciBusId).Set(flagToGauge(status.BThresholdExceeded != 0))
+	}
+}
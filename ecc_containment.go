@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Xids the driver raises for an uncorrectable ECC error on Ampere and newer:
+// 94 when it contained the error to the affected application, 95 when it
+// could not and every application on the GPU is affected.
+const (
+	xidContainedEccError   = 94
+	xidUncontainedEccError = 95
+)
+
+var eccContainment = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ecc_error_containment",
+		Help:      "Whether the GPU had a contained or uncontained (containment) uncorrectable ECC error since its last reset and runs degraded until it is reset (1 = yes).",
+	},
+	[]string{"UUID", "pci_bus_id", "containment"},
+)
+
+// eccContainmentTracker keeps, per GPU, the containment of the uncorrectable
+// ECC errors since the last reset. An error is learned from its Xid as it
+// happens, or from the volatile double bit error count while the driver waits
+// for a reset, which also covers errors from before the exporter started. A
+// nil *eccContainmentTracker is valid and ignores every signal.
+type eccContainmentTracker struct {
+	mu   sync.Mutex
+	gpus map[string]*eccContainmentState
+}
+
+// eccContainmentState holds what is known of the uncorrectable ECC errors of a
+// GPU since its last reset.
+type eccContainmentState struct {
+	contained   bool
+	uncontained bool
+	// volatileDbe is the volatile double bit error count of the last round;
+	// the driver clears it on a reset
+	volatileDbe uint64
+}
+
+func newEccContainmentTracker() *eccContainmentTracker {
+	return &eccContainmentTracker{gpus: make(map[string]*eccContainmentState)}
+}
+
+func (t *eccContainmentTracker) gpu(uuid string) *eccContainmentState {
+	state, ok := t.gpus[uuid]
+	if !ok {
+		state = &eccContainmentState{}
+		t.gpus[uuid] = state
+	}
+	return state
+}
+
+// reportXid records an Xid event on GPU uuid.
+func (t *eccContainmentTracker) reportXid(uuid string, xid uint64) {
+	if t == nil || (xid != xidContainedEccError && xid != xidUncontainedEccError) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.gpu(uuid)
+	if xid == xidContainedEccError {
+		state.contained = true
+	} else {
+		state.uncontained = true
+	}
+}
+
+// reset forgets the errors of GPU uuid after it was reset.
+func (t *eccContainmentTracker) reset(uuid string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.gpus, uuid)
+}
+
+// collect combines the Xids with the ECC data of every GPU and exports the
+// containment state.
+func (t *eccContainmentTracker) collect(devices []Device, logger *slog.Logger) {
+	if t == nil {
+		return
+	}
+
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		values := []nvml.FieldValue{{FieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}}
+		dbe, dbeOk := uint64(0), false
+		if ret := device.GetFieldValues(values); !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get volatile double bit ECC errors", "uuid", uuid, "error", nvml.ErrorString(ret))
+		} else if errors.Is(nvml.Return(values[0].NvmlReturn), nvml.SUCCESS) {
+			v, err := fieldValueToUint64(values[0])
+			if err != nil {
+				logger.Warn("failed to decode volatile double bit ECC errors", "uuid", uuid, "err", err)
+			} else {
+				dbe, dbeOk = v, true
+			}
+		}
+		pending := dbeOk && dbe > 0 && resetRequired(device, uuid, logger)
+
+		t.mu.Lock()
+		state := t.gpu(uuid)
+		if dbeOk {
+			if dbe < state.volatileDbe {
+				// The volatile count was cleared, so the GPU was reset
+				state.contained = false
+				state.uncontained = false
+			}
+			state.volatileDbe = dbe
+		}
+		contained := state.contained || pending
+		uncontained := state.uncontained
+		t.mu.Unlock()
+
+		eccContainment.WithLabelValues(uuid, pciBusId, "contained").Set(flagToGauge(contained))
+		eccContainment.WithLabelValues(uuid, pciBusId, "uncontained").Set(flagToGauge(uncontained))
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEccContainmentFromXids(t *testing.T) {
+	assert := hammy.New(t)
+	eccContainment.Reset()
+	t.Cleanup(eccContainment.Reset)
+
+	device := &resetDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fields: map[nvlinkFieldKey]uint64{
+		{fieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}: 0,
+	}}}
+	tracker := newEccContainmentTracker()
+	state := func(containment string) float64 {
+		return testutil.ToFloat64(eccContainment.WithLabelValues("GPU-0", "0000:18:00.0", containment))
+	}
+
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(state("contained")).EqualTo(0))
+	assert.Is(hammy.Number(state("uncontained")).EqualTo(0))
+
+	tracker.reportXid("GPU-0", 79)
+	tracker.reportXid("GPU-0", xidContainedEccError)
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}] = 1
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(state("contained")).EqualTo(1))
+	assert.Is(hammy.Number(state("uncontained")).EqualTo(0))
+
+	tracker.reportXid("GPU-0", xidUncontainedEccError)
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(state("uncontained")).EqualTo(1))
+
+	// A reset clears the volatile count and the state with it
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}] = 0
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(state("contained")).EqualTo(0))
+	assert.Is(hammy.Number(state("uncontained")).EqualTo(0))
+}
+
+func TestEccContainmentFromEccData(t *testing.T) {
+	assert := hammy.New(t)
+	eccContainment.Reset()
+	t.Cleanup(eccContainment.Reset)
+
+	// Errors from before the exporter started that still wait for a reset
+	device := &resetDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fields: map[nvlinkFieldKey]uint64{
+		{fieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}: 2,
+	}}, remapPending: true}
+	tracker := newEccContainmentTracker()
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(eccContainment.WithLabelValues("GPU-0", "0000:18:00.0", "contained"))).EqualTo(1))
+
+	device.remapPending = false
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(eccContainment.WithLabelValues("GPU-0", "0000:18:00.0", "contained"))).EqualTo(0))
+}
+
+func TestResetClearsEccContainment(t *testing.T) {
+	assert := hammy.New(t)
+	eccContainment.Reset()
+	gpuResets.Reset()
+	t.Cleanup(eccContainment.Reset)
+	t.Cleanup(gpuResets.Reset)
+
+	device := &resetDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}}
+	tracker := newEccContainmentTracker()
+	resets := newResetCollector()
+	resets.containment = tracker
+
+	resets.collectResets([]Device{device}, discardLogger())
+	tracker.reportXid("GPU-0", xidUncontainedEccError)
+	device.uuidRet = nvml.ERROR_GPU_IS_LOST
+	resets.collectResets([]Device{device}, discardLogger())
+	device.uuidRet = nvml.SUCCESS
+	resets.collectResets([]Device{device}, discardLogger())
+
+	tracker.collect([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(eccContainment.WithLabelValues("GPU-0", "0000:18:00.0", "uncontained"))).EqualTo(0))
+}
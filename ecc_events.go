@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// eccEventTypes are the NVML events raised for every ECC error as it happens.
+const eccEventTypes = uint64(nvml.EventTypeSingleBitEccError | nvml.EventTypeDoubleBitEccError)
+
+var eccEvents = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ecc_events_total",
+		Help:      "Total ECC error events seen since exporter start by type (sbe = single bit, dbe = double bit).",
+	},
+	[]string{"UUID", "pci_bus_id", "type"},
+)
+
+var eccEventsDelta = deltaSource{
+	collector: eccEvents,
+	name:      namespace + "_ecc_events_delta",
+	help:      "Increase of nvgpu_ecc_events_total over the last collection round.",
+	labels:    []string{"UUID", "pci_bus_id", "type"},
+}
+
+// handleEccEvent increments the ECC event counter for a single or double bit
+// ECC error event.
+func handleEccEvent(event Event, logger *slog.Logger) {
+	uuid, ret := event.Device.GetUUID()
+	if !errors.Is(ret, nvml.SUCCESS) {
+		logger.Warn("failed to get UUID for device in ECC event", "error", nvml.ErrorString(ret))
+		return
+	}
+
+	pciInfo, ret := event.Device.GetPciInfo()
+	if !errors.Is(ret, nvml.SUCCESS) {
+		logger.Warn("failed to get PCI info for device in ECC event", "error", nvml.ErrorString(ret))
+		return
+	}
+	pciBusId := pciBusID(pciInfo)
+
+	if event.EventType&nvml.EventTypeSingleBitEccError != 0 {
+		eccEvents.WithLabelValues(uuid, pciBusId, "sbe").Inc()
+	}
+	if event.EventType&nvml.EventTypeDoubleBitEccError != 0 {
+		eccEvents.WithLabelValues(uuid, pciBusId, "dbe").Inc()
+		logger.Warn("double bit ECC error detected", "uuid", uuid, "pci_bus_id", pciBusId)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// noEccDevice is a GPU without ECC, which rejects ECC event registrations.
+type noEccDevice struct {
+	fakeDevice
+}
+
+func (d *noEccDevice) RegisterEvents(eventTypes uint64, set EventSet) nvml.Return {
+	if eventTypes&eccEventTypes != 0 {
+		return nvml.ERROR_NOT_SUPPORTED
+	}
+	return d.fakeDevice.RegisterEvents(eventTypes, set)
+}
+
+func TestHandleEccEvent(t *testing.T) {
+	assert := hammy.New(t)
+	eccEvents.Reset()
+	t.Cleanup(eccEvents.Reset)
+
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}
+	handleEccEvent(Event{Device: device, EventType: nvml.EventTypeSingleBitEccError}, discardLogger())
+	handleEccEvent(Event{Device: device, EventType: nvml.EventTypeSingleBitEccError}, discardLogger())
+	handleEccEvent(Event{Device: device, EventType: nvml.EventTypeDoubleBitEccError}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(eccEvents.WithLabelValues("GPU-0", "0000:18:00.0", "sbe"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(eccEvents.WithLabelValues("GPU-0", "0000:18:00.0", "dbe"))).EqualTo(1))
+}
+
+func TestStartXidEventCollectorEccEvents(t *testing.T) {
+	assert := hammy.New(t)
+	resetXidMetric(t)
+	eccEvents.Reset()
+	t.Cleanup(eccEvents.Reset)
+
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}
+	noEcc := &noEccDevice{fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0"}}
+	client := &fakeClient{devices: []Device{device, noEcc}}
+	devices, _, err := New(client, nil, discardLogger())
+	assert.Is(hammy.NilError(err))
+
+	cfg := &Config{XidWaitTimeout: 10 * time.Millisecond, XidEventShards: 1}
+	err = startXidEventCollector(devices, cfg, nil, nil, nil, nil, prometheus.NewRegistry(), discardLogger())
+	assert.Is(hammy.NilError(err))
+	// The GPU without ECC still receives Xid events
+	assert.Is(hammy.Number(len(client.eventSets[0].registered)).EqualTo(2))
+
+	client.eventSets[0].events <- Event{Device: device, EventType: nvml.EventTypeSingleBitEccError}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.CollectAndCount(eccEvents) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Is(hammy.Number(testutil.ToFloat64(eccEvents.WithLabelValues("GPU-0", "0000:18:00.0", "sbe"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(xidErrors)).EqualTo(0))
+}
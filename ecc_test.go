@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// sramEccDevice is a GPU reporting a fixed SRAM ECC error status.
+type sramEccDevice struct {
+	fakeDevice
+	status nvml.EccSramErrorStatus
+	ret    nvml.Return
+}
+
+func (d *sramEccDevice) GetSramEccErrorStatus() (nvml.EccSramErrorStatus, nvml.Return) {
+	return d.status, d.ret
+}
+
+func resetEccSramMetrics() {
+	eccSramAggregateUncorrectable.Reset()
+	eccSramAggregateUncorrectableBucket.Reset()
+	eccSramThresholdExceeded.Reset()
+}
+
+func TestCollectEccSramStatus(t *testing.T) {
+	assert := hammy.New(t)
+	resetEccSramMetrics()
+	t.Cleanup(resetEccSramMetrics)
+
+	devices := []Device{
+		&sramEccDevice{
+			fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+			status: nvml.EccSramErrorStatus{
+				AggregateUncParity:      3,
+				AggregateUncSecDed:      1,
+				AggregateUncBucketL2:    2,
+				AggregateUncBucketSm:    1,
+				AggregateUncBucketOther: 1,
+				BThresholdExceeded:      1,
+			},
+			ret: nvml.SUCCESS,
+		},
+		// Ampere has no SRAM ECC error status
+		&sramEccDevice{fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:2A:00.0"}, ret: nvml.ERROR_NOT_SUPPORTED},
+	}
+	collectEccSramStatus(devices, discardLogger())
+
+	err := testutil.CollectAndCompare(eccSramAggregateUncorrectable, strings.NewReader(`
+# HELP nvgpu_ecc_sram_aggregate_uncorrectable_errors Lifetime SRAM uncorrectable ECC error count by error type (parity, sec_ded).
+# TYPE nvgpu_ecc_sram_aggregate_uncorrectable_errors gauge
+nvgpu_ecc_sram_aggregate_uncorrectable_errors{UUID="GPU-0",error_type="parity",pci_bus_id="0000:18:00.0"} 3
+nvgpu_ecc_sram_aggregate_uncorrectable_errors{UUID="GPU-0",error_type="sec_ded",pci_bus_id="0000:18:00.0"} 1
+`))
+	assert.Is(hammy.NilError(err))
+
+	err = testutil.CollectAndCompare(eccSramAggregateUncorrectableBucket, strings.NewReader(`
+# HELP nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors Lifetime SRAM uncorrectable ECC error count by hardware unit (l2, sm, pcie, mcu, other).
+# TYPE nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors gauge
+nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors{UUID="GPU-0",bucket="l2",pci_bus_id="0000:18:00.0"} 2
+nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors{UUID="GPU-0",bucket="mcu",pci_bus_id="0000:18:00.0"} 0
+nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors{UUID="GPU-0",bucket="other",pci_bus_id="0000:18:00.0"} 1
+nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors{UUID="GPU-0",bucket="pcie",pci_bus_id="0000:18:00.0"} 0
+nvgpu_ecc_sram_aggregate_uncorrectable_bucket_errors{UUID="GPU-0",bucket="sm",pci_bus_id="0000:18:00.0"} 1
+`))
+	assert.Is(hammy.NilError(err))
+
+	err = testutil.CollectAndCompare(eccSramThresholdExceeded, strings.NewReader(`
+# HELP nvgpu_ecc_sram_threshold_exceeded Whether the SRAM uncorrectable error threshold used for RMA has been exceeded (1 = exceeded, 0 = not exceeded).
+# TYPE nvgpu_ecc_sram_threshold_exceeded gauge
+nvgpu_ecc_sram_threshold_exceeded{UUID="GPU-0",pci_bus_id="0000:18:00.0"} 1
+`))
+	assert.Is(hammy.NilError(err))
+}
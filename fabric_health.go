@@ -46,6 +46,15 @@ var (
 		[]string{"UUID", "pci_bus_id", "clique_id", "cluster_uuid"},
 	)
 
+	fabricHealthMask = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "fabric_health_mask",
+			Help:      "Raw GPU fabric health mask as reported by NVML, including bits this exporter does not decode yet.",
+		},
+		[]string{"UUID", "pci_bus_id", "clique_id", "cluster_uuid"},
+	)
+
 	fabricIncorrectConfig = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -54,10 +63,48 @@ var (
 		},
 		[]string{"UUID", "pci_bus_id", "clique_id", "cluster_uuid"},
 	)
+
+	fabricIncorrectConfigCause = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "fabric_incorrect_configuration_cause",
+			Help:      "Whether GPU fabric registration failed because of an incorrect configuration, per cause (1 = this cause, 0 = not this cause).",
+		},
+		[]string{"UUID", "pci_bus_id", "clique_id", "cluster_uuid", "cause"},
+	)
+
+	fabricCliqueMember = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "fabric_clique_member",
+			Help:      "Fabric clique and cluster a GPU has joined; the value is the number of other GPUs on this node in the same clique.",
+		},
+		[]string{"UUID", "pci_bus_id", "clique_id", "cluster_uuid"},
+	)
 )
 
+// incorrectConfigCauses names the incorrect configuration values of the fabric
+// health mask that describe why a GPU failed fabric registration.
+var incorrectConfigCauses = []struct {
+	value uint32
+	cause string
+}{
+	{nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_INCORRECT_SYSGUID, "incorrect_sysguid"},
+	{nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_INCORRECT_CHASSIS_SN, "incorrect_chassis_sn"},
+	{nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_NO_PARTITION, "no_partition"},
+	{nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_INSUFFICIENT_NVLINKS, "insufficient_nvlinks"},
+}
+
+// fabricMembership is the clique a GPU joined once fabric registration completed.
+type fabricMembership struct {
+	uuid, pciBusId, cliqueID, clusterUUID string
+}
+
 // collectFabricHealth collects GPU fabric health metrics for all devices
-func collectFabricHealth(devices []nvml.Device, logger *slog.Logger) {
+func collectFabricHealth(devices []Device, health *gpuHealthTracker, logger *slog.Logger) {
+	var members []fabricMembership
+	defer func() { setFabricCliqueMembers(members) }()
+
 	for _, device := range devices {
 		uuid, ret := device.GetUUID()
 		if !errors.Is(ret, nvml.SUCCESS) {
@@ -71,10 +118,10 @@ func collectFabricHealth(devices []nvml.Device, logger *slog.Logger) {
 			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
 			continue
 		}
-		pciBusId := pciBusIdToString(pciInfo.BusIdLegacy)
+		pciBusId := pciBusID(pciInfo)
 
 		// Get GPU fabric info - try V2 which includes health mask
-		fabricInfo, ret := device.GetGpuFabricInfoV().V2()
+		fabricInfo, ret := device.GetGpuFabricInfoV2()
 		if !errors.Is(ret, nvml.SUCCESS) {
 			logger.Warn("failed to get fabric info", "uuid", uuid, "error", nvml.ErrorString(ret))
 			continue
@@ -90,6 +137,15 @@ func collectFabricHealth(devices []nvml.Device, logger *slog.Logger) {
 		// Fabric status metric
 		fabricStatus.WithLabelValues(uuid, pciBusId, cliqueID, clusterUUID).Set(float64(fabricInfo.Status))
 
+		// Clique and cluster are only assigned once registration completed
+		if fabricInfo.State == nvml.GPU_FABRIC_STATE_COMPLETED {
+			members = append(members, fabricMembership{uuid, pciBusId, cliqueID, clusterUUID})
+		}
+
+		// Keep the undecoded mask too, so that bits added by newer drivers are
+		// in the history before the exporter learns to decode them
+		fabricHealthMask.WithLabelValues(uuid, pciBusId, cliqueID, clusterUUID).Set(float64(fabricInfo.HealthMask))
+
 		// Extract health status bits from the health mask
 		// Based on NVML documentation, the health mask contains various health indicators
 		// We'll extract the common health fields using bit operations
@@ -113,10 +169,32 @@ func collectFabricHealth(devices []nvml.Device, logger *slog.Logger) {
 		// Incorrect configuration (bits 8-21)
 		incorrectConfig := (fabricInfo.HealthMask >> 8) & 0x3FFF
 		fabricIncorrectConfig.WithLabelValues(uuid, pciBusId, cliqueID, clusterUUID).Set(float64(incorrectConfig))
+		if incorrectConfig != nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_NOT_SUPPORTED {
+			for _, c := range incorrectConfigCauses {
+				fabricIncorrectConfigCause.WithLabelValues(uuid, pciBusId, cliqueID, clusterUUID, c.cause).Set(flagToGauge(incorrectConfig == c.value))
+			}
+		}
 
 		// Calculate health summary based on all health mask fields
 		healthSummary := calculateHealthSummary(degradedBw, routeRecovery, routeUnhealthy, accessTimeoutRecovery, incorrectConfig)
 		fabricHealthSummary.WithLabelValues(uuid, pciBusId, cliqueID, clusterUUID).Set(float64(healthSummary))
+		health.reportFabricHealth(uuid, healthSummary)
+	}
+}
+
+// setFabricCliqueMembers replaces nvgpu_fabric_clique_member with members, so
+// that a GPU that moved to another clique after a Fabric Manager restart does
+// not keep its old membership.
+func setFabricCliqueMembers(members []fabricMembership) {
+	cliques := make(map[[2]string]int, len(members))
+	for _, m := range members {
+		cliques[[2]string{m.clusterUUID, m.cliqueID}]++
+	}
+
+	fabricCliqueMember.Reset()
+	for _, m := range members {
+		peers := cliques[[2]string{m.clusterUUID, m.cliqueID}] - 1
+		fabricCliqueMember.WithLabelValues(m.uuid, m.pciBusId, m.cliqueID, m.clusterUUID).Set(float64(peers))
 	}
 }
 
@@ -139,6 +217,12 @@ func uuidBytesToString(uuid [16]uint8) string {
 		uuid[10], uuid[11], uuid[12], uuid[13], uuid[14], uuid[15])
 }
 
+// healthSummaryOfMask decodes a fabric health mask and returns its summary as
+// calculateHealthSummary does.
+func healthSummaryOfMask(mask uint32) uint32 {
+	return calculateHealthSummary(mask&0x3, (mask>>2)&0x3, (mask>>4)&0x3, (mask>>6)&0x3, (mask>>8)&0x3FFF)
+}
+
 // calculateHealthSummary determines the overall health summary based on individual health fields
 // Returns: 0=not_supported, 1=healthy, 2=unhealthy, 3=limited_capacity
 func calculateHealthSummary(degradedBw, routeRecovery, routeUnhealthy, accessTimeoutRecovery, incorrectConfig uint32) uint32 {
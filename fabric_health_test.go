@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func healthMask(degradedBw, routeRecovery, routeUnhealthy, accessTimeout, incorrectConfig uint32) uint32 {
+	return degradedBw<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_DEGRADED_BW |
+		routeRecovery<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_ROUTE_RECOVERY |
+		routeUnhealthy<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_ROUTE_UNHEALTHY |
+		accessTimeout<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_ACCESS_TIMEOUT_RECOVERY |
+		incorrectConfig<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_INCORRECT_CONFIGURATION
+}
+
+func TestCollectFabricHealth(t *testing.T) {
+	assert := hammy.New(t)
+	resetFabricMetrics(t)
+
+	healthy := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fabric: &nvml.GpuFabricInfo_v2{
+		CliqueId:   7,
+		State:      nvml.GPU_FABRIC_STATE_COMPLETED,
+		HealthMask: healthMask(2, 2, 2, 2, 1),
+	}}
+	unhealthy := &fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0", fabric: &nvml.GpuFabricInfo_v2{
+		CliqueId:   7,
+		State:      nvml.GPU_FABRIC_STATE_COMPLETED,
+		HealthMask: healthMask(2, 2, nvml.GPU_FABRIC_HEALTH_MASK_ROUTE_UNHEALTHY_TRUE, 2, 1),
+	}}
+	unsupported := &fakeDevice{uuid: "GPU-2", pciBusId: "0000:38:00.0"}
+
+	labeler := newNodeLabeler(nil, "node-a", nil, discardLogger())
+	health := newGpuHealthTracker(nil, labeler, nil, time.Hour)
+	collectFabricHealth([]Device{healthy, unhealthy, unsupported}, health, discardLogger())
+
+	clusterUUID := uuidBytesToString([16]uint8{})
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricHealthSummary.WithLabelValues("GPU-0", "0000:18:00.0", "7", clusterUUID))).EqualTo(float64(nvml.GPU_FABRIC_HEALTH_SUMMARY_HEALTHY)))
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricHealthSummary.WithLabelValues("GPU-1", "0000:28:00.0", "7", clusterUUID))).EqualTo(float64(nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY)))
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricState.WithLabelValues("GPU-0", "0000:18:00.0", "7", clusterUUID))).EqualTo(float64(nvml.GPU_FABRIC_STATE_COMPLETED)))
+	assert.Is(hammy.Number(testutil.CollectAndCount(fabricHealthSummary)).EqualTo(2))
+	assert.Is(hammy.True(labeler.desired()[labelFabricUnhealthy]))
+}
+
+func TestCollectFabricHealthRawMask(t *testing.T) {
+	assert := hammy.New(t)
+	resetFabricMetrics(t)
+
+	// Bit 30 is not decoded by the exporter
+	mask := healthMask(2, 2, 2, 2, 1) | 1<<30
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fabric: &nvml.GpuFabricInfo_v2{
+		CliqueId:   7,
+		State:      nvml.GPU_FABRIC_STATE_COMPLETED,
+		HealthMask: mask,
+	}}
+	collectFabricHealth([]Device{device}, nil, discardLogger())
+
+	clusterUUID := uuidBytesToString([16]uint8{})
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricHealthMask.WithLabelValues("GPU-0", "0000:18:00.0", "7", clusterUUID))).EqualTo(float64(mask)))
+}
+
+func TestCollectFabricHealthCliqueMembers(t *testing.T) {
+	assert := hammy.New(t)
+	resetFabricMetrics(t)
+
+	gpu := func(uuid string, clique uint32, state nvml.GpuFabricState) *fakeDevice {
+		return &fakeDevice{uuid: uuid, pciBusId: "0000:18:00.0", fabric: &nvml.GpuFabricInfo_v2{
+			CliqueId:   clique,
+			State:      uint8(state),
+			HealthMask: healthMask(2, 2, 2, 2, 1),
+		}}
+	}
+	devices := []Device{
+		gpu("GPU-0", 7, nvml.GPU_FABRIC_STATE_COMPLETED),
+		gpu("GPU-1", 7, nvml.GPU_FABRIC_STATE_COMPLETED),
+		gpu("GPU-2", 7, nvml.GPU_FABRIC_STATE_COMPLETED),
+		// Rejoined another partition after a Fabric Manager restart
+		gpu("GPU-3", 9, nvml.GPU_FABRIC_STATE_COMPLETED),
+		gpu("GPU-4", 0, nvml.GPU_FABRIC_STATE_IN_PROGRESS),
+	}
+	collectFabricHealth(devices, nil, discardLogger())
+
+	clusterUUID := uuidBytesToString([16]uint8{})
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricCliqueMember.WithLabelValues("GPU-0", "0000:18:00.0", "7", clusterUUID))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricCliqueMember.WithLabelValues("GPU-3", "0000:18:00.0", "9", clusterUUID))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(fabricCliqueMember)).EqualTo(4))
+
+	// Moving GPU-3 back drops its old membership
+	devices[3] = gpu("GPU-3", 7, nvml.GPU_FABRIC_STATE_COMPLETED)
+	collectFabricHealth(devices, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricCliqueMember.WithLabelValues("GPU-3", "0000:18:00.0", "7", clusterUUID))).EqualTo(3))
+	assert.Is(hammy.Number(testutil.CollectAndCount(fabricCliqueMember)).EqualTo(4))
+}
+
+func TestCollectFabricHealthIncorrectConfigCause(t *testing.T) {
+	tests := []struct {
+		name   string
+		config uint32
+		// cause set to 1, "" for none
+		want   string
+		series int
+	}{
+		{name: "not supported", config: nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_NOT_SUPPORTED, series: 0},
+		{name: "none", config: nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_NONE, series: 4},
+		{name: "incorrect chassis serial", config: nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_INCORRECT_CHASSIS_SN, want: "incorrect_chassis_sn", series: 4},
+		{name: "insufficient nvlinks", config: nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_INSUFFICIENT_NVLINKS, want: "insufficient_nvlinks", series: 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			resetFabricMetrics(t)
+
+			gpu := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fabric: &nvml.GpuFabricInfo_v2{
+				State:      nvml.GPU_FABRIC_STATE_COMPLETED,
+				HealthMask: healthMask(2, 2, 2, 2, tc.config),
+			}}
+			collectFabricHealth([]Device{gpu}, nil, discardLogger())
+
+			clusterUUID := uuidBytesToString([16]uint8{})
+			assert.Is(hammy.Number(testutil.CollectAndCount(fabricIncorrectConfigCause)).EqualTo(tc.series))
+			for _, c := range incorrectConfigCauses {
+				if tc.series == 0 {
+					break
+				}
+				got := testutil.ToFloat64(fabricIncorrectConfigCause.WithLabelValues("GPU-0", "0000:18:00.0", "0", clusterUUID, c.cause))
+				assert.Is(hammy.Number(got).EqualTo(flagToGauge(c.cause == tc.want)))
+			}
+		})
+	}
+}
+
+func TestCalculateHealthSummary(t *testing.T) {
+	tests := []struct {
+		name string
+		mask uint32
+		want uint32
+	}{
+		{name: "not supported", mask: 0, want: nvml.GPU_FABRIC_HEALTH_SUMMARY_NOT_SUPPORTED},
+		{name: "healthy", mask: healthMask(2, 2, 2, 2, 1), want: nvml.GPU_FABRIC_HEALTH_SUMMARY_HEALTHY},
+		{name: "degraded bandwidth", mask: healthMask(1, 2, 2, 2, 1), want: nvml.GPU_FABRIC_HEALTH_SUMMARY_LIMITED_CAPACITY},
+		{name: "route recovery", mask: healthMask(2, 1, 2, 2, 1), want: nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY},
+		{name: "incorrect configuration", mask: healthMask(2, 2, 2, 2, 5), want: nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			got := calculateHealthSummary(tc.mask&0x3, (tc.mask>>2)&0x3, (tc.mask>>4)&0x3, (tc.mask>>6)&0x3, (tc.mask>>8)&0x3FFF)
+			assert.Is(hammy.Number(got).EqualTo(tc.want))
+		})
+	}
+}
+
+func resetFabricMetrics(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		fabricHealth.Reset()
+		fabricState.Reset()
+		fabricStatus.Reset()
+		fabricHealthSummary.Reset()
+		fabricHealthMask.Reset()
+		fabricIncorrectConfig.Reset()
+		fabricIncorrectConfigCause.Reset()
+		fabricCliqueMember.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fabricManagerComm is the process name of nv-fabricmanager as truncated by
+// the kernel to 15 characters.
+const fabricManagerComm = "nv-fabricmanage"
+
+// livenessDialTimeout bounds the connection checks of the fabric manager and
+// device plugin sockets.
+const livenessDialTimeout = time.Second
+
+var fabricManagerUp = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "fabric_manager_up",
+		Help:      "Whether the NVIDIA fabric manager runs, or with -fabric-manager-address accepts connections (1 = up, 0 = down). Only exported on nodes whose GPUs are part of an NVSwitch fabric.",
+	},
+)
+
+// collectFabricManager checks whether the fabric manager is alive. When
+// nv-fabricmanager dies the GPUs keep their last fabric state, so the fabric
+// metrics freeze instead of pointing at the culprit.
+func collectFabricManager(procPath, addr string, logger *slog.Logger) {
+	if addr != "" {
+		fabricManagerUp.Set(flagToGauge(dialFabricManager(addr, logger)))
+		return
+	}
+
+	running, err := processRunning(procPath, fabricManagerComm)
+	if err != nil {
+		logger.Debug("failed to look for nv-fabricmanager", "proc_path", procPath, "err", err)
+		return
+	}
+	fabricManagerUp.Set(flagToGauge(running))
+}
+
+// fabricAttached reports whether any GPU is part of an NVSwitch fabric. Nodes
+// without one do not run a fabric manager.
+func fabricAttached(devices []Device) bool {
+	for _, device := range devices {
+		info, ret := device.GetGpuFabricInfoV2()
+		if errors.Is(ret, nvml.SUCCESS) && info.State != nvml.GPU_FABRIC_STATE_NOT_SUPPORTED {
+			return true
+		}
+	}
+	return false
+}
+
+// dialFabricManager reports whether the fabric manager accepts a connection
+// on addr, either host:port or unix:<path>.
+func dialFabricManager(addr string, logger *slog.Logger) bool {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+	}
+
+	conn, err := net.DialTimeout(network, addr, livenessDialTimeout)
+	if err != nil {
+		logger.Debug("fabric manager does not accept connections", "address", addr, "err", err)
+		return false
+	}
+	conn.Close()
+	return true
+}
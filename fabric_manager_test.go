@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectFabricManager(t *testing.T) {
+	tests := []struct {
+		name     string
+		comms    []string
+		expected float64
+	}{
+		{name: "running", comms: []string{"bash", fabricManagerComm}, expected: 1},
+		{name: "dead", comms: []string{"bash"}, expected: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			collectFabricManager(writeProc(t, tc.comms...), "", discardLogger())
+			assert.Is(hammy.Number(testutil.ToFloat64(fabricManagerUp)).EqualTo(tc.expected))
+		})
+	}
+}
+
+func TestCollectFabricManagerDialsAddress(t *testing.T) {
+	assert := hammy.New(t)
+	socket := filepath.Join(t.TempDir(), "fm.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.Is(hammy.NilError(err))
+
+	collectFabricManager(t.TempDir(), "unix:"+socket, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricManagerUp)).EqualTo(1))
+
+	listener.Close()
+	collectFabricManager(t.TempDir(), "unix:"+socket, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(fabricManagerUp)).EqualTo(0))
+}
+
+func TestFabricAttached(t *testing.T) {
+	assert := hammy.New(t)
+	pcie := &fakeDevice{uuid: "GPU-0"}
+	nvswitch := &fakeDevice{uuid: "GPU-1", fabric: &nvml.GpuFabricInfo_v2{State: nvml.GPU_FABRIC_STATE_COMPLETED}}
+
+	assert.Is(hammy.False(fabricAttached([]Device{pcie})))
+	assert.Is(hammy.True(fabricAttached([]Device{pcie, nvswitch})))
+}
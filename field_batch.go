@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldValueCalls = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exporter_field_value_calls_total",
+		Help:      "Number of GetFieldValues calls made to NVML by the collectors, batched or not.",
+	},
+)
+
+// fieldKey identifies a field value request.
+type fieldKey struct {
+	fieldId uint32
+	scopeId uint32
+}
+
+// fieldBatch reads every field the collectors of a round ask of a GPU in a
+// single GetFieldValues call at the start of the round and serves their
+// requests from the result, instead of one driver round-trip per collector.
+// The fields of a round are those asked for in the previous round, so a
+// request for a field that is new, e.g. of a link that just came up, is
+// passed through to NVML once and batched from the next round on.
+type fieldBatch struct {
+	devices []*batchedDevice
+}
+
+func newFieldBatch(devices []Device) *fieldBatch {
+	b := &fieldBatch{}
+	for _, device := range devices {
+		b.devices = append(b.devices, &batchedDevice{Device: device, wanted: make(map[fieldKey]bool)})
+	}
+	return b
+}
+
+// handles returns the devices the collectors query, in the order of the
+// devices passed to newFieldBatch.
+func (b *fieldBatch) handles() []Device {
+	handles := make([]Device, len(b.devices))
+	for i, d := range b.devices {
+		handles[i] = d
+	}
+	return handles
+}
+
+// begin reads the fields of the round on every GPU. A GPU whose batched read
+// fails is queried directly by each collector, which then reports the error.
+func (b *fieldBatch) begin(logger *slog.Logger) {
+	for _, d := range b.devices {
+		d.prefetch(logger)
+	}
+}
+
+// end discards the values of the round, so that queries made between rounds,
+// e.g. by HTTP handlers, read NVML.
+func (b *fieldBatch) end() {
+	for _, d := range b.devices {
+		d.mu.Lock()
+		d.values = nil
+		d.mu.Unlock()
+	}
+}
+
+// batchedDevice serves field value requests from the batched read of the
+// current round.
+type batchedDevice struct {
+	Device
+
+	mu sync.Mutex
+	// wanted holds the fields requested in the last round
+	wanted map[fieldKey]bool
+	// values holds the fields read at the start of the round, nil outside a
+	// round
+	values map[fieldKey]nvml.FieldValue
+}
+
+func (d *batchedDevice) unwrap() Device {
+	return d.Device
+}
+
+func (d *batchedDevice) prefetch(logger *slog.Logger) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	requests := make([]nvml.FieldValue, 0, len(d.wanted))
+	for key := range d.wanted {
+		requests = append(requests, nvml.FieldValue{FieldId: key.fieldId, ScopeId: key.scopeId})
+	}
+	clear(d.wanted)
+	d.values = make(map[fieldKey]nvml.FieldValue, len(requests))
+	if len(requests) == 0 {
+		return
+	}
+
+	fieldValueCalls.Inc()
+	if ret := d.Device.GetFieldValues(requests); !errors.Is(ret, nvml.SUCCESS) {
+		logger.Debug("batched field value read failed, falling back to direct reads", "fields", len(requests), "error", nvml.ErrorString(ret))
+		return
+	}
+	for _, fv := range requests {
+		d.values[fieldKey{fv.FieldId, fv.ScopeId}] = fv
+	}
+}
+
+// GetFieldValues fills values from the batched read when it has all of them
+// and otherwise reads them from NVML.
+func (d *batchedDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	d.mu.Lock()
+	batched := d.values != nil
+	for i := range values {
+		key := fieldKey{values[i].FieldId, values[i].ScopeId}
+		if d.values != nil {
+			d.wanted[key] = true
+		}
+		if fv, ok := d.values[key]; ok {
+			values[i] = fv
+		} else {
+			batched = false
+		}
+	}
+	d.mu.Unlock()
+	if batched {
+		return nvml.SUCCESS
+	}
+
+	fieldValueCalls.Inc()
+	return d.Device.GetFieldValues(values)
+}
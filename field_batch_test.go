@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+// countingDevice counts the GetFieldValues calls that reach the device.
+type countingDevice struct {
+	*fakeDevice
+	calls int
+}
+
+func (d *countingDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	d.calls++
+	return d.fakeDevice.GetFieldValues(values)
+}
+
+func readField(t *testing.T, device Device, fieldId, link int) uint64 {
+	t.Helper()
+	values := []nvml.FieldValue{{FieldId: uint32(fieldId), ScopeId: uint32(link)}}
+	if ret := device.GetFieldValues(values); ret != nvml.SUCCESS {
+		t.Fatalf("GetFieldValues returned %v", nvml.ErrorString(ret))
+	}
+	v, err := fieldValueToUint64(values[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestFieldBatchReadsRoundInOneCall(t *testing.T) {
+	assert := hammy.New(t)
+	device := &countingDevice{fakeDevice: &fakeDevice{fields: map[nvlinkFieldKey]uint64{
+		{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, link: 0}: 3,
+		{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_CRC, link: 1}:    5,
+	}}}
+	batch := newFieldBatch([]Device{device})
+	handle := batch.handles()[0]
+
+	// The first round learns which fields the collectors read
+	batch.begin(discardLogger())
+	readField(t, handle, nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, 0)
+	readField(t, handle, nvml.FI_DEV_NVLINK_ERROR_DL_CRC, 1)
+	batch.end()
+	assert.Is(hammy.Number(device.calls).EqualTo(2))
+
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, link: 0}] = 4
+	batch.begin(discardLogger())
+	assert.Is(hammy.Number(device.calls).EqualTo(3))
+	assert.Is(hammy.Number(readField(t, handle, nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, 0)).EqualTo(4))
+	assert.Is(hammy.Number(readField(t, handle, nvml.FI_DEV_NVLINK_ERROR_DL_CRC, 1)).EqualTo(5))
+	assert.Is(hammy.Number(device.calls).EqualTo(3))
+
+	// A field that was not read last round is passed through
+	handle.GetFieldValues([]nvml.FieldValue{{FieldId: nvml.FI_DEV_NVLINK_ERROR_DL_CRC}})
+	batch.end()
+	assert.Is(hammy.Number(device.calls).EqualTo(4))
+
+	// Between rounds, values come from NVML
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_CRC, link: 1}] = 6
+	assert.Is(hammy.Number(readField(t, handle, nvml.FI_DEV_NVLINK_ERROR_DL_CRC, 1)).EqualTo(6))
+	assert.Is(hammy.Number(device.calls).EqualTo(5))
+}
+
+func TestFieldBatchFallsBackWhenBatchFails(t *testing.T) {
+	assert := hammy.New(t)
+	device := &countingDevice{fakeDevice: &fakeDevice{fields: map[nvlinkFieldKey]uint64{
+		{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, link: 0}: 3,
+	}}}
+	batch := newFieldBatch([]Device{device})
+	handle := batch.handles()[0]
+
+	batch.begin(discardLogger())
+	readField(t, handle, nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, 0)
+	batch.end()
+
+	device.fieldsRet = nvml.ERROR_TIMEOUT
+	batch.begin(discardLogger())
+	values := []nvml.FieldValue{{FieldId: nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY}}
+	ret := handle.GetFieldValues(values)
+	batch.end()
+
+	assert.Is(hammy.Number(ret).EqualTo(nvml.ERROR_TIMEOUT))
+	assert.Is(hammy.Number(device.calls).EqualTo(3))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// fieldDumpAPIPath is the route of the raw field value dump.
+const fieldDumpAPIPath = "GET /-/debug/fields"
+
+// fieldDumpList is the comma separated list of fields to dump, usable as a
+// flag.Value. An entry is a field ID, with an optional :<scope ID> (e.g. the
+// link of an NVLink field) or :* for the scopes of every NVLink.
+type fieldDumpList []fieldKey
+
+func (l *fieldDumpList) String() string {
+	parts := make([]string, 0, len(*l))
+	for _, key := range *l {
+		parts = append(parts, fmt.Sprintf("%d:%d", key.fieldId, key.scopeId))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *fieldDumpList) Set(value string) error {
+	var keys fieldDumpList
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, scope, hasScope := strings.Cut(part, ":")
+		fieldId, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid field ID %q: %w", id, err)
+		}
+		switch {
+		case !hasScope:
+			keys = append(keys, fieldKey{fieldId: uint32(fieldId)})
+		case scope == "*":
+			for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+				keys = append(keys, fieldKey{fieldId: uint32(fieldId), scopeId: uint32(link)})
+			}
+		default:
+			scopeId, err := strconv.ParseUint(scope, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid scope ID %q of field %d: %w", scope, fieldId, err)
+			}
+			keys = append(keys, fieldKey{fieldId: uint32(fieldId), scopeId: uint32(scopeId)})
+		}
+	}
+	*l = keys
+	return nil
+}
+
+// rawFieldValue is an nvml.FieldValue as NVML returned it, for working out
+// the encoding of fields whose documentation is missing or wrong.
+type rawFieldValue struct {
+	FieldId     uint32 `json:"field_id"`
+	ScopeId     uint32 `json:"scope_id"`
+	Timestamp   int64  `json:"timestamp"`
+	LatencyUsec int64  `json:"latency_usec"`
+	ValueType   uint32 `json:"value_type"`
+	NvmlReturn  string `json:"nvml_return"`
+	// Value holds the 8 value bytes in hex, in the order NVML wrote them
+	Value string `json:"value"`
+	// Decoded is the value as the collectors read it, absent when the value
+	// type is unknown or the field failed
+	Decoded *float64 `json:"decoded,omitempty"`
+}
+
+func newRawFieldValue(fv nvml.FieldValue) rawFieldValue {
+	raw := rawFieldValue{
+		FieldId:     fv.FieldId,
+		ScopeId:     fv.ScopeId,
+		Timestamp:   fv.Timestamp,
+		LatencyUsec: fv.LatencyUsec,
+		ValueType:   fv.ValueType,
+		NvmlReturn:  nvml.ErrorString(nvml.Return(fv.NvmlReturn)),
+		Value:       hex.EncodeToString(fv.Value[:]),
+	}
+	if errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
+		if v, err := fieldValueToFloat64(fv); err == nil {
+			raw.Decoded = &v
+		}
+	}
+	return raw
+}
+
+// fieldDump reads the fields of -debug.dump-fields every collection round,
+// logs them and keeps the last reading of every GPU for fieldDumpHandler.
+type fieldDump struct {
+	fields fieldDumpList
+
+	mu sync.Mutex
+	// last holds the fields of the last round by GPU UUID
+	last map[string][]rawFieldValue
+}
+
+func newFieldDump(fields fieldDumpList) *fieldDump {
+	return &fieldDump{fields: fields, last: make(map[string][]rawFieldValue)}
+}
+
+// collect reads the fields on every GPU and logs each value.
+func (d *fieldDump) collect(devices []Device, logger *slog.Logger) {
+	last := make(map[string][]rawFieldValue, len(devices))
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		values := make([]nvml.FieldValue, len(d.fields))
+		for i, key := range d.fields {
+			values[i] = nvml.FieldValue{FieldId: key.fieldId, ScopeId: key.scopeId}
+		}
+		if ret := device.GetFieldValues(values); !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get field values to dump", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		raws := make([]rawFieldValue, 0, len(values))
+		for _, fv := range values {
+			raw := newRawFieldValue(fv)
+			logger.Info("field value dump", "uuid", uuid, "field_id", raw.FieldId, "scope_id", raw.ScopeId,
+				"timestamp", raw.Timestamp, "latency_usec", raw.LatencyUsec, "value_type", raw.ValueType,
+				"nvml_return", raw.NvmlReturn, "value", raw.Value)
+			raws = append(raws, raw)
+		}
+		last[uuid] = raws
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = last
+}
+
+// fieldDumpHandler serves the fields of the last round by GPU UUID.
+func fieldDumpHandler(d *fieldDump, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		last := d.last
+		d.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(last); err != nil {
+			logger.Debug("failed to write field value dump", "err", err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestFieldDumpListSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		fields int
+		want   string
+	}{
+		{"field IDs", "140, 141", 2, "140:0,141:0"},
+		{"scope", "235:3", 1, "235:3"},
+		{"every link", "235:*", nvml.NVLINK_MAX_LINKS, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			var l fieldDumpList
+			assert.Is(hammy.NilError(l.Set(tc.value)))
+			assert.Is(hammy.Number(len(l)).EqualTo(tc.fields))
+			if tc.want != "" {
+				assert.Is(hammy.String(l.String()).EqualTo(tc.want))
+			}
+		})
+	}
+
+	for _, invalid := range []string{"ber", "235:link"} {
+		var l fieldDumpList
+		hammy.New(t).Is(hammy.Error(l.Set(invalid)))
+	}
+}
+
+func TestFieldDumpCollect(t *testing.T) {
+	assert := hammy.New(t)
+	device := &fakeDevice{
+		uuid: "GPU-0",
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_NVLINK_COUNT_FEC_HISTORY_0, link: 1}: 0x0102,
+		},
+	}
+	var fields fieldDumpList
+	assert.Is(hammy.NilError(fields.Set("235:1,235:2")))
+
+	dump := newFieldDump(fields)
+	dump.collect([]Device{device, &fakeDevice{uuid: "GPU-1", fieldsRet: nvml.ERROR_NOT_SUPPORTED}}, discardLogger())
+
+	rec := httptest.NewRecorder()
+	fieldDumpHandler(dump, discardLogger())(rec, httptest.NewRequest("GET", "/-/debug/fields", nil))
+	var got map[string][]rawFieldValue
+	assert.Is(hammy.NilError(json.Unmarshal(rec.Body.Bytes(), &got)))
+
+	assert.Is(hammy.Number(len(got)).EqualTo(1))
+	values := got["GPU-0"]
+	assert.Is(hammy.Number(len(values)).EqualTo(2))
+	assert.Is(hammy.String(values[0].Value).EqualTo("0201000000000000"))
+	assert.Is(hammy.String(values[0].NvmlReturn).EqualTo(nvml.ErrorString(nvml.SUCCESS)))
+	assert.Is(hammy.True(values[0].Decoded != nil))
+	assert.Is(hammy.Number(*values[0].Decoded).EqualTo(0x0102))
+	assert.Is(hammy.Number(values[1].ScopeId).EqualTo(2))
+	assert.Is(hammy.String(values[1].NvmlReturn).EqualTo(nvml.ErrorString(nvml.ERROR_NOT_SUPPORTED)))
+	assert.Is(hammy.True(values[1].Decoded == nil))
+}
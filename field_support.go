@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldSupportInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "field_support_info",
+		Help:      "Whether the driver supports a family of NVML fields on the GPU (support = supported or not_supported), probed once at startup.",
+	},
+	[]string{"UUID", "pci_bus_id", "family", "support"},
+)
+
+// fieldFamilies are the groups of NVML queries whose metrics are absent on
+// hardware or drivers that do not support them, in the order they are probed.
+var fieldFamilies = []struct {
+	name  string
+	probe func(device Device) nvml.Return
+}{
+	{"nvlink_errors", func(device Device) nvml.Return {
+		ids := make([]int, 0, len(nvlinkErrorFields))
+		for _, field := range nvlinkErrorFields {
+			ids = append(ids, field.fieldId)
+		}
+		return probeNVLinkFields(device, ids)
+	}},
+	{"ber", func(device Device) nvml.Return {
+		ids := make([]int, 0, len(nvlinkBerFields))
+		for _, field := range nvlinkBerFields {
+			ids = append(ids, field.fieldId)
+		}
+		return probeNVLinkFields(device, ids)
+	}},
+	{"fec_history", func(device Device) nvml.Return {
+		ids := make([]int, 0, len(nvlinkFecFields))
+		for _, field := range nvlinkFecFields {
+			ids = append(ids, field.fieldId)
+		}
+		return probeNVLinkFields(device, ids)
+	}},
+	{"clock_events", func(device Device) nvml.Return {
+		values, _ := buildClockEventRequests(clockEventReasons)
+		return probeFields(device, values)
+	}},
+	{"fabric_v2", func(device Device) nvml.Return {
+		info, ret := device.GetGpuFabricInfoV2()
+		if errors.Is(ret, nvml.SUCCESS) && info.State == nvml.GPU_FABRIC_STATE_NOT_SUPPORTED {
+			return nvml.ERROR_NOT_SUPPORTED
+		}
+		return ret
+	}},
+}
+
+// initFieldSupport probes every field family on every GPU once and exports
+// the result, so that operators can tell which series are expected to be
+// absent on which hardware.
+func initFieldSupport(devices []Device, reg prometheus.Registerer, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		for _, family := range fieldFamilies {
+			support := "supported"
+			if ret := family.probe(device); !errors.Is(ret, nvml.SUCCESS) {
+				if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+					logger.Warn("failed to probe field support", "family", family.name, "uuid", uuid, "error", nvml.ErrorString(ret))
+				}
+				support = "not_supported"
+			}
+			fieldSupportInfo.WithLabelValues(uuid, pciBusId, family.name, support).Set(1)
+		}
+	}
+
+	reg.MustRegister(fieldSupportInfo)
+}
+
+// probeNVLinkFields reads fieldIds on the first active NVLink. GPUs without
+// active links do not support any NVLink field.
+func probeNVLinkFields(device Device, fieldIds []int) nvml.Return {
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		state, ret := device.GetNvLinkState(link)
+		if !errors.Is(ret, nvml.SUCCESS) || state != nvml.FEATURE_ENABLED {
+			continue
+		}
+
+		values := make([]nvml.FieldValue, 0, len(fieldIds))
+		for _, id := range fieldIds {
+			values = append(values, nvml.FieldValue{FieldId: uint32(id), ScopeId: uint32(link)})
+		}
+		return probeFields(device, values)
+	}
+	return nvml.ERROR_NOT_SUPPORTED
+}
+
+// probeFields reads values and succeeds when at least one of them is supported.
+func probeFields(device Device, values []nvml.FieldValue) nvml.Return {
+	if ret := device.GetFieldValues(values); !errors.Is(ret, nvml.SUCCESS) {
+		return ret
+	}
+
+	ret := nvml.ERROR_NOT_SUPPORTED
+	for _, fv := range values {
+		switch nvml.Return(fv.NvmlReturn) {
+		case nvml.SUCCESS:
+			return nvml.SUCCESS
+		case nvml.ERROR_NOT_SUPPORTED:
+		default:
+			ret = nvml.Return(fv.NvmlReturn)
+		}
+	}
+	return ret
+}
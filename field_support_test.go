@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInitFieldSupport(t *testing.T) {
+	assert := hammy.New(t)
+	fieldSupportInfo.Reset()
+	t.Cleanup(fieldSupportInfo.Reset)
+
+	nvswitch := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		fabric:   &nvml.GpuFabricInfo_v2{State: nvml.GPU_FABRIC_STATE_COMPLETED},
+		// Link 0 is down, so the probe reads link 1
+		links: map[int]bool{0: false, 1: true},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 1}:                1,
+			{fieldId: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP, link: 0}: 1,
+			{fieldId: nvmlFieldIdNvLinkMalformedPacketErrors, link: 0}:       1,
+			{fieldId: nvmlFieldIdNvLinkEffectiveBER, link: 0}:                1,
+		},
+		fieldsRet: nvml.SUCCESS,
+	}
+	pcie := &fakeDevice{
+		uuid:      "GPU-1",
+		pciBusId:  "0000:28:00.0",
+		fabric:    &nvml.GpuFabricInfo_v2{State: nvml.GPU_FABRIC_STATE_NOT_SUPPORTED},
+		fields:    map[nvlinkFieldKey]uint64{},
+		fieldsRet: nvml.SUCCESS,
+	}
+
+	initFieldSupport([]Device{nvswitch, pcie}, prometheus.NewRegistry(), discardLogger())
+
+	cases := []struct {
+		uuid, pciBusId, family, support string
+	}{
+		{"GPU-0", "0000:18:00.0", "nvlink_errors", "supported"},
+		{"GPU-0", "0000:18:00.0", "ber", "not_supported"},
+		{"GPU-0", "0000:18:00.0", "fec_history", "not_supported"},
+		{"GPU-0", "0000:18:00.0", "clock_events", "supported"},
+		{"GPU-0", "0000:18:00.0", "fabric_v2", "supported"},
+		{"GPU-1", "0000:28:00.0", "nvlink_errors", "not_supported"},
+		{"GPU-1", "0000:28:00.0", "ber", "not_supported"},
+		{"GPU-1", "0000:28:00.0", "fec_history", "not_supported"},
+		{"GPU-1", "0000:28:00.0", "clock_events", "not_supported"},
+		{"GPU-1", "0000:28:00.0", "fabric_v2", "not_supported"},
+	}
+	for _, tc := range cases {
+		assert.Is(hammy.Number(testutil.ToFloat64(fieldSupportInfo.WithLabelValues(tc.uuid, tc.pciBusId, tc.family, tc.support))).EqualTo(1))
+	}
+	// Exactly one support value per GPU and family
+	assert.Is(hammy.Number(testutil.CollectAndCount(fieldSupportInfo)).EqualTo(len(cases)))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// lastCollectionRound holds the Unix time in nanoseconds at which the last
+// collection round completed, or 0 before the first.
+var lastCollectionRound atomic.Int64
+
+var dataAge = prometheus.NewGaugeFunc(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_data_age_seconds",
+		Help:      "Seconds since the last collection round completed, i.e. the age of the device metrics being served.",
+	},
+	func() float64 {
+		last := lastCollectionRound.Load()
+		if last == 0 {
+			return 0
+		}
+		return time.Since(time.Unix(0, last)).Seconds()
+	},
+)
+
+// eventDrivenFamilies change when NVML raises an event rather than in a
+// collection round. Stamped with the last round, a change between two rounds
+// would be a new value under a timestamp already scraped, which Prometheus
+// rejects as a duplicate sample, so they are left unstamped.
+var eventDrivenFamilies = map[string]bool{
+	namespace + "_xid_errors_total":   true,
+	namespace + "_ecc_events_total":   true,
+	namespace + "_xid_row_remap_info": true,
+}
+
+// timestampGatherer stamps every metric of the gatherer it wraps with the
+// completion time of the last collection round, so that consumers see when
+// the values were read rather than when they were scraped. Metrics that carry
+// their own timestamp keep it, and event-driven families are not stamped.
+type timestampGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer. The families of the wrapped gatherer
+// are copied, not modified, so that it may be a gatherCache.
+func (g timestampGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	last := lastCollectionRound.Load()
+	if last == 0 {
+		return families, err
+	}
+	ms := time.Unix(0, last).UnixMilli()
+
+	stamped := make([]*dto.MetricFamily, 0, len(families))
+	for _, mf := range families {
+		if eventDrivenFamilies[mf.GetName()] {
+			stamped = append(stamped, mf)
+			continue
+		}
+		metrics := make([]*dto.Metric, 0, len(mf.GetMetric()))
+		for _, m := range mf.GetMetric() {
+			timestamp := m.TimestampMs
+			if timestamp == nil {
+				timestamp = &ms
+			}
+			metrics = append(metrics, &dto.Metric{
+				Label:       m.Label,
+				Gauge:       m.Gauge,
+				Counter:     m.Counter,
+				Summary:     m.Summary,
+				Untyped:     m.Untyped,
+				Histogram:   m.Histogram,
+				TimestampMs: timestamp,
+			})
+		}
+		stamped = append(stamped, &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit, Metric: metrics})
+	}
+	return stamped, err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDataAge(t *testing.T) {
+	assert := hammy.New(t)
+	t.Cleanup(func() { lastCollectionRound.Store(0) })
+
+	lastCollectionRound.Store(0)
+	assert.Is(hammy.Number(testutil.ToFloat64(dataAge)).EqualTo(0))
+
+	lastCollectionRound.Store(time.Now().Add(-59 * time.Second).UnixNano())
+	assert.Is(hammy.Number(testutil.ToFloat64(dataAge)).Within(59, 1))
+}
+
+func TestTimestampGatherer(t *testing.T) {
+	assert := hammy.New(t)
+	t.Cleanup(func() { lastCollectionRound.Store(0) })
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: "test_value", Help: "Test value."})
+	registry.MustRegister(gauge)
+	cache := newGatherCache(registry)
+	gatherer := timestampGatherer{cache}
+
+	// Nothing is stamped before the first round
+	families, err := gatherer.Gather()
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(families[0].GetMetric()[0].TimestampMs == nil))
+
+	collected := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastCollectionRound.Store(collected.UnixNano())
+	families, err = gatherer.Gather()
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(families[0].GetMetric()[0].GetTimestampMs()).EqualTo(collected.UnixMilli()))
+
+	// Event-driven families keep the scrape time
+	xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", "79").Inc()
+	t.Cleanup(xidErrors.Reset)
+	registry.MustRegister(xidErrors)
+	families, err = timestampGatherer{registry}.Gather()
+	assert.Is(hammy.NilError(err))
+	for _, mf := range families {
+		stamped := mf.GetMetric()[0].TimestampMs != nil
+		assert.Is(hammy.True(stamped == (mf.GetName() != "nvgpu_xid_errors_total")))
+	}
+
+	// The cached families stay unstamped
+	cached, err := cache.Gather()
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(cached[0].GetMetric()[0].TimestampMs == nil))
+}
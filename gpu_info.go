@@ -10,19 +10,26 @@ import (
 
 // GpuInfo captures immutable metadata about a GPU returned by NVML.
 type GpuInfo struct {
-	UUID                string
-	PciBusId            string
+	UUID     string
+	PciBusId string
+	// Index and MinorNumber are the NVML index and the N of /dev/nvidiaN
+	Index               string
+	MinorNumber         string
 	PciDomain           uint32
 	PciBus              uint32
 	PciDevice           uint32
 	Name                string
 	Brand               string
+	BrandId             string
 	Serial              string
 	BoardId             string
 	OemInforomVersion   string
 	EccInforomVersion   string
 	PowerInforomVersion string
 	VbiosVersion        string
+	BoardPartNumber     string
+	// FormFactor is sxm, superchip or pcie, derived from the GPU name
+	FormFactor          string
 	InforomImageVersion string
 	IbGuid              string
 	// Platform Info fields
@@ -37,6 +44,13 @@ type GpuInfo struct {
 	ComputeSlotIndex    string
 	NodeIndex           string
 	GpuFabricGuid       string
+	// GSP firmware fields
+	GspFirmwareMode    string
+	GspFirmwareVersion string
+	// Architecture fields
+	ComputeCapability string
+	Architecture      string
+	DriverBranch      string
 }
 
 // ExporterInfo stores driver/library versions exposed by the exporter.
@@ -82,10 +96,19 @@ var gpuInfo = prometheus.NewGaugeVec(
 		Name:      "gpu_info",
 		Help:      "GPU device information.",
 	},
-	[]string{"UUID", "pci_bus_id", "pci_domain", "pci_bus", "pci_device", "name", "brand", "serial", "board_id", "vbios_version", "oem_inforom_version", "ecc_inforom_version", "power_inforom_version", "inforom_image_version", "chassis_serial_number", "slot_number", "tray_index", "host_id", "peer_type", "module_id", "gpu_fabric_guid", "ib_guid", "rack_guid", "chassis_physical_slot", "compute_slot_index", "node_index"},
+	[]string{"UUID", "pci_bus_id", "pci_domain", "pci_bus", "pci_device", "name", "brand", "serial", "board_id", "vbios_version", "oem_inforom_version", "ecc_inforom_version", "power_inforom_version", "inforom_image_version", "chassis_serial_number", "slot_number", "tray_index", "host_id", "peer_type", "module_id", "gpu_fabric_guid", "ib_guid", "rack_guid", "chassis_physical_slot", "compute_slot_index", "node_index", "gsp_firmware_mode", "gsp_firmware_version", "compute_capability", "architecture", "driver_branch", "brand_id", "board_part_number", "form_factor"},
 )
 
-func initExporterInfo(devices DeviceLister, version string, commit string) error {
+var gpuDeviceMapping = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "gpu_device_mapping",
+		Help:      "Maps the UUID of a GPU to its NVML index, PCI bus ID and minor number (the N of /dev/nvidiaN), always 1.",
+	},
+	[]string{"UUID", "pci_bus_id", "index", "minor_number"},
+)
+
+func initExporterInfo(devices DeviceLister, version string, commit string, reg prometheus.Registerer) error {
 	info, err := devices.ExporterInfo()
 	if err != nil {
 		return err
@@ -95,7 +118,7 @@ func initExporterInfo(devices DeviceLister, version string, commit string) error
 	exporterInfo.WithLabelValues(version+"-"+commit, info.DriverVersion, info.NVMLVersion, info.CudaVersion).Set(1)
 
 	// Register the exporter info metric
-	prometheus.MustRegister(exporterInfo)
+	reg.MustRegister(exporterInfo)
 	return nil
 }
 
@@ -114,7 +137,7 @@ func loadGpuInfos(devices DeviceLister) ([]*GpuInfo, error) {
 	return infos, nil
 }
 
-func initGpuInfoWithCache(infos []*GpuInfo) error {
+func initGpuInfoWithCache(infos []*GpuInfo, reg prometheus.Registerer) error {
 	for _, info := range infos {
 
 		// Set GPU info metric
@@ -145,41 +168,288 @@ func initGpuInfoWithCache(infos []*GpuInfo) error {
 			info.ChassisPhysicalSlot,
 			info.ComputeSlotIndex,
 			info.NodeIndex,
+			info.GspFirmwareMode,
+			info.GspFirmwareVersion,
+			info.ComputeCapability,
+			info.Architecture,
+			info.DriverBranch,
+			info.BrandId,
+			info.BoardPartNumber,
+			info.FormFactor,
 		).Set(1)
 	}
 
 	// Register the GPU info metric
-	prometheus.MustRegister(gpuInfo)
+	reg.MustRegister(gpuInfo)
+
+	for _, info := range infos {
+		gpuDeviceMapping.WithLabelValues(info.UUID, info.PciBusId, info.Index, info.MinorNumber).Set(1)
+	}
+	reg.MustRegister(gpuDeviceMapping)
 
 	return nil
 }
 
 // startCollectors starts a goroutine that periodically collects fabric health and NVLink error metrics
-func startCollectors(devices Devices, interval time.Duration, infos []*GpuInfo, logger *slog.Logger) {
-	prometheus.MustRegister(fabricHealth)
-	prometheus.MustRegister(fabricState)
-	prometheus.MustRegister(fabricStatus)
-	prometheus.MustRegister(fabricHealthSummary)
-	prometheus.MustRegister(fabricIncorrectConfig)
-	prometheus.MustRegister(nvlinkErrors)
-	prometheus.MustRegister(clockEventDurations)
-
-	clockCollector := newClockEventCollector()
-
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		collectFabricHealth(devices, logger)
-		collectNVLinkErrors(devices, logger)
-		clockCollector.collectClockEventReasons(devices, logger)
-
-		for range ticker.C {
-			collectFabricHealth(devices, logger)
-			collectNVLinkErrors(devices, logger)
-			clockCollector.collectClockEventReasons(devices, logger)
+func startCollectors(devices Devices, cfg *Config, infos []*GpuInfo, health *gpuHealthTracker, remaps *xidRowRemapTracker, locations *locationLabels, history *nvlinkHistory, dump *fieldDump, profiles map[string]expectedProfile, custom []customField, cache *gatherCache, reg, internal prometheus.Registerer, logger *slog.Logger) []string {
+	reg.MustRegister(locations.wrap(fabricHealth))
+	reg.MustRegister(locations.wrap(fabricState))
+	reg.MustRegister(locations.wrap(fabricStatus))
+	reg.MustRegister(locations.wrap(fabricHealthSummary))
+	reg.MustRegister(locations.wrap(fabricHealthMask))
+	reg.MustRegister(locations.wrap(fabricIncorrectConfig))
+	reg.MustRegister(locations.wrap(fabricIncorrectConfigCause))
+	reg.MustRegister(fabricCliqueMember)
+	reg.MustRegister(locations.wrap(nvlinkErrors))
+	reg.MustRegister(nvlinkCounterResets)
+	reg.MustRegister(nvlinkCounterRollovers)
+	reg.MustRegister(nvlinkUp)
+	reg.MustRegister(nvlinkPowerState)
+	reg.MustRegister(nvlinkBer)
+	reg.MustRegister(locations.wrap(nvlinkBerThresholdExceeded))
+	reg.MustRegister(locations.wrap(nvlinkRemoteEndpoint))
+	reg.MustRegister(nvlinkErrorsPerGigabyte)
+	reg.MustRegister(nvlinksActive)
+	reg.MustRegister(nvlinksExpected)
+	if cfg.NVLinkFecHistogram {
+		nvlinkFecErrors.native = cfg.NativeHistograms
+		reg.MustRegister(nvlinkFecErrors)
+	}
+	if cfg.NativeHistograms {
+		reg.MustRegister(utilizationSamples)
+		internal.MustRegister(collectionDuration)
+	}
+	if cfg.NVLinkUtilization {
+		reg.MustRegister(nvlinkThroughput)
+		reg.MustRegister(nvlinkUtilization)
+	}
+	if cfg.Grace {
+		reg.MustRegister(modulePower)
+		reg.MustRegister(c2cLinkUp)
+		reg.MustRegister(c2cLinkMaxBandwidth)
+		reg.MustRegister(c2cLinkLowPower)
+		reg.MustRegister(egmCapable)
+	}
+	reg.MustRegister(clockEventDurations)
+	reg.MustRegister(clockEventDomainDurations)
+	reg.MustRegister(clockEventCounterResets)
+	reg.MustRegister(clockEventActiveRatio)
+	reg.MustRegister(clockEventActive)
+	reg.MustRegister(applicationsClock)
+	reg.MustRegister(defaultApplicationsClock)
+	reg.MustRegister(clockOffset)
+	reg.MustRegister(clocksNonDefault)
+	reg.MustRegister(powerLimit)
+	reg.MustRegister(configDrift)
+	reg.MustRegister(autoBoostEnabled)
+	reg.MustRegister(autoBoostDefaultEnabled)
+	reg.MustRegister(eccSramAggregateUncorrectable)
+	reg.MustRegister(eccSramAggregateUncorrectableBucket)
+	reg.MustRegister(eccSramThresholdExceeded)
+	reg.MustRegister(gpuProcesses)
+	reg.MustRegister(ghostProcesses)
+	reg.MustRegister(ghostProcessMemory)
+	reg.MustRegister(operationMode)
+	reg.MustRegister(operationModePending)
+	reg.MustRegister(displayActive)
+	reg.MustRegister(displayMode)
+	reg.MustRegister(driverModel)
+	reg.MustRegister(persistenceMode)
+	reg.MustRegister(persistencedRunning)
+	reg.MustRegister(pcieAerErrors)
+	reg.MustRegister(pcieLinkSpeed)
+	reg.MustRegister(pcieLinkWidth)
+	reg.MustRegister(pcieLinkDowntrained)
+	reg.MustRegister(pcieLinkDowntrainEvents)
+	reg.MustRegister(powerProfile)
+	reg.MustRegister(powerSmoothingEnabled)
+	reg.MustRegister(powerSmoothingActiveProfile)
+	reg.MustRegister(powerSmoothingAppliedTmp)
+	reg.MustRegister(powerSmoothingLifetimeRemaining)
+	reg.MustRegister(retiredPagesPending)
+	reg.MustRegister(retiredPages)
+	reg.MustRegister(retiredPagesLastTimestamp)
+	reg.MustRegister(remappedRows)
+	reg.MustRegister(rowRemapPending)
+	reg.MustRegister(rowRemapFailed)
+	reg.MustRegister(rowRemapBankAvailability)
+	reg.MustRegister(gpuResets)
+	reg.MustRegister(gpuLastReset)
+	reg.MustRegister(eccContainment)
+	reg.MustRegister(utilizationInterval)
+	reg.MustRegister(dramActivity)
+	reg.MustRegister(powerByScope)
+	reg.MustRegister(temperatureThreshold)
+	reg.MustRegister(temperatureTargetModified)
+	reg.MustRegister(gpuTopology)
+	reg.MustRegister(gpuTopologyID)
+	reg.MustRegister(topologyChanges)
+	reg.MustRegister(nodeGpus)
+	reg.MustRegister(nodeMemoryUsed)
+	reg.MustRegister(nodeMemoryTotal)
+	reg.MustRegister(nodeUtilizationMean)
+	reg.MustRegister(nodeNVLinksActive)
+	reg.MustRegister(health)
+	reg.MustRegister(healthWatchViolations)
+	internal.MustRegister(collectorPanics)
+	internal.MustRegister(collectorLastSuccess)
+	internal.MustRegister(collectorGoroutines)
+	internal.MustRegister(dataAge)
+	internal.MustRegister(fieldValueCalls)
+	if cfg.AdaptiveUtilizationThreshold > 0 {
+		internal.MustRegister(effectiveCollectionInterval)
+	}
+	if health.burst != nil {
+		internal.MustRegister(collectionBursts)
+	}
+	reg.MustRegister(deviceCollectionSuccess)
+
+	batch := newFieldBatch(devices.handles)
+	metadata := newDeviceMetadataCache(batch.handles())
+	tracker := newDeviceCollectionTracker(metadata.handles(), logger)
+	handles := tracker.devices
+	clockCollector := newClockEventCollector(cfg.ClockEventReasons)
+	nvlinkCollector := newNVLinkCollector(cfg.NVLinkLegacyBER, cfg.NVLinkFecHistogram, map[string]float64{
+		"effective": cfg.NVLinkEffectiveBERThreshold,
+		"symbol":    cfg.NVLinkSymbolBERThreshold,
+	}, infos)
+	nvlinkCollector.expectedLinks = expectedNVLinkCounts(infos, profiles)
+	pcieCollector := newPCIeCollector(cfg.SysPath)
+	samplesCollector := newUtilizationSampleCollector(cfg.NativeHistograms)
+	topologyCollector := newTopologyCollector(cfg.TopologyGpuID, infos)
+	resetCollector := newResetCollector()
+	throttle := newAdaptiveThrottle(cfg.AdaptiveUtilizationThreshold, cfg.AdaptiveSlowdown, cfg.CollectionInterval)
+	resetCollector.containment = health.containment
+
+	// appClocks hands the applications clocks of a round to config_drift,
+	// which runs after application_clocks
+	var appClocks applicationClockReadings
+	collectors := []namedCollector{
+		{"fabric_health", func() { collectFabricHealth(handles, health, logger) }},
+		{"nvlink", func() {
+			nvlinkCollector.skipFec = !throttle.due(heavyNVLinkFec)
+			nvlinkCollector.collectNVLinkErrors(handles, health, logger)
+		}},
+		{"nvlink_remote", func() { collectNVLinkRemoteEndpoints(handles, logger) }},
+		{"clock_events", func() { clockCollector.collectClockEventReasons(handles, logger) }},
+		{"application_clocks", func() { appClocks = collectApplicationClocks(handles, logger) }},
+		{"config_drift", func() { collectConfigDrift(handles, profiles, appClocks, logger) }},
+		{"ecc_sram", func() { collectEccSramStatus(handles, logger) }},
+		{"processes", func() { collectProcesses(handles, cfg.ProcPath, logger) }},
+		{"retired_pages", func() { collectRetiredPages(handles, health, remaps, logger) }},
+		{"gpu_resets", func() { resetCollector.collectResets(handles, logger) }},
+		{"ecc_containment", func() { health.containment.collect(handles, logger) }},
+		{"operation_mode", func() { collectOperationModes(handles, logger) }},
+		{"persistence", func() { collectPersistence(handles, cfg.ProcPath, logger) }},
+		{"pcie", func() { pcieCollector.collectPCIe(handles, logger) }},
+		{"power_profiles", func() { collectPowerProfiles(handles, logger) }},
+		{"utilization_samples", func() { samplesCollector.collectUtilizationSamples(handles, logger) }},
+		{"dram_activity", func() { collectDramActivity(handles, logger) }},
+		{"temperature_thresholds", func() { collectTemperatureThresholds(handles, logger) }},
+		{"power_rails", func() { collectPowerRails(handles, logger) }},
+		{"topology", func() {
+			if throttle.due(heavyTopology) {
+				topologyCollector.collectTopology(handles, logger)
+			}
+		}},
+		// Runs after the collectors above so that it sees this round's health signals
+		{"node_rollup", func() { collectNodeRollup(handles, health, logger) }},
+		{"health_watch", func() { health.watcher.evaluate(handles, health) }},
+	}
+	if cfg.NVLinkUtilization {
+		utilizationCollector := newNVLinkUtilizationCollector()
+		collectors = append(collectors, namedCollector{"nvlink_utilization", func() { utilizationCollector.collectNVLinkUtilization(handles, logger) }})
+	}
+	if history != nil {
+		reg.MustRegister(history)
+		nvlinkCollector.history = history
+		if cfg.NVLinkHistoryFile != "" {
+			collectors = append(collectors, namedCollector{"nvlink_history", func() {
+				if err := history.save(); err != nil {
+					logger.Warn("failed to save NVLink history", "path", cfg.NVLinkHistoryFile, "err", err)
+				}
+			}})
+		}
+	}
+	if fabricAttached(devices.handles) {
+		reg.MustRegister(fabricManagerUp)
+		collectors = append(collectors, namedCollector{"fabric_manager", func() { collectFabricManager(cfg.ProcPath, cfg.FabricManagerAddr, logger) }})
+	}
+	if cfg.ContainerRuntimeRoot != "" {
+		reg.MustRegister(containerToolkitInstalled)
+		reg.MustRegister(containerToolkitInfo)
+		reg.MustRegister(devicePluginUp)
+		reg.MustRegister(devicePluginRegisteredDevices)
+		collectors = append(collectors, namedCollector{"container_runtime", func() { collectContainerRuntime(cfg.ContainerRuntimeRoot, logger) }})
+	}
+	if cfg.MPSClients {
+		reg.MustRegister(mpsClients)
+		reg.MustRegister(mpsClientMemory)
+		reg.MustRegister(mpsClientUtilization)
+		mpsCollector := newMPSCollector(cfg.ProcPath)
+		collectors = append(collectors, namedCollector{"mps", func() { mpsCollector.collectMPS(handles, logger) }})
+	}
+	if cfg.Grace {
+		collectors = append(collectors, namedCollector{"grace", func() { collectGrace(handles, logger) }})
+	}
+	if cfg.ExportDeltas {
+		deltas, err := newDeltaCollector(deltaSources)
+		if err != nil {
+			logger.Error("failed to set up per-round deltas, they are not exported", "err", err)
+		} else {
+			reg.MustRegister(locations.wrap(deltas))
+			// Runs last so that the deltas cover this round's readings
+			collectors = append(collectors, namedCollector{"deltas", deltas.update})
+		}
+	}
+	if len(custom) > 0 {
+		customCollector := newCustomFieldCollector(custom)
+		if err := reg.Register(customCollector); err != nil {
+			logger.Error("failed to register custom fields, they are not exported", "err", err)
+		} else {
+			collectors = append(collectors, namedCollector{"custom_fields", func() { customCollector.collectCustomFields(handles, logger) }})
+		}
+	}
+	if dump != nil {
+		collectors = append(collectors, namedCollector{"field_dump", func() { dump.collect(handles, logger) }})
+	}
+	names := make([]string, 0, len(collectors))
+	for _, c := range collectors {
+		collectorPanics.WithLabelValues(c.name)
+		names = append(names, c.name)
+	}
+
+	schedule := newCollectionSchedule(cfg.CollectionInterval, cfg.CollectionAlign, cfg.CollectionJitter)
+	round := func() {
+		batch.begin(logger)
+		metadata.begin()
+		throttle.update(devices.handles, logger)
+		runCollectors(collectors, tracker, logger)
+		metadata.end()
+		batch.end()
+		cache.invalidate()
+		lastCollectionRound.Store(time.Now().UnixNano())
+	}
+	goCollector("collection", func() {
+		round()
+
+		due := schedule.first(time.Now())
+		for {
+			timer := time.NewTimer(time.Until(health.burst.wake(due, time.Now())))
+			select {
+			case <-timer.C:
+			case event := <-health.burst.triggered():
+				timer.Stop()
+				logger.Info("collecting out of cycle", "uuid", event.uuid, "reason", event.reason, "interval", cfg.BurstInterval, "duration", cfg.BurstDuration)
+			}
+			round()
+			// Burst rounds run between the scheduled ones, which keep their place
+			if now := time.Now(); !now.Before(due) {
+				due = schedule.next(due, now)
+			}
 		}
-	}()
+	})
 
-	logger.Info("started collectors", "interval", interval)
+	logger.Info("started collectors", "interval", cfg.CollectionInterval, "align", cfg.CollectionAlign, "offset", schedule.offset)
+	return names
 }
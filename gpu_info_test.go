@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/gogunit/gunit/hammy"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -22,7 +23,7 @@ func TestInitExporterInfoRegistersMetric(t *testing.T) {
 		},
 	}
 
-	err := initExporterInfo(devices, "0.2.0", "abcd1234")
+	err := initExporterInfo(devices, "0.2.0", "abcd1234", prometheus.NewRegistry())
 	assert.Is(hammy.True(err == nil))
 
 	value := testutil.ToFloat64(exporterInfo.WithLabelValues("0.2.0-abcd1234", "560.35", "12.4", "12.4"))
@@ -41,14 +42,19 @@ func TestInitGpuInfoExportsAllDevices(t *testing.T) {
 			{
 				UUID:                "GPU-1",
 				PciBusId:            "0000:01:00.0",
+				Index:               "3",
+				MinorNumber:         "5",
 				PciDomain:           0,
 				PciBus:              1,
 				PciDevice:           0,
 				Name:                "H100",
-				Brand:               "1",
+				Brand:               "NVIDIA",
+				BrandId:             "14",
 				Serial:              "ABC123",
 				BoardId:             "10",
 				VbiosVersion:        "95.02",
+				BoardPartNumber:     "692-2G520-0200-000",
+				FormFactor:          "sxm",
 				OemInforomVersion:   "1.0",
 				EccInforomVersion:   "1.0",
 				PowerInforomVersion: "1.0",
@@ -65,6 +71,11 @@ func TestInitGpuInfoExportsAllDevices(t *testing.T) {
 				ChassisPhysicalSlot: "chassis-slot-1",
 				ComputeSlotIndex:    "compute-slot-1",
 				NodeIndex:           "node-1",
+				GspFirmwareMode:     "enabled",
+				GspFirmwareVersion:  "570.86.15",
+				ComputeCapability:   "9.0",
+				Architecture:        "hopper",
+				DriverBranch:        "r570_00",
 			},
 			{
 				UUID:                "GPU-2",
@@ -73,7 +84,8 @@ func TestInitGpuInfoExportsAllDevices(t *testing.T) {
 				PciBus:              2,
 				PciDevice:           0,
 				Name:                "H100",
-				Brand:               "1",
+				Brand:               "NVIDIA",
+				BrandId:             "14",
 				Serial:              "XYZ987",
 				BoardId:             "11",
 				VbiosVersion:        "95.03",
@@ -93,6 +105,11 @@ func TestInitGpuInfoExportsAllDevices(t *testing.T) {
 				ChassisPhysicalSlot: "chassis-slot-2",
 				ComputeSlotIndex:    "compute-slot-2",
 				NodeIndex:           "node-2",
+				GspFirmwareMode:     "disabled",
+				GspFirmwareVersion:  "unsupported",
+				ComputeCapability:   "10.0",
+				Architecture:        "blackwell",
+				DriverBranch:        "r570_00",
 			},
 		},
 	}
@@ -100,7 +117,7 @@ func TestInitGpuInfoExportsAllDevices(t *testing.T) {
 	infos, err := loadGpuInfos(devices)
 	assert.Is(hammy.True(err == nil))
 
-	err = initGpuInfoWithCache(infos)
+	err = initGpuInfoWithCache(infos, prometheus.NewRegistry())
 	assert.Is(hammy.True(err == nil))
 
 	for _, info := range devices.gpuInfos {
@@ -131,12 +148,23 @@ func TestInitGpuInfoExportsAllDevices(t *testing.T) {
 			info.ChassisPhysicalSlot,
 			info.ComputeSlotIndex,
 			info.NodeIndex,
+			info.GspFirmwareMode,
+			info.GspFirmwareVersion,
+			info.ComputeCapability,
+			info.Architecture,
+			info.DriverBranch,
+			info.BrandId,
+			info.BoardPartNumber,
+			info.FormFactor,
 		))
 		assert.Is(hammy.Number(value).EqualTo(1))
 	}
 
 	count := testutil.CollectAndCount(gpuInfo)
 	assert.Is(hammy.Number(count).EqualTo(2))
+
+	// The index and minor number of GPU-1 differ, e.g. after a GPU fell off the bus
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuDeviceMapping.WithLabelValues("GPU-1", "0000:01:00.0", "3", "5"))).EqualTo(1))
 }
 
 func TestInitGpuInfoPropagatesErrors(t *testing.T) {
@@ -187,19 +215,79 @@ func (s *stubDeviceLister) ExporterInfo() (*ExporterInfo, error) {
 func resetExporterInfoMetric(t *testing.T) {
 	t.Helper()
 	exporterInfo.Reset()
-	prometheus.Unregister(exporterInfo)
-	t.Cleanup(func() {
-		exporterInfo.Reset()
-		prometheus.Unregister(exporterInfo)
-	})
+	t.Cleanup(exporterInfo.Reset)
 }
 
 func resetGpuInfoMetric(t *testing.T) {
 	t.Helper()
 	gpuInfo.Reset()
-	prometheus.Unregister(gpuInfo)
-	t.Cleanup(func() {
-		gpuInfo.Reset()
-		prometheus.Unregister(gpuInfo)
-	})
+	gpuDeviceMapping.Reset()
+	t.Cleanup(gpuInfo.Reset)
+	t.Cleanup(gpuDeviceMapping.Reset)
+}
+
+func TestBrandToString(t *testing.T) {
+	tests := []struct {
+		name  string
+		brand nvml.BrandType
+		want  string
+	}{
+		{name: "quadro", brand: nvml.BRAND_QUADRO, want: "Quadro"},
+		{name: "tesla", brand: nvml.BRAND_TESLA, want: "Tesla"},
+		{name: "geforce", brand: nvml.BRAND_GEFORCE, want: "GeForce"},
+		{name: "nvidia", brand: nvml.BRAND_NVIDIA, want: "NVIDIA"},
+		{name: "unknown", brand: nvml.BRAND_UNKNOWN, want: "Unknown"},
+		{name: "future", brand: 99, want: "unknown_99"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			assert.Is(hammy.String(brandToString(tc.brand)).EqualTo(tc.want))
+		})
+	}
+}
+
+func TestArchitectureToString(t *testing.T) {
+	tests := []struct {
+		name string
+		arch nvml.DeviceArchitecture
+		want string
+	}{
+		{name: "ampere", arch: nvml.DEVICE_ARCH_AMPERE, want: "ampere"},
+		{name: "hopper", arch: nvml.DEVICE_ARCH_HOPPER, want: "hopper"},
+		{name: "blackwell", arch: nvml.DEVICE_ARCH_BLACKWELL, want: "blackwell"},
+		{name: "nvml unknown", arch: nvml.DEVICE_ARCH_UNKNOWN, want: "unknown"},
+		{name: "future", arch: 42, want: "unknown_42"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			assert.Is(hammy.String(architectureToString(tc.arch)).EqualTo(tc.want))
+		})
+	}
+}
+
+func TestFormFactor(t *testing.T) {
+	tests := []struct {
+		name string
+		gpu  string
+		want string
+	}{
+		{name: "sxm", gpu: "NVIDIA H100 80GB HBM3", want: "sxm"},
+		{name: "a100 sxm", gpu: "NVIDIA A100-SXM4-80GB", want: "sxm"},
+		{name: "pcie", gpu: "NVIDIA H100 PCIe", want: "pcie"},
+		{name: "nvl", gpu: "NVIDIA H100 NVL", want: "pcie"},
+		{name: "grace hopper", gpu: "NVIDIA GH200 480GB", want: "superchip"},
+		{name: "grace blackwell", gpu: "NVIDIA GB200", want: "superchip"},
+		{name: "unknown", gpu: "NVIDIA L40S", want: "unknown"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			assert.Is(hammy.String(formFactor(tc.gpu)).EqualTo(tc.want))
+		})
+	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gpuResets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "gpu_resets_total",
+			Help:      "Number of GPU resets observed since the exporter started: the GPU answered again after being lost, or a reset the driver asked for was carried out.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	gpuLastReset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "gpu_last_reset_timestamp_seconds",
+			Help:      "Unix time the exporter last observed a reset of the GPU. Absent when no reset was observed.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+)
+
+// resetRecoveryActions are the FI_DEV_GET_GPU_RECOVERY_ACTION values that ask
+// for a GPU reset.
+var resetRecoveryActions = map[uint64]bool{
+	uint64(nvml.GPU_RECOVERY_ACTION_GPU_RESET):       true,
+	uint64(nvml.GPU_RECOVERY_ACTION_DRAIN_AND_RESET): true,
+}
+
+// resetState is what the reset collector remembers of a GPU between rounds.
+type resetState struct {
+	uuid     string
+	pciBusId string
+	// lost is set while the GPU is lost or refuses queries until it is reset
+	lost bool
+	// resetRequired is set while the driver waits for a reset: a recovery
+	// action or pending page retirements or row remappings
+	resetRequired bool
+}
+
+// resetCollector detects GPU resets. A GPU that is reset "cleanly", e.g. by
+// nvidia-smi -r or the driver's own recovery, keeps its UUID and handle and
+// leaves no trace in the other metrics, so flaky boards went unnoticed. A
+// reset is counted when a GPU answers again after it was lost, or when a
+// reset the driver asked for is no longer pending. State is kept per device
+// position, since a lost GPU no longer reports its UUID.
+type resetCollector struct {
+	gpus map[int]*resetState
+	now  func() time.Time
+	// containment, when set, forgets the ECC errors of a GPU that was reset
+	containment *eccContainmentTracker
+}
+
+func newResetCollector() *resetCollector {
+	return &resetCollector{gpus: make(map[int]*resetState), now: time.Now}
+}
+
+func (c *resetCollector) collectResets(devices []Device, logger *slog.Logger) {
+	for i, device := range devices {
+		state := c.gpus[i]
+		uuid, ret := device.GetUUID()
+		if errors.Is(ret, nvml.ERROR_GPU_IS_LOST) || errors.Is(ret, nvml.ERROR_RESET_REQUIRED) {
+			if state != nil && !state.lost {
+				logger.Warn("GPU stopped answering", "uuid", state.uuid, "error", nvml.ErrorString(ret))
+				state.lost = true
+			}
+			continue
+		}
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+		resetRequired := resetRequired(device, uuid, logger)
+
+		if state == nil || state.uuid != uuid {
+			// First sighting, or another GPU took the position: nothing to
+			// compare with
+			c.gpus[i] = &resetState{uuid: uuid, pciBusId: pciBusId, resetRequired: resetRequired}
+			gpuResets.WithLabelValues(uuid, pciBusId)
+			continue
+		}
+
+		var cause string
+		switch {
+		case state.lost:
+			cause = "recovered"
+		case state.resetRequired && !resetRequired:
+			cause = "reset_required_cleared"
+		}
+		if cause != "" {
+			logger.Info("GPU reset detected", "uuid", uuid, "pci_bus_id", pciBusId, "cause", cause)
+			gpuResets.WithLabelValues(uuid, pciBusId).Inc()
+			gpuLastReset.WithLabelValues(uuid, pciBusId).Set(float64(c.now().Unix()))
+			c.containment.reset(uuid)
+		}
+		state.pciBusId = pciBusId
+		state.lost = false
+		state.resetRequired = resetRequired
+	}
+}
+
+// resetRequired reports whether the driver waits for a reset of the GPU.
+// Signals the GPU does not support count as not pending.
+func resetRequired(device Device, uuid string, logger *slog.Logger) bool {
+	values := []nvml.FieldValue{{FieldId: nvml.FI_DEV_GET_GPU_RECOVERY_ACTION}}
+	if ret := device.GetFieldValues(values); errors.Is(ret, nvml.SUCCESS) && errors.Is(nvml.Return(values[0].NvmlReturn), nvml.SUCCESS) {
+		action, err := fieldValueToUint64(values[0])
+		if err != nil {
+			logger.Warn("failed to decode GPU recovery action", "uuid", uuid, "err", err)
+		} else if resetRecoveryActions[action] {
+			return true
+		}
+	}
+
+	if pending, ret := device.GetRetiredPagesPendingStatus(); errors.Is(ret, nvml.SUCCESS) && pending == nvml.FEATURE_ENABLED {
+		return true
+	}
+	if _, _, pending, _, ret := device.GetRemappedRows(); errors.Is(ret, nvml.SUCCESS) && pending {
+		return true
+	}
+	return false
+}
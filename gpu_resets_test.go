@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// resetDevice is a GPU that can be lost and wait for a reset.
+type resetDevice struct {
+	fakeDevice
+	uuidRet      nvml.Return
+	remapPending bool
+}
+
+func (d *resetDevice) GetUUID() (string, nvml.Return) {
+	if d.uuidRet != nvml.SUCCESS {
+		return "", d.uuidRet
+	}
+	return d.uuid, nvml.SUCCESS
+}
+
+func (d *resetDevice) GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *resetDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	return 0, 0, d.remapPending, false, nvml.SUCCESS
+}
+
+func TestCollectResets(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		gpuResets.Reset()
+		gpuLastReset.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	device := &resetDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fields: map[nvlinkFieldKey]uint64{}}}
+	c := newResetCollector()
+	c.now = func() time.Time { return time.Unix(1700000000, 0) }
+	resets := func() float64 {
+		return testutil.ToFloat64(gpuResets.WithLabelValues("GPU-0", "0000:18:00.0"))
+	}
+
+	c.collectResets([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(resets()).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(gpuLastReset)).EqualTo(0))
+
+	// Lost, then answering again
+	device.uuidRet = nvml.ERROR_GPU_IS_LOST
+	c.collectResets([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(resets()).EqualTo(0))
+	device.uuidRet = nvml.SUCCESS
+	c.collectResets([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(resets()).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuLastReset.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1700000000))
+
+	// A pending row remapping is applied by a reset
+	device.remapPending = true
+	c.collectResets([]Device{device}, discardLogger())
+	c.collectResets([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(resets()).EqualTo(1))
+	device.remapPending = false
+	c.collectResets([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(resets()).EqualTo(2))
+
+	// So is the recovery action the driver asked for
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_GET_GPU_RECOVERY_ACTION}] = uint64(nvml.GPU_RECOVERY_ACTION_DRAIN_AND_RESET)
+	c.collectResets([]Device{device}, discardLogger())
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_GET_GPU_RECOVERY_ACTION}] = uint64(nvml.GPU_RECOVERY_ACTION_NONE)
+	c.collectResets([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(resets()).EqualTo(3))
+}
+
+func TestCollectResetsIgnoresReplacedGPU(t *testing.T) {
+	assert := hammy.New(t)
+	gpuResets.Reset()
+	t.Cleanup(gpuResets.Reset)
+
+	device := &resetDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, remapPending: true}
+	c := newResetCollector()
+	c.collectResets([]Device{device}, discardLogger())
+
+	device.uuid = "GPU-1"
+	device.remapPending = false
+	c.collectResets([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuResets.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuResets.WithLabelValues("GPU-1", "0000:18:00.0"))).EqualTo(0))
+}
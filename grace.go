@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	modulePower = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "module_power_watts",
+			Help:      "Instantaneous power of the superchip module (Grace CPU, GPU and memory) the GPU belongs to, in watts.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	c2cLinkUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "c2c_link_up",
+			Help:      "Whether the NVLink-C2C link between the GPU and its Grace CPU is active (1 = active, 0 = inactive).",
+		},
+		[]string{"UUID", "pci_bus_id", "link"},
+	)
+
+	c2cLinkMaxBandwidth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "c2c_link_max_bandwidth_bytes_per_second",
+			Help:      "Maximum bandwidth of the NVLink-C2C link in bytes per second.",
+		},
+		[]string{"UUID", "pci_bus_id", "link"},
+	)
+
+	c2cLinkLowPower = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "c2c_link_low_power",
+			Help:      "Whether the NVLink-C2C link is in its low power state (1 = low power, 0 = full power).",
+		},
+		[]string{"UUID", "pci_bus_id", "link"},
+	)
+
+	egmCapable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "egm_capable",
+			Help:      "Whether the GPU supports Extended GPU Memory (EGM) backed by Grace CPU memory (1 = capable).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+)
+
+// collectGrace collects the Grace companion telemetry NVML exposes through the
+// GPUs of a superchip: module power, NVLink-C2C link state and EGM support.
+// GPUs without C2C links are not attached to a Grace CPU and export nothing.
+func collectGrace(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		values := []nvml.FieldValue{
+			{FieldId: nvml.FI_DEV_C2C_LINK_COUNT},
+			{FieldId: nvml.FI_DEV_POWER_INSTANT, ScopeId: nvml.POWER_SCOPE_MODULE},
+		}
+		ret = device.GetFieldValues(values)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get C2C link count", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+		if nvml.Return(values[0].NvmlReturn) != nvml.SUCCESS {
+			continue
+		}
+		links, err := fieldValueToUint64(values[0])
+		if err != nil {
+			logger.Debug("failed to decode C2C link count", "uuid", uuid, "err", err)
+			continue
+		}
+		if links == 0 {
+			continue
+		}
+
+		if nvml.Return(values[1].NvmlReturn) == nvml.SUCCESS {
+			if mw, err := fieldValueToFloat64(values[1]); err == nil {
+				modulePower.WithLabelValues(uuid, pciBusId).Set(mw / 1000)
+			}
+		}
+
+		collectC2CLinks(device, uuid, pciBusId, int(links), logger)
+
+		caps, ret := device.GetCapabilities()
+		if errors.Is(ret, nvml.SUCCESS) {
+			egmCapable.WithLabelValues(uuid, pciBusId).Set(flagToGauge(caps.CapMask&nvml.DEV_CAP_EGM != 0))
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get device capabilities", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+	}
+}
+
+// collectC2CLinks reads the state of each NVLink-C2C link of a GPU.
+func collectC2CLinks(device Device, uuid, pciBusId string, links int, logger *slog.Logger) {
+	values := make([]nvml.FieldValue, 0, 3*links)
+	for link := 0; link < links; link++ {
+		values = append(values,
+			nvml.FieldValue{FieldId: nvml.FI_DEV_C2C_LINK_GET_STATUS, ScopeId: uint32(link)},
+			nvml.FieldValue{FieldId: nvml.FI_DEV_C2C_LINK_GET_MAX_BW, ScopeId: uint32(link)},
+			nvml.FieldValue{FieldId: nvml.FI_DEV_C2C_LINK_POWER_STATE, ScopeId: uint32(link)},
+		)
+	}
+	ret := device.GetFieldValues(values)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		logger.Warn("failed to get C2C link fields", "uuid", uuid, "error", nvml.ErrorString(ret))
+		return
+	}
+
+	for _, fv := range values {
+		if nvml.Return(fv.NvmlReturn) != nvml.SUCCESS {
+			continue
+		}
+		v, err := fieldValueToFloat64(fv)
+		if err != nil {
+			logger.Debug("failed to decode C2C link field", "uuid", uuid, "field_id", fv.FieldId, "err", err)
+			continue
+		}
+
+		link := fmt.Sprintf("%d", fv.ScopeId)
+		switch fv.FieldId {
+		case nvml.FI_DEV_C2C_LINK_GET_STATUS:
+			c2cLinkUp.WithLabelValues(uuid, pciBusId, link).Set(flagToGauge(v != 0))
+		case nvml.FI_DEV_C2C_LINK_GET_MAX_BW:
+			// NVML reports MB/s
+			c2cLinkMaxBandwidth.WithLabelValues(uuid, pciBusId, link).Set(v * 1e6)
+		case nvml.FI_DEV_C2C_LINK_POWER_STATE:
+			c2cLinkLowPower.WithLabelValues(uuid, pciBusId, link).Set(flagToGauge(v == nvml.C2C_POWER_STATE_LOW_POWER))
+		}
+	}
+}
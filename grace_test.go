@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// graceDevice is a GPU of a Grace superchip.
+type graceDevice struct {
+	fakeDevice
+	caps nvml.DeviceCapabilities
+}
+
+func (d *graceDevice) GetCapabilities() (nvml.DeviceCapabilities, nvml.Return) {
+	return d.caps, nvml.SUCCESS
+}
+
+func resetGraceMetrics() {
+	modulePower.Reset()
+	c2cLinkUp.Reset()
+	c2cLinkMaxBandwidth.Reset()
+	c2cLinkLowPower.Reset()
+	egmCapable.Reset()
+}
+
+func TestCollectGrace(t *testing.T) {
+	assert := hammy.New(t)
+	resetGraceMetrics()
+	t.Cleanup(resetGraceMetrics)
+
+	grace := &graceDevice{
+		fakeDevice: fakeDevice{
+			uuid:     "GPU-0",
+			pciBusId: "0000:18:00.0",
+			fields: map[nvlinkFieldKey]uint64{
+				{fieldId: nvml.FI_DEV_C2C_LINK_COUNT}:                               2,
+				{fieldId: nvml.FI_DEV_POWER_INSTANT, link: nvml.POWER_SCOPE_MODULE}: 1850500,
+				{fieldId: nvml.FI_DEV_C2C_LINK_GET_STATUS, link: 0}:                 1,
+				{fieldId: nvml.FI_DEV_C2C_LINK_GET_STATUS, link: 1}:                 0,
+				{fieldId: nvml.FI_DEV_C2C_LINK_GET_MAX_BW, link: 0}:                 45000,
+				{fieldId: nvml.FI_DEV_C2C_LINK_POWER_STATE, link: 0}:                nvml.C2C_POWER_STATE_FULL_POWER,
+				{fieldId: nvml.FI_DEV_C2C_LINK_POWER_STATE, link: 1}:                nvml.C2C_POWER_STATE_LOW_POWER,
+			},
+			fieldsRet: nvml.SUCCESS,
+		},
+		caps: nvml.DeviceCapabilities{CapMask: nvml.DEV_CAP_EGM},
+	}
+	pcie := &graceDevice{
+		fakeDevice: fakeDevice{
+			uuid:      "GPU-1",
+			pciBusId:  "0000:28:00.0",
+			fields:    map[nvlinkFieldKey]uint64{},
+			fieldsRet: nvml.SUCCESS,
+		},
+	}
+
+	collectGrace([]Device{grace, pcie}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(modulePower.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1850.5))
+	assert.Is(hammy.Number(testutil.ToFloat64(c2cLinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(c2cLinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "1"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(c2cLinkMaxBandwidth.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(45e9))
+	assert.Is(hammy.Number(testutil.ToFloat64(c2cLinkLowPower.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(c2cLinkLowPower.WithLabelValues("GPU-0", "0000:18:00.0", "1"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(egmCapable.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1))
+
+	// The GPU without C2C links exports nothing
+	assert.Is(hammy.Number(testutil.CollectAndCount(modulePower)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(egmCapable)).EqualTo(1))
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Combined GPU health levels exported by nvgpu_gpu_health_summary.
+const (
+	gpuHealthOK       = 0
+	gpuHealthDegraded = 1
+	gpuHealthFailed   = 2
+)
+
+var gpuHealthSummaryDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "gpu_health_summary"),
+	"Combined GPU health (0=ok, 1=degraded, 2=failed) derived from fabric health, recent critical Xids, pending page retirements and NVLink down-links.",
+	[]string{"UUID", "pci_bus_id", "reason"},
+	nil,
+)
+
+// gpuHealthTracker combines the health signals reported by the collectors into
+// one series per GPU, so that schedulers do not have to replicate the logic in
+// PromQL. It also forwards signals to the optional node labeler, node event
+// recorder and health watcher, Xids to the ECC containment tracker, and
+// failures to the optional collection burst. A nil *gpuHealthTracker is valid
+// and ignores every signal.
+type gpuHealthTracker struct {
+	labeler      *nodeLabeler
+	events       *nodeEventRecorder
+	watcher      *healthWatcher
+	containment  *eccContainmentTracker
+	burst        *collectionBurst
+	criticalXids map[uint64]bool
+	xidWindow    time.Duration
+	now          func() time.Time
+
+	mu   sync.Mutex
+	gpus map[string]*gpuHealthState
+}
+
+// gpuHealthState holds the latest health signals of a single GPU.
+type gpuHealthState struct {
+	pciBusId          string
+	fabricSummary     uint32
+	lastCriticalXid   time.Time
+	retirementPending bool
+	// links maps every NVLink seen active at least once to whether it is active now
+	links map[int]bool
+}
+
+func newGpuHealthTracker(infos []*GpuInfo, labeler *nodeLabeler, criticalXids []uint64, xidWindow time.Duration) *gpuHealthTracker {
+	xids := make(map[uint64]bool, len(criticalXids))
+	for _, xid := range criticalXids {
+		xids[xid] = true
+	}
+
+	t := &gpuHealthTracker{
+		labeler:      labeler,
+		containment:  newEccContainmentTracker(),
+		criticalXids: xids,
+		xidWindow:    xidWindow,
+		now:          time.Now,
+		gpus:         make(map[string]*gpuHealthState, len(infos)),
+	}
+	for _, info := range infos {
+		t.gpus[info.UUID] = &gpuHealthState{pciBusId: info.PciBusId, links: make(map[int]bool)}
+	}
+	return t
+}
+
+// gpu returns the state of uuid, creating it if needed. t.mu must be held.
+func (t *gpuHealthTracker) gpu(uuid string) *gpuHealthState {
+	state, ok := t.gpus[uuid]
+	if !ok {
+		state = &gpuHealthState{links: make(map[int]bool)}
+		t.gpus[uuid] = state
+	}
+	return state
+}
+
+// reportFabricHealth records the fabric health summary of GPU uuid.
+func (t *gpuHealthTracker) reportFabricHealth(uuid string, summary uint32) {
+	if t == nil {
+		return
+	}
+
+	unhealthy := summary == nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY
+	t.mu.Lock()
+	state := t.gpu(uuid)
+	turnedUnhealthy := unhealthy && state.fabricSummary != nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY
+	state.fabricSummary = summary
+	t.mu.Unlock()
+
+	if turnedUnhealthy {
+		t.burst.trigger(uuid, burstFabricUnhealthy)
+	}
+	t.labeler.reportFabricHealth(uuid, summary == nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY)
+	t.events.reportFabricHealth(uuid, summary == nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY)
+}
+
+// reportXid records an Xid event on GPU uuid.
+func (t *gpuHealthTracker) reportXid(uuid string, xid uint64) {
+	if t == nil {
+		return
+	}
+
+	if t.criticalXids[xid] {
+		t.mu.Lock()
+		t.gpu(uuid).lastCriticalXid = t.now()
+		t.mu.Unlock()
+		t.burst.trigger(uuid, burstCriticalXid)
+	}
+
+	t.labeler.reportXid(uuid, xid)
+	t.events.reportXid(uuid, xid)
+	t.watcher.reportXid(uuid, xid)
+	t.containment.reportXid(uuid, xid)
+}
+
+// reportRetirementPending records whether GPU uuid has page retirements or row
+// remappings waiting for a reset.
+func (t *gpuHealthTracker) reportRetirementPending(uuid string, pending bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.gpu(uuid).retirementPending = pending
+	t.mu.Unlock()
+}
+
+// reportNVLinkState records whether link of GPU uuid is active. A link only
+// counts as down once it has been seen active, so that unpopulated links on
+// PCIe or partially connected boards are not flagged.
+func (t *gpuHealthTracker) reportNVLinkState(uuid string, link int, active bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	links := t.gpu(uuid).links
+	if _, seen := links[link]; seen || active {
+		links[link] = active
+	}
+}
+
+// downLinks returns the NVLinks of GPU uuid that were active once and are down
+// now, in ascending order.
+func (t *gpuHealthTracker) downLinks(uuid string) []int {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var down []int
+	for link, active := range t.gpu(uuid).links {
+		if !active {
+			down = append(down, link)
+		}
+	}
+	slices.Sort(down)
+	return down
+}
+
+// evaluate returns the health level of state and the reasons for it, worst first.
+func (t *gpuHealthTracker) evaluate(state *gpuHealthState) (int, []string) {
+	var failed, degraded []string
+
+	switch state.fabricSummary {
+	case nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY:
+		failed = append(failed, "fabric_unhealthy")
+	case nvml.GPU_FABRIC_HEALTH_SUMMARY_LIMITED_CAPACITY:
+		degraded = append(degraded, "fabric_limited_capacity")
+	}
+
+	if !state.lastCriticalXid.IsZero() && t.now().Sub(state.lastCriticalXid) < t.xidWindow {
+		failed = append(failed, "critical_xid")
+	}
+
+	if state.retirementPending {
+		degraded = append(degraded, "retirement_pending")
+	}
+
+	for _, active := range state.links {
+		if !active {
+			degraded = append(degraded, "nvlink_down")
+			break
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		return gpuHealthFailed, append(failed, degraded...)
+	case len(degraded) > 0:
+		return gpuHealthDegraded, degraded
+	default:
+		return gpuHealthOK, nil
+	}
+}
+
+// nodeSummary returns the number of GPUs per health level and the number of
+// NVLinks currently active across all GPUs.
+func (t *gpuHealthTracker) nodeSummary() (levels [gpuHealthFailed + 1]int, activeLinks int) {
+	if t == nil {
+		return levels, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, state := range t.gpus {
+		level, _ := t.evaluate(state)
+		levels[level]++
+		for _, active := range state.links {
+			if active {
+				activeLinks++
+			}
+		}
+	}
+	return levels, activeLinks
+}
+
+// Describe implements prometheus.Collector.
+func (t *gpuHealthTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- gpuHealthSummaryDesc
+}
+
+// Collect implements prometheus.Collector.
+func (t *gpuHealthTracker) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, uuid := range slices.Sorted(maps.Keys(t.gpus)) {
+		state := t.gpus[uuid]
+		level, reasons := t.evaluate(state)
+
+		reason := "none"
+		if len(reasons) > 0 {
+			reason = strings.Join(reasons, ",")
+		}
+		ch <- prometheus.MustNewConstMetric(gpuHealthSummaryDesc, prometheus.GaugeValue, float64(level), uuid, state.pciBusId, reason)
+	}
+}
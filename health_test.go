@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGpuHealthTrackerCollect(t *testing.T) {
+	assert := hammy.New(t)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	infos := []*GpuInfo{
+		{UUID: "GPU-0", PciBusId: "0000:18:00.0"},
+		{UUID: "GPU-1", PciBusId: "0000:28:00.0"},
+		{UUID: "GPU-2", PciBusId: "0000:38:00.0"},
+		{UUID: "GPU-3", PciBusId: "0000:48:00.0"},
+	}
+	tracker := newGpuHealthTracker(infos, nil, []uint64{79}, time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	// GPU-0 stays healthy; non-critical Xids and never-active links are ignored
+	tracker.reportFabricHealth("GPU-0", nvml.GPU_FABRIC_HEALTH_SUMMARY_HEALTHY)
+	tracker.reportXid("GPU-0", 13)
+	tracker.reportNVLinkState("GPU-0", 17, false)
+
+	// GPU-1 lost a link and has a page retirement pending
+	tracker.reportNVLinkState("GPU-1", 3, true)
+	tracker.reportNVLinkState("GPU-1", 3, false)
+	tracker.reportRetirementPending("GPU-1", true)
+
+	// GPU-2 fell off the bus while its fabric is degraded
+	tracker.reportXid("GPU-2", 79)
+	tracker.reportFabricHealth("GPU-2", nvml.GPU_FABRIC_HEALTH_SUMMARY_LIMITED_CAPACITY)
+
+	// GPU-3 had a critical Xid long enough ago to have aged out
+	tracker.reportXid("GPU-3", 79)
+	now = now.Add(2 * time.Hour)
+	tracker.reportXid("GPU-2", 79)
+
+	expected := `
+# HELP nvgpu_gpu_health_summary Combined GPU health (0=ok, 1=degraded, 2=failed) derived from fabric health, recent critical Xids, pending page retirements and NVLink down-links.
+# TYPE nvgpu_gpu_health_summary gauge
+nvgpu_gpu_health_summary{UUID="GPU-0",pci_bus_id="0000:18:00.0",reason="none"} 0
+nvgpu_gpu_health_summary{UUID="GPU-1",pci_bus_id="0000:28:00.0",reason="retirement_pending,nvlink_down"} 1
+nvgpu_gpu_health_summary{UUID="GPU-2",pci_bus_id="0000:38:00.0",reason="critical_xid,fabric_limited_capacity"} 2
+nvgpu_gpu_health_summary{UUID="GPU-3",pci_bus_id="0000:48:00.0",reason="none"} 0
+`
+	assert.Is(hammy.NilError(testutil.CollectAndCompare(tracker, strings.NewReader(expected))))
+
+	// The link coming back clears the degradation
+	tracker.reportNVLinkState("GPU-1", 3, true)
+	tracker.reportRetirementPending("GPU-1", false)
+	level, reasons := tracker.evaluate(tracker.gpus["GPU-1"])
+	assert.Is(hammy.Number(level).EqualTo(gpuHealthOK))
+	assert.Is(hammy.Number(len(reasons)).EqualTo(0))
+}
+
+func TestNilGpuHealthTrackerIgnoresReports(t *testing.T) {
+	var tracker *gpuHealthTracker
+	tracker.reportFabricHealth("GPU-0", nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY)
+	tracker.reportXid("GPU-0", 79)
+	tracker.reportRetirementPending("GPU-0", true)
+	tracker.reportNVLinkState("GPU-0", 0, false)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// healthWatchHookTimeout bounds a single run of -health-watch-hook.
+const healthWatchHookTimeout = 30 * time.Second
+
+var healthWatchViolations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "health_watch_violations_total",
+		Help:      "Violations of a -health-watches condition: every matching Xid, and every time an ECC, temperature or NVLink condition starts to hold.",
+	},
+	[]string{"UUID", "pci_bus_id", "watch"},
+)
+
+// healthWatch is a named condition of -health-watches. Exactly one condition
+// is set per watch.
+type healthWatch struct {
+	Name string `json:"name"`
+	// Xids is violated by every Xid in the list
+	Xids []uint64 `json:"xids,omitempty"`
+	// EccDbe is violated once the volatile double bit ECC error count reaches it
+	EccDbe *uint64 `json:"ecc_dbe,omitempty"`
+	// TemperatureCelsius is violated while the GPU is at or above it
+	TemperatureCelsius *uint32 `json:"temperature_celsius,omitempty"`
+	// NVLinkDown is violated while an NVLink that was active is down
+	NVLinkDown bool `json:"nvlink_down,omitempty"`
+}
+
+// conditions returns the number of conditions set on w.
+func (w healthWatch) conditions() int {
+	var n int
+	for _, set := range []bool{len(w.Xids) > 0, w.EccDbe != nil, w.TemperatureCelsius != nil, w.NVLinkDown} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// healthWatchesFile is the format of -health-watches.
+type healthWatchesFile struct {
+	Watches []healthWatch `json:"watches"`
+}
+
+// loadHealthWatches reads the watches from path.
+func loadHealthWatches(path string) ([]healthWatch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file healthWatchesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(file.Watches))
+	for i, w := range file.Watches {
+		switch {
+		case w.Name == "":
+			return nil, fmt.Errorf("watch %d in %s has no name", i, path)
+		case names[w.Name]:
+			return nil, fmt.Errorf("duplicate watch %q in %s", w.Name, path)
+		case w.conditions() != 1:
+			return nil, fmt.Errorf("watch %q in %s must set exactly one of xids, ecc_dbe, temperature_celsius and nvlink_down", w.Name, path)
+		}
+		names[w.Name] = true
+	}
+	return file.Watches, nil
+}
+
+// healthWatcher evaluates the -health-watches conditions on every collection,
+// similar to the DCGM policy manager, so that fleet policy lives next to the
+// GPUs instead of in PromQL. Violations are counted and optionally handed to a
+// local script. A nil *healthWatcher ignores every signal.
+type healthWatcher struct {
+	watches []healthWatch
+	hook    string
+	logger  *slog.Logger
+	// hooks tracks running hooks, so that tests can wait for them
+	hooks sync.WaitGroup
+
+	mu sync.Mutex
+	// xids holds the Xids reported per UUID since the last evaluation
+	xids map[string][]uint64
+	// violated holds the watch|UUID pairs whose condition held at the last evaluation
+	violated map[string]bool
+}
+
+func newHealthWatcher(watches []healthWatch, hook string, logger *slog.Logger) *healthWatcher {
+	return &healthWatcher{
+		watches:  watches,
+		hook:     hook,
+		logger:   logger,
+		xids:     make(map[string][]uint64),
+		violated: make(map[string]bool),
+	}
+}
+
+// reportXid queues an Xid of GPU uuid for the next evaluation.
+func (w *healthWatcher) reportXid(uuid string, xid uint64) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	w.xids[uuid] = append(w.xids[uuid], xid)
+	w.mu.Unlock()
+}
+
+// needs reports whether any watch matches cond, so that readings no watch uses
+// are not queried.
+func (w *healthWatcher) needs(cond func(healthWatch) bool) bool {
+	return slices.ContainsFunc(w.watches, cond)
+}
+
+// evaluate checks every watch against every device.
+func (w *healthWatcher) evaluate(devices []Device, health *gpuHealthTracker) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	xids := w.xids
+	w.xids = make(map[string][]uint64)
+	w.mu.Unlock()
+
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			w.logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			w.logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		var dbe, temperature float64
+		var hasDbe, hasTemperature bool
+		if w.needs(func(hw healthWatch) bool { return hw.EccDbe != nil }) {
+			dbe, hasDbe = w.readEccDbe(device, uuid)
+		}
+		if w.needs(func(hw healthWatch) bool { return hw.TemperatureCelsius != nil }) {
+			celsius, ret := device.GetTemperature(nvml.TEMPERATURE_GPU)
+			if errors.Is(ret, nvml.SUCCESS) {
+				temperature, hasTemperature = float64(celsius), true
+			} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				w.logger.Warn("failed to get temperature", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+		}
+		downLinks := health.downLinks(uuid)
+
+		for _, watch := range w.watches {
+			switch {
+			case len(watch.Xids) > 0:
+				for _, xid := range xids[uuid] {
+					if slices.Contains(watch.Xids, xid) {
+						w.violate(watch.Name, uuid, pciBusId, fmt.Sprintf("xid %d", xid))
+					}
+				}
+			case watch.EccDbe != nil:
+				if hasDbe {
+					w.transition(watch.Name, uuid, pciBusId, dbe >= float64(*watch.EccDbe), fmt.Sprintf("%g volatile double bit ECC errors", dbe))
+				}
+			case watch.TemperatureCelsius != nil:
+				if hasTemperature {
+					w.transition(watch.Name, uuid, pciBusId, temperature >= float64(*watch.TemperatureCelsius), fmt.Sprintf("temperature %g C", temperature))
+				}
+			case watch.NVLinkDown:
+				links := make([]string, 0, len(downLinks))
+				for _, link := range downLinks {
+					links = append(links, strconv.Itoa(link))
+				}
+				w.transition(watch.Name, uuid, pciBusId, len(downLinks) > 0, "nvlink down: "+strings.Join(links, ","))
+			}
+		}
+	}
+}
+
+// readEccDbe returns the volatile double bit ECC error count of device.
+func (w *healthWatcher) readEccDbe(device Device, uuid string) (float64, bool) {
+	values := []nvml.FieldValue{{FieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}}
+	ret := device.GetFieldValues(values)
+	if errors.Is(ret, nvml.SUCCESS) {
+		ret = nvml.Return(values[0].NvmlReturn)
+	}
+	if !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			w.logger.Warn("failed to get ECC double bit errors", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+		return 0, false
+	}
+
+	v, err := fieldValueToFloat64(values[0])
+	if err != nil {
+		w.logger.Debug("failed to decode ECC double bit errors", "uuid", uuid, "err", err)
+		return 0, false
+	}
+	return v, true
+}
+
+// transition records whether a condition holds and counts a violation when it
+// starts to hold.
+func (w *healthWatcher) transition(watch, uuid, pciBusId string, holds bool, detail string) {
+	key := watch + "|" + uuid
+
+	w.mu.Lock()
+	started := holds && !w.violated[key]
+	w.violated[key] = holds
+	w.mu.Unlock()
+
+	if started {
+		w.violate(watch, uuid, pciBusId, detail)
+	}
+}
+
+// violate counts a violation and runs the hook in the background.
+func (w *healthWatcher) violate(watch, uuid, pciBusId, detail string) {
+	healthWatchViolations.WithLabelValues(uuid, pciBusId, watch).Inc()
+	w.logger.Warn("health watch violated", "watch", watch, "uuid", uuid, "pci_bus_id", pciBusId, "detail", detail)
+
+	if w.hook == "" {
+		return
+	}
+	w.hooks.Add(1)
+	goCollector("health_watch_hook", func() {
+		defer w.hooks.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), healthWatchHookTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, w.hook, watch, uuid)
+		cmd.Env = append(os.Environ(),
+			"NVGPU_WATCH="+watch,
+			"NVGPU_UUID="+uuid,
+			"NVGPU_PCI_BUS_ID="+pciBusId,
+			"NVGPU_DETAIL="+detail,
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			w.logger.Warn("health watch hook failed", "hook", w.hook, "watch", watch, "uuid", uuid, "err", err, "output", string(out))
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type watchDevice struct {
+	fakeDevice
+	celsius uint32
+}
+
+func (d *watchDevice) GetTemperature(nvml.TemperatureSensors) (uint32, nvml.Return) {
+	return d.celsius, nvml.SUCCESS
+}
+
+func TestHealthWatcherEvaluate(t *testing.T) {
+	assert := hammy.New(t)
+	healthWatchViolations.Reset()
+
+	dbe, hot := uint64(1), uint32(90)
+	watcher := newHealthWatcher([]healthWatch{
+		{Name: "fell_off_bus", Xids: []uint64{79}},
+		{Name: "ecc_dbe", EccDbe: &dbe},
+		{Name: "hot", TemperatureCelsius: &hot},
+		{Name: "nvlink_down", NVLinkDown: true},
+	}, "", discardLogger())
+
+	gpu0 := &watchDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, celsius: 95}
+	gpu1 := &watchDevice{fakeDevice: fakeDevice{
+		uuid:     "GPU-1",
+		pciBusId: "0000:2A:00.0",
+		fields:   map[nvlinkFieldKey]uint64{{fieldId: nvml.FI_DEV_ECC_DBE_VOL_TOTAL}: 2},
+	}, celsius: 60}
+	devices := []Device{gpu0, gpu1}
+
+	health := newGpuHealthTracker(nil, nil, nil, 0)
+	health.watcher = watcher
+	health.reportNVLinkState("GPU-1", 3, true)
+	health.reportNVLinkState("GPU-1", 3, false)
+	health.reportXid("GPU-0", 79)
+	health.reportXid("GPU-0", 13)
+
+	violations := func(uuid, pciBusId, watch string) float64 {
+		return testutil.ToFloat64(healthWatchViolations.WithLabelValues(uuid, pciBusId, watch))
+	}
+
+	watcher.evaluate(devices, health)
+	assert.Is(hammy.Number(violations("GPU-0", "0000:18:00.0", "fell_off_bus")).EqualTo(1))
+	assert.Is(hammy.Number(violations("GPU-0", "0000:18:00.0", "hot")).EqualTo(1))
+	assert.Is(hammy.Number(violations("GPU-1", "0000:2A:00.0", "ecc_dbe")).EqualTo(1))
+	assert.Is(hammy.Number(violations("GPU-1", "0000:2A:00.0", "nvlink_down")).EqualTo(1))
+
+	// Conditions that keep holding are not counted again, Xids are not replayed
+	watcher.evaluate(devices, health)
+	assert.Is(hammy.Number(violations("GPU-0", "0000:18:00.0", "fell_off_bus")).EqualTo(1))
+	assert.Is(hammy.Number(violations("GPU-0", "0000:18:00.0", "hot")).EqualTo(1))
+
+	// A condition that clears and holds again is a new violation
+	gpu0.celsius = 80
+	watcher.evaluate(devices, health)
+	gpu0.celsius = 91
+	watcher.evaluate(devices, health)
+	assert.Is(hammy.Number(violations("GPU-0", "0000:18:00.0", "hot")).EqualTo(2))
+	assert.Is(hammy.Number(violations("GPU-1", "0000:2A:00.0", "hot")).EqualTo(0))
+}
+
+func TestHealthWatcherHook(t *testing.T) {
+	assert := hammy.New(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	hook := filepath.Join(dir, "hook.sh")
+	script := "#!/bin/sh\necho \"$1 $2 $NVGPU_PCI_BUS_ID $NVGPU_DETAIL\" >> " + out + "\n"
+	assert.Is(hammy.NilError(os.WriteFile(hook, []byte(script), 0o755)))
+
+	watcher := newHealthWatcher([]healthWatch{{Name: "fell_off_bus", Xids: []uint64{79}}}, hook, discardLogger())
+	watcher.reportXid("GPU-0", 79)
+	watcher.evaluate([]Device{&fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}}, nil)
+	watcher.hooks.Wait()
+
+	data, err := os.ReadFile(out)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.String(string(data)).EqualTo("fell_off_bus GPU-0 0000:18:00.0 xid 79\n"))
+}
+
+func TestLoadHealthWatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"watches": [{"name": "xid", "xids": [79]}, {"name": "hot", "temperature_celsius": 90}]}`, false},
+		{"no condition", `{"watches": [{"name": "empty"}]}`, true},
+		{"two conditions", `{"watches": [{"name": "both", "xids": [79], "nvlink_down": true}]}`, true},
+		{"duplicate", `{"watches": [{"name": "xid", "xids": [79]}, {"name": "xid", "xids": [48]}]}`, true},
+		{"unnamed", `{"watches": [{"xids": [79]}]}`, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			path := filepath.Join(t.TempDir(), "watches.json")
+			assert.Is(hammy.NilError(os.WriteFile(path, []byte(tc.content), 0o644)))
+
+			_, err := loadHealthWatches(path)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"runtime"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// infoAPIPath is the route of the exporter info document.
+const infoAPIPath = "GET /-/info"
+
+// infoConfigFlags are the flags whose values the info document serves. The
+// document is served to anyone who can reach -addr, so flags that hold file
+// paths, hook scripts, remote targets, addresses or templates are left out.
+var infoConfigFlags = map[string]bool{
+	"addr":                           true,
+	"web.max-requests":               true,
+	"http-max-header-size":           true,
+	"pprof":                          true,
+	"scrape-timeout":                 true,
+	"metrics-cache":                  true,
+	"metrics-timestamps":             true,
+	"access-log":                     true,
+	"collection-interval":            true,
+	"collection-align":               true,
+	"adaptive-utilization-threshold": true,
+	"adaptive-slowdown":              true,
+	"max-series":                     true,
+	"go-metrics":                     true,
+	"process-metrics":                true,
+	"memory-limit":                   true,
+	"collection-jitter":              true,
+	"burst-interval":                 true,
+	"burst-duration":                 true,
+	"nvlink-legacy-ber":              true,
+	"nvlink-effective-ber-threshold": true,
+	"nvlink-symbol-ber-threshold":    true,
+	"critical-xids":                  true,
+	"health-xid-window":              true,
+	"k8s-node-labels":                true,
+	"k8s-node-events":                true,
+	"k8s-event-interval":             true,
+	"k8s-critical-xids":              true,
+	"probe":                          true,
+	"probe-only":                     true,
+	"probe-timeout":                  true,
+	"rack-clique-size":               true,
+	"xid-wait-timeout":               true,
+	"xid-event-shards":               true,
+	"pcie-topology":                  true,
+	"nvml-retry-interval":            true,
+	"respect-visibility":             true,
+	"location-labels":                true,
+	"topology-gpu-id":                true,
+	"clock-event-reasons":            true,
+	"redact-asset-labels":            true,
+	"nvlink-utilization":             true,
+	"mps-clients":                    true,
+	"export-deltas":                  true,
+	"grace":                          true,
+	"nvlink-history":                 true,
+	"native-histograms":              true,
+	"nvlink-fec-histogram":           true,
+}
+
+// exporterInfoDocument describes a running exporter, so that fleet tooling
+// can audit its version and configuration without logging into the node.
+type exporterInfoDocument struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+	// Collectors lists the collectors run every collection round
+	Collectors []string `json:"collectors"`
+	// Config holds the effective value of the infoConfigFlags, defaults
+	// included
+	Config   map[string]string `json:"config"`
+	Hardware hardwareInfo      `json:"hardware"`
+}
+
+// hardwareInfo is the driver and GPU capabilities detected at startup.
+type hardwareInfo struct {
+	DriverVersion string            `json:"driver_version"`
+	CudaVersion   string            `json:"cuda_version"`
+	NVMLVersion   string            `json:"nvml_version"`
+	GPUs          []gpuCapabilities `json:"gpus"`
+}
+
+// gpuCapabilities are the optional features a GPU supports.
+type gpuCapabilities struct {
+	UUID              string `json:"uuid"`
+	PciBusId          string `json:"pci_bus_id"`
+	Name              string `json:"name"`
+	Architecture      string `json:"architecture"`
+	ComputeCapability string `json:"compute_capability"`
+	// NVLinks is the number of links that are not unused
+	NVLinks      int  `json:"nvlinks"`
+	Fabric       bool `json:"fabric"`
+	RowRemapping bool `json:"row_remapping"`
+}
+
+// newExporterInfoDocument collects the info document; the flags are read from
+// fs after parsing.
+func newExporterInfoDocument(fs *flag.FlagSet, collectors []string, exporter *ExporterInfo, devices []Device, infos []*GpuInfo) *exporterInfoDocument {
+	doc := &exporterInfoDocument{
+		Version:    version,
+		Commit:     commit,
+		GoVersion:  runtime.Version(),
+		Collectors: collectors,
+		Config:     make(map[string]string),
+		Hardware: hardwareInfo{
+			DriverVersion: exporter.DriverVersion,
+			CudaVersion:   exporter.CudaVersion,
+			NVMLVersion:   exporter.NVMLVersion,
+			GPUs:          make([]gpuCapabilities, 0, len(devices)),
+		},
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if infoConfigFlags[f.Name] {
+			doc.Config[f.Name] = f.Value.String()
+		}
+	})
+	for i, device := range devices {
+		doc.Hardware.GPUs = append(doc.Hardware.GPUs, detectCapabilities(device, infos[i]))
+	}
+	return doc
+}
+
+func detectCapabilities(device Device, info *GpuInfo) gpuCapabilities {
+	c := gpuCapabilities{
+		UUID:              info.UUID,
+		PciBusId:          info.PciBusId,
+		Name:              info.Name,
+		Architecture:      info.Architecture,
+		ComputeCapability: info.ComputeCapability,
+	}
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		if _, ret := device.GetNvLinkState(link); errors.Is(ret, nvml.SUCCESS) {
+			c.NVLinks++
+		}
+	}
+	if fabric, ret := device.GetGpuFabricInfoV2(); errors.Is(ret, nvml.SUCCESS) {
+		c.Fabric = fabric.State != nvml.GPU_FABRIC_STATE_NOT_SUPPORTED
+	}
+	_, _, _, _, ret := device.GetRemappedRows()
+	c.RowRemapping = errors.Is(ret, nvml.SUCCESS)
+	return c
+}
+
+// infoHandler serves the info document.
+func infoHandler(doc *exporterInfoDocument, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(doc); err != nil {
+			logger.Debug("failed to write exporter info", "err", err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestInfoHandler(t *testing.T) {
+	assert := hammy.New(t)
+	lib, err := newSimulatedLibrary("2xH100")
+	assert.Is(hammy.NilError(err))
+	devices, shutdown, err := New(newNvmlClient(lib), nil, discardLogger())
+	assert.Is(hammy.NilError(err))
+	defer shutdown()
+	infos, err := loadGpuInfos(devices)
+	assert.Is(hammy.NilError(err))
+	exporter, err := devices.ExporterInfo()
+	assert.Is(hammy.NilError(err))
+
+	var cfg Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.registerFlags(fs)
+	assert.Is(hammy.NilError(fs.Parse([]string{"-collection-interval", "15s", "-health-watch-hook", "/opt/hooks/drain.sh"})))
+	doc := newExporterInfoDocument(fs, []string{"fabric_health", "nvlink"}, exporter, devices.handles, infos)
+
+	mux := http.NewServeMux()
+	mux.Handle(infoAPIPath, infoHandler(doc, discardLogger()))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/info", nil))
+
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+	var got exporterInfoDocument
+	assert.Is(hammy.NilError(json.Unmarshal(rec.Body.Bytes(), &got)))
+	assert.Is(hammy.String(got.Version).EqualTo(version))
+	assert.Is(hammy.Number(len(got.Collectors)).EqualTo(2))
+	assert.Is(hammy.String(got.Config["collection-interval"]).EqualTo("15s"))
+	assert.Is(hammy.String(got.Config["addr"]).EqualTo(":9400"))
+	_, ok := got.Config["health-watch-hook"]
+	assert.Is(hammy.False(ok))
+	assert.Is(hammy.String(got.Hardware.DriverVersion).EqualTo(exporter.DriverVersion))
+	assert.Is(hammy.Number(len(got.Hardware.GPUs)).EqualTo(2))
+	assert.Is(hammy.String(got.Hardware.GPUs[0].Architecture).EqualTo("hopper"))
+	assert.Is(hammy.Number(got.Hardware.GPUs[0].NVLinks).EqualTo(18))
+	assert.Is(hammy.True(got.Hardware.GPUs[0].RowRemapping))
+}
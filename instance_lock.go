@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instanceLockRetryInterval is how often a waiting instance retries the lock.
+const instanceLockRetryInterval = time.Second
+
+var duplicateInstance = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_duplicate_instance_detected",
+		Help:      "Whether another exporter instance holds -instance-lock (1 = this instance waits and does not collect Xid events, 0 = this instance holds the lock).",
+	},
+	[]string{"instance_id"},
+)
+
+// instanceLock is an exclusive lock on -instance-lock that keeps two exporters
+// on one node, e.g. during a rolling DaemonSet update, from both registering
+// for Xid events and counting every Xid twice. The file holds the ID and PID
+// of the holder. A nil *instanceLock is always held.
+type instanceLock struct {
+	id   string
+	file *os.File
+}
+
+// openInstanceLock opens, creating if needed, the lock file at path for the
+// instance id without locking it.
+func openInstanceLock(path, id string) (*instanceLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &instanceLock{id: id, file: file}, nil
+}
+
+// defaultInstanceID identifies the exporter by host name and PID when
+// -instance-id is not set.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// tryLock takes the lock if no other instance holds it and records the result
+// in nvgpu_exporter_duplicate_instance_detected. It only reports false without
+// an error when another instance holds the lock; an error together with true
+// means the lock is held but the holder could not be recorded.
+func (l *instanceLock) tryLock() (bool, error) {
+	if l == nil {
+		return true, nil
+	}
+
+	err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		duplicateInstance.WithLabelValues(l.id).Set(1)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock %s: %w", l.file.Name(), err)
+	}
+
+	duplicateInstance.WithLabelValues(l.id).Set(0)
+	if err := l.file.Truncate(0); err != nil {
+		return true, err
+	}
+	_, err = l.file.WriteAt([]byte(fmt.Sprintf("%s %d\n", l.id, os.Getpid())), 0)
+	return true, err
+}
+
+// wait blocks until the lock is taken, as long as another instance holds it.
+// Any other failure to lock is returned instead of retried.
+func (l *instanceLock) wait(interval time.Duration, logger *slog.Logger) error {
+	for {
+		locked, err := l.tryLock()
+		if locked {
+			if err != nil {
+				logger.Warn("failed to write instance lock", "path", l.file.Name(), "err", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		time.Sleep(interval)
+	}
+}
+
+// holder returns the ID and PID recorded by the instance holding the lock.
+func (l *instanceLock) holder() string {
+	data, err := os.ReadFile(l.file.Name())
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(data))
+}
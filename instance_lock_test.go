@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstanceLock(t *testing.T) {
+	assert := hammy.New(t)
+	duplicateInstance.Reset()
+	path := filepath.Join(t.TempDir(), "instance.lock")
+
+	first, err := openInstanceLock(path, "exporter-a")
+	assert.Is(hammy.NilError(err))
+	second, err := openInstanceLock(path, "exporter-b")
+	assert.Is(hammy.NilError(err))
+
+	locked, err := first.tryLock()
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(locked))
+	assert.Is(hammy.Number(testutil.ToFloat64(duplicateInstance.WithLabelValues("exporter-a"))).EqualTo(0))
+
+	locked, err = second.tryLock()
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(!locked))
+	assert.Is(hammy.Number(testutil.ToFloat64(duplicateInstance.WithLabelValues("exporter-b"))).EqualTo(1))
+	assert.Is(hammy.String(second.holder()).EqualTo(fmt.Sprintf("exporter-a %d", os.Getpid())))
+
+	// The waiting instance takes over once the holder exits
+	done := make(chan error)
+	go func() {
+		done <- second.wait(time.Millisecond, discardLogger())
+	}()
+	assert.Is(hammy.NilError(first.file.Close()))
+	assert.Is(hammy.NilError(<-done))
+	assert.Is(hammy.Number(testutil.ToFloat64(duplicateInstance.WithLabelValues("exporter-b"))).EqualTo(0))
+	assert.Is(hammy.String(second.holder()).EqualTo(fmt.Sprintf("exporter-b %d", os.Getpid())))
+
+	// Without -instance-lock the lock is always held
+	var none *instanceLock
+	locked, err = none.tryLock()
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(locked))
+}
+
+func TestInstanceLockError(t *testing.T) {
+	assert := hammy.New(t)
+	duplicateInstance.Reset()
+
+	lock, err := openInstanceLock(filepath.Join(t.TempDir(), "instance.lock"), "exporter-a")
+	assert.Is(hammy.NilError(err))
+	// flock fails with EBADF rather than reporting another holder
+	assert.Is(hammy.NilError(lock.file.Close()))
+
+	locked, err := lock.tryLock()
+	assert.Is(hammy.Error(err))
+	assert.Is(hammy.True(!locked))
+	assert.Is(hammy.Number(testutil.CollectAndCount(duplicateInstance)).EqualTo(0))
+
+	// Waiting gives up instead of retrying forever
+	assert.Is(hammy.Error(lock.wait(time.Millisecond, discardLogger())))
+}
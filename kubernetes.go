@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	labelFabricUnhealthy = "nvgpu.mlmon.io/fabric-unhealthy"
+	labelXidCritical     = "nvgpu.mlmon.io/xid-critical"
+)
+
+// stickyLabels are only ever set by the exporter. Their fault is not visible
+// after a restart, so removing them is left to the operator.
+var stickyLabels = map[string]bool{labelXidCritical: true}
+
+// kubeClient is a minimal Kubernetes API client using the in-cluster service
+// account. It intentionally avoids client-go to keep the dependency graph small.
+type kubeClient struct {
+	host      string
+	tokenFile string
+	http      *http.Client
+}
+
+// newInClusterKubeClient builds a client from the service account mounted into the pod.
+func newInClusterKubeClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set (not running in a cluster?)")
+	}
+
+	caCert, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to parse service account CA")
+	}
+
+	return &kubeClient{
+		host:      "https://" + net.JoinHostPort(host, port),
+		tokenFile: serviceAccountDir + "/token",
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+// do sends a request to the API server and returns an error for non-2xx responses.
+func (k *kubeClient) do(ctx context.Context, method, path, contentType string, body []byte) error {
+	// Bound service account tokens are rotated, so re-read the token every request
+	token, err := os.ReadFile(k.tokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, k.host+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// patchNodeLabels sets or removes (nil value) labels on a node using a JSON merge patch.
+func (k *kubeClient) patchNodeLabels(ctx context.Context, node string, labels map[string]*string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": labels},
+	})
+	if err != nil {
+		return err
+	}
+	return k.do(ctx, http.MethodPatch, "/api/v1/nodes/"+node, "application/merge-patch+json", patch)
+}
+
+// nodeLabeler tracks GPU health signals and mirrors them onto node labels so
+// that taints or scheduling rules can react without an extra operator. A nil
+// *nodeLabeler is valid and ignores every signal.
+type nodeLabeler struct {
+	client       *kubeClient
+	nodeName     string
+	criticalXids map[uint64]bool
+	logger       *slog.Logger
+
+	mu              sync.Mutex
+	fabricUnhealthy map[string]bool
+	criticalXidSeen bool
+	applied         map[string]bool
+	appliedOnce     bool
+	changed         chan struct{}
+}
+
+func newNodeLabeler(client *kubeClient, nodeName string, criticalXids []uint64, logger *slog.Logger) *nodeLabeler {
+	xids := make(map[uint64]bool, len(criticalXids))
+	for _, xid := range criticalXids {
+		xids[xid] = true
+	}
+
+	return &nodeLabeler{
+		client:          client,
+		nodeName:        nodeName,
+		criticalXids:    xids,
+		logger:          logger,
+		fabricUnhealthy: make(map[string]bool),
+		applied:         make(map[string]bool),
+		changed:         make(chan struct{}, 1),
+	}
+}
+
+// reportFabricHealth records whether the fabric of GPU uuid is unhealthy.
+func (l *nodeLabeler) reportFabricHealth(uuid string, unhealthy bool) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	changed := l.fabricUnhealthy[uuid] != unhealthy
+	l.fabricUnhealthy[uuid] = unhealthy
+	l.mu.Unlock()
+
+	if changed {
+		l.notify()
+	}
+}
+
+// reportXid records an Xid event; critical Xids mark the node until an operator removes the label.
+func (l *nodeLabeler) reportXid(uuid string, xid uint64) {
+	if l == nil || !l.criticalXids[xid] {
+		return
+	}
+
+	l.mu.Lock()
+	changed := !l.criticalXidSeen
+	l.criticalXidSeen = true
+	l.mu.Unlock()
+
+	if changed {
+		l.logger.Warn("critical Xid observed, labeling node", "uuid", uuid, "xid", xid, "node", l.nodeName)
+		l.notify()
+	}
+}
+
+func (l *nodeLabeler) notify() {
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
+// desired returns the label state implied by the current health signals.
+func (l *nodeLabeler) desired() map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fabricUnhealthy := false
+	for _, unhealthy := range l.fabricUnhealthy {
+		fabricUnhealthy = fabricUnhealthy || unhealthy
+	}
+
+	return map[string]bool{
+		labelFabricUnhealthy: fabricUnhealthy,
+		labelXidCritical:     l.criticalXidSeen,
+	}
+}
+
+// run applies label changes as they are reported, retrying failed patches every retryInterval.
+func (l *nodeLabeler) run(retryInterval time.Duration) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		l.sync()
+
+		select {
+		case <-l.changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *nodeLabeler) sync() {
+	desired := l.desired()
+
+	patch := make(map[string]*string)
+	for label, set := range desired {
+		if l.appliedOnce && l.applied[label] == set {
+			continue
+		}
+		if !set && stickyLabels[label] {
+			continue
+		}
+		if set {
+			value := "true"
+			patch[label] = &value
+		} else {
+			patch[label] = nil
+		}
+	}
+	if len(patch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := l.client.patchNodeLabels(ctx, l.nodeName, patch); err != nil {
+		l.logger.Warn("failed to patch node labels", "node", l.nodeName, "err", err)
+		return
+	}
+
+	l.applied = desired
+	l.appliedOnce = true
+	l.logger.Info("updated node labels", "node", l.nodeName, "labels", desired)
+}
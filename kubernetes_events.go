@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// nodeEventNamespace is where the API server keeps the events of
+	// cluster-scoped objects such as nodes
+	nodeEventNamespace = "default"
+	// nodeEventQueue bounds the events waiting to be posted; further events
+	// are dropped while the API server is slow
+	nodeEventQueue = 64
+
+	eventReasonCriticalXid     = "GPUCriticalXid"
+	eventReasonFabricUnhealthy = "GPUFabricUnhealthy"
+	eventReasonFabricHealthy   = "GPUFabricHealthy"
+)
+
+// kubeEvent is a core/v1 Event, with only the fields the exporter sets.
+type kubeEvent struct {
+	Metadata       kubeObjectMeta      `json:"metadata"`
+	InvolvedObject kubeObjectReference `json:"involvedObject"`
+	Reason         string              `json:"reason"`
+	Message        string              `json:"message"`
+	Type           string              `json:"type"`
+	Source         kubeEventSource     `json:"source"`
+	FirstTimestamp time.Time           `json:"firstTimestamp"`
+	LastTimestamp  time.Time           `json:"lastTimestamp"`
+	Count          int                 `json:"count"`
+}
+
+type kubeObjectMeta struct {
+	GenerateName string `json:"generateName"`
+	Namespace    string `json:"namespace"`
+}
+
+type kubeObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// UID is the node name, as the kubelet sets it on node events, so that
+	// kubectl describe node finds them by involvedObject.uid
+	UID string `json:"uid"`
+}
+
+type kubeEventSource struct {
+	Component string `json:"component"`
+	Host      string `json:"host"`
+}
+
+// createEvent posts event to its namespace.
+func (k *kubeClient) createEvent(ctx context.Context, event kubeEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return k.do(ctx, http.MethodPost, "/api/v1/namespaces/"+event.Metadata.Namespace+"/events", "application/json", body)
+}
+
+// nodeEventRecorder posts Kubernetes events to the exporter's node for
+// critical Xids and fabric health transitions, so that GPU faults show up in
+// kubectl describe node. Each reason is posted at most once per interval and
+// GPU, so that an Xid storm does not flood the API server. A nil
+// *nodeEventRecorder is valid and ignores every signal.
+type nodeEventRecorder struct {
+	client       *kubeClient
+	nodeName     string
+	criticalXids map[uint64]bool
+	interval     time.Duration
+	now          func() time.Time
+	logger       *slog.Logger
+
+	mu              sync.Mutex
+	fabricUnhealthy map[string]bool
+	// lastPosted holds when an event was last queued per reason and GPU
+	lastPosted map[string]time.Time
+	queue      chan kubeEvent
+}
+
+func newNodeEventRecorder(client *kubeClient, nodeName string, criticalXids []uint64, interval time.Duration, logger *slog.Logger) *nodeEventRecorder {
+	xids := make(map[uint64]bool, len(criticalXids))
+	for _, xid := range criticalXids {
+		xids[xid] = true
+	}
+
+	return &nodeEventRecorder{
+		client:          client,
+		nodeName:        nodeName,
+		criticalXids:    xids,
+		interval:        interval,
+		now:             time.Now,
+		logger:          logger,
+		fabricUnhealthy: make(map[string]bool),
+		lastPosted:      make(map[string]time.Time),
+		queue:           make(chan kubeEvent, nodeEventQueue),
+	}
+}
+
+// reportFabricHealth posts an event when the fabric of GPU uuid turns
+// unhealthy and when it recovers.
+func (r *nodeEventRecorder) reportFabricHealth(uuid string, unhealthy bool) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	changed := r.fabricUnhealthy[uuid] != unhealthy
+	r.fabricUnhealthy[uuid] = unhealthy
+	r.mu.Unlock()
+
+	switch {
+	case changed && unhealthy:
+		r.post(eventReasonFabricUnhealthy, uuid, "Warning", fmt.Sprintf("NVLink fabric of GPU %s is unhealthy", uuid))
+	case changed:
+		r.post(eventReasonFabricHealthy, uuid, "Normal", fmt.Sprintf("NVLink fabric of GPU %s recovered", uuid))
+	}
+}
+
+// reportXid posts an event for a critical Xid.
+func (r *nodeEventRecorder) reportXid(uuid string, xid uint64) {
+	if r == nil || !r.criticalXids[xid] {
+		return
+	}
+	r.post(eventReasonCriticalXid, uuid, "Warning", fmt.Sprintf("Critical Xid %d on GPU %s", xid, uuid))
+}
+
+// post queues an event unless one with the same reason was queued for the GPU
+// within the interval.
+func (r *nodeEventRecorder) post(reason, uuid, eventType, message string) {
+	now := r.now()
+	key := reason + "|" + uuid
+
+	r.mu.Lock()
+	last, ok := r.lastPosted[key]
+	limited := ok && now.Sub(last) < r.interval
+	if !limited {
+		r.lastPosted[key] = now
+	}
+	r.mu.Unlock()
+	if limited {
+		r.logger.Debug("rate limiting node event", "reason", reason, "uuid", uuid, "message", message)
+		return
+	}
+
+	event := kubeEvent{
+		Metadata:       kubeObjectMeta{GenerateName: r.nodeName + ".", Namespace: nodeEventNamespace},
+		InvolvedObject: kubeObjectReference{APIVersion: "v1", Kind: "Node", Name: r.nodeName, UID: r.nodeName},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         kubeEventSource{Component: "nvgpu-exporter", Host: r.nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	select {
+	case r.queue <- event:
+	default:
+		r.logger.Warn("dropping node event, too many pending", "reason", reason, "uuid", uuid)
+	}
+}
+
+// run posts queued events; reports do not wait on the API server.
+func (r *nodeEventRecorder) run() {
+	for event := range r.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		if err := r.client.createEvent(ctx, event); err != nil {
+			r.logger.Warn("failed to post node event", "node", r.nodeName, "reason", event.Reason, "err", err)
+		}
+		cancel()
+	}
+}
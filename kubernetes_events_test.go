@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestNodeEventRecorderPostsRateLimitedEvents(t *testing.T) {
+	assert := hammy.New(t)
+
+	var events []kubeEvent
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event kubeEvent
+		_ = json.Unmarshal(body, &event)
+		events = append(events, event)
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1000, 0)
+	recorder := newNodeEventRecorder(newTestKubeClient(t, srv), "node-a", []uint64{79}, 10*time.Minute, discardLogger())
+	recorder.now = func() time.Time { return now }
+
+	recorder.reportXid("GPU-1", 13)
+	recorder.reportXid("GPU-1", 79)
+	// Rate limited per reason and GPU
+	recorder.reportXid("GPU-1", 79)
+	recorder.reportXid("GPU-2", 79)
+	recorder.reportFabricHealth("GPU-1", false)
+	recorder.reportFabricHealth("GPU-1", true)
+	recorder.reportFabricHealth("GPU-1", true)
+	recorder.reportFabricHealth("GPU-1", false)
+	now = now.Add(11 * time.Minute)
+	recorder.reportXid("GPU-1", 79)
+
+	close(recorder.queue)
+	recorder.run()
+
+	assert.Is(hammy.Number(len(events)).EqualTo(5))
+	assert.Is(hammy.String(paths[0]).EqualTo("POST /api/v1/namespaces/default/events"))
+	assert.Is(hammy.String(events[0].Reason).EqualTo(eventReasonCriticalXid))
+	assert.Is(hammy.String(events[0].Type).EqualTo("Warning"))
+	assert.Is(hammy.String(events[0].Message).EqualTo("Critical Xid 79 on GPU GPU-1"))
+	assert.Is(hammy.String(events[0].InvolvedObject.Kind).EqualTo("Node"))
+	assert.Is(hammy.String(events[0].InvolvedObject.Name).EqualTo("node-a"))
+	assert.Is(hammy.String(events[1].Message).EqualTo("Critical Xid 79 on GPU GPU-2"))
+	assert.Is(hammy.String(events[2].Reason).EqualTo(eventReasonFabricUnhealthy))
+	assert.Is(hammy.String(events[3].Reason).EqualTo(eventReasonFabricHealthy))
+	assert.Is(hammy.String(events[3].Type).EqualTo("Normal"))
+	assert.Is(hammy.String(events[4].Reason).EqualTo(eventReasonCriticalXid))
+}
+
+func TestNilNodeEventRecorderIgnoresReports(t *testing.T) {
+	var recorder *nodeEventRecorder
+	recorder.reportFabricHealth("GPU-1", true)
+	recorder.reportXid("GPU-1", 79)
+}
+
+func TestNodeEventRecorderSetsInvolvedObjectUID(t *testing.T) {
+	assert := hammy.New(t)
+
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	recorder := newNodeEventRecorder(newTestKubeClient(t, srv), "node-a", []uint64{79}, 10*time.Minute, discardLogger())
+	recorder.reportXid("GPU-1", 79)
+	close(recorder.queue)
+	recorder.run()
+
+	// kubectl describe node selects events on involvedObject.uid
+	involved, ok := body["involvedObject"].(map[string]any)
+	assert.Is(hammy.True(ok))
+	assert.Is(hammy.True(involved["uid"] == "node-a"))
+	assert.Is(hammy.True(involved["kind"] == "Node"))
+	assert.Is(hammy.True(involved["name"] == "node-a"))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestNodeLabelerSyncPatchesLabels(t *testing.T) {
+	assert := hammy.New(t)
+
+	var patches []map[string]map[string]map[string]*string
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var patch map[string]map[string]map[string]*string
+		_ = json.Unmarshal(body, &patch)
+		patches = append(patches, patch)
+		paths = append(paths, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	labeler := newNodeLabeler(newTestKubeClient(t, srv), "node-a", []uint64{79}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Initial sync removes any stale labels
+	labeler.sync()
+	assert.Is(hammy.Number(len(patches)).EqualTo(1))
+	assert.Is(hammy.String(paths[0]).EqualTo("PATCH /api/v1/nodes/node-a Bearer secret"))
+	assert.Is(hammy.True(patches[0]["metadata"]["labels"][labelFabricUnhealthy] == nil))
+
+	// Non-critical Xids are ignored and nothing changed
+	labeler.reportXid("GPU-1", 13)
+	labeler.sync()
+	assert.Is(hammy.Number(len(patches)).EqualTo(1))
+
+	labeler.reportXid("GPU-1", 79)
+	labeler.reportFabricHealth("GPU-2", true)
+	labeler.sync()
+	assert.Is(hammy.Number(len(patches)).EqualTo(2))
+	assert.Is(hammy.String(*patches[1]["metadata"]["labels"][labelXidCritical]).EqualTo("true"))
+	assert.Is(hammy.String(*patches[1]["metadata"]["labels"][labelFabricUnhealthy]).EqualTo("true"))
+
+	// Recovery of the fabric only clears the fabric label
+	labeler.reportFabricHealth("GPU-2", false)
+	labeler.sync()
+	assert.Is(hammy.Number(len(patches)).EqualTo(3))
+	labels := patches[2]["metadata"]["labels"]
+	assert.Is(hammy.Number(len(labels)).EqualTo(1))
+	assert.Is(hammy.True(labels[labelFabricUnhealthy] == nil))
+}
+
+func TestNodeLabelerKeepsXidCriticalOnStartup(t *testing.T) {
+	assert := hammy.New(t)
+
+	var patches []map[string]map[string]map[string]*string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var patch map[string]map[string]map[string]*string
+		_ = json.Unmarshal(body, &patch)
+		patches = append(patches, patch)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// A restarted exporter has not seen the critical Xid of its predecessor
+	labeler := newNodeLabeler(newTestKubeClient(t, srv), "node-a", []uint64{79}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	labeler.sync()
+	assert.Is(hammy.Number(len(patches)).EqualTo(1))
+	labels := patches[0]["metadata"]["labels"]
+	_, patched := labels[labelXidCritical]
+	assert.Is(hammy.False(patched))
+	_, patched = labels[labelFabricUnhealthy]
+	assert.Is(hammy.True(patched))
+}
+
+func TestNilNodeLabelerIgnoresReports(t *testing.T) {
+	var labeler *nodeLabeler
+	labeler.reportFabricHealth("GPU-1", true)
+	labeler.reportXid("GPU-1", 79)
+}
+
+func newTestKubeClient(t *testing.T, srv *httptest.Server) *kubeClient {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &kubeClient{host: srv.URL, tokenFile: tokenFile, http: srv.Client()}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// liteMetricsPath serves liteMetrics only.
+const liteMetricsPath = "/metrics-lite"
+
+// liteMetrics are the low-cardinality metrics served on /metrics-lite for
+// nodes that cannot afford to ship the per-link series of /metrics: whether
+// the exporter and NVML work, per-GPU health, utilization and Xids, and the
+// node rollups.
+var liteMetrics = []prometheus.Collector{
+	exporterUp,
+	nvmlInitialized,
+	exporterInfo,
+	utilizationInterval,
+	xidErrors,
+	nodeGpus,
+	nodeMemoryUsed,
+	nodeMemoryTotal,
+	nodeUtilizationMean,
+	nodeNVLinksActive,
+}
+
+// liteGatherer returns the families of gatherer that are on /metrics-lite.
+type liteGatherer struct {
+	gatherer prometheus.Gatherer
+	names    map[string]bool
+}
+
+func newLiteGatherer(gatherer prometheus.Gatherer) liteGatherer {
+	return liteGatherer{gatherer: gatherer, names: liteMetricNames()}
+}
+
+// liteMetricNames returns the names of the families on /metrics-lite.
+func liteMetricNames() map[string]bool {
+	names := map[string]bool{descName(gpuHealthSummaryDesc): true}
+	for _, c := range liteMetrics {
+		names[metricName(c)] = true
+	}
+	return names
+}
+
+// Gather implements prometheus.Gatherer.
+func (g liteGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	lite := make([]*dto.MetricFamily, 0, len(g.names))
+	for _, mf := range families {
+		if g.names[mf.GetName()] {
+			lite = append(lite, mf)
+		}
+	}
+	return lite, err
+}
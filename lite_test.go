@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLiteGathererKeepsLiteMetrics(t *testing.T) {
+	assert := hammy.New(t)
+	resetXidMetric(t)
+	nvlinkUp.Reset()
+	t.Cleanup(nvlinkUp.Reset)
+
+	reg := prometheus.NewRegistry()
+	registerInitStatus(reg, true)
+	reg.MustRegister(xidErrors, nvlinkUp, nodeMemoryUsed)
+	xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", "79").Inc()
+	nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0").Set(1)
+
+	families, err := newLiteGatherer(reg).Gather()
+	assert.Is(hammy.NilError(err))
+
+	var names []string
+	for _, mf := range families {
+		names = append(names, mf.GetName())
+	}
+	assert.Is(hammy.Number(len(names)).EqualTo(4))
+	assert.Is(hammy.String(names[0]).EqualTo("nvgpu_node_memory_used_bytes"))
+	assert.Is(hammy.String(names[1]).EqualTo("nvgpu_nvml_initialized"))
+	assert.Is(hammy.String(names[2]).EqualTo("nvgpu_up"))
+	assert.Is(hammy.String(names[3]).EqualTo("nvgpu_xid_errors_total"))
+}
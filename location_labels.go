@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// locationField is a platform info label and how to read it from a GpuInfo.
+type locationField struct {
+	label string
+	value func(info *GpuInfo) string
+}
+
+// locationFields are the platform info labels of nvgpu_gpu_info that can be
+// copied onto fabric, NVLink error and Xid metrics with -location-labels.
+var locationFields = []locationField{
+	{"rack_guid", func(info *GpuInfo) string { return info.RackGuid }},
+	{"chassis_serial_number", func(info *GpuInfo) string { return info.ChassisSerialNumber }},
+	{"chassis_physical_slot", func(info *GpuInfo) string { return info.ChassisPhysicalSlot }},
+	{"tray_index", func(info *GpuInfo) string { return info.TrayIndex }},
+	{"slot_number", func(info *GpuInfo) string { return info.SlotNumber }},
+	{"compute_slot_index", func(info *GpuInfo) string { return info.ComputeSlotIndex }},
+	{"node_index", func(info *GpuInfo) string { return info.NodeIndex }},
+	{"host_id", func(info *GpuInfo) string { return info.HostId }},
+	{"module_id", func(info *GpuInfo) string { return info.ModuleId }},
+}
+
+// locationLabels adds the physical location of a GPU to the series of wrapped
+// collectors, so that alerts carry the rack, tray and slot without a join
+// against nvgpu_gpu_info. A nil *locationLabels wraps nothing.
+type locationLabels struct {
+	names []string
+	// values holds the label values, in the order of names, per UUID
+	values map[string][]string
+}
+
+// newLocationLabels resolves names against locationFields. It returns nil when
+// names is empty.
+func newLocationLabels(names []string, infos []*GpuInfo) (*locationLabels, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	fields := make([]func(info *GpuInfo) string, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(locationFields, func(f locationField) bool { return f.label == name })
+		if i < 0 {
+			supported := make([]string, 0, len(locationFields))
+			for _, f := range locationFields {
+				supported = append(supported, f.label)
+			}
+			return nil, fmt.Errorf("unknown location label %q (supported: %s)", name, strings.Join(supported, ", "))
+		}
+		fields = append(fields, locationFields[i].value)
+	}
+
+	l := &locationLabels{names: names, values: make(map[string][]string, len(infos))}
+	for _, info := range infos {
+		values := make([]string, 0, len(fields))
+		for _, field := range fields {
+			values = append(values, field(info))
+		}
+		l.values[info.UUID] = values
+	}
+	return l, nil
+}
+
+// wrap returns c with the location labels appended to every series, looked up
+// by the UUID label of the series.
+func (l *locationLabels) wrap(c prometheus.Collector) prometheus.Collector {
+	if l == nil {
+		return c
+	}
+
+	w := &locationCollector{Collector: c, locations: l, descs: make(map[*prometheus.Desc]locationDesc)}
+	for _, desc := range describe(c) {
+		p, err := parseDesc(desc)
+		if err != nil {
+			w.descs[desc] = locationDesc{desc: prometheus.NewInvalidDesc(err)}
+			continue
+		}
+		w.descs[desc] = locationDesc{
+			desc:   prometheus.NewDesc(p.name, p.help, append(slices.Clone(p.labels), l.names...), nil),
+			labels: p.labels,
+		}
+	}
+	return w
+}
+
+// locationDesc is the descriptor of a wrapped metric with the labels of the
+// original descriptor, in order.
+type locationDesc struct {
+	desc   *prometheus.Desc
+	labels []string
+}
+
+// locationCollector re-labels the metrics of the collector it embeds.
+type locationCollector struct {
+	prometheus.Collector
+	locations *locationLabels
+	descs     map[*prometheus.Desc]locationDesc
+}
+
+func (c *locationCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descs {
+		ch <- d.desc
+	}
+}
+
+func (c *locationCollector) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		c.Collector.Collect(metrics)
+		close(metrics)
+	}()
+
+	for m := range metrics {
+		ch <- c.relabel(m)
+	}
+}
+
+// relabel returns m under the wrapped descriptor with the location of its GPU.
+// GPUs without platform info get empty location labels.
+func (c *locationCollector) relabel(m prometheus.Metric) prometheus.Metric {
+	d, ok := c.descs[m.Desc()]
+	if !ok {
+		return prometheus.NewInvalidMetric(m.Desc(), fmt.Errorf("metric of an undescribed descriptor"))
+	}
+
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return prometheus.NewInvalidMetric(d.desc, err)
+	}
+
+	values := make([]string, 0, len(d.labels)+len(c.locations.names))
+	for _, name := range d.labels {
+		values = append(values, metricLabel(&pb, name))
+	}
+	location := c.locations.values[metricLabel(&pb, "UUID")]
+	if location == nil {
+		location = make([]string, len(c.locations.names))
+	}
+	values = append(values, location...)
+
+	switch {
+	case pb.Counter != nil:
+		metric, err := prometheus.NewConstMetric(d.desc, prometheus.CounterValue, pb.Counter.GetValue(), values...)
+		if created := pb.Counter.GetCreatedTimestamp(); created != nil {
+			metric, err = prometheus.NewConstMetricWithCreatedTimestamp(d.desc, prometheus.CounterValue, pb.Counter.GetValue(), created.AsTime(), values...)
+		}
+		if err != nil || pb.Counter.Exemplar == nil {
+			return metricOrInvalid(d.desc, metric, err)
+		}
+		exemplar := pb.Counter.GetExemplar()
+		labels := make(prometheus.Labels, len(exemplar.GetLabel()))
+		for _, pair := range exemplar.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		e := prometheus.Exemplar{Value: exemplar.GetValue(), Labels: labels}
+		if ts := exemplar.GetTimestamp(); ts != nil {
+			e.Timestamp = ts.AsTime()
+		}
+		metric, err = prometheus.NewMetricWithExemplars(metric, e)
+		return metricOrInvalid(d.desc, metric, err)
+	case pb.Gauge != nil:
+		metric, err := prometheus.NewConstMetric(d.desc, prometheus.GaugeValue, pb.Gauge.GetValue(), values...)
+		return metricOrInvalid(d.desc, metric, err)
+	case pb.Untyped != nil:
+		metric, err := prometheus.NewConstMetric(d.desc, prometheus.UntypedValue, pb.Untyped.GetValue(), values...)
+		return metricOrInvalid(d.desc, metric, err)
+	default:
+		return prometheus.NewInvalidMetric(d.desc, fmt.Errorf("location labels only support counters and gauges"))
+	}
+}
+
+func metricOrInvalid(desc *prometheus.Desc, metric prometheus.Metric, err error) prometheus.Metric {
+	if err != nil {
+		return prometheus.NewInvalidMetric(desc, err)
+	}
+	return metric
+}
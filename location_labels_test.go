@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewLocationLabels(t *testing.T) {
+	infos := []*GpuInfo{{UUID: "GPU-1", RackGuid: "rack-a", TrayIndex: "3"}}
+
+	tests := []struct {
+		name    string
+		labels  []string
+		wantNil bool
+		wantErr bool
+	}{
+		{"disabled", nil, true, false},
+		{"supported", []string{"rack_guid", "tray_index"}, false, false},
+		{"unknown", []string{"rack_guid", "row"}, true, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			locations, err := newLocationLabels(tc.labels, infos)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+			assert.Is(hammy.True((locations == nil) == tc.wantNil))
+		})
+	}
+}
+
+func TestLocationLabelsWrap(t *testing.T) {
+	assert := hammy.New(t)
+	infos := []*GpuInfo{
+		{UUID: "GPU-1", RackGuid: "rack-a", TrayIndex: "3"},
+		{UUID: "GPU-2", RackGuid: "rack-a", TrayIndex: "4"},
+	}
+	locations, err := newLocationLabels([]string{"rack_guid", "tray_index"}, infos)
+	assert.Is(hammy.NilError(err))
+
+	errs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "test_errors_total",
+		Help:      "Test errors.",
+	}, []string{"UUID", "pci_bus_id", "link"})
+	health := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "test_health",
+		Help:      "Test health.",
+	}, []string{"UUID"})
+	errs.WithLabelValues("GPU-1", "00000000:01:00.0", "2").Add(5)
+	health.WithLabelValues("GPU-2").Set(1)
+	health.WithLabelValues("GPU-9").Set(0)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(locations.wrap(errs), locations.wrap(health))
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP nvgpu_test_errors_total Test errors.
+# TYPE nvgpu_test_errors_total counter
+nvgpu_test_errors_total{UUID="GPU-1",link="2",pci_bus_id="00000000:01:00.0",rack_guid="rack-a",tray_index="3"} 5
+# HELP nvgpu_test_health Test health.
+# TYPE nvgpu_test_health gauge
+nvgpu_test_health{UUID="GPU-2",rack_guid="rack-a",tray_index="4"} 1
+nvgpu_test_health{UUID="GPU-9",rack_guid="",tray_index=""} 0
+`))
+	assert.Is(hammy.NilError(err))
+
+	// Without location labels collectors are registered unchanged
+	var none *locationLabels
+	assert.Is(hammy.True(none.wrap(health) == prometheus.Collector(health)))
+}
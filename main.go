@@ -2,9 +2,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
-	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 
 	_ "go.uber.org/automaxprocs"
 )
@@ -19,20 +21,108 @@ var (
 )
 
 func main() {
-	addr := flag.String("addr", ":9400", "HTTP server address")
-	collectionInterval := flag.Duration("collection-interval", 60*time.Second, "Interval for collecting GPU fabric health metrics")
+	if len(os.Args) > 1 && os.Args[1] == "rules" {
+		if err := runRules(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		if err := runSnapshot(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		changed, err := runDiff(os.Args[2:], os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if changed {
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		ok, err := runValidate(os.Args[2:], os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var cfg Config
+	cfg.registerFlags(flag.CommandLine)
 	flag.Parse()
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{AddSource: true}))
+	warnings := newRecordRing[logRecord](supportBundleRecords)
+	logger := slog.New(newWarningRecorder(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{AddSource: true}), warnings))
+	applyMemoryLimit(cfg.MemoryLimit, logger)
+	systemd := newSystemdNotifier(os.Getenv)
+	liveness := newCollectionLiveness(max(3*cfg.CollectionInterval, watchdogMinCollectionAge))
+	go systemd.runWatchdog(liveness.check, logger)
+
+	if cfg.ProbeOnly {
+		if err := RunProbe(&cfg, systemd, logger); err != nil {
+			logger.Error("exporter terminated", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	devices, shutdown, err := New(logger)
+	var lib nvml.Interface
+	var err error
+	if cfg.Simulate != "" {
+		logger.Warn("serving simulated GPUs, metrics are synthetic", "simulate", cfg.Simulate)
+		lib, err = newSimulatedLibrary(cfg.Simulate)
+	} else {
+		lib, err = newNvmlLibrary(cfg.NVML, cfg.NVMLLibrary, logger)
+	}
+	if err != nil {
+		logger.Error("invalid NVML source", "err", err)
+		os.Exit(1)
+	}
+
+	var visibility *deviceVisibility
+	if cfg.RespectVisibility {
+		var source string
+		var ignored []string
+		visibility, source, ignored, err = visibilityFromEnv(os.LookupEnv)
+		if err != nil {
+			logger.Error("invalid device visibility", "err", err)
+			os.Exit(1)
+		}
+		if len(ignored) > 0 {
+			logger.Warn("ignoring MIG devices in device visibility, the exporter reports whole GPUs", "source", source, "devices", ignored)
+		}
+		if visibility != nil {
+			logger.Info("restricting GPUs to the visible devices", "source", source)
+		}
+	}
+
+	initNvml := func() (Devices, func(), error) {
+		return New(newNvmlClient(lib), visibility, logger)
+	}
+	devices, shutdown, err := initNvml()
+	if err != nil && cfg.NVMLRetryInterval > 0 {
+		logger.Error("failed to initialize NVML, serving nvgpu_nvml_initialized 0 until it succeeds", "err", err, "retry", cfg.NVMLRetryInterval)
+		devices, shutdown, err = waitForNvml(&cfg, initNvml, systemd, logger)
+	}
 	if err != nil {
 		logger.Error("failed to initialize NVML", "err", err)
 		os.Exit(1)
 	}
 	defer shutdown()
 
-	if err := Run(addr, collectionInterval, devices, logger); err != nil {
+	liveness.start()
+	if err := Run(&cfg, devices, systemd, warnings, logger); err != nil {
 		logger.Error("exporter terminated", "err", err)
 		os.Exit(1)
 	}
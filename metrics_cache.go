@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatherCache serves the metric families of the last Gather until the next
+// collection round completes. Device metrics only change once per round, so
+// with many scrapers (e.g. several federated Prometheus servers) every scrape
+// after the first in a round skips walking the collectors. A nil *gatherCache
+// ignores invalidation.
+type gatherCache struct {
+	gatherer prometheus.Gatherer
+
+	mu       sync.Mutex
+	families []*dto.MetricFamily
+	err      error
+	valid    bool
+}
+
+func newGatherCache(gatherer prometheus.Gatherer) *gatherCache {
+	return &gatherCache{gatherer: gatherer}
+}
+
+// Gather implements prometheus.Gatherer. The returned families are shared
+// between callers and must not be modified.
+func (c *gatherCache) Gather() ([]*dto.MetricFamily, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.valid {
+		c.families, c.err = c.gatherer.Gather()
+		c.valid = true
+	}
+	return c.families, c.err
+}
+
+// invalidate makes the next Gather collect afresh.
+func (c *gatherCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.valid = false
+	c.families, c.err = nil, nil
+	c.mu.Unlock()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGatherCache(t *testing.T) {
+	assert := hammy.New(t)
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: "test_value", Help: "Test value."})
+	registry.MustRegister(gauge)
+	cache := newGatherCache(registry)
+
+	value := func() float64 {
+		families, err := cache.Gather()
+		assert.Is(hammy.NilError(err))
+		return families[0].GetMetric()[0].GetGauge().GetValue()
+	}
+
+	gauge.Set(1)
+	assert.Is(hammy.Number(value()).EqualTo(1))
+
+	// Updates within a round are served at the next round
+	gauge.Set(2)
+	assert.Is(hammy.Number(value()).EqualTo(1))
+	cache.invalidate()
+	assert.Is(hammy.Number(value()).EqualTo(2))
+}
+
+func TestMetricsHandlerCompression(t *testing.T) {
+	assert := hammy.New(t)
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: "test_value", Help: "Test value."})
+	registry.MustRegister(gauge)
+	handler := metricsHandler(newGatherCache(registry), prometheus.NewRegistry(), &Config{}, discardLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Is(hammy.String(rec.Header().Get("Content-Encoding")).EqualTo("gzip"))
+
+	body, err := gzip.NewReader(rec.Body)
+	assert.Is(hammy.NilError(err))
+	data, err := io.ReadAll(body)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.String(string(data)).Contains("nvgpu_test_value 0"))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	mpsClients = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mps_clients",
+			Help:      "Number of MPS client processes running on the GPU.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	mpsClientMemory = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mps_client_memory_bytes",
+			Help:      "GPU memory (bytes) used by an MPS client process, when NVML can attribute it.",
+		},
+		[]string{"UUID", "pci_bus_id", "pid", "process_name"},
+	)
+
+	mpsClientUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mps_client_utilization_ratio",
+			Help:      "Fraction (0-1) of the GPU's SMs (type sm) or memory bandwidth (type memory) used by an MPS client process in the driver's last sample of it.",
+		},
+		[]string{"UUID", "pci_bus_id", "pid", "process_name", "type"},
+	)
+)
+
+// mpsCollector attributes GPU memory and utilization to the clients of an MPS
+// server, which otherwise show up as the single nvidia-cuda-mps-server process
+// in every per-process view.
+type mpsCollector struct {
+	procPath string
+	// lastSeen is the timestamp of the newest process utilization sample per
+	// GPU, so that each round only reads new samples
+	lastSeen map[string]uint64
+	// samples holds the latest utilization sample of each client per GPU
+	samples map[string]map[uint32]nvml.ProcessUtilizationSample
+}
+
+func newMPSCollector(procPath string) *mpsCollector {
+	return &mpsCollector{
+		procPath: procPath,
+		lastSeen: make(map[string]uint64),
+		samples:  make(map[string]map[uint32]nvml.ProcessUtilizationSample),
+	}
+}
+
+func (c *mpsCollector) collectMPS(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		clients, ret := device.GetMPSComputeRunningProcesses()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get MPS client processes", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+		mpsClients.WithLabelValues(uuid, pciBusId).Set(float64(len(clients)))
+
+		// Clients come and go with jobs; drop the series of exited ones
+		mpsClientMemory.DeletePartialMatch(prometheus.Labels{"UUID": uuid})
+		mpsClientUtilization.DeletePartialMatch(prometheus.Labels{"UUID": uuid})
+		latest := c.updateSamples(device, uuid, clients, logger)
+
+		for _, p := range clients {
+			pid := strconv.FormatUint(uint64(p.Pid), 10)
+			name := processName(c.procPath, p.Pid)
+			if p.UsedGpuMemory != math.MaxUint64 {
+				mpsClientMemory.WithLabelValues(uuid, pciBusId, pid, name).Set(float64(p.UsedGpuMemory))
+			}
+			if sample, ok := latest[p.Pid]; ok {
+				mpsClientUtilization.WithLabelValues(uuid, pciBusId, pid, name, "sm").Set(float64(sample.SmUtil) / 100)
+				mpsClientUtilization.WithLabelValues(uuid, pciBusId, pid, name, "memory").Set(float64(sample.MemUtil) / 100)
+			}
+		}
+	}
+}
+
+// updateSamples reads the process utilization samples taken since the last
+// round and returns the latest sample of each client. A client without a new
+// sample keeps its previous one.
+func (c *mpsCollector) updateSamples(device Device, uuid string, clients []nvml.ProcessInfo, logger *slog.Logger) map[uint32]nvml.ProcessUtilizationSample {
+	latest := c.samples[uuid]
+	if latest == nil {
+		latest = make(map[uint32]nvml.ProcessUtilizationSample)
+		c.samples[uuid] = latest
+	}
+	running := make(map[uint32]bool, len(clients))
+	for _, p := range clients {
+		running[p.Pid] = true
+	}
+	for pid := range latest {
+		if !running[pid] {
+			delete(latest, pid)
+		}
+	}
+	if len(clients) == 0 {
+		return latest
+	}
+
+	samples, ret := device.GetProcessUtilization(c.lastSeen[uuid])
+	if !errors.Is(ret, nvml.SUCCESS) {
+		// NOT_FOUND means no process was sampled since lastSeen
+		if !errors.Is(ret, nvml.ERROR_NOT_FOUND) && !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get process utilization", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+		return latest
+	}
+	for _, s := range samples {
+		c.lastSeen[uuid] = max(c.lastSeen[uuid], s.TimeStamp)
+		if running[s.Pid] && s.TimeStamp >= latest[s.Pid].TimeStamp {
+			latest[s.Pid] = s
+		}
+	}
+	return latest
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// mpsDevice runs fixed MPS clients and serves process utilization samples
+// newer than the requested timestamp.
+type mpsDevice struct {
+	fakeDevice
+	clients []nvml.ProcessInfo
+	samples []nvml.ProcessUtilizationSample
+}
+
+func (d *mpsDevice) GetMPSComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	return d.clients, nvml.SUCCESS
+}
+
+func (d *mpsDevice) GetProcessUtilization(lastSeenTimestamp uint64) ([]nvml.ProcessUtilizationSample, nvml.Return) {
+	var samples []nvml.ProcessUtilizationSample
+	for _, s := range d.samples {
+		if s.TimeStamp > lastSeenTimestamp {
+			samples = append(samples, s)
+		}
+	}
+	if len(samples) == 0 {
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+	return samples, nvml.SUCCESS
+}
+
+func TestCollectMPS(t *testing.T) {
+	assert := hammy.New(t)
+	for _, vec := range []interface{ Reset() }{mpsClients, mpsClientMemory, mpsClientUtilization} {
+		vec.Reset()
+		t.Cleanup(vec.Reset)
+	}
+
+	// PIDs 100 and 101
+	procPath := writeProc(t, "python3", "trainer")
+	device := &mpsDevice{
+		fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+		clients: []nvml.ProcessInfo{
+			{Pid: 100, UsedGpuMemory: 4 << 30},
+			{Pid: 101, UsedGpuMemory: math.MaxUint64},
+		},
+		samples: []nvml.ProcessUtilizationSample{
+			{Pid: 100, TimeStamp: 10, SmUtil: 20, MemUtil: 5},
+			{Pid: 100, TimeStamp: 20, SmUtil: 40, MemUtil: 10},
+			{Pid: 101, TimeStamp: 15, SmUtil: 30, MemUtil: 25},
+		},
+	}
+	c := newMPSCollector(procPath)
+	c.collectMPS([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(mpsClients.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(mpsClientMemory.WithLabelValues("GPU-0", "0000:18:00.0", "100", "python3"))).EqualTo(4 << 30))
+	// Memory of a client NVML cannot attribute is left out
+	assert.Is(hammy.Number(testutil.CollectAndCount(mpsClientMemory)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(mpsClientUtilization.WithLabelValues("GPU-0", "0000:18:00.0", "100", "python3", "sm"))).EqualTo(0.4))
+	assert.Is(hammy.Number(testutil.ToFloat64(mpsClientUtilization.WithLabelValues("GPU-0", "0000:18:00.0", "101", "trainer", "memory"))).EqualTo(0.25))
+
+	// Without new samples a client keeps its last one; exited clients are dropped
+	device.clients = device.clients[:1]
+	c.collectMPS([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(mpsClients.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(mpsClientUtilization)).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(mpsClientUtilization.WithLabelValues("GPU-0", "0000:18:00.0", "100", "python3", "sm"))).EqualTo(0.4))
+}
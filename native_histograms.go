@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Native histograms grow their buckets by this factor, i.e. a resolution of
+// about 9%, and merge buckets beyond nativeHistogramMaxBuckets.
+const (
+	nativeHistogramBucketFactor = 1.1
+	nativeHistogramMaxBuckets   = 100
+)
+
+// fecNativeHistogramSchema is the native histogram schema of
+// nvgpu_nvlink_fec_errors. At schema 3 every bucket grows by 2^(1/8), which
+// puts each of the 16 FEC history bins into a bucket of its own.
+const fecNativeHistogramSchema = 3
+
+var (
+	utilizationSamples = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:                      namespace,
+			Name:                           "utilization_samples_ratio",
+			Help:                           "Distribution of the GPU or memory (type) utilization samples (0-1) the driver took, as a native histogram.",
+			NativeHistogramBucketFactor:    nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber: nativeHistogramMaxBuckets,
+		},
+		[]string{"UUID", "pci_bus_id", "type"},
+	)
+
+	collectionDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:                      namespace,
+			Name:                           "exporter_collection_duration_seconds",
+			Help:                           "Time a collector took per collection round, as a native histogram.",
+			NativeHistogramBucketFactor:    nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber: nativeHistogramMaxBuckets,
+		},
+		[]string{"collector"},
+	)
+)
+
+// nativeBucketIndex returns the index of the native histogram bucket of
+// schema that holds v > 0: bucket i covers (2^((i-1)/2^schema), 2^(i/2^schema)].
+func nativeBucketIndex(v float64, schema int32) int {
+	return int(math.Ceil(math.Log2(v) * math.Ldexp(1, int(schema))))
+}
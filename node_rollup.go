@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	nodeGpus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "node_gpus",
+			Help:      "Number of GPUs on the node by health (ok, degraded, failed) as in nvgpu_gpu_health_summary.",
+		},
+		[]string{"health"},
+	)
+
+	nodeMemoryUsed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "node_memory_used_bytes",
+			Help:      "GPU memory used summed over all GPUs of the node.",
+		},
+	)
+
+	nodeMemoryTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "node_memory_total_bytes",
+			Help:      "GPU memory summed over all GPUs of the node.",
+		},
+	)
+
+	nodeUtilizationMean = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "node_gpu_utilization_mean_ratio",
+			Help:      "Mean GPU utilization (0-1) over the GPUs of the node that report it.",
+		},
+	)
+
+	nodeNVLinksActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "node_nvlinks_active",
+			Help:      "Number of active NVLinks summed over all GPUs of the node.",
+		},
+	)
+)
+
+// collectNodeRollup exports node-level aggregates so that fleet dashboards do
+// not have to aggregate every per-GPU series at query time. Health and NVLink
+// counts come from health, which the other collectors keep up to date; memory
+// and utilization are read here.
+func collectNodeRollup(devices []Device, health *gpuHealthTracker, logger *slog.Logger) {
+	var used, total, utilization float64
+	var memoryReported, utilizationReported int
+
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		memory, ret := device.GetMemoryInfo()
+		if errors.Is(ret, nvml.SUCCESS) {
+			used += float64(memory.Used)
+			total += float64(memory.Total)
+			memoryReported++
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get memory info", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		rates, ret := device.GetUtilizationRates()
+		if errors.Is(ret, nvml.SUCCESS) {
+			utilization += float64(rates.Gpu) / 100
+			utilizationReported++
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get utilization rates", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+	}
+
+	if memoryReported > 0 {
+		nodeMemoryUsed.Set(used)
+		nodeMemoryTotal.Set(total)
+	}
+	if utilizationReported > 0 {
+		nodeUtilizationMean.Set(utilization / float64(utilizationReported))
+	}
+
+	levels, activeLinks := health.nodeSummary()
+	nodeGpus.WithLabelValues("ok").Set(float64(levels[gpuHealthOK]))
+	nodeGpus.WithLabelValues("degraded").Set(float64(levels[gpuHealthDegraded]))
+	nodeGpus.WithLabelValues("failed").Set(float64(levels[gpuHealthFailed]))
+	nodeNVLinksActive.Set(float64(activeLinks))
+}
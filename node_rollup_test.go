@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type rollupDevice struct {
+	fakeDevice
+	memory      nvml.Memory
+	utilization uint32
+}
+
+func (d *rollupDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	return d.memory, nvml.SUCCESS
+}
+
+func (d *rollupDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	return nvml.Utilization{Gpu: d.utilization}, nvml.SUCCESS
+}
+
+func TestCollectNodeRollup(t *testing.T) {
+	assert := hammy.New(t)
+
+	infos := []*GpuInfo{{UUID: "GPU-0"}, {UUID: "GPU-1"}, {UUID: "GPU-2"}}
+	health := newGpuHealthTracker(infos, nil, []uint64{79}, time.Hour)
+	health.reportNVLinkState("GPU-0", 0, true)
+	health.reportNVLinkState("GPU-0", 1, true)
+	health.reportNVLinkState("GPU-1", 0, true)
+	// A link that went down degrades the GPU
+	health.reportNVLinkState("GPU-1", 1, true)
+	health.reportNVLinkState("GPU-1", 1, false)
+	health.reportXid("GPU-2", 79)
+
+	devices := []Device{
+		&rollupDevice{fakeDevice: fakeDevice{uuid: "GPU-0"}, memory: nvml.Memory{Total: 80, Used: 20}, utilization: 100},
+		&rollupDevice{fakeDevice: fakeDevice{uuid: "GPU-1"}, memory: nvml.Memory{Total: 80, Used: 40}, utilization: 50},
+		&rollupDevice{fakeDevice: fakeDevice{uuid: "GPU-2"}, memory: nvml.Memory{Total: 80, Used: 0}, utilization: 0},
+	}
+	collectNodeRollup(devices, health, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeMemoryUsed)).EqualTo(60))
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeMemoryTotal)).EqualTo(240))
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeUtilizationMean)).EqualTo(0.5))
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeNVLinksActive)).EqualTo(3))
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeGpus.WithLabelValues("ok"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeGpus.WithLabelValues("degraded"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(nodeGpus.WithLabelValues("failed"))).EqualTo(1))
+}
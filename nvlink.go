@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"strings"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const (
@@ -17,7 +19,11 @@ const (
 	// These are used with DeviceGetFieldValues API
 	nvmlFieldIdNvLinkMalformedPacketErrors    = 206
 	nvmlFieldIdNvLinkBufferOverrunErrors      = 207
+	nvmlFieldIdNvLinkReceiveErrors            = 208
+	nvmlFieldIdNvLinkReceiveRemoteErrors      = 209
+	nvmlFieldIdNvLinkReceiveGeneralErrors     = 210
 	nvmlFieldIdNvLinkLocalLinkIntegrityErrors = 211
+	nvmlFieldIdNvLinkTransmitDiscards         = 212
 	nvmlFieldIdNvLinkRecoverySuccessfulEvents = 213
 	nvmlFieldIdNvLinkRecoveryFailedEvents     = 214
 	nvmlFieldIdNvLinkRecoveryEvents           = 215
@@ -48,31 +54,121 @@ var (
 		prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "nvlink_errors_total",
-			Help:      "Total NVLink errors by type.",
+			Help:      "Total NVLink errors by type. peer names the remote end of the link; direction (tx, rx) is set for error types NVML counts on one side of the link only.",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "peer", "error_type", "direction"},
+	)
+
+	// nvlinkErrorsDelta leaves out the BER values nvlinkErrors carries with
+	// -nvlink-legacy-ber: they are ratios, and their differences mean nothing.
+	nvlinkErrorsDelta = deltaSource{
+		collector: nvlinkErrors,
+		name:      namespace + "_nvlink_errors_delta",
+		help:      "Increase of nvgpu_nvlink_errors_total over the last collection round. BER values are left out.",
+		labels:    []string{"UUID", "pci_bus_id", "link", "peer", "error_type", "direction"},
+		skip: func(m *dto.Metric) bool {
+			return isNVLinkBerField(metricLabel(m, "error_type"))
+		},
+	}
+
+	nvlinkUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlink_up",
+			Help:      "Whether the NVLink is active (1 = active or asleep in low power, 0 = down). Only links seen active or asleep since exporter start are reported.",
+		},
+		[]string{"UUID", "pci_bus_id", "link"},
+	)
+
+	nvlinkPowerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlink_power_state",
+			Help:      "NVLink power state (0 = high speed, 1 = low power sleep). Links that are down are not reported.",
+		},
+		[]string{"UUID", "pci_bus_id", "link"},
+	)
+
+	nvlinkBer = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlink_ber",
+			Help:      "NVLink bit error rate per link by type (effective, symbol).",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "type"},
+	)
+
+	nvlinkBerThresholdExceeded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlink_ber_threshold_exceeded",
+			Help:      "Whether the NVLink bit error rate of a type (effective, symbol) is above its configured threshold (1 = above, 0 = at or below).",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "type"},
+	)
+
+	nvlinkErrorsPerGigabyte = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlink_errors_per_gigabyte",
+			Help:      "NVLink errors by type per GB (1e9 bytes) of data on the link, over the most recent window in which at least 1 GB was transferred. Error types with a direction (tx, rx) are divided by the data of that direction only, the others by the data sent and received.",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "error_type", "direction"},
+	)
+
+	nvlinkFecErrors = newNVLinkFecHistogram()
+
+	nvlinkCounterResets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "nvlink_counter_resets_total",
+			Help:      "Number of times an NVLink error counter was observed to reset (driver reload or GPU reset).",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "error_type"},
+	)
+
+	nvlinkCounterRollovers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "nvlink_counter_rollovers_total",
+			Help:      "Number of times a 32-bit NVLink error counter was observed to wrap around; the accumulated value in nvlink_errors_total keeps counting past 2^32.",
 		},
 		[]string{"UUID", "pci_bus_id", "link", "error_type"},
 	)
 
+	// nvlinkErrorFields are the NVLink error counters. direction is the side
+	// of the link, tx or rx, of the counters NVML names as received or
+	// transmitted (NVML_FI_DEV_NVLINK_COUNT_RCV_* and _XMIT_*), which tells
+	// which end's optics or cable to swap. Counters NVML does not attribute to
+	// a side have none.
 	nvlinkErrorFields = []struct {
-		fieldId int
-		name    string
+		fieldId   int
+		name      string
+		direction string
 	}{
-		{nvmlFieldIdNvLinkMalformedPacketErrors, "malformed_packet_errors"},
-		{nvmlFieldIdNvLinkBufferOverrunErrors, "buffer_overrun_errors"},
-		{nvmlFieldIdNvLinkLocalLinkIntegrityErrors, "local_link_integrity_errors"},
-		{nvmlFieldIdNvLinkRecoverySuccessfulEvents, "recovery_successful_events"},
-		{nvmlFieldIdNvLinkRecoveryFailedEvents, "recovery_failed_events"},
-		{nvmlFieldIdNvLinkRecoveryEvents, "recovery_events"},
-		{nvmlFieldIdNvLinkEffectiveErrors, "effective_errors"},
-		{nvmlFieldIdNvLinkSymbolErrors, "symbol_errors"},
+		{nvmlFieldIdNvLinkMalformedPacketErrors, "malformed_packet_errors", ""},
+		{nvmlFieldIdNvLinkBufferOverrunErrors, "buffer_overrun_errors", ""},
+		{nvmlFieldIdNvLinkReceiveErrors, "receive_errors", "rx"},
+		{nvmlFieldIdNvLinkReceiveRemoteErrors, "receive_remote_errors", "rx"},
+		{nvmlFieldIdNvLinkReceiveGeneralErrors, "receive_general_errors", "rx"},
+		{nvmlFieldIdNvLinkTransmitDiscards, "transmit_discards", "tx"},
+		{nvmlFieldIdNvLinkLocalLinkIntegrityErrors, "local_link_integrity_errors", ""},
+		{nvmlFieldIdNvLinkRecoverySuccessfulEvents, "recovery_successful_events", ""},
+		{nvmlFieldIdNvLinkRecoveryFailedEvents, "recovery_failed_events", ""},
+		{nvmlFieldIdNvLinkRecoveryEvents, "recovery_events", ""},
+		{nvmlFieldIdNvLinkEffectiveErrors, "effective_errors", ""},
+		{nvmlFieldIdNvLinkSymbolErrors, "symbol_errors", ""},
+		{nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, "replay_errors", ""},
+		{nvml.FI_DEV_NVLINK_ERROR_DL_CRC, "crc_errors", ""},
 	}
 
 	nvlinkBerFields = []struct {
 		fieldId int
 		name    string
+		berType string
 	}{
-		{nvmlFieldIdNvLinkEffectiveBER, "effective_ber"},
-		{nvmlFieldIdNvLinkSymbolBER, "symbol_ber"},
+		{nvmlFieldIdNvLinkEffectiveBER, "effective_ber", "effective"},
+		{nvmlFieldIdNvLinkSymbolBER, "symbol_ber", "symbol"},
 	}
 
 	nvlinkFecFields = []struct {
@@ -98,8 +194,90 @@ var (
 	}
 )
 
+// isNVLinkBerField reports whether name is one of nvlinkBerFields.
+func isNVLinkBerField(name string) bool {
+	for _, field := range nvlinkBerFields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nvlinkErrorBudgetMinBytes is the traffic a link must carry before its errors
+// per GB are updated. Idle links keep accumulating into the same window, so a
+// single error on a link that moved a few kilobytes does not show up as a
+// huge error rate.
+const nvlinkErrorBudgetMinBytes = 1e9
+
+// nvlinkErrorWindow holds the monotonic counters of a link at the start of
+// the current error budget window.
+type nvlinkErrorWindow struct {
+	txBytes float64
+	rxBytes float64
+	errors  map[string]float64
+}
+
+// nvlinkErrorDirection returns the direction of the NVLink error counter
+// name, or "" when it counts both sides of the link.
+func nvlinkErrorDirection(name string) string {
+	for _, field := range nvlinkErrorFields {
+		if field.name == name {
+			return field.direction
+		}
+	}
+	return ""
+}
+
+// nvlinkCollector tracks raw NVLink counter readings between collections so
+// that counter resets are re-baselined instead of exported as decreases.
+// BER values may still share the nvlink_errors_total family (legacyBER), which
+// is why it remains a GaugeVec set to the accumulated values rather than a
+// CounterVec.
+type nvlinkCollector struct {
+	counters     *counterTracker
+	legacyBER    bool
+	fecHistogram bool
+	// berThresholds holds the threshold per BER type; types without one are
+	// not compared
+	berThresholds map[string]float64
+	// gpus maps the PCI bus ID of every local GPU to its UUID to name link peers
+	gpus map[string]string
+	// seenLinks holds the links (uuid|link) seen active at least once
+	seenLinks map[string]bool
+	// peers holds the last peer per uuid|link resolved without NVML errors
+	peers map[string]string
+	// errorWindows holds the error budget window per uuid|link
+	errorWindows map[string]nvlinkErrorWindow
+	// history keeps BER and FEC readings with -nvlink-history, may be nil
+	history *nvlinkHistory
+	// skipFec leaves the FEC history out of a round, keeping the previous
+	// values
+	skipFec bool
+	// expectedLinks holds the expected number of active NVLinks per UUID
+	expectedLinks map[string]int
+}
+
+func newNVLinkCollector(legacyBER, fecHistogram bool, berThresholds map[string]float64, infos []*GpuInfo) *nvlinkCollector {
+	gpus := make(map[string]string, len(infos))
+	for _, info := range infos {
+		gpus[strings.ToUpper(info.PciBusId)] = info.UUID
+	}
+
+	return &nvlinkCollector{
+		counters:      newCounterTracker(),
+		legacyBER:     legacyBER,
+		fecHistogram:  fecHistogram,
+		berThresholds: berThresholds,
+		gpus:          gpus,
+		seenLinks:     make(map[string]bool),
+		peers:         make(map[string]string),
+		errorWindows:  make(map[string]nvlinkErrorWindow),
+	}
+}
+
 // collectNVLinkErrors collects NVLink error counters for all devices using Field Values API (GB200 compatible)
-func collectNVLinkErrors(devices []nvml.Device, logger *slog.Logger) {
+func (c *nvlinkCollector) collectNVLinkErrors(devices []Device, health *gpuHealthTracker, logger *slog.Logger) {
 	for _, device := range devices {
 		uuid, ret := device.GetUUID()
 		if !errors.Is(ret, nvml.SUCCESS) {
@@ -113,9 +291,11 @@ func collectNVLinkErrors(devices []nvml.Device, logger *slog.Logger) {
 			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
 			continue
 		}
-		pciBusId := pciBusIdToString(pciInfo.BusIdLegacy)
+		pciBusId := pciBusID(pciInfo)
 
-		fieldValues, index := buildDeviceWideNvLinkRequests(device)
+		active := c.collectLinkStates(device, uuid, pciBusId, health, logger)
+
+		fieldValues, index := buildDeviceWideNvLinkRequests(device, !c.skipFec)
 		if len(fieldValues) == 0 {
 			continue
 		}
@@ -129,10 +309,12 @@ func collectNVLinkErrors(devices []nvml.Device, logger *slog.Logger) {
 		}
 
 		for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
-			if !linkActive(device, uuid, link, logger) {
+			if !active[link] {
 				continue
 			}
+			peer := c.linkPeer(device, uuid, link, logger)
 
+			counts := make(map[string]float64, len(nvlinkErrorFields))
 			for _, field := range nvlinkErrorFields {
 				fv := fieldValues[index[nvlinkFieldKey{fieldId: field.fieldId, link: link}]]
 				if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
@@ -143,15 +325,14 @@ func collectNVLinkErrors(devices []nvml.Device, logger *slog.Logger) {
 				}
 
 				if f, err := fieldValueToFloat64(fv); err == nil {
-					nvlinkErrors.WithLabelValues(
-						uuid,
-						pciBusId,
-						fmt.Sprintf("%d", link),
-						field.name,
-					).Set(f)
+					counts[field.name] = c.setCounter(uuid, pciBusId, link, peer, field.name, field.direction, f, fieldValueBits(fv), logger)
 				}
 			}
 
+			tx := fieldValues[index[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, link: link}]]
+			rx := fieldValues[index[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX, link: link}]]
+			c.updateErrorBudget(uuid, pciBusId, link, counts, tx, rx)
+
 			// Collect BER (Bit Error Rate) metrics
 			for _, field := range nvlinkBerFields {
 				fv := fieldValues[index[nvlinkFieldKey{fieldId: field.fieldId, link: link}]]
@@ -163,36 +344,318 @@ func collectNVLinkErrors(devices []nvml.Device, logger *slog.Logger) {
 				}
 
 				if berValue, err := decodeBER(fv); err == nil {
-					nvlinkErrors.WithLabelValues(
+					nvlinkBer.WithLabelValues(
 						uuid,
 						pciBusId,
 						fmt.Sprintf("%d", link),
-						field.name,
+						field.berType,
 					).Set(berValue)
-				}
-			}
 
-			// Collect FEC error history counters
-			for _, field := range nvlinkFecFields {
-				fv := fieldValues[index[nvlinkFieldKey{fieldId: field.fieldId, link: link}]]
-				if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
-					if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.ERROR_NOT_SUPPORTED) {
-						logger.Warn("FEC field not available", "field", field.name, "uuid", uuid, "link", link, "error", nvml.ErrorString(nvml.Return(fv.NvmlReturn)))
+					c.history.observe(uuid, pciBusId, link, field.name, berValue)
+
+					if threshold, ok := c.berThresholds[field.berType]; ok {
+						nvlinkBerThresholdExceeded.WithLabelValues(
+							uuid,
+							pciBusId,
+							fmt.Sprintf("%d", link),
+							field.berType,
+						).Set(flagToGauge(berValue > threshold))
 					}
-					continue
-				}
 
-				if f, err := fieldValueToFloat64(fv); err == nil {
-					nvlinkErrors.WithLabelValues(
-						uuid,
-						pciBusId,
-						fmt.Sprintf("%d", link),
-						field.name,
-					).Set(f)
+					if c.legacyBER {
+						nvlinkErrors.WithLabelValues(
+							uuid,
+							pciBusId,
+							fmt.Sprintf("%d", link),
+							peer,
+							field.name,
+							"",
+						).Set(berValue)
+					}
 				}
 			}
+
+			if !c.skipFec {
+				c.collectFecHistory(uuid, pciBusId, link, peer, fieldValues, index, logger)
+			}
+		}
+	}
+}
+
+// collectFecHistory exports the FEC history bins of link, as error types or
+// the FEC histogram, and records them in the history.
+func (c *nvlinkCollector) collectFecHistory(uuid, pciBusId string, link int, peer string, fieldValues []nvml.FieldValue, index map[nvlinkFieldKey]int, logger *slog.Logger) {
+	fecBins := make([]float64, 0, len(nvlinkFecFields))
+	// A bin that fails is skipped, the others are still exported
+	complete := true
+	for _, field := range nvlinkFecFields {
+		fv := fieldValues[index[nvlinkFieldKey{fieldId: field.fieldId, link: link}]]
+		if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
+			if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("FEC field not available", "field", field.name, "uuid", uuid, "link", link, "error", nvml.ErrorString(nvml.Return(fv.NvmlReturn)))
+			}
+			complete = false
+			continue
+		}
+
+		f, err := fieldValueToFloat64(fv)
+		if err != nil {
+			complete = false
+			continue
+		}
+
+		if c.fecHistogram {
+			fecBins = append(fecBins, c.observeCounter(uuid, pciBusId, link, field.name, f, fieldValueBits(fv), logger))
+		} else {
+			fecBins = append(fecBins, c.setCounter(uuid, pciBusId, link, peer, field.name, "", f, fieldValueBits(fv), logger))
+		}
+	}
+
+	// The histogram and history are only meaningful when every bin was read
+	if complete {
+		if c.fecHistogram {
+			nvlinkFecErrors.update(uuid, pciBusId, fmt.Sprintf("%d", link), fecBins)
+		}
+		var total float64
+		for _, bin := range fecBins {
+			total += bin
+		}
+		c.history.observeFec(uuid, pciBusId, link, total)
+	}
+}
+
+// setCounter exports and returns the monotonic value of a cumulative NVLink
+// counter that is bits wide. direction is empty for counters of both sides.
+func (c *nvlinkCollector) setCounter(uuid, pciBusId string, link int, peer, errorType, direction string, raw float64, bits uint, logger *slog.Logger) float64 {
+	value := c.observeCounter(uuid, pciBusId, link, errorType, raw, bits, logger)
+	nvlinkErrors.WithLabelValues(uuid, pciBusId, fmt.Sprintf("%d", link), peer, errorType, direction).Set(value)
+	return value
+}
+
+// updateErrorBudget exports the errors per GB of link once the link carried
+// nvlinkErrorBudgetMinBytes since the window started, then starts a new
+// window. counts holds the monotonic error counters read this round; tx and
+// rx are the throughput field values, which GPUs before Ampere lack.
+func (c *nvlinkCollector) updateErrorBudget(uuid, pciBusId string, link int, counts map[string]float64, tx, rx nvml.FieldValue) {
+	if nvml.Return(tx.NvmlReturn) != nvml.SUCCESS || nvml.Return(rx.NvmlReturn) != nvml.SUCCESS {
+		return
+	}
+	// Throughput field values count KiB
+	txKiB, txErr := fieldValueToFloat64(tx)
+	rxKiB, rxErr := fieldValueToFloat64(rx)
+	if txErr != nil || rxErr != nil {
+		return
+	}
+
+	linkLabel := fmt.Sprintf("%d", link)
+	key := uuid + "|" + linkLabel
+	txBytes, _ := c.counters.observe(key+"|throughput_tx", txKiB*1024)
+	rxBytes, _ := c.counters.observe(key+"|throughput_rx", rxKiB*1024)
+
+	window, ok := c.errorWindows[key]
+	sent, received := txBytes-window.txBytes, rxBytes-window.rxBytes
+	if ok && sent+received < nvlinkErrorBudgetMinBytes {
+		return
+	}
+	if ok {
+		for errorType, count := range counts {
+			start, ok := window.errors[errorType]
+			if !ok {
+				continue
+			}
+			direction := nvlinkErrorDirection(errorType)
+			bytes := sent + received
+			switch direction {
+			case "tx":
+				bytes = sent
+			case "rx":
+				bytes = received
+			}
+			// A direction that carried no data keeps its last value
+			if bytes > 0 {
+				nvlinkErrorsPerGigabyte.WithLabelValues(uuid, pciBusId, linkLabel, errorType, direction).Set((count - start) / (bytes / 1e9))
+			}
+		}
+	}
+	c.errorWindows[key] = nvlinkErrorWindow{txBytes: txBytes, rxBytes: rxBytes, errors: counts}
+}
+
+// linkPeer names the remote end of link: the UUID of a local GPU,
+// "switch:<pci_bus_id>" for an NVSwitch, or "unknown" when NVML cannot tell.
+// Remote PCI information is not available for every link type (for example
+// switches outside the node), in which case only the device type is used.
+// The peer is part of the series of the link's counters, so when a lookup
+// fails the last peer resolved without errors is kept rather than starting a
+// new series.
+func (c *nvlinkCollector) linkPeer(device Device, uuid string, link int, logger *slog.Logger) string {
+	key := fmt.Sprintf("%s|%d", uuid, link)
+	peer, resolved := resolveLinkPeer(device, uuid, link, c.gpus, logger)
+	if resolved {
+		c.peers[key] = peer
+	} else if cached, ok := c.peers[key]; ok {
+		return cached
+	}
+	return peer
+}
+
+// resolveLinkPeer looks up the peer of link, reporting false when an NVML
+// query failed for a reason other than not being supported.
+func resolveLinkPeer(device Device, uuid string, link int, gpus map[string]string, logger *slog.Logger) (string, bool) {
+	busId, resolved := remotePciBusID(device, uuid, link, logger)
+	if peer, ok := gpus[busId]; ok && busId != "" {
+		return peer, resolved
+	}
+
+	deviceType, ret := device.GetNvLinkRemoteDeviceType(link)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		resolved = resolved && errors.Is(ret, nvml.ERROR_NOT_SUPPORTED)
+		deviceType = nvml.NVLINK_DEVICE_TYPE_UNKNOWN
+	}
+
+	prefix := nvlinkDeviceTypeName(deviceType)
+	if prefix == "unknown" {
+		if busId != "" {
+			return busId, resolved
+		}
+		return prefix, resolved
+	}
+	if busId == "" {
+		return prefix, resolved
+	}
+	return prefix + ":" + busId, resolved
+}
+
+// nvlinkDeviceTypeName names the type of the remote end of an NVLink.
+func nvlinkDeviceTypeName(deviceType nvml.IntNvLinkDeviceType) string {
+	switch deviceType {
+	case nvml.NVLINK_DEVICE_TYPE_SWITCH:
+		return "switch"
+	case nvml.NVLINK_DEVICE_TYPE_GPU:
+		return "gpu"
+	case nvml.NVLINK_DEVICE_TYPE_IBMNPU:
+		return "npu"
+	default:
+		return "unknown"
+	}
+}
+
+// remotePciBusID returns the upper case PCI bus ID of the remote end of link,
+// or "" when NVML does not know it, e.g. for a switch outside the node. ok is
+// false when the query failed for a reason other than not being supported.
+func remotePciBusID(device Device, uuid string, link int, logger *slog.Logger) (busId string, ok bool) {
+	pciInfo, ret := device.GetNvLinkRemotePciInfo(link)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Debug("failed to get NVLink remote PCI info", "uuid", uuid, "link", link, "error", nvml.ErrorString(ret))
+			return "", false
 		}
+		return "", true
+	}
+	busId = strings.ToUpper(pciBusID(pciInfo))
+	if strings.Trim(busId, "0:.") == "" {
+		return "", true
 	}
+	return busId, true
+}
+
+// observeCounter returns the monotonic value of a cumulative NVLink counter
+// that is bits wide, recording a reset when the raw reading went backwards
+// and a rollover when it wrapped around.
+func (c *nvlinkCollector) observeCounter(uuid, pciBusId string, link int, errorType string, raw float64, bits uint, logger *slog.Logger) float64 {
+	linkLabel := fmt.Sprintf("%d", link)
+	value, reset, rollover := c.counters.observeWrapping(uuid+"|"+linkLabel+"|"+errorType, raw, bits)
+	if reset {
+		nvlinkCounterResets.WithLabelValues(uuid, pciBusId, linkLabel, errorType).Inc()
+		logger.Info("NVLink counter reset detected", "uuid", uuid, "link", link, "error_type", errorType)
+	}
+	if rollover {
+		nvlinkCounterRollovers.WithLabelValues(uuid, pciBusId, linkLabel, errorType).Inc()
+		logger.Debug("NVLink counter rolled over", "uuid", uuid, "link", link, "error_type", errorType)
+	}
+	return value
+}
+
+// fieldValueBits returns the width of the integer counter in fv, or 0 for
+// 64-bit and floating point values, which do not wrap in practice.
+func fieldValueBits(fv nvml.FieldValue) uint {
+	switch nvml.ValueType(fv.ValueType) {
+	case nvml.VALUE_TYPE_UNSIGNED_INT, nvml.VALUE_TYPE_SIGNED_INT:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// collectLinkStates reports the state of every NVLink of device to health,
+// nvgpu_nvlink_up and nvgpu_nvlink_power_state, and returns which links are
+// active. Like the health summary, a link is only exported once it has been
+// seen active or asleep, so that unpopulated links are not reported as down. A
+// link that is not active but in its low power state sleeps on purpose and
+// counts as up; its counters are not read until it wakes.
+func (c *nvlinkCollector) collectLinkStates(device Device, uuid, pciBusId string, health *gpuHealthTracker, logger *slog.Logger) map[int]bool {
+	powerStates := linkPowerStates(device, uuid, logger)
+
+	active := make(map[int]bool)
+	var available int
+	var hasLinks bool
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		up := linkActive(device, uuid, link, logger)
+		powerState, known := powerStates[link]
+		asleep := !up && known && powerState == nvml.NVLINK_POWER_STATE_LOW
+		health.reportNVLinkState(uuid, link, up || asleep)
+
+		key := fmt.Sprintf("%s|%d", uuid, link)
+		if up {
+			active[link] = true
+		}
+		if up || asleep {
+			c.seenLinks[key] = true
+		} else if !c.seenLinks[key] {
+			continue
+		}
+		hasLinks = true
+		if up || asleep {
+			available++
+		}
+
+		linkLabel := fmt.Sprintf("%d", link)
+		nvlinkUp.WithLabelValues(uuid, pciBusId, linkLabel).Set(flagToGauge(up || asleep))
+		if known && (up || asleep) {
+			nvlinkPowerState.WithLabelValues(uuid, pciBusId, linkLabel).Set(float64(powerState))
+		} else {
+			nvlinkPowerState.DeleteLabelValues(uuid, pciBusId, linkLabel)
+		}
+	}
+	c.setNVLinkCounts(uuid, pciBusId, available, hasLinks)
+	return active
+}
+
+// linkPowerStates reads the power state (NVLINK_POWER_STATE_*) of every
+// NVLink of device in one call. Links whose power state is not available,
+// e.g. on GPUs without NVLink low power support, are missing from the result.
+func linkPowerStates(device Device, uuid string, logger *slog.Logger) map[int]uint32 {
+	values := make([]nvml.FieldValue, nvml.NVLINK_MAX_LINKS)
+	for link := range values {
+		values[link] = nvml.FieldValue{FieldId: nvml.FI_DEV_NVLINK_GET_POWER_STATE, ScopeId: uint32(link)}
+	}
+	ret := device.GetFieldValues(values)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to read NVLink power states", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+		return nil
+	}
+
+	states := make(map[int]uint32)
+	for link, fv := range values {
+		if nvml.Return(fv.NvmlReturn) != nvml.SUCCESS {
+			continue
+		}
+		if v, err := fieldValueToUint64(fv); err == nil {
+			states[link] = uint32(v)
+		}
+	}
+	return states
 }
 
 type nvlinkFieldKey struct {
@@ -200,7 +663,7 @@ type nvlinkFieldKey struct {
 	link    int
 }
 
-func linkActive(device nvml.Device, uuid string, link int, logger *slog.Logger) bool {
+func linkActive(device Device, uuid string, link int, logger *slog.Logger) bool {
 	state, ret := device.GetNvLinkState(link)
 	if !errors.Is(ret, nvml.SUCCESS) {
 		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) && !errors.Is(ret, nvml.ERROR_INVALID_ARGUMENT) {
@@ -217,8 +680,8 @@ func linkActive(device nvml.Device, uuid string, link int, logger *slog.Logger)
 	return true
 }
 
-func buildDeviceWideNvLinkRequests(device nvml.Device) ([]nvml.FieldValue, map[nvlinkFieldKey]int) {
-	totalFields := len(nvlinkErrorFields) + len(nvlinkBerFields) + len(nvlinkFecFields)
+func buildDeviceWideNvLinkRequests(device Device, fec bool) ([]nvml.FieldValue, map[nvlinkFieldKey]int) {
+	totalFields := len(nvlinkErrorFields) + len(nvlinkBerFields) + len(nvlinkFecFields) + 2
 	values := make([]nvml.FieldValue, 0, totalFields*nvml.NVLINK_MAX_LINKS)
 	index := make(map[nvlinkFieldKey]int, totalFields*nvml.NVLINK_MAX_LINKS)
 
@@ -243,9 +706,13 @@ func buildDeviceWideNvLinkRequests(device nvml.Device) ([]nvml.FieldValue, map[n
 		for _, field := range nvlinkBerFields {
 			add(field.fieldId)
 		}
-		for _, field := range nvlinkFecFields {
-			add(field.fieldId)
+		if fec {
+			for _, field := range nvlinkFecFields {
+				add(field.fieldId)
+			}
 		}
+		add(nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX)
+		add(nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX)
 	}
 
 	return values, index
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	nvlinksActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlinks_active",
+			Help:      "Number of active NVLinks of the GPU, counting links asleep in low power. Only GPUs with NVLinks or an expected link count are reported.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	nvlinksExpected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlinks_expected",
+			Help:      "Number of NVLinks the GPU model is expected to have active, from -expected-profiles or the built-in table of SXM models. Absent when unknown.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+)
+
+// modelNVLinks is the number of NVLinks of the SXM and superchip modules of a
+// GPU model, which are all wired to NVSwitches or peers on HGX and NVL
+// platforms. PCIe cards and NVL boards connect a varying number of links
+// through bridges, so they have no entry.
+var modelNVLinks = []struct {
+	model string
+	links int
+}{
+	{"V100", 6},
+	{"A100", 12},
+	{"A800", 8},
+	{"H100", 18},
+	{"H800", 8},
+	{"H200", 18},
+	{"B200", 18},
+	{"GB200", 18},
+}
+
+// expectedNVLinkCounts returns the expected number of active NVLinks per UUID.
+// The nvlinks setting of the expected profile of the GPU model takes
+// precedence over modelNVLinks.
+func expectedNVLinkCounts(infos []*GpuInfo, profiles map[string]expectedProfile) map[string]int {
+	expected := make(map[string]int, len(infos))
+	for _, info := range infos {
+		if profile, ok := profiles[info.Name]; ok && profile.NVLinks != nil {
+			expected[info.UUID] = *profile.NVLinks
+			continue
+		}
+		if links, ok := builtinNVLinkCount(info.Name); ok {
+			expected[info.UUID] = links
+		}
+	}
+	return expected
+}
+
+// builtinNVLinkCount looks up the GPU name in modelNVLinks.
+func builtinNVLinkCount(name string) (int, bool) {
+	if isModel(name, "PCIe") || isModel(name, "NVL") {
+		return 0, false
+	}
+	for _, m := range modelNVLinks {
+		if isModel(name, m.model) {
+			return m.links, true
+		}
+	}
+	return 0, false
+}
+
+// setNVLinkCounts exports the number of active and expected NVLinks of a GPU.
+// hasLinks tells whether the GPU has been seen with an active link.
+func (c *nvlinkCollector) setNVLinkCounts(uuid, pciBusId string, active int, hasLinks bool) {
+	expected, ok := c.expectedLinks[uuid]
+	if ok {
+		nvlinksExpected.WithLabelValues(uuid, pciBusId).Set(float64(expected))
+	}
+	if ok || hasLinks {
+		nvlinksActive.WithLabelValues(uuid, pciBusId).Set(float64(active))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBuiltinNVLinkCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		gpu   string
+		links int
+		known bool
+	}{
+		{name: "H100 SXM", gpu: "NVIDIA H100 80GB HBM3", links: 18, known: true},
+		{name: "A100 SXM", gpu: "NVIDIA A100-SXM4-80GB", links: 12, known: true},
+		{name: "GB200", gpu: "NVIDIA GB200", links: 18, known: true},
+		{name: "V100 SXM", gpu: "Tesla V100-SXM2-32GB", links: 6, known: true},
+		{name: "H100 PCIe", gpu: "NVIDIA H100 PCIe"},
+		{name: "H100 NVL", gpu: "NVIDIA H100 NVL"},
+		{name: "unknown", gpu: "NVIDIA L40S"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			links, known := builtinNVLinkCount(tc.gpu)
+			assert.Is(hammy.Number(links).EqualTo(tc.links))
+			assert.Is(hammy.True(known == tc.known))
+		})
+	}
+}
+
+func TestExpectedNVLinkCounts(t *testing.T) {
+	assert := hammy.New(t)
+	eight := 8
+	infos := []*GpuInfo{
+		{UUID: "GPU-0", Name: "NVIDIA H100 80GB HBM3"},
+		{UUID: "GPU-1", Name: "NVIDIA H100 NVL"},
+		{UUID: "GPU-2", Name: "NVIDIA L40S"},
+	}
+	profiles := map[string]expectedProfile{"NVIDIA H100 NVL": {NVLinks: &eight}}
+
+	expected := expectedNVLinkCounts(infos, profiles)
+	assert.Is(hammy.Map(expected).EqualTo(map[string]int{"GPU-0": 18, "GPU-1": 8}))
+}
+
+func TestCollectNVLinkCounts(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	gpu0 := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", links: map[int]bool{0: true, 1: true, 2: true}}
+	gpu1 := &fakeDevice{uuid: "GPU-1", pciBusId: "0000:2A:00.0"}
+	gpu2 := &fakeDevice{uuid: "GPU-2", pciBusId: "0000:3A:00.0"}
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.expectedLinks = map[string]int{"GPU-0": 3, "GPU-1": 18}
+
+	collector.collectNVLinkErrors([]Device{gpu0, gpu1, gpu2}, nil, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinksActive.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(3))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinksExpected.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(3))
+	// A GPU expected to have links reports none active; one without links
+	// and no expectation is left out
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinksActive.WithLabelValues("GPU-1", "0000:2A:00.0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinksActive)).EqualTo(2))
+
+	gpu0.links[1] = false
+	collector.collectNVLinkErrors([]Device{gpu0, gpu1, gpu2}, nil, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinksActive.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(2))
+}
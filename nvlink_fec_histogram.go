@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nvlinkFecHistogram exposes the NVML FEC history bins of each link as a
+// Prometheus histogram. Bin N counts codewords corrected with N symbol errors,
+// so bucket le=N holds the cumulative count of bins 0..N.
+type nvlinkFecHistogram struct {
+	mu    sync.Mutex
+	desc  *prometheus.Desc
+	links map[nvlinkFecHistogramKey][]float64
+	// native exposes a native histogram instead of le buckets
+	native bool
+}
+
+type nvlinkFecHistogramKey struct {
+	uuid     string
+	pciBusId string
+	link     string
+}
+
+func newNVLinkFecHistogram() *nvlinkFecHistogram {
+	return &nvlinkFecHistogram{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "nvlink_fec_errors"),
+			"NVLink FEC codewords by number of corrected symbol errors (NVML FEC history bins).",
+			[]string{"UUID", "pci_bus_id", "link"},
+			nil,
+		),
+		links: make(map[nvlinkFecHistogramKey][]float64),
+	}
+}
+
+// update stores the latest per-bin counts for a link. bins[i] is the count of
+// codewords with i symbol errors.
+func (h *nvlinkFecHistogram) update(uuid, pciBusId, link string, bins []float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.links[nvlinkFecHistogramKey{uuid: uuid, pciBusId: pciBusId, link: link}] = bins
+}
+
+// Describe implements prometheus.Collector.
+func (h *nvlinkFecHistogram) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+// Collect implements prometheus.Collector.
+func (h *nvlinkFecHistogram) Collect(ch chan<- prometheus.Metric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, bins := range h.links {
+		if h.native {
+			count, sum, zero, buckets := fecBinsToNativeBuckets(bins)
+			ch <- prometheus.MustNewConstNativeHistogram(h.desc, count, sum, buckets, nil, zero, fecNativeHistogramSchema, prometheus.DefNativeHistogramZeroThreshold, time.Time{}, key.uuid, key.pciBusId, key.link)
+			continue
+		}
+		count, sum, buckets := fecBinsToBuckets(bins)
+		ch <- prometheus.MustNewConstHistogram(h.desc, count, sum, buckets, key.uuid, key.pciBusId, key.link)
+	}
+}
+
+// fecBinsToBuckets converts per-bin counts into cumulative histogram buckets,
+// returning the total count and the sum of symbol errors across all codewords.
+func fecBinsToBuckets(bins []float64) (uint64, float64, map[float64]uint64) {
+	buckets := make(map[float64]uint64, len(bins))
+	var count uint64
+	var sum float64
+	for i, v := range bins {
+		count += uint64(v)
+		sum += float64(i) * v
+		buckets[float64(i)] = count
+	}
+	return count, sum, buckets
+}
+
+// fecBinsToNativeBuckets converts per-bin counts into the buckets of a native
+// histogram of fecNativeHistogramSchema: bin 0 goes to the zero bucket, every
+// other bin to the bucket of its symbol error count.
+func fecBinsToNativeBuckets(bins []float64) (count uint64, sum float64, zero uint64, buckets map[int]int64) {
+	buckets = make(map[int]int64, len(bins))
+	for i, v := range bins {
+		count += uint64(v)
+		sum += float64(i) * v
+		if i == 0 {
+			zero = uint64(v)
+			continue
+		}
+		if v > 0 {
+			buckets[nativeBucketIndex(float64(i), fecNativeHistogramSchema)] += int64(v)
+		}
+	}
+	return count, sum, zero, buckets
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFecBinsToBuckets(t *testing.T) {
+	assert := hammy.New(t)
+
+	count, sum, buckets := fecBinsToBuckets([]float64{100, 10, 2, 0})
+
+	assert.Is(hammy.Number(count).EqualTo(112))
+	assert.Is(hammy.Number(sum).EqualTo(14))
+	assert.Is(hammy.Map(buckets).EqualTo(map[float64]uint64{0: 100, 1: 110, 2: 112, 3: 112}))
+}
+
+func TestNVLinkFecHistogramCollect(t *testing.T) {
+	assert := hammy.New(t)
+	h := newNVLinkFecHistogram()
+	h.update("GPU-1", "0000:01:00.0", "3", []float64{5, 1})
+
+	expected := `
+# HELP nvgpu_nvlink_fec_errors NVLink FEC codewords by number of corrected symbol errors (NVML FEC history bins).
+# TYPE nvgpu_nvlink_fec_errors histogram
+nvgpu_nvlink_fec_errors_bucket{UUID="GPU-1",link="3",pci_bus_id="0000:01:00.0",le="0"} 5
+nvgpu_nvlink_fec_errors_bucket{UUID="GPU-1",link="3",pci_bus_id="0000:01:00.0",le="1"} 6
+nvgpu_nvlink_fec_errors_bucket{UUID="GPU-1",link="3",pci_bus_id="0000:01:00.0",le="+Inf"} 6
+nvgpu_nvlink_fec_errors_sum{UUID="GPU-1",link="3",pci_bus_id="0000:01:00.0"} 1
+nvgpu_nvlink_fec_errors_count{UUID="GPU-1",link="3",pci_bus_id="0000:01:00.0"} 6
+`
+	err := testutil.CollectAndCompare(h, strings.NewReader(expected))
+	assert.Is(hammy.NilError(err))
+}
+
+func TestFecBinsToNativeBuckets(t *testing.T) {
+	assert := hammy.New(t)
+
+	count, sum, zero, buckets := fecBinsToNativeBuckets([]float64{100, 10, 2, 0, 1})
+
+	assert.Is(hammy.Number(count).EqualTo(113))
+	assert.Is(hammy.Number(sum).EqualTo(18))
+	assert.Is(hammy.Number(zero).EqualTo(100))
+	// At schema 3 bucket 8*log2(N) holds N symbol errors
+	assert.Is(hammy.Map(buckets).EqualTo(map[int]int64{0: 10, 8: 2, 16: 1}))
+}
+
+func TestNativeBucketIndexSeparatesFecBins(t *testing.T) {
+	assert := hammy.New(t)
+	seen := make(map[int]bool)
+	for i := 1; i < 16; i++ {
+		seen[nativeBucketIndex(float64(i), fecNativeHistogramSchema)] = true
+	}
+	assert.Is(hammy.Number(len(seen)).EqualTo(15))
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nvlinkHistoryAPIPattern is the route of the per-GPU NVLink history.
+const nvlinkHistoryAPIPattern = "GET /api/v1/gpus/{uuid}/nvlink/history"
+
+var nvlinkHistoryDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "nvlink_history"),
+	"Statistics (min, max, p50, p90, p99) of the per-link NVLink readings (effective_ber, symbol_ber, fec_errors per collection) kept for -nvlink-history.",
+	[]string{"UUID", "pci_bus_id", "link", "series", "stat"},
+	nil,
+)
+
+// nvlinkHistoryStats are the statistics exported per series; quantiles use the
+// nearest rank.
+var nvlinkHistoryStats = []struct {
+	name     string
+	quantile float64
+}{
+	{"min", 0},
+	{"p50", 0.5},
+	{"p90", 0.9},
+	{"p99", 0.99},
+	{"max", 1},
+}
+
+// nvlinkHistoryFileVersion is bumped on incompatible changes of the file format.
+const nvlinkHistoryFileVersion = 1
+
+// linkSample is one NVLink reading.
+type linkSample struct {
+	At    time.Time `json:"t"`
+	Value float64   `json:"v"`
+}
+
+// linkSeries is the bounded history of one reading of one link.
+type linkSeries struct {
+	UUID     string `json:"uuid"`
+	PciBusId string `json:"pci_bus_id"`
+	Link     int    `json:"link"`
+	Series   string `json:"series"`
+	// samples is a ring buffer; next is the slot the following sample goes to
+	samples []linkSample
+	next    int
+}
+
+// push adds s, overwriting the oldest sample once the buffer holds capacity.
+func (s *linkSeries) push(sample linkSample, capacity int) {
+	if len(s.samples) < capacity {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+// since returns the samples taken after cutoff, oldest first.
+func (s *linkSeries) since(cutoff time.Time) []linkSample {
+	ordered := append(slices.Clone(s.samples[s.next:]), s.samples[:s.next]...)
+	i := slices.IndexFunc(ordered, func(sample linkSample) bool { return sample.At.After(cutoff) })
+	if i < 0 {
+		return nil
+	}
+	return ordered[i:]
+}
+
+// nvlinkHistory keeps the per-link BER and FEC readings of the last window in
+// memory, and optionally in a file, so that link flaps between scrapes and
+// across exporter restarts can still be diagnosed. A nil *nvlinkHistory
+// ignores every reading.
+type nvlinkHistory struct {
+	window time.Duration
+	// capacity bounds every ring buffer to the readings of one window
+	capacity int
+	path     string
+	now      func() time.Time
+
+	mu     sync.Mutex
+	series map[string]*linkSeries
+	// totals holds the last monotonic FEC total per uuid|link
+	totals map[string]float64
+}
+
+func newNVLinkHistory(window, interval time.Duration, path string) *nvlinkHistory {
+	return &nvlinkHistory{
+		window:   window,
+		capacity: int(window/interval) + 1,
+		path:     path,
+		now:      time.Now,
+		series:   make(map[string]*linkSeries),
+		totals:   make(map[string]float64),
+	}
+}
+
+// observe records a reading of series on link.
+func (h *nvlinkHistory) observe(uuid, pciBusId string, link int, series string, value float64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.push(uuid, pciBusId, link, series, linkSample{At: h.now(), Value: value})
+}
+
+// observeFec records the FEC errors counted since the previous reading of
+// link, given the monotonic sum of its FEC history counters.
+func (h *nvlinkHistory) observeFec(uuid, pciBusId string, link int, total float64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := fmt.Sprintf("%s|%d", uuid, link)
+	prev, ok := h.totals[key]
+	h.totals[key] = total
+	if ok {
+		h.push(uuid, pciBusId, link, "fec_errors", linkSample{At: h.now(), Value: max(total-prev, 0)})
+	}
+}
+
+func (h *nvlinkHistory) push(uuid, pciBusId string, link int, series string, sample linkSample) {
+	key := fmt.Sprintf("%s|%d|%s", uuid, link, series)
+	s, ok := h.series[key]
+	if !ok {
+		s = &linkSeries{UUID: uuid, PciBusId: pciBusId, Link: link, Series: series}
+		h.series[key] = s
+	}
+	s.push(sample, h.capacity)
+}
+
+// Describe implements prometheus.Collector.
+func (h *nvlinkHistory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nvlinkHistoryDesc
+}
+
+// Collect implements prometheus.Collector.
+func (h *nvlinkHistory) Collect(ch chan<- prometheus.Metric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := h.now().Add(-h.window)
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		samples := s.since(cutoff)
+		if len(samples) == 0 {
+			continue
+		}
+
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			values = append(values, sample.Value)
+		}
+		slices.Sort(values)
+
+		link := strconv.Itoa(s.Link)
+		for _, stat := range nvlinkHistoryStats {
+			rank := int(math.Ceil(stat.quantile*float64(len(values)))) - 1
+			value := values[min(max(rank, 0), len(values)-1)]
+			ch <- prometheus.MustNewConstMetric(nvlinkHistoryDesc, prometheus.GaugeValue, value, s.UUID, s.PciBusId, link, s.Series, stat.name)
+		}
+	}
+}
+
+// nvlinkHistoryFile is the persisted and served form of the history.
+type nvlinkHistoryFile struct {
+	Version int                 `json:"version"`
+	Series  []nvlinkHistoryJSON `json:"series"`
+}
+
+// nvlinkHistoryJSON is one series with its samples, oldest first.
+type nvlinkHistoryJSON struct {
+	linkSeries
+	Samples []linkSample `json:"samples"`
+}
+
+// snapshot returns the series of uuid, or of every GPU when uuid is empty,
+// with the samples of the current window.
+func (h *nvlinkHistory) snapshot(uuid string) nvlinkHistoryFile {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file := nvlinkHistoryFile{Version: nvlinkHistoryFileVersion, Series: []nvlinkHistoryJSON{}}
+	cutoff := h.now().Add(-h.window)
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		if uuid != "" && s.UUID != uuid {
+			continue
+		}
+		samples := s.since(cutoff)
+		if len(samples) == 0 {
+			continue
+		}
+		file.Series = append(file.Series, nvlinkHistoryJSON{
+			linkSeries: linkSeries{UUID: s.UUID, PciBusId: s.PciBusId, Link: s.Link, Series: s.Series},
+			Samples:    samples,
+		})
+	}
+	return file
+}
+
+// save writes the history to its file, replacing it atomically.
+func (h *nvlinkHistory) save() error {
+	data, err := json.Marshal(h.snapshot(""))
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), h.path)
+}
+
+// load restores the samples of the current window from the history file. A
+// missing file is not an error.
+func (h *nvlinkHistory) load() error {
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file nvlinkHistoryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", h.path, err)
+	}
+	if file.Version != nvlinkHistoryFileVersion {
+		return fmt.Errorf("unsupported version %d in %s", file.Version, h.path)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := h.now().Add(-h.window)
+	for _, s := range file.Series {
+		for _, sample := range s.Samples {
+			if sample.At.After(cutoff) {
+				h.push(s.UUID, s.PciBusId, s.Link, s.Series, sample)
+			}
+		}
+	}
+	return nil
+}
+
+// nvlinkHistoryHandler serves the NVLink history of a single GPU as JSON.
+func nvlinkHistoryHandler(h *nvlinkHistory, devices []Device, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := r.PathValue("uuid")
+		if findDevice(devices, uuid) == nil {
+			http.Error(w, fmt.Sprintf("unknown GPU %q", uuid), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.snapshot(uuid)); err != nil {
+			logger.Debug("failed to write NVLink history", "uuid", uuid, "err", err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestNVLinkHistory returns a history whose clock advances by one minute
+// per call of tick.
+func newTestNVLinkHistory(window time.Duration, path string) (*nvlinkHistory, func()) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newNVLinkHistory(window, time.Minute, path)
+	h.now = func() time.Time { return now }
+	return h, func() { now = now.Add(time.Minute) }
+}
+
+func TestNVLinkHistoryStats(t *testing.T) {
+	assert := hammy.New(t)
+	h, tick := newTestNVLinkHistory(10*time.Minute, "")
+
+	// Ten readings, of which the window still holds 4..9 at the end
+	for i := range 10 {
+		h.observe("GPU-0", "0000:18:00.0", 2, "effective_ber", float64(i)*1e-12)
+		tick()
+	}
+	// The FEC series starts with the second total
+	for _, total := range []float64{100, 100, 130} {
+		h.observeFec("GPU-0", "0000:18:00.0", 2, total)
+		tick()
+	}
+
+	err := testutil.CollectAndCompare(h, strings.NewReader(`
+# HELP nvgpu_nvlink_history Statistics (min, max, p50, p90, p99) of the per-link NVLink readings (effective_ber, symbol_ber, fec_errors per collection) kept for -nvlink-history.
+# TYPE nvgpu_nvlink_history gauge
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="effective_ber",stat="max"} 9e-12
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="effective_ber",stat="min"} 4e-12
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="effective_ber",stat="p50"} 6e-12
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="effective_ber",stat="p90"} 9e-12
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="effective_ber",stat="p99"} 9e-12
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="fec_errors",stat="max"} 30
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="fec_errors",stat="min"} 0
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="fec_errors",stat="p50"} 0
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="fec_errors",stat="p90"} 30
+nvgpu_nvlink_history{UUID="GPU-0",link="2",pci_bus_id="0000:18:00.0",series="fec_errors",stat="p99"} 30
+`))
+	assert.Is(hammy.NilError(err))
+
+	// Readings older than the window are not exported
+	for range 20 {
+		tick()
+	}
+	assert.Is(hammy.Number(testutil.CollectAndCount(h)).EqualTo(0))
+}
+
+func TestLinkSeriesRingBuffer(t *testing.T) {
+	assert := hammy.New(t)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var s linkSeries
+	for i := range 5 {
+		s.push(linkSample{At: start.Add(time.Duration(i) * time.Minute), Value: float64(i)}, 3)
+	}
+
+	samples := s.since(start)
+	assert.Is(hammy.Number(len(samples)).EqualTo(3))
+	assert.Is(hammy.Number(samples[0].Value).EqualTo(2))
+	assert.Is(hammy.Number(samples[2].Value).EqualTo(4))
+}
+
+func TestNVLinkHistorySaveLoad(t *testing.T) {
+	assert := hammy.New(t)
+	path := filepath.Join(t.TempDir(), "nvlink-history.json")
+
+	h, tick := newTestNVLinkHistory(time.Hour, path)
+	for i := range 3 {
+		h.observe("GPU-0", "0000:18:00.0", 0, "symbol_ber", float64(i+1)*1e-7)
+		tick()
+	}
+	assert.Is(hammy.NilError(h.save()))
+
+	restored, _ := newTestNVLinkHistory(time.Hour, path)
+	restored.now = h.now
+	assert.Is(hammy.NilError(restored.load()))
+
+	series := restored.snapshot("GPU-0").Series
+	assert.Is(hammy.Number(len(series)).EqualTo(1))
+	assert.Is(hammy.Number(len(series[0].Samples)).EqualTo(3))
+	assert.Is(hammy.Number(series[0].Samples[2].Value).EqualTo(3e-7))
+
+	// A missing file starts an empty history
+	empty, _ := newTestNVLinkHistory(time.Hour, filepath.Join(t.TempDir(), "missing.json"))
+	assert.Is(hammy.NilError(empty.load()))
+}
+
+func TestNVLinkHistoryHandler(t *testing.T) {
+	assert := hammy.New(t)
+	h, _ := newTestNVLinkHistory(time.Hour, "")
+	h.observe("GPU-0", "0000:18:00.0", 1, "effective_ber", 1e-13)
+	h.observe("GPU-1", "0000:28:00.0", 1, "effective_ber", 2e-13)
+
+	devices := []Device{&fakeDevice{uuid: "GPU-0"}, &fakeDevice{uuid: "GPU-1"}}
+	mux := http.NewServeMux()
+	mux.Handle(nvlinkHistoryAPIPattern, nvlinkHistoryHandler(h, devices, discardLogger()))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/gpus/GPU-1/nvlink/history", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+
+	var file nvlinkHistoryFile
+	assert.Is(hammy.NilError(json.Unmarshal(rec.Body.Bytes(), &file)))
+	assert.Is(hammy.Number(len(file.Series)).EqualTo(1))
+	assert.Is(hammy.String(file.Series[0].PciBusId).EqualTo("0000:28:00.0"))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/gpus/GPU-9/nvlink/history", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusNotFound))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var nvlinkRemoteEndpoint = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "nvlink_remote_endpoint",
+		Help:      "1 for each active NVLink that terminates outside the node, identifying its remote end as far as the fabric reports it: remote_type, the link (port) number on the remote device in remote_link, and the NVLink domain of the GPU in cluster_uuid and clique_id.",
+	},
+	[]string{"UUID", "pci_bus_id", "link", "remote_type", "remote_link", "cluster_uuid", "clique_id"},
+)
+
+// collectNVLinkRemoteEndpoints exports the remote end of every active NVLink
+// of a fabric-attached GPU that leaves the node, as on GB200 NVL72 where all
+// links go to NVSwitch trays. Joined across the nodes of an NVLink domain, a
+// link problem seen on one node can be traced to the switch port at its other
+// end. Links to devices in the node have a remote PCI bus ID and are left out;
+// nvgpu_gpu_topology covers them.
+func collectNVLinkRemoteEndpoints(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		fabricInfo, ret := device.GetGpuFabricInfoV2()
+		if !errors.Is(ret, nvml.SUCCESS) || fabricInfo.State == nvml.GPU_FABRIC_STATE_NOT_SUPPORTED {
+			continue
+		}
+		clusterUUID := uuidBytesToString(fabricInfo.ClusterUuid)
+		cliqueID := fmt.Sprintf("%d", fabricInfo.CliqueId)
+
+		remoteLinks := remoteNvLinkIDs(device, uuid, logger)
+
+		// Replace the endpoints of the GPU, as links go down and get re-cabled
+		nvlinkRemoteEndpoint.DeletePartialMatch(prometheus.Labels{"UUID": uuid})
+		for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+			if !linkActive(device, uuid, link, logger) {
+				continue
+			}
+			if busId, _ := remotePciBusID(device, uuid, link, logger); busId != "" {
+				continue
+			}
+			deviceType, ret := device.GetNvLinkRemoteDeviceType(link)
+			if !errors.Is(ret, nvml.SUCCESS) {
+				deviceType = nvml.NVLINK_DEVICE_TYPE_UNKNOWN
+			}
+			remoteLink, ok := remoteLinks[link]
+			if !ok {
+				remoteLink = "unknown"
+			}
+			nvlinkRemoteEndpoint.WithLabelValues(uuid, pciBusId, fmt.Sprintf("%d", link), nvlinkDeviceTypeName(deviceType), remoteLink, clusterUUID, cliqueID).Set(1)
+		}
+	}
+}
+
+// remoteNvLinkIDs reads the link number on the remote device of every NVLink
+// of device in one call. Links without one are missing from the result.
+func remoteNvLinkIDs(device Device, uuid string, logger *slog.Logger) map[int]string {
+	values := make([]nvml.FieldValue, nvml.NVLINK_MAX_LINKS)
+	for link := range values {
+		values[link] = nvml.FieldValue{FieldId: nvml.FI_DEV_NVLINK_REMOTE_NVLINK_ID, ScopeId: uint32(link)}
+	}
+	ids := make(map[int]string)
+	if ret := device.GetFieldValues(values); !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get NVLink remote link IDs", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+		return ids
+	}
+	for link, fv := range values {
+		if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
+			continue
+		}
+		if id, err := fieldValueToUint64(fv); err == nil {
+			ids[link] = fmt.Sprintf("%d", id)
+		}
+	}
+	return ids
+}
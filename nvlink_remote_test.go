@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectNVLinkRemoteEndpoints(t *testing.T) {
+	assert := hammy.New(t)
+	nvlinkRemoteEndpoint.Reset()
+	t.Cleanup(nvlinkRemoteEndpoint.Reset)
+
+	fabric := &nvml.GpuFabricInfo_v2{State: nvml.GPU_FABRIC_STATE_COMPLETED, CliqueId: 7}
+	copy(fabric.ClusterUuid[:], "simulated-clique")
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		fabric:   fabric,
+		// Link 2 is down
+		links: map[int]bool{0: true, 1: true, 2: false, 3: true},
+		peers: map[int]fakePeer{
+			0: {deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH},
+			1: {deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH},
+			2: {deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH},
+			// Link 3 goes to a switch in the node
+			3: {pciBusId: "0000:A0:00.0", deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH},
+		},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_NVLINK_REMOTE_NVLINK_ID, link: 0}: 12,
+		},
+	}
+	nonFabric := &fakeDevice{uuid: "GPU-1", pciBusId: "0000:2a:00.0", links: map[int]bool{0: true}}
+
+	collectNVLinkRemoteEndpoints([]Device{device, nonFabric}, discardLogger())
+
+	cluster := uuidBytesToString(fabric.ClusterUuid)
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkRemoteEndpoint)).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkRemoteEndpoint.WithLabelValues("GPU-0", "0000:18:00.0", "0", "switch", "12", cluster, "7"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkRemoteEndpoint.WithLabelValues("GPU-0", "0000:18:00.0", "1", "switch", "unknown", cluster, "7"))).EqualTo(1))
+
+	// A link that goes down loses its endpoint
+	device.links[1] = false
+	collectNVLinkRemoteEndpoints([]Device{device, nonFabric}, discardLogger())
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkRemoteEndpoint)).EqualTo(1))
+}
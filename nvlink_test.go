@@ -0,0 +1,482 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectNVLinkErrors(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true, 1: false},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 0}: 42,
+			{fieldId: nvmlFieldIdNvLinkEffectiveBER, link: 0}: 3<<8 | 12,
+			{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 1}: 99,
+		},
+	}
+
+	collector := newNVLinkCollector(true, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", "symbol_errors", ""))).EqualTo(42))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkBer.WithLabelValues("GPU-0", "0000:18:00.0", "0", "effective"))).EqualTo(3e-12))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", "effective_ber", ""))).EqualTo(3e-12))
+	// Link 1 is down and unsupported fields are omitted
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(2))
+
+	// A driver reload resets the raw counter; the exported value keeps growing
+	device.fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 0}] = 5
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", "symbol_errors", ""))).EqualTo(47))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkCounterResets.WithLabelValues("GPU-0", "0000:18:00.0", "0", "symbol_errors"))).EqualTo(1))
+}
+
+func TestCollectNVLinkErrorsLegacyBER(t *testing.T) {
+	tests := []struct {
+		name      string
+		legacyBER bool
+		// series of nvgpu_nvlink_errors_total: the symbol errors, and the BER
+		// with -nvlink-legacy-ber
+		wantErrors int
+	}{
+		{name: "legacy BER", legacyBER: true, wantErrors: 2},
+		{name: "no legacy BER", legacyBER: false, wantErrors: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			resetNVLinkMetrics(t)
+
+			device := &fakeDevice{
+				uuid:     "GPU-0",
+				pciBusId: "0000:18:00.0",
+				links:    map[int]bool{0: true},
+				fields: map[nvlinkFieldKey]uint64{
+					{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 0}: 42,
+					{fieldId: nvmlFieldIdNvLinkEffectiveBER, link: 0}: 3<<8 | 12,
+				},
+			}
+			newNVLinkCollector(tc.legacyBER, false, nil, nil).collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+			assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(tc.wantErrors))
+			if tc.legacyBER {
+				assert.Is(hammy.Number(testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", "effective_ber", ""))).EqualTo(3e-12))
+			}
+			// nvgpu_nvlink_ber is exported either way
+			assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkBer)).EqualTo(1))
+			assert.Is(hammy.Number(testutil.ToFloat64(nvlinkBer.WithLabelValues("GPU-0", "0000:18:00.0", "0", "effective"))).EqualTo(3e-12))
+		})
+	}
+}
+
+func TestCollectNVLinkErrorsDirection(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvmlFieldIdNvLinkReceiveErrors, link: 0}:    3,
+			{fieldId: nvmlFieldIdNvLinkTransmitDiscards, link: 0}: 5,
+			{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_CRC, link: 0}:   7,
+			// NVML does not attribute malformed packets to a side
+			{fieldId: nvmlFieldIdNvLinkMalformedPacketErrors, link: 0}: 2,
+		},
+	}
+
+	newNVLinkCollector(false, false, nil, nil).collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	errorCount := func(errorType, direction string) float64 {
+		return testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", errorType, direction))
+	}
+	assert.Is(hammy.Number(errorCount("receive_errors", "rx")).EqualTo(3))
+	assert.Is(hammy.Number(errorCount("transmit_discards", "tx")).EqualTo(5))
+	// Counters of both sides of the link have no direction
+	assert.Is(hammy.Number(errorCount("crc_errors", "")).EqualTo(7))
+	assert.Is(hammy.Number(errorCount("malformed_packet_errors", "")).EqualTo(2))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(4))
+}
+
+// narrowFieldsDevice reports its field values as 32-bit integers, like NVLink
+// counters on older GPUs.
+type narrowFieldsDevice struct {
+	*fakeDevice
+}
+
+func (d narrowFieldsDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	ret := d.fakeDevice.GetFieldValues(values)
+	for i := range values {
+		values[i].ValueType = uint32(nvml.VALUE_TYPE_UNSIGNED_INT)
+	}
+	return ret
+}
+
+func TestCollectNVLinkErrorsRollover(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	replay := nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, link: 0}
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true},
+		fields:   map[nvlinkFieldKey]uint64{replay: math.MaxUint32 - 9},
+	}
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{narrowFieldsDevice{device}}, nil, discardLogger())
+
+	// The counter wraps past 2^32 and keeps counting
+	device.fields[replay] = 20
+	collector.collectNVLinkErrors([]Device{narrowFieldsDevice{device}}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", "replay_errors", ""))).EqualTo(math.MaxUint32 + 21))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkCounterRollovers.WithLabelValues("GPU-0", "0000:18:00.0", "0", "replay_errors"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkCounterResets)).EqualTo(0))
+}
+
+func TestCollectNVLinkErrorsSkipsUnsupportedDevices(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:      "GPU-0",
+		pciBusId:  "0000:18:00.0",
+		links:     map[int]bool{0: true},
+		fieldsRet: nvml.ERROR_NOT_SUPPORTED,
+	}
+
+	newNVLinkCollector(true, false, nil, nil).collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(0))
+}
+
+func TestCollectNVLinkErrorsPeerLabel(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	fields := make(map[nvlinkFieldKey]uint64)
+	for link := 0; link < 4; link++ {
+		fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: link}] = 1
+	}
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true, 1: true, 2: true, 3: true},
+		fields:   fields,
+		peers: map[int]fakePeer{
+			0: {pciBusId: "0000:2A:00.0", deviceType: nvml.NVLINK_DEVICE_TYPE_GPU},
+			1: {pciBusId: "0000:A0:00.0", deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH},
+			// Switch in another tray without a PCI address on this node
+			2: {deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH},
+		},
+	}
+	infos := []*GpuInfo{{UUID: "GPU-0", PciBusId: "0000:18:00.0"}, {UUID: "GPU-1", PciBusId: "0000:2a:00.0"}}
+
+	newNVLinkCollector(false, false, nil, infos).collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	for link, peer := range []string{"GPU-1", "switch:0000:A0:00.0", "switch", "unknown"} {
+		value := testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", fmt.Sprintf("%d", link), peer, "symbol_errors", ""))
+		assert.Is(hammy.Number(value).EqualTo(1))
+	}
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(4))
+}
+
+func TestCollectNVLinkErrorsPeerLabelKeptOnFailure(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true},
+		fields:   map[nvlinkFieldKey]uint64{{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 0}: 1},
+		peers:    map[int]fakePeer{0: {pciBusId: "0000:A0:00.0", deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH}},
+	}
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	// The remote queries fail for a round: the series keeps its peer
+	device.peers[0] = fakePeer{ret: nvml.ERROR_UNKNOWN}
+	device.fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 0}] = 3
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	value := testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "switch:0000:A0:00.0", "symbol_errors", ""))
+	assert.Is(hammy.Number(value).EqualTo(3))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(1))
+}
+
+func TestCollectNVLinkErrorsLinkState(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true, 1: true, 2: false},
+		fields:   map[nvlinkFieldKey]uint64{},
+	}
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(1))
+	// Link 2 was never active and is not reported
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkUp)).EqualTo(2))
+
+	// Links going down are reported even when no link is left to read fields from
+	device.links = map[int]bool{0: false, 1: false, 2: false}
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "1"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkUp)).EqualTo(2))
+}
+
+func TestCollectNVLinkErrorsPowerState(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	powerState := func(link int) nvlinkFieldKey {
+		return nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_GET_POWER_STATE, link: link}
+	}
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true, 1: true},
+		fields: map[nvlinkFieldKey]uint64{
+			powerState(0): nvml.NVLINK_POWER_STATE_HIGH_SPEED,
+			powerState(1): nvml.NVLINK_POWER_STATE_HIGH_SPEED,
+		},
+	}
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkPowerState.WithLabelValues("GPU-0", "0000:18:00.0", "1"))).EqualTo(nvml.NVLINK_POWER_STATE_HIGH_SPEED))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkPowerState)).EqualTo(2))
+
+	// Link 0 sleeps in low power while link 1 failed
+	device.links = map[int]bool{0: false, 1: false}
+	device.fields[powerState(0)] = nvml.NVLINK_POWER_STATE_LOW
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkPowerState.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(nvml.NVLINK_POWER_STATE_LOW))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "1"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkPowerState)).EqualTo(1))
+}
+
+func TestCollectNVLinkErrorsStartsAsleep(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	powerState := func(link int) nvlinkFieldKey {
+		return nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_GET_POWER_STATE, link: link}
+	}
+	// Link 0 already sleeps in low power when the exporter starts
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: false, 1: true},
+		fields: map[nvlinkFieldKey]uint64{
+			powerState(0): nvml.NVLINK_POWER_STATE_LOW,
+			powerState(1): nvml.NVLINK_POWER_STATE_HIGH_SPEED,
+		},
+	}
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkPowerState.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(nvml.NVLINK_POWER_STATE_LOW))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkUp)).EqualTo(2))
+
+	// The link fails later without having been active
+	delete(device.fields, powerState(0))
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUp.WithLabelValues("GPU-0", "0000:18:00.0", "0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkPowerState)).EqualTo(1))
+}
+
+func resetNVLinkMetrics(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		nvlinkErrors.Reset()
+		nvlinkBer.Reset()
+		nvlinkCounterResets.Reset()
+		nvlinkCounterRollovers.Reset()
+		nvlinkUp.Reset()
+		nvlinkPowerState.Reset()
+		nvlinkErrorsPerGigabyte.Reset()
+		nvlinkBerThresholdExceeded.Reset()
+		nvlinksActive.Reset()
+		nvlinksExpected.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestCollectNVLinkErrorsPerGigabyte(t *testing.T) {
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true},
+		fields:   map[nvlinkFieldKey]uint64{},
+	}
+	collector := newNVLinkCollector(false, false, nil, nil)
+
+	tests := []struct {
+		name          string
+		symbolErrors  uint64
+		receiveErrors uint64
+		txKiB, rxKiB  uint64
+		want          float64
+		wantRx        float64
+		wantSeries    int
+	}{
+		{"first round starts the window", 10, 1, 0, 0, 0, 0, 0},
+		{"idle link keeps the window open", 11, 1, 1, 1, 0, 0, 0},
+		// Throughput fields count KiB: 2.048 GB since the window started, of
+		// which 1.024 GB received
+		{"window closes after 1 GB", 16, 5, 1_000_000, 1_000_000, 6 / 2.048, 4 / 1.024, 2},
+		{"next window", 16, 5, 1_500_000, 1_500_000, 0, 0, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			device.fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkSymbolErrors, link: 0}] = tc.symbolErrors
+			device.fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkReceiveErrors, link: 0}] = tc.receiveErrors
+			device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, link: 0}] = tc.txKiB
+			device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX, link: 0}] = tc.rxKiB
+
+			collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+			assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrorsPerGigabyte)).EqualTo(tc.wantSeries))
+			if tc.wantSeries > 0 {
+				got := testutil.ToFloat64(nvlinkErrorsPerGigabyte.WithLabelValues("GPU-0", "0000:18:00.0", "0", "symbol_errors", ""))
+				assert.Is(hammy.Number(got).Within(tc.want, 1e-6))
+				// Receive errors are divided by the data received only
+				got = testutil.ToFloat64(nvlinkErrorsPerGigabyte.WithLabelValues("GPU-0", "0000:18:00.0", "0", "receive_errors", "rx"))
+				assert.Is(hammy.Number(got).Within(tc.wantRx, 1e-6))
+			}
+		})
+	}
+}
+
+func TestCollectNVLinkBerThresholdExceeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds map[string]float64
+		want       float64
+		wantSeries int
+	}{
+		{"above", map[string]float64{"effective": 1e-12}, 1, 1},
+		{"below", map[string]float64{"effective": 1e-11}, 0, 1},
+		{"equal", map[string]float64{"effective": 3e-12}, 0, 1},
+		{"no threshold", nil, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			resetNVLinkMetrics(t)
+
+			device := &fakeDevice{
+				uuid:     "GPU-0",
+				pciBusId: "0000:18:00.0",
+				links:    map[int]bool{0: true},
+				fields: map[nvlinkFieldKey]uint64{
+					// 3e-12
+					{fieldId: nvmlFieldIdNvLinkEffectiveBER, link: 0}: 3<<8 | 12,
+				},
+			}
+			newNVLinkCollector(false, false, tc.thresholds, nil).collectNVLinkErrors([]Device{device}, nil, discardLogger())
+
+			assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkBerThresholdExceeded)).EqualTo(tc.wantSeries))
+			if tc.wantSeries > 0 {
+				assert.Is(hammy.Number(testutil.ToFloat64(nvlinkBerThresholdExceeded.WithLabelValues("GPU-0", "0000:18:00.0", "0", "effective"))).EqualTo(tc.want))
+			}
+		})
+	}
+}
+
+func TestCollectNVLinkErrorsSkipsFec(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvmlFieldIdNvLinkFECHistory0, link: 0}: 7,
+		},
+	}
+	fec := func() float64 {
+		return testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", "fec_errors_0", ""))
+	}
+
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+	assert.Is(hammy.Number(fec()).EqualTo(7))
+
+	// Skipped rounds keep the previous value
+	device.fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkFECHistory0, link: 0}] = 9
+	collector.skipFec = true
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+	assert.Is(hammy.Number(fec()).EqualTo(7))
+
+	collector.skipFec = false
+	collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+	assert.Is(hammy.Number(fec()).EqualTo(9))
+}
+
+func TestCollectNVLinkErrorsFecBinUnsupported(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkMetrics(t)
+
+	fields := make(map[nvlinkFieldKey]uint64)
+	for bin := range nvlinkFecFields {
+		// Bin 3 is not supported
+		if bin != 3 {
+			fields[nvlinkFieldKey{fieldId: nvmlFieldIdNvLinkFECHistory0 + bin, link: 0}] = uint64(bin + 1)
+		}
+	}
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", links: map[int]bool{0: true}, fields: fields}
+	history, tick := newTestNVLinkHistory(time.Hour, "")
+
+	collector := newNVLinkCollector(false, false, nil, nil)
+	collector.history = history
+	for range 2 {
+		collector.collectNVLinkErrors([]Device{device}, nil, discardLogger())
+		tick()
+	}
+
+	// The bins after the unsupported one are still exported
+	for bin := range nvlinkFecFields {
+		if bin == 3 {
+			continue
+		}
+		value := testutil.ToFloat64(nvlinkErrors.WithLabelValues("GPU-0", "0000:18:00.0", "0", "unknown", fmt.Sprintf("fec_errors_%d", bin), ""))
+		assert.Is(hammy.Number(value).EqualTo(float64(bin + 1)))
+	}
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkErrors)).EqualTo(len(nvlinkFecFields) - 1))
+	// The FEC total of an incomplete bin set is not recorded
+	assert.Is(hammy.Number(testutil.CollectAndCount(history)).EqualTo(0))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nvlinkUtilizationCounter is the legacy utilization counter the exporter
+// configures on GPUs without the throughput field values. NVML offers two
+// counters per link; the second is left alone for other tools.
+const nvlinkUtilizationCounter = 0
+
+var (
+	nvlinkThroughput = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "nvlink_throughput_bytes_total",
+			Help:      "Total NVLink data bytes per link by direction (tx, rx).",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "direction"},
+	)
+
+	nvlinkUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvlink_utilization_ratio",
+			Help:      "NVLink data throughput per link and direction (tx, rx) over the last collection interval as a fraction of the link speed.",
+		},
+		[]string{"UUID", "pci_bus_id", "link", "direction"},
+	)
+)
+
+// nvlinkThroughputSample is a monotonic throughput reading of one link direction.
+type nvlinkThroughputSample struct {
+	bytes float64
+	at    time.Time
+}
+
+// nvlinkUtilizationCollector derives per-link NVLink utilization from the
+// cumulative throughput counters. GPUs that expose the throughput field values
+// (Ampere and newer) are read as is. Older GPUs need their utilization counter
+// configured to count bytes first, which changes state other tools may rely
+// on; that is why the collector only runs with -nvlink-utilization.
+type nvlinkUtilizationCollector struct {
+	counters *counterTracker
+	previous map[string]nvlinkThroughputSample
+	// legacy records per uuid|link whether configuring the legacy counter succeeded
+	legacy map[string]bool
+	now    func() time.Time
+}
+
+func newNVLinkUtilizationCollector() *nvlinkUtilizationCollector {
+	return &nvlinkUtilizationCollector{
+		counters: newCounterTracker(),
+		previous: make(map[string]nvlinkThroughputSample),
+		legacy:   make(map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// collectNVLinkUtilization collects throughput and utilization of every active NVLink.
+func (c *nvlinkUtilizationCollector) collectNVLinkUtilization(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		var links []int
+		var values []nvml.FieldValue
+		for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+			if !linkActive(device, uuid, link, logger) {
+				continue
+			}
+			links = append(links, link)
+			for _, fieldId := range []uint32{nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX, nvml.FI_DEV_NVLINK_GET_SPEED} {
+				values = append(values, nvml.FieldValue{FieldId: fieldId, ScopeId: uint32(link)})
+			}
+		}
+		if len(links) == 0 {
+			continue
+		}
+
+		ret = device.GetFieldValues(values)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to read NVLink throughput field values", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		now := c.now()
+		for i, link := range links {
+			tx, rx, speed := values[3*i], values[3*i+1], values[3*i+2]
+
+			// Link speed in MBps, per direction
+			var speedMBps float64
+			if nvml.Return(speed.NvmlReturn) == nvml.SUCCESS {
+				speedMBps, _ = fieldValueToFloat64(speed)
+			}
+
+			txBytes, rxBytes, ok := c.readThroughput(device, uuid, link, tx, rx, logger)
+			if !ok {
+				continue
+			}
+
+			c.update(uuid, pciBusId, link, "tx", txBytes, speedMBps, now)
+			c.update(uuid, pciBusId, link, "rx", rxBytes, speedMBps, now)
+		}
+	}
+}
+
+// readThroughput returns the raw tx and rx byte counters of link, from the
+// throughput field values when supported and from the legacy utilization
+// counter otherwise.
+func (c *nvlinkUtilizationCollector) readThroughput(device Device, uuid string, link int, tx, rx nvml.FieldValue, logger *slog.Logger) (float64, float64, bool) {
+	if nvml.Return(tx.NvmlReturn) == nvml.SUCCESS && nvml.Return(rx.NvmlReturn) == nvml.SUCCESS {
+		// Throughput field values count KiB
+		txKiB, txErr := fieldValueToFloat64(tx)
+		rxKiB, rxErr := fieldValueToFloat64(rx)
+		if txErr != nil || rxErr != nil {
+			logger.Debug("failed to decode NVLink throughput", "uuid", uuid, "link", link, "tx_err", txErr, "rx_err", rxErr)
+			return 0, 0, false
+		}
+		return txKiB * 1024, rxKiB * 1024, true
+	}
+
+	key := fmt.Sprintf("%s|%d", uuid, link)
+	configured, tried := c.legacy[key]
+	if !tried {
+		control := nvml.NvLinkUtilizationControl{
+			Units:     uint32(nvml.NVLINK_COUNTER_UNIT_BYTES),
+			Pktfilter: uint32(nvml.NVLINK_COUNTER_PKTFILTER_ALL),
+		}
+		ret := device.SetNvLinkUtilizationControl(link, nvlinkUtilizationCounter, &control, false)
+		configured = errors.Is(ret, nvml.SUCCESS)
+		c.legacy[key] = configured
+		if configured {
+			logger.Info("configured NVLink utilization counter to count bytes", "uuid", uuid, "link", link, "counter", nvlinkUtilizationCounter)
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to configure NVLink utilization counter", "uuid", uuid, "link", link, "error", nvml.ErrorString(ret))
+		}
+	}
+	if !configured {
+		return 0, 0, false
+	}
+
+	rxBytes, txBytes, ret := device.GetNvLinkUtilizationCounter(link, nvlinkUtilizationCounter)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		logger.Warn("failed to get NVLink utilization counter", "uuid", uuid, "link", link, "error", nvml.ErrorString(ret))
+		return 0, 0, false
+	}
+	return float64(txBytes), float64(rxBytes), true
+}
+
+// update advances the throughput counter of one link direction to the
+// monotonic reading and, once a previous reading exists, exports its
+// utilization since that reading.
+func (c *nvlinkUtilizationCollector) update(uuid, pciBusId string, link int, direction string, raw, speedMBps float64, now time.Time) {
+	linkLabel := fmt.Sprintf("%d", link)
+	key := uuid + "|" + linkLabel + "|" + direction
+
+	bytes, reset := c.counters.observe(key, raw)
+	prev, ok := c.previous[key]
+	c.previous[key] = nvlinkThroughputSample{bytes: bytes, at: now}
+	nvlinkThroughput.WithLabelValues(uuid, pciBusId, linkLabel, direction).Add(bytes - prev.bytes)
+	if !ok || reset || speedMBps <= 0 {
+		return
+	}
+
+	elapsed := now.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	ratio := (bytes - prev.bytes) / (elapsed * speedMBps * 1e6)
+	nvlinkUtilization.WithLabelValues(uuid, pciBusId, linkLabel, direction).Set(min(max(ratio, 0), 1))
+}
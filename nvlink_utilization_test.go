@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// legacyNVLinkDevice is a pre-Ampere GPU that only offers the configurable
+// utilization counters.
+type legacyNVLinkDevice struct {
+	fakeDevice
+	configured map[int]nvml.NvLinkUtilizationControl
+	rx, tx     uint64
+}
+
+func (d *legacyNVLinkDevice) SetNvLinkUtilizationControl(link int, counter int, control *nvml.NvLinkUtilizationControl, reset bool) nvml.Return {
+	d.configured[link] = *control
+	return nvml.SUCCESS
+}
+
+func (d *legacyNVLinkDevice) GetNvLinkUtilizationCounter(link int, counter int) (uint64, uint64, nvml.Return) {
+	return d.rx, d.tx, nvml.SUCCESS
+}
+
+func resetNVLinkUtilizationMetrics() {
+	nvlinkThroughput.Reset()
+	nvlinkUtilization.Reset()
+}
+
+func TestCollectNVLinkUtilizationFieldValues(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkUtilizationMetrics()
+	t.Cleanup(resetNVLinkUtilizationMetrics)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		links:    map[int]bool{0: true, 1: false},
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, link: 0}: 1000,
+			{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX, link: 0}: 2000,
+			{fieldId: nvml.FI_DEV_NVLINK_GET_SPEED, link: 0}:          128,
+		},
+		fieldsRet: nvml.SUCCESS,
+	}
+
+	now := time.Unix(1700000000, 0)
+	c := newNVLinkUtilizationCollector()
+	c.now = func() time.Time { return now }
+	c.collectNVLinkUtilization([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkThroughput.WithLabelValues("GPU-0", "0000:18:00.0", "0", "tx"))).EqualTo(1000 * 1024))
+	// Utilization needs two readings
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkUtilization)).EqualTo(0))
+
+	// 10s at 128 MBps is 1.28e9 bytes: tx moves half of that, rx none
+	now = now.Add(10 * time.Second)
+	device.fields[nvlinkFieldKey{fieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, link: 0}] += 625000
+	c.collectNVLinkUtilization([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUtilization.WithLabelValues("GPU-0", "0000:18:00.0", "0", "tx"))).EqualTo(0.5))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkUtilization.WithLabelValues("GPU-0", "0000:18:00.0", "0", "rx"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkThroughput.WithLabelValues("GPU-0", "0000:18:00.0", "0", "tx"))).EqualTo(626000 * 1024))
+	// The inactive link is skipped
+	assert.Is(hammy.Number(testutil.CollectAndCount(nvlinkThroughput)).EqualTo(2))
+}
+
+func TestCollectNVLinkUtilizationLegacyCounters(t *testing.T) {
+	assert := hammy.New(t)
+	resetNVLinkUtilizationMetrics()
+	t.Cleanup(resetNVLinkUtilizationMetrics)
+
+	device := &legacyNVLinkDevice{
+		fakeDevice: fakeDevice{
+			uuid:      "GPU-0",
+			pciBusId:  "0000:18:00.0",
+			links:     map[int]bool{0: true},
+			fieldsRet: nvml.SUCCESS,
+		},
+		configured: make(map[int]nvml.NvLinkUtilizationControl),
+		rx:         4096,
+		tx:         8192,
+	}
+
+	c := newNVLinkUtilizationCollector()
+	c.collectNVLinkUtilization([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(device.configured[0].Units).EqualTo(uint32(nvml.NVLINK_COUNTER_UNIT_BYTES)))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkThroughput.WithLabelValues("GPU-0", "0000:18:00.0", "0", "rx"))).EqualTo(4096))
+	assert.Is(hammy.Number(testutil.ToFloat64(nvlinkThroughput.WithLabelValues("GPU-0", "0000:18:00.0", "0", "tx"))).EqualTo(8192))
+
+	// The counter is configured only once
+	delete(device.configured, 0)
+	c.collectNVLinkUtilization([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(len(device.configured)).EqualTo(0))
+}
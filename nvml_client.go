@@ -0,0 +1,260 @@
+package main
+
+import (
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// nvmlClient is the subset of the NVML library used by the exporter. It is
+// narrow enough to be faked in tests, unlike nvml.Interface.
+type nvmlClient interface {
+	Init() nvml.Return
+	Shutdown() nvml.Return
+	DeviceGetCount() (int, nvml.Return)
+	DeviceGetHandleByIndex(i int) (Device, nvml.Return)
+	SystemGetDriverVersion() (string, nvml.Return)
+	SystemGetNVMLVersion() (string, nvml.Return)
+	SystemGetCudaDriverVersion() (int, nvml.Return)
+	SystemGetDriverBranch() (nvml.SystemDriverBranchInfo, nvml.Return)
+	EventSetCreate() (EventSet, nvml.Return)
+}
+
+// Device is the subset of NVML device queries used by the collectors.
+type Device interface {
+	GetUUID() (string, nvml.Return)
+	GetPciInfo() (nvml.PciInfo, nvml.Return)
+	GetIndex() (int, nvml.Return)
+	GetMinorNumber() (int, nvml.Return)
+	GetName() (string, nvml.Return)
+	GetBrand() (nvml.BrandType, nvml.Return)
+	GetSerial() (string, nvml.Return)
+	GetBoardId() (uint32, nvml.Return)
+	GetVbiosVersion() (string, nvml.Return)
+	GetBoardPartNumber() (string, nvml.Return)
+	GetInforomVersion(object nvml.InforomObject) (string, nvml.Return)
+	GetInforomImageVersion() (string, nvml.Return)
+	GetPlatformInfo() (nvml.PlatformInfo, nvml.Return)
+	GetGpuFabricInfoV2() (nvml.GpuFabricInfo_v2, nvml.Return)
+	GetGspFirmwareMode() (bool, bool, nvml.Return)
+	GetGspFirmwareVersion() (string, nvml.Return)
+	GetCudaComputeCapability() (int, int, nvml.Return)
+	GetArchitecture() (nvml.DeviceArchitecture, nvml.Return)
+	GetMemoryInfo() (nvml.Memory, nvml.Return)
+	GetUtilizationRates() (nvml.Utilization, nvml.Return)
+	GetSamples(samplingType nvml.SamplingType, lastSeenTimestamp uint64) (nvml.ValueType, []nvml.Sample, nvml.Return)
+	GetNvLinkState(link int) (nvml.EnableState, nvml.Return)
+	GetNvLinkRemotePciInfo(link int) (nvml.PciInfo, nvml.Return)
+	GetNvLinkRemoteDeviceType(link int) (nvml.IntNvLinkDeviceType, nvml.Return)
+	GetFieldValues(values []nvml.FieldValue) nvml.Return
+	SetNvLinkUtilizationControl(link int, counter int, control *nvml.NvLinkUtilizationControl, reset bool) nvml.Return
+	GetNvLinkUtilizationCounter(link int, counter int) (uint64, uint64, nvml.Return)
+	GetApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return)
+	GetDefaultApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return)
+	GetAutoBoostedClocksEnabled() (nvml.EnableState, nvml.EnableState, nvml.Return)
+	GetGpcClkVfOffset() (int, nvml.Return)
+	GetMemClkVfOffset() (int, nvml.Return)
+	GetSramEccErrorStatus() (nvml.EccSramErrorStatus, nvml.Return)
+	GetComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return)
+	GetGraphicsRunningProcesses() ([]nvml.ProcessInfo, nvml.Return)
+	GetMPSComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return)
+	GetProcessUtilization(lastSeenTimestamp uint64) ([]nvml.ProcessUtilizationSample, nvml.Return)
+	GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return)
+	GetRetiredPages_v2(cause nvml.PageRetirementCause) ([]uint64, []uint64, nvml.Return)
+	GetRemappedRows() (int, int, bool, bool, nvml.Return)
+	GetRowRemapperHistogram() (nvml.RowRemapperHistogramValues, nvml.Return)
+	GetGpuOperationMode() (nvml.GpuOperationMode, nvml.GpuOperationMode, nvml.Return)
+	GetDisplayActive() (nvml.EnableState, nvml.Return)
+	GetDisplayMode() (nvml.EnableState, nvml.Return)
+	GetDriverModel() (nvml.DriverModel, nvml.DriverModel, nvml.Return)
+	WorkloadPowerProfileGetCurrentProfiles() (nvml.WorkloadPowerProfileCurrentProfiles, nvml.Return)
+	GetCapabilities() (nvml.DeviceCapabilities, nvml.Return)
+	GetPowerManagementLimit() (uint32, nvml.Return)
+	GetTemperature(sensor nvml.TemperatureSensors) (uint32, nvml.Return)
+	GetTemperatureThreshold(threshold nvml.TemperatureThresholds) (uint32, nvml.Return)
+	GetPersistenceMode() (nvml.EnableState, nvml.Return)
+	GetCurrentClocksEventReasons() (uint64, nvml.Return)
+	GetTopologyCommonAncestor(peer Device) (nvml.GpuTopologyLevel, nvml.Return)
+	RegisterEvents(eventTypes uint64, set EventSet) nvml.Return
+}
+
+// EventSet delivers NVML events for the devices registered with it.
+type EventSet interface {
+	Wait(timeoutMs uint32) (Event, nvml.Return)
+	Free() nvml.Return
+}
+
+// Event is an NVML event raised by Device.
+type Event struct {
+	Device    Device
+	EventType uint64
+	EventData uint64
+}
+
+// newNvmlClient adapts a go-nvml library (system, recording, replay or
+// simulated) to nvmlClient.
+func newNvmlClient(lib nvml.Interface) nvmlClient {
+	return nvmlLibrary{lib: lib}
+}
+
+// nvmlLibrary implements nvmlClient on top of go-nvml.
+type nvmlLibrary struct {
+	lib nvml.Interface
+}
+
+func (l nvmlLibrary) Init() nvml.Return {
+	return l.lib.Init()
+}
+
+func (l nvmlLibrary) Shutdown() nvml.Return {
+	return l.lib.Shutdown()
+}
+
+func (l nvmlLibrary) DeviceGetCount() (int, nvml.Return) {
+	return l.lib.DeviceGetCount()
+}
+
+func (l nvmlLibrary) DeviceGetHandleByIndex(i int) (Device, nvml.Return) {
+	device, ret := l.lib.DeviceGetHandleByIndex(i)
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	return nvmlDevice{Device: device}, ret
+}
+
+func (l nvmlLibrary) SystemGetDriverVersion() (string, nvml.Return) {
+	return sanitizedString(l.lib.SystemGetDriverVersion())
+}
+
+func (l nvmlLibrary) SystemGetNVMLVersion() (string, nvml.Return) {
+	return sanitizedString(l.lib.SystemGetNVMLVersion())
+}
+
+func (l nvmlLibrary) SystemGetCudaDriverVersion() (int, nvml.Return) {
+	return l.lib.SystemGetCudaDriverVersion()
+}
+
+func (l nvmlLibrary) SystemGetDriverBranch() (nvml.SystemDriverBranchInfo, nvml.Return) {
+	return l.lib.SystemGetDriverBranch()
+}
+
+func (l nvmlLibrary) EventSetCreate() (EventSet, nvml.Return) {
+	set, ret := l.lib.EventSetCreate()
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	return nvmlEventSet{set: set}, ret
+}
+
+// nvmlDevice implements Device on top of a go-nvml device handle.
+type nvmlDevice struct {
+	nvml.Device
+}
+
+// sanitizedString passes the result of an NVML string query through
+// sanitizeLabelValue. Every string a Device or the library returns is cleaned
+// here, so that collectors can use them as label values as is.
+func sanitizedString(s string, ret nvml.Return) (string, nvml.Return) {
+	return sanitizeLabelValue(s), ret
+}
+
+func (d nvmlDevice) GetUUID() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetUUID())
+}
+
+func (d nvmlDevice) GetName() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetName())
+}
+
+func (d nvmlDevice) GetSerial() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetSerial())
+}
+
+func (d nvmlDevice) GetVbiosVersion() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetVbiosVersion())
+}
+
+func (d nvmlDevice) GetBoardPartNumber() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetBoardPartNumber())
+}
+
+func (d nvmlDevice) GetInforomVersion(object nvml.InforomObject) (string, nvml.Return) {
+	return sanitizedString(d.Device.GetInforomVersion(object))
+}
+
+func (d nvmlDevice) GetInforomImageVersion() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetInforomImageVersion())
+}
+
+func (d nvmlDevice) GetGspFirmwareVersion() (string, nvml.Return) {
+	return sanitizedString(d.Device.GetGspFirmwareVersion())
+}
+
+// fabricInfoV2Getter is implemented by devices that are not backed by NVML
+// (replay, simulation) and therefore cannot return a GpuFabricInfoHandler.
+type fabricInfoV2Getter interface {
+	GetGpuFabricInfoV2() (nvml.GpuFabricInfo_v2, nvml.Return)
+}
+
+// GetGpuFabricInfoV2 returns the V2 fabric info, which includes the health mask.
+func (d nvmlDevice) GetGpuFabricInfoV2() (nvml.GpuFabricInfo_v2, nvml.Return) {
+	if f, ok := d.Device.(fabricInfoV2Getter); ok {
+		return f.GetGpuFabricInfoV2()
+	}
+	return d.Device.GetGpuFabricInfoV().V2()
+}
+
+// GetTopologyCommonAncestor returns the closest common ancestor of d and peer
+// in the PCIe and CPU topology.
+func (d nvmlDevice) GetTopologyCommonAncestor(peer Device) (nvml.GpuTopologyLevel, nvml.Return) {
+	p, ok := unwrapDevice(peer).(nvmlDevice)
+	if !ok {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return d.Device.GetTopologyCommonAncestor(p.Device)
+}
+
+// deviceWrapper is implemented by Devices that decorate another Device, such
+// as trackedDevice.
+type deviceWrapper interface {
+	unwrap() Device
+}
+
+// unwrapDevice returns the innermost Device of device, so that queries taking
+// a peer device can pass the handle NVML knows.
+func unwrapDevice(device Device) Device {
+	for {
+		w, ok := device.(deviceWrapper)
+		if !ok {
+			return device
+		}
+		device = w.unwrap()
+	}
+}
+
+func (d nvmlDevice) RegisterEvents(eventTypes uint64, set EventSet) nvml.Return {
+	s, ok := set.(nvmlEventSet)
+	if !ok {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	return d.Device.RegisterEvents(eventTypes, s.set)
+}
+
+// nvmlEventSet implements EventSet on top of a go-nvml event set.
+type nvmlEventSet struct {
+	set nvml.EventSet
+}
+
+func (s nvmlEventSet) Wait(timeoutMs uint32) (Event, nvml.Return) {
+	data, ret := s.set.Wait(timeoutMs)
+	if ret != nvml.SUCCESS {
+		return Event{}, ret
+	}
+	return Event{
+		Device:    nvmlDevice{Device: data.Device},
+		EventType: data.EventType,
+		EventData: data.EventData,
+	}, ret
+}
+
+func (s nvmlEventSet) Free() nvml.Return {
+	return s.set.Free()
+}
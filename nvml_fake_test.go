@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// fakeClient is an in-memory nvmlClient. Unset methods of the embedded
+// interface panic, so tests only exercise the calls they configure.
+type fakeClient struct {
+	nvmlClient
+	devices   []Device
+	eventSets []*fakeEventSet
+}
+
+func (c *fakeClient) Init() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (c *fakeClient) Shutdown() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (c *fakeClient) DeviceGetCount() (int, nvml.Return) {
+	return len(c.devices), nvml.SUCCESS
+}
+
+func (c *fakeClient) DeviceGetHandleByIndex(i int) (Device, nvml.Return) {
+	if c.devices[i] == nil {
+		return nil, nvml.ERROR_NO_PERMISSION
+	}
+	return c.devices[i], nvml.SUCCESS
+}
+
+func (c *fakeClient) EventSetCreate() (EventSet, nvml.Return) {
+	set := &fakeEventSet{events: make(chan Event, 16)}
+	c.eventSets = append(c.eventSets, set)
+	return set, nvml.SUCCESS
+}
+
+// fakeEventSet delivers the events sent on its channel.
+type fakeEventSet struct {
+	events     chan Event
+	registered []Device
+}
+
+func (s *fakeEventSet) Wait(timeoutMs uint32) (Event, nvml.Return) {
+	select {
+	case event := <-s.events:
+		return event, nvml.SUCCESS
+	case <-time.After(time.Duration(timeoutMs) * time.Millisecond):
+		return Event{}, nvml.ERROR_TIMEOUT
+	}
+}
+
+func (s *fakeEventSet) Free() nvml.Return {
+	return nvml.SUCCESS
+}
+
+// fakeDevice is an in-memory Device. Queries it has no data for report
+// ERROR_NOT_SUPPORTED, like a GPU without the feature.
+type fakeDevice struct {
+	Device
+	uuid      string
+	pciBusId  string
+	fabric    *nvml.GpuFabricInfo_v2
+	links     map[int]bool
+	fields    map[nvlinkFieldKey]uint64
+	fieldsRet nvml.Return
+	peers     map[int]fakePeer
+	// ancestors holds the topology common ancestor per peer UUID
+	ancestors map[string]nvml.GpuTopologyLevel
+	// clockReasons is the current clock event reasons bit mask
+	clockReasons uint64
+}
+
+// fakePeer is the remote end of an NVLink.
+type fakePeer struct {
+	pciBusId   string
+	deviceType nvml.IntNvLinkDeviceType
+	// ret, if set, fails the remote PCI info and device type queries
+	ret nvml.Return
+}
+
+func (d *fakeDevice) GetUUID() (string, nvml.Return) {
+	return d.uuid, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	var info nvml.PciInfo
+	copy(info.BusIdLegacy[:], d.pciBusId)
+	return info, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	return 0, 0, false, false, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *fakeDevice) GetRowRemapperHistogram() (nvml.RowRemapperHistogramValues, nvml.Return) {
+	return nvml.RowRemapperHistogramValues{}, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *fakeDevice) GetGpuFabricInfoV2() (nvml.GpuFabricInfo_v2, nvml.Return) {
+	if d.fabric == nil {
+		return nvml.GpuFabricInfo_v2{}, nvml.ERROR_NOT_SUPPORTED
+	}
+	return *d.fabric, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetNvLinkState(link int) (nvml.EnableState, nvml.Return) {
+	active, ok := d.links[link]
+	if !ok {
+		return nvml.FEATURE_DISABLED, nvml.ERROR_INVALID_ARGUMENT
+	}
+	if active {
+		return nvml.FEATURE_ENABLED, nvml.SUCCESS
+	}
+	return nvml.FEATURE_DISABLED, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetNvLinkRemotePciInfo(link int) (nvml.PciInfo, nvml.Return) {
+	var info nvml.PciInfo
+	peer, ok := d.peers[link]
+	if ok && peer.ret != nvml.SUCCESS {
+		return info, peer.ret
+	}
+	if !ok || peer.pciBusId == "" {
+		return info, nvml.ERROR_NOT_SUPPORTED
+	}
+	copy(info.BusIdLegacy[:], peer.pciBusId)
+	return info, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetNvLinkRemoteDeviceType(link int) (nvml.IntNvLinkDeviceType, nvml.Return) {
+	peer, ok := d.peers[link]
+	if !ok {
+		return nvml.NVLINK_DEVICE_TYPE_UNKNOWN, nvml.ERROR_NOT_SUPPORTED
+	}
+	if peer.ret != nvml.SUCCESS {
+		return nvml.NVLINK_DEVICE_TYPE_UNKNOWN, peer.ret
+	}
+	return peer.deviceType, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetTopologyCommonAncestor(peer Device) (nvml.GpuTopologyLevel, nvml.Return) {
+	level, ok := d.ancestors[unwrapDevice(peer).(*fakeDevice).uuid]
+	if !ok {
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+	return level, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	if d.fieldsRet != nvml.SUCCESS {
+		return d.fieldsRet
+	}
+	for i := range values {
+		v, ok := d.fields[nvlinkFieldKey{fieldId: int(values[i].FieldId), link: int(values[i].ScopeId)}]
+		if !ok {
+			values[i].NvmlReturn = uint32(nvml.ERROR_NOT_SUPPORTED)
+			continue
+		}
+		setSimulatedField(&values[i], v)
+	}
+	return nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetCurrentClocksEventReasons() (uint64, nvml.Return) {
+	return d.clockReasons, nvml.SUCCESS
+}
+
+func (d *fakeDevice) RegisterEvents(eventTypes uint64, set EventSet) nvml.Return {
+	s := set.(*fakeEventSet)
+	s.registered = append(s.registered, d)
+	return nvml.SUCCESS
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
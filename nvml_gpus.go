@@ -17,46 +17,69 @@ func setNvmlLogger(logger *slog.Logger) {
 	}
 }
 
-func shutdown(logger *slog.Logger) {
-	ret := nvml.Shutdown()
+func shutdown(lib nvmlClient, logger *slog.Logger) {
+	ret := lib.Shutdown()
 	if !errors.Is(ret, nvml.SUCCESS) {
 		logger.Error("failed to shutdown NVML", "error", nvml.ErrorString(ret))
 	}
 }
 
-// New initializes the NVML library, discovers every GPU device, and returns the
-// handles alongside a cleanup routine that must be called on shutdown.
-func New(logger *slog.Logger) (Devices, func(), error) {
+// New initializes the NVML library, discovers every GPU device included in
+// visibility, and returns the handles alongside a cleanup routine that must be
+// called on shutdown. With a visibility, GPUs that deny access are skipped
+// rather than failing the exporter.
+func New(lib nvmlClient, visibility *deviceVisibility, logger *slog.Logger) (Devices, func(), error) {
 	setNvmlLogger(logger)
-	ret := nvml.Init()
+	ret := lib.Init()
 	if !errors.Is(ret, nvml.SUCCESS) {
-		return nil, nil, fmt.Errorf("failed to init NVML: %v", nvml.ErrorString(ret))
+		return Devices{}, nil, fmt.Errorf("failed to init NVML: %v", nvml.ErrorString(ret))
 	}
 
 	// Get device count and populate GPU info metrics
-	count, ret := nvml.DeviceGetCount()
+	count, ret := lib.DeviceGetCount()
 	if !errors.Is(ret, nvml.SUCCESS) {
-		return nil, nil, fmt.Errorf("failed to get device count: %v", nvml.ErrorString(ret))
+		return Devices{}, nil, fmt.Errorf("failed to get device count: %v", nvml.ErrorString(ret))
 	}
 
-	var devices Devices
+	devices := Devices{lib: lib}
 
 	for i := 0; i < count; i++ {
-		device, ret := nvml.DeviceGetHandleByIndex(i)
+		device, ret := lib.DeviceGetHandleByIndex(i)
+		if visibility != nil && errors.Is(ret, nvml.ERROR_NO_PERMISSION) {
+			nvmlLogger.Debug("skipping GPU without access", "index", i)
+			continue
+		}
 		if !errors.Is(ret, nvml.SUCCESS) {
-			return nil, nil, fmt.Errorf("failed to get device handle: %v", nvml.ErrorString(ret))
+			return Devices{}, nil, fmt.Errorf("failed to get device handle: %v", nvml.ErrorString(ret))
+		}
+		if visibility != nil {
+			uuid, ret := device.GetUUID()
+			if errors.Is(ret, nvml.ERROR_NO_PERMISSION) {
+				nvmlLogger.Debug("skipping GPU without access", "index", i)
+				continue
+			}
+			if !errors.Is(ret, nvml.SUCCESS) {
+				return Devices{}, nil, fmt.Errorf("failed to get UUID of device %d: %v", i, nvml.ErrorString(ret))
+			}
+			if !visibility.includes(i, uuid) {
+				nvmlLogger.Debug("skipping GPU not visible to the container", "index", i, "uuid", uuid)
+				continue
+			}
 		}
-		devices = append(devices, device)
+		devices.handles = append(devices.handles, device)
 	}
-	return devices, func() { shutdown(logger) }, nil
+	return devices, func() { shutdown(lib, logger) }, nil
 }
 
-// Devices is a thin slice wrapper that provides helper methods for NVML queries.
-type Devices []nvml.Device
+// Devices holds the NVML library and device handles and provides helper methods for NVML queries.
+type Devices struct {
+	lib     nvmlClient
+	handles []Device
+}
 
-// Count returns how many GPU handles are tracked in the slice.
+// Count returns how many GPU handles are tracked.
 func (d Devices) Count() int {
-	return len(d)
+	return len(d.handles)
 }
 
 // ExporterInfo queries system-wide NVML state to describe the exporter host.
@@ -64,19 +87,19 @@ func (d Devices) ExporterInfo() (*ExporterInfo, error) {
 	info := &ExporterInfo{}
 	var ret nvml.Return
 	// Get driver version
-	info.DriverVersion, ret = nvml.SystemGetDriverVersion()
+	info.DriverVersion, ret = d.lib.SystemGetDriverVersion()
 	if !errors.Is(ret, nvml.SUCCESS) {
 		return nil, fmt.Errorf("failed to get driver version: %v", nvml.ErrorString(ret))
 	}
 
 	// Get NVML version
-	info.NVMLVersion, ret = nvml.SystemGetNVMLVersion()
+	info.NVMLVersion, ret = d.lib.SystemGetNVMLVersion()
 	if !errors.Is(ret, nvml.SUCCESS) {
 		return nil, fmt.Errorf("failed to get NVML version: %v", nvml.ErrorString(ret))
 	}
 
 	// Get CUDA version
-	cudaVersion, ret := nvml.SystemGetCudaDriverVersion()
+	cudaVersion, ret := d.lib.SystemGetCudaDriverVersion()
 	if !errors.Is(ret, nvml.SUCCESS) {
 		return nil, fmt.Errorf("failed to get CUDA version: %v", nvml.ErrorString(ret))
 	}
@@ -88,6 +111,8 @@ func (d Devices) ExporterInfo() (*ExporterInfo, error) {
 // GpuInfo populates detailed metadata for the GPU at index i.
 func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 	info := &GpuInfo{
+		Index:               "unknown",
+		MinorNumber:         "unknown",
 		ChassisSerialNumber: "unknown",
 		SlotNumber:          "unknown",
 		TrayIndex:           "unknown",
@@ -100,8 +125,14 @@ func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 		NodeIndex:           "unknown",
 		IbGuid:              "unknown",
 		GpuFabricGuid:       "unknown",
+		GspFirmwareMode:     "unknown",
+		GspFirmwareVersion:  "unknown",
+		ComputeCapability:   "unknown",
+		Architecture:        "unknown",
+		DriverBranch:        "unknown",
+		BoardPartNumber:     "unknown",
 	}
-	device := d[i]
+	device := d.handles[i]
 
 	// Get UUID
 	uuid, ret := device.GetUUID()
@@ -115,11 +146,18 @@ func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 	if !errors.Is(ret, nvml.SUCCESS) {
 		return nil, fmt.Errorf("failed to get PCI info: %v", nvml.ErrorString(ret))
 	}
-	info.PciBusId = pciBusIdToString(pciInfo.BusIdLegacy)
+	info.PciBusId = pciBusID(pciInfo)
 	info.PciDomain = pciInfo.Domain
 	info.PciBus = uint32(pciInfo.Bus)
 	info.PciDevice = uint32(pciInfo.Device)
-	info.PciDomain = pciInfo.Domain
+
+	// Index and minor number, used to match container device mounts and kernel logs
+	if index, ret := device.GetIndex(); errors.Is(ret, nvml.SUCCESS) {
+		info.Index = fmt.Sprintf("%d", index)
+	}
+	if minor, ret := device.GetMinorNumber(); errors.Is(ret, nvml.SUCCESS) {
+		info.MinorNumber = fmt.Sprintf("%d", minor)
+	}
 	info.PciBus = uint32(pciInfo.Bus)
 	info.PciDevice = uint32(pciInfo.Device)
 
@@ -129,13 +167,15 @@ func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 		return nil, fmt.Errorf("failed to get name: %v", nvml.ErrorString(ret))
 	}
 	info.Name = name
+	info.FormFactor = formFactor(name)
 
 	// Get brand
 	brand, ret := device.GetBrand()
 	if !errors.Is(ret, nvml.SUCCESS) {
 		return nil, fmt.Errorf("failed to get brand: %v", nvml.ErrorString(ret))
 	}
-	info.Brand = fmt.Sprintf("%d", brand)
+	info.Brand = brandToString(brand)
+	info.BrandId = fmt.Sprintf("%d", brand)
 
 	// Get serial
 	serial, ret := device.GetSerial()
@@ -161,6 +201,16 @@ func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 	}
 	info.VbiosVersion = vbios
 
+	// Get board part number, needed for RMAs
+	partNumber, ret := device.GetBoardPartNumber()
+	if errors.Is(ret, nvml.SUCCESS) {
+		info.BoardPartNumber = partNumber
+	} else if errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		info.BoardPartNumber = "unsupported"
+	} else {
+		nvmlLogger.Warn("Failed to get board part number", "index", i, "error", nvml.ErrorString(ret))
+	}
+
 	// Get InfoROM versions
 	oemVersion, ret := device.GetInforomVersion(nvml.INFOROM_OEM)
 	if !errors.Is(ret, nvml.SUCCESS) {
@@ -193,7 +243,7 @@ func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 	platformInfo, ret := device.GetPlatformInfo()
 	if errors.Is(ret, nvml.SUCCESS) {
 		info.IbGuid = hex.EncodeToString(platformInfo.IbGuid[:])
-		info.ChassisSerialNumber = trimNull(platformInfo.ChassisSerialNumber[:])
+		info.ChassisSerialNumber = sanitizeLabelValue(trimNull(platformInfo.ChassisSerialNumber[:]))
 		info.SlotNumber = fmt.Sprintf("%d", platformInfo.SlotNumber)
 		info.TrayIndex = fmt.Sprintf("%d", platformInfo.TrayIndex)
 		info.HostId = fmt.Sprintf("%d", platformInfo.HostId)
@@ -217,15 +267,150 @@ func (d Devices) GpuInfo(i int) (*GpuInfo, error) {
 	}
 
 	// Get GPU Fabric Info for GUID
-	fabricInfo, ret := device.GetGpuFabricInfoV().V2()
+	fabricInfo, ret := device.GetGpuFabricInfoV2()
 	if errors.Is(ret, nvml.SUCCESS) {
 		// Convert ClusterUUID (which is the fabric GUID) to string
 		info.GpuFabricGuid = uuidBytesToString(fabricInfo.ClusterUuid)
 	}
 
+	// Get GSP firmware mode and version
+	gspEnabled, _, ret := device.GetGspFirmwareMode()
+	if errors.Is(ret, nvml.SUCCESS) {
+		if gspEnabled {
+			info.GspFirmwareMode = "enabled"
+		} else {
+			info.GspFirmwareMode = "disabled"
+		}
+	} else if errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		info.GspFirmwareMode = "unsupported"
+	} else {
+		nvmlLogger.Warn("Failed to get GSP firmware mode", "index", i, "error", nvml.ErrorString(ret))
+	}
+
+	gspVersion, ret := device.GetGspFirmwareVersion()
+	if errors.Is(ret, nvml.SUCCESS) {
+		info.GspFirmwareVersion = gspVersion
+	} else if errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		info.GspFirmwareVersion = "unsupported"
+	} else {
+		nvmlLogger.Warn("Failed to get GSP firmware version", "index", i, "error", nvml.ErrorString(ret))
+	}
+
+	// Get CUDA compute capability
+	major, minor, ret := device.GetCudaComputeCapability()
+	if errors.Is(ret, nvml.SUCCESS) {
+		info.ComputeCapability = fmt.Sprintf("%d.%d", major, minor)
+	} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		nvmlLogger.Warn("Failed to get CUDA compute capability", "index", i, "error", nvml.ErrorString(ret))
+	}
+
+	// Get architecture
+	arch, ret := device.GetArchitecture()
+	if errors.Is(ret, nvml.SUCCESS) {
+		info.Architecture = architectureToString(arch)
+	} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		nvmlLogger.Warn("Failed to get architecture", "index", i, "error", nvml.ErrorString(ret))
+	}
+
+	// Get driver branch (system wide, older drivers do not implement it)
+	branchInfo, ret := d.lib.SystemGetDriverBranch()
+	if errors.Is(ret, nvml.SUCCESS) {
+		info.DriverBranch = sanitizeLabelValue(trimNull(branchInfo.Branch[:]))
+	} else if errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) || errors.Is(ret, nvml.ERROR_FUNCTION_NOT_FOUND) {
+		info.DriverBranch = "unsupported"
+	} else {
+		nvmlLogger.Warn("Failed to get driver branch", "index", i, "error", nvml.ErrorString(ret))
+	}
+
 	return info, nil
 }
 
+// formFactor derives the module form factor from the GPU name, as NVML has no
+// query for it: superchip for Grace modules, pcie for PCIe cards and NVL
+// boards, and sxm for the models of modelNVLinks. Other GPUs are unknown.
+func formFactor(name string) string {
+	switch {
+	case isModel(name, "GH200") || isModel(name, "GB200") || isModel(name, "GB300"):
+		return "superchip"
+	case isModel(name, "PCIe") || isModel(name, "NVL"):
+		return "pcie"
+	}
+	if _, ok := builtinNVLinkCount(name); ok {
+		return "sxm"
+	}
+	return "unknown"
+}
+
+// brandToString maps the NVML brand enum to the product brand shown by nvidia-smi
+func brandToString(brand nvml.BrandType) string {
+	switch brand {
+	case nvml.BRAND_UNKNOWN:
+		return "Unknown"
+	case nvml.BRAND_QUADRO:
+		return "Quadro"
+	case nvml.BRAND_TESLA:
+		return "Tesla"
+	case nvml.BRAND_NVS:
+		return "NVS"
+	case nvml.BRAND_GRID:
+		return "GRID"
+	case nvml.BRAND_GEFORCE:
+		return "GeForce"
+	case nvml.BRAND_TITAN:
+		return "Titan"
+	case nvml.BRAND_NVIDIA_VAPPS:
+		return "NVIDIA Virtual Applications"
+	case nvml.BRAND_NVIDIA_VPC:
+		return "NVIDIA Virtual PC"
+	case nvml.BRAND_NVIDIA_VCS:
+		return "NVIDIA Virtual Compute Server"
+	case nvml.BRAND_NVIDIA_VWS:
+		return "NVIDIA RTX Virtual Workstation"
+	case nvml.BRAND_NVIDIA_CLOUD_GAMING:
+		return "NVIDIA Cloud Gaming"
+	case nvml.BRAND_QUADRO_RTX:
+		return "Quadro RTX"
+	case nvml.BRAND_NVIDIA_RTX:
+		return "NVIDIA RTX"
+	case nvml.BRAND_NVIDIA:
+		return "NVIDIA"
+	case nvml.BRAND_GEFORCE_RTX:
+		return "GeForce RTX"
+	case nvml.BRAND_TITAN_RTX:
+		return "Titan RTX"
+	default:
+		return fmt.Sprintf("unknown_%d", brand)
+	}
+}
+
+// architectureToString maps the NVML architecture enum to a lowercase name
+func architectureToString(arch nvml.DeviceArchitecture) string {
+	switch arch {
+	case nvml.DEVICE_ARCH_KEPLER:
+		return "kepler"
+	case nvml.DEVICE_ARCH_MAXWELL:
+		return "maxwell"
+	case nvml.DEVICE_ARCH_PASCAL:
+		return "pascal"
+	case nvml.DEVICE_ARCH_VOLTA:
+		return "volta"
+	case nvml.DEVICE_ARCH_TURING:
+		return "turing"
+	case nvml.DEVICE_ARCH_AMPERE:
+		return "ampere"
+	case nvml.DEVICE_ARCH_ADA:
+		return "ada"
+	case nvml.DEVICE_ARCH_HOPPER:
+		return "hopper"
+	case nvml.DEVICE_ARCH_BLACKWELL:
+		return "blackwell"
+	case nvml.DEVICE_ARCH_UNKNOWN:
+		return "unknown"
+	default:
+		return fmt.Sprintf("unknown_%d", arch)
+	}
+}
+
 func trimNull(buf []uint8) string {
 	end := len(buf)
 	for i, b := range buf {
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	exporterUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "up",
+			Help:      "Whether the exporter is running and serving metrics, always 1.",
+		},
+	)
+
+	nvmlInitialized = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nvml_initialized",
+			Help:      "Whether NVML initialized and the GPUs are being collected (1) or the exporter is waiting for NVML (0).",
+		},
+	)
+)
+
+// registerInitStatus exports nvgpu_up and nvgpu_nvml_initialized in reg.
+func registerInitStatus(reg prometheus.Registerer, initialized bool) {
+	exporterUp.Set(1)
+	nvmlInitialized.Set(flagToGauge(initialized))
+	reg.MustRegister(exporterUp, nvmlInitialized)
+}
+
+// nvmlUnavailableHandler serves the metrics of an exporter whose NVML failed
+// to initialize: nvgpu_up, nvgpu_nvml_initialized 0 and the exporter-internal
+// metrics.
+func nvmlUnavailableHandler(cfg *Config, logger *slog.Logger) http.Handler {
+	status := prometheus.NewRegistry()
+	registerInitStatus(status, false)
+	internal := newInternalRegistry(cfg)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(prometheus.Gatherers{status, internal}, internal, cfg, logger))
+	return mux
+}
+
+// waitForNvml retries init every -nvml-retry-interval until it succeeds,
+// serving nvmlUnavailableHandler on -addr in the meantime, so that a broken
+// driver shows up as nvgpu_nvml_initialized 0 rather than as a crash-looping
+// exporter. The server is shut down before the devices are returned.
+func waitForNvml(cfg *Config, init func() (Devices, func(), error), systemd *systemdNotifier, logger *slog.Logger) (Devices, func(), error) {
+	srv := newHTTPServer(cfg.Addr, nvmlUnavailableHandler(cfg, logger), cfg)
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return Devices{}, nil, fmt.Errorf("failed to start server: %w", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+	systemd.ready("waiting for NVML", logger)
+
+	for {
+		select {
+		case err := <-served:
+			return Devices{}, nil, fmt.Errorf("failed to start server: %w", err)
+		case <-time.After(cfg.NVMLRetryInterval):
+		}
+
+		devices, shutdown, err := init()
+		if err != nil {
+			logger.Warn("NVML still unavailable", "err", err, "retry", cfg.NVMLRetryInterval)
+			continue
+		}
+
+		logger.Info("NVML initialized")
+		ctx, cancel := context.WithTimeout(context.Background(), httpReadHeaderTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			shutdown()
+			return Devices{}, nil, fmt.Errorf("failed to stop server: %w", err)
+		}
+		return devices, shutdown, nil
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestNvmlUnavailableHandler(t *testing.T) {
+	assert := hammy.New(t)
+	handler := nvmlUnavailableHandler(&Config{GoMetrics: true}, discardLogger())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+	assert.Is(hammy.String(rec.Body.String()).Contains("nvgpu_up 1"))
+	assert.Is(hammy.String(rec.Body.String()).Contains("nvgpu_nvml_initialized 0"))
+	assert.Is(hammy.String(rec.Body.String()).Contains("go_goroutines"))
+}
+
+func TestWaitForNvmlRetriesUntilInitialized(t *testing.T) {
+	assert := hammy.New(t)
+	cfg := &Config{Addr: "127.0.0.1:0", NVMLRetryInterval: time.Millisecond}
+
+	attempts := 0
+	devices, shutdown, err := waitForNvml(cfg, func() (Devices, func(), error) {
+		attempts++
+		if attempts < 3 {
+			return Devices{}, nil, errors.New("failed to init NVML: Driver Not Loaded")
+		}
+		return Devices{handles: []Device{&fakeDevice{uuid: "GPU-1"}}}, func() {}, nil
+	}, nil, discardLogger())
+
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(attempts).EqualTo(3))
+	assert.Is(hammy.Number(devices.Count()).EqualTo(1))
+	shutdown()
+}
@@ -0,0 +1,952 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// systemScope is the snapshot index used for library-wide (non-device) calls.
+const systemScope = -1
+
+// nvmlSnapshot is the on-disk format of a recorded NVML session. Every call is
+// keyed by method name and arguments, e.g. "GetInforomVersion(1)".
+type nvmlSnapshot struct {
+	System  map[string]nvmlCall   `json:"system"`
+	Devices []map[string]nvmlCall `json:"devices"`
+}
+
+// nvmlCall is the recorded result of a single NVML query.
+type nvmlCall struct {
+	Return nvml.Return       `json:"return"`
+	Values []json.RawMessage `json:"values,omitempty"`
+}
+
+// newNvmlLibrary returns the NVML implementation selected by mode: empty for
+// the system library, "record:<file>" to record the responses of the system
+// library to file, or "replay:<file>" to serve responses from a recording.
+// libraryPath overrides where the system library is loaded from.
+func newNvmlLibrary(mode, libraryPath string, logger *slog.Logger) (nvml.Interface, error) {
+	kind, path, _ := strings.Cut(mode, ":")
+	switch kind {
+	case "":
+		return newSystemNvml(libraryPath, logger), nil
+	case "record":
+		if path == "" {
+			return nil, fmt.Errorf("-nvml=record requires a file, e.g. record:snapshot.json")
+		}
+		rec := newNvmlRecorder(path)
+		go rec.run(5*time.Second, logger)
+		logger.Info("recording NVML responses", "file", path)
+		return &recordingLibrary{Interface: newSystemNvml(libraryPath, logger), rec: rec, logger: logger}, nil
+	case "replay":
+		if path == "" {
+			return nil, fmt.Errorf("-nvml=replay requires a file, e.g. replay:snapshot.json")
+		}
+		snapshot, err := loadNvmlSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("replaying NVML responses", "file", path, "devices", len(snapshot.Devices))
+		return &replayLibrary{snapshot: snapshot}, nil
+	default:
+		return nil, fmt.Errorf("unknown -nvml mode %q (expected record:<file> or replay:<file>)", mode)
+	}
+}
+
+// nvmlLibraryName is the soname of the NVML library installed by the driver.
+const nvmlLibraryName = "libnvidia-ml.so.1"
+
+// newSystemNvml returns the NVML library of the installed driver. An empty path
+// leaves the search to the dynamic loader (LD_LIBRARY_PATH, ld.so.cache); a
+// directory is searched for libnvidia-ml.so.1. The library is only opened by
+// Init, and symbols are bound lazily on first call, so functions missing from
+// older drivers do not prevent the exporter from starting.
+func newSystemNvml(path string, logger *slog.Logger) nvml.Interface {
+	if path == "" {
+		return nvml.New()
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, nvmlLibraryName)
+	}
+	logger.Info("loading NVML", "library", path)
+	return nvml.New(nvml.WithLibraryPath(path))
+}
+
+func loadNvmlSnapshot(path string) (*nvmlSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NVML snapshot: %w", err)
+	}
+
+	var snapshot nvmlSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse NVML snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+func fieldValueKey(fieldId, scopeId uint32) string {
+	return fmt.Sprintf("GetFieldValues(%d,%d)", fieldId, scopeId)
+}
+
+// nvmlRecorder accumulates NVML responses and periodically writes them to disk.
+// The latest response for each call wins.
+type nvmlRecorder struct {
+	path string
+
+	mu       sync.Mutex
+	snapshot nvmlSnapshot
+	dirty    bool
+}
+
+func newNvmlRecorder(path string) *nvmlRecorder {
+	return &nvmlRecorder{
+		path:     path,
+		snapshot: nvmlSnapshot{System: make(map[string]nvmlCall)},
+	}
+}
+
+// record stores the result of a call for device (or systemScope).
+func (r *nvmlRecorder) record(device int, key string, ret nvml.Return, values ...any) {
+	call := nvmlCall{Return: ret}
+	for _, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		call.Values = append(call.Values, raw)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := r.snapshot.System
+	if device != systemScope {
+		for len(r.snapshot.Devices) <= device {
+			r.snapshot.Devices = append(r.snapshot.Devices, make(map[string]nvmlCall))
+		}
+		calls = r.snapshot.Devices[device]
+	}
+	calls[key] = call
+	r.dirty = true
+}
+
+// flush writes the snapshot to disk if anything changed since the last write.
+func (r *nvmlRecorder) flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a concurrent replay never sees a partial file
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+
+	r.dirty = false
+	return nil
+}
+
+func (r *nvmlRecorder) run(interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := r.flush(); err != nil {
+			logger.Warn("failed to write NVML snapshot", "file", r.path, "err", err)
+		}
+	}
+}
+
+// recordingLibrary wraps the system NVML library and records every response
+// used by the exporter.
+type recordingLibrary struct {
+	nvml.Interface
+	rec    *nvmlRecorder
+	logger *slog.Logger
+}
+
+func (l *recordingLibrary) Shutdown() nvml.Return {
+	if err := l.rec.flush(); err != nil {
+		l.logger.Warn("failed to write NVML snapshot", "file", l.rec.path, "err", err)
+	}
+	return l.Interface.Shutdown()
+}
+
+func (l *recordingLibrary) DeviceGetCount() (int, nvml.Return) {
+	v, ret := l.Interface.DeviceGetCount()
+	l.rec.record(systemScope, "DeviceGetCount", ret, v)
+	return v, ret
+}
+
+func (l *recordingLibrary) DeviceGetHandleByIndex(i int) (nvml.Device, nvml.Return) {
+	device, ret := l.Interface.DeviceGetHandleByIndex(i)
+	if ret != nvml.SUCCESS {
+		return device, ret
+	}
+	return &recordingDevice{Device: device, index: i, rec: l.rec}, ret
+}
+
+func (l *recordingLibrary) SystemGetDriverVersion() (string, nvml.Return) {
+	v, ret := l.Interface.SystemGetDriverVersion()
+	l.rec.record(systemScope, "SystemGetDriverVersion", ret, v)
+	return v, ret
+}
+
+func (l *recordingLibrary) SystemGetNVMLVersion() (string, nvml.Return) {
+	v, ret := l.Interface.SystemGetNVMLVersion()
+	l.rec.record(systemScope, "SystemGetNVMLVersion", ret, v)
+	return v, ret
+}
+
+func (l *recordingLibrary) SystemGetCudaDriverVersion() (int, nvml.Return) {
+	v, ret := l.Interface.SystemGetCudaDriverVersion()
+	l.rec.record(systemScope, "SystemGetCudaDriverVersion", ret, v)
+	return v, ret
+}
+
+func (l *recordingLibrary) SystemGetDriverBranch() (nvml.SystemDriverBranchInfo, nvml.Return) {
+	v, ret := l.Interface.SystemGetDriverBranch()
+	l.rec.record(systemScope, "SystemGetDriverBranch", ret, v)
+	return v, ret
+}
+
+// recordingDevice wraps an NVML device handle and records every response used
+// by the collectors. Methods not overridden here are passed through unrecorded.
+type recordingDevice struct {
+	nvml.Device
+	index int
+	rec   *nvmlRecorder
+}
+
+func (d *recordingDevice) GetUUID() (string, nvml.Return) {
+	v, ret := d.Device.GetUUID()
+	d.rec.record(d.index, "GetUUID", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	v, ret := d.Device.GetPciInfo()
+	d.rec.record(d.index, "GetPciInfo", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetName() (string, nvml.Return) {
+	v, ret := d.Device.GetName()
+	d.rec.record(d.index, "GetName", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetBrand() (nvml.BrandType, nvml.Return) {
+	v, ret := d.Device.GetBrand()
+	d.rec.record(d.index, "GetBrand", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetSerial() (string, nvml.Return) {
+	v, ret := d.Device.GetSerial()
+	d.rec.record(d.index, "GetSerial", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetBoardId() (uint32, nvml.Return) {
+	v, ret := d.Device.GetBoardId()
+	d.rec.record(d.index, "GetBoardId", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetVbiosVersion() (string, nvml.Return) {
+	v, ret := d.Device.GetVbiosVersion()
+	d.rec.record(d.index, "GetVbiosVersion", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetBoardPartNumber() (string, nvml.Return) {
+	v, ret := d.Device.GetBoardPartNumber()
+	d.rec.record(d.index, "GetBoardPartNumber", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetInforomVersion(object nvml.InforomObject) (string, nvml.Return) {
+	v, ret := d.Device.GetInforomVersion(object)
+	d.rec.record(d.index, fmt.Sprintf("GetInforomVersion(%d)", object), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetInforomImageVersion() (string, nvml.Return) {
+	v, ret := d.Device.GetInforomImageVersion()
+	d.rec.record(d.index, "GetInforomImageVersion", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetPlatformInfo() (nvml.PlatformInfo, nvml.Return) {
+	v, ret := d.Device.GetPlatformInfo()
+	d.rec.record(d.index, "GetPlatformInfo", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetGpuFabricInfoV2() (nvml.GpuFabricInfo_v2, nvml.Return) {
+	v, ret := d.Device.GetGpuFabricInfoV().V2()
+	d.rec.record(d.index, "GetGpuFabricInfoV2", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetGspFirmwareMode() (bool, bool, nvml.Return) {
+	enabled, defaultMode, ret := d.Device.GetGspFirmwareMode()
+	d.rec.record(d.index, "GetGspFirmwareMode", ret, enabled, defaultMode)
+	return enabled, defaultMode, ret
+}
+
+func (d *recordingDevice) GetGspFirmwareVersion() (string, nvml.Return) {
+	v, ret := d.Device.GetGspFirmwareVersion()
+	d.rec.record(d.index, "GetGspFirmwareVersion", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetCudaComputeCapability() (int, int, nvml.Return) {
+	major, minor, ret := d.Device.GetCudaComputeCapability()
+	d.rec.record(d.index, "GetCudaComputeCapability", ret, major, minor)
+	return major, minor, ret
+}
+
+func (d *recordingDevice) GetArchitecture() (nvml.DeviceArchitecture, nvml.Return) {
+	v, ret := d.Device.GetArchitecture()
+	d.rec.record(d.index, "GetArchitecture", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetNvLinkState(link int) (nvml.EnableState, nvml.Return) {
+	v, ret := d.Device.GetNvLinkState(link)
+	d.rec.record(d.index, fmt.Sprintf("GetNvLinkState(%d)", link), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetNvLinkRemotePciInfo(link int) (nvml.PciInfo, nvml.Return) {
+	v, ret := d.Device.GetNvLinkRemotePciInfo(link)
+	d.rec.record(d.index, fmt.Sprintf("GetNvLinkRemotePciInfo(%d)", link), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetNvLinkRemoteDeviceType(link int) (nvml.IntNvLinkDeviceType, nvml.Return) {
+	v, ret := d.Device.GetNvLinkRemoteDeviceType(link)
+	d.rec.record(d.index, fmt.Sprintf("GetNvLinkRemoteDeviceType(%d)", link), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	ret := d.Device.GetFieldValues(values)
+	if ret == nvml.SUCCESS {
+		for _, fv := range values {
+			d.rec.record(d.index, fieldValueKey(fv.FieldId, fv.ScopeId), ret, fv)
+		}
+	}
+	return ret
+}
+
+func (d *recordingDevice) SetNvLinkUtilizationControl(link int, counter int, control *nvml.NvLinkUtilizationControl, reset bool) nvml.Return {
+	ret := d.Device.SetNvLinkUtilizationControl(link, counter, control, reset)
+	d.rec.record(d.index, fmt.Sprintf("SetNvLinkUtilizationControl(%d,%d)", link, counter), ret)
+	return ret
+}
+
+func (d *recordingDevice) GetNvLinkUtilizationCounter(link int, counter int) (uint64, uint64, nvml.Return) {
+	rx, tx, ret := d.Device.GetNvLinkUtilizationCounter(link, counter)
+	d.rec.record(d.index, fmt.Sprintf("GetNvLinkUtilizationCounter(%d,%d)", link, counter), ret, rx, tx)
+	return rx, tx, ret
+}
+
+func (d *recordingDevice) GetApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return) {
+	v, ret := d.Device.GetApplicationsClock(clockType)
+	d.rec.record(d.index, fmt.Sprintf("GetApplicationsClock(%d)", clockType), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetDefaultApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return) {
+	v, ret := d.Device.GetDefaultApplicationsClock(clockType)
+	d.rec.record(d.index, fmt.Sprintf("GetDefaultApplicationsClock(%d)", clockType), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetAutoBoostedClocksEnabled() (nvml.EnableState, nvml.EnableState, nvml.Return) {
+	enabled, defaultEnabled, ret := d.Device.GetAutoBoostedClocksEnabled()
+	d.rec.record(d.index, "GetAutoBoostedClocksEnabled", ret, enabled, defaultEnabled)
+	return enabled, defaultEnabled, ret
+}
+
+func (d *recordingDevice) GetSramEccErrorStatus() (nvml.EccSramErrorStatus, nvml.Return) {
+	v, ret := d.Device.GetSramEccErrorStatus()
+	d.rec.record(d.index, "GetSramEccErrorStatus", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	v, ret := d.Device.GetComputeRunningProcesses()
+	d.rec.record(d.index, "GetComputeRunningProcesses", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetGraphicsRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	v, ret := d.Device.GetGraphicsRunningProcesses()
+	d.rec.record(d.index, "GetGraphicsRunningProcesses", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetMPSComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	v, ret := d.Device.GetMPSComputeRunningProcesses()
+	d.rec.record(d.index, "GetMPSComputeRunningProcesses", ret, v)
+	return v, ret
+}
+
+// GetProcessUtilization records the latest samples, which replay serves to
+// every caller that has not seen them yet.
+func (d *recordingDevice) GetProcessUtilization(lastSeenTimestamp uint64) ([]nvml.ProcessUtilizationSample, nvml.Return) {
+	v, ret := d.Device.GetProcessUtilization(lastSeenTimestamp)
+	d.rec.record(d.index, "GetProcessUtilization", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return) {
+	v, ret := d.Device.GetRetiredPagesPendingStatus()
+	d.rec.record(d.index, "GetRetiredPagesPendingStatus", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetRetiredPages_v2(cause nvml.PageRetirementCause) ([]uint64, []uint64, nvml.Return) {
+	addresses, timestamps, ret := d.Device.GetRetiredPages_v2(cause)
+	d.rec.record(d.index, fmt.Sprintf("GetRetiredPages_v2(%d)", cause), ret, addresses, timestamps)
+	return addresses, timestamps, ret
+}
+
+func (d *recordingDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	corrRows, uncRows, isPending, failureOccurred, ret := d.Device.GetRemappedRows()
+	d.rec.record(d.index, "GetRemappedRows", ret, corrRows, uncRows, isPending, failureOccurred)
+	return corrRows, uncRows, isPending, failureOccurred, ret
+}
+
+func (d *recordingDevice) GetRowRemapperHistogram() (nvml.RowRemapperHistogramValues, nvml.Return) {
+	v, ret := d.Device.GetRowRemapperHistogram()
+	d.rec.record(d.index, "GetRowRemapperHistogram", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetGpuOperationMode() (nvml.GpuOperationMode, nvml.GpuOperationMode, nvml.Return) {
+	current, pending, ret := d.Device.GetGpuOperationMode()
+	d.rec.record(d.index, "GetGpuOperationMode", ret, current, pending)
+	return current, pending, ret
+}
+
+func (d *recordingDevice) GetDisplayActive() (nvml.EnableState, nvml.Return) {
+	v, ret := d.Device.GetDisplayActive()
+	d.rec.record(d.index, "GetDisplayActive", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetDisplayMode() (nvml.EnableState, nvml.Return) {
+	v, ret := d.Device.GetDisplayMode()
+	d.rec.record(d.index, "GetDisplayMode", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetDriverModel() (nvml.DriverModel, nvml.DriverModel, nvml.Return) {
+	current, pending, ret := d.Device.GetDriverModel()
+	d.rec.record(d.index, "GetDriverModel", ret, current, pending)
+	return current, pending, ret
+}
+
+func (d *recordingDevice) WorkloadPowerProfileGetCurrentProfiles() (nvml.WorkloadPowerProfileCurrentProfiles, nvml.Return) {
+	v, ret := d.Device.WorkloadPowerProfileGetCurrentProfiles()
+	d.rec.record(d.index, "WorkloadPowerProfileGetCurrentProfiles", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetGpcClkVfOffset() (int, nvml.Return) {
+	v, ret := d.Device.GetGpcClkVfOffset()
+	d.rec.record(d.index, "GetGpcClkVfOffset", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetMemClkVfOffset() (int, nvml.Return) {
+	v, ret := d.Device.GetMemClkVfOffset()
+	d.rec.record(d.index, "GetMemClkVfOffset", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	v, ret := d.Device.GetMemoryInfo()
+	d.rec.record(d.index, "GetMemoryInfo", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	v, ret := d.Device.GetUtilizationRates()
+	d.rec.record(d.index, "GetUtilizationRates", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetCapabilities() (nvml.DeviceCapabilities, nvml.Return) {
+	v, ret := d.Device.GetCapabilities()
+	d.rec.record(d.index, "GetCapabilities", ret, v)
+	return v, ret
+}
+
+// GetSamples records the latest samples, which replay serves to every caller
+// that has not seen them yet.
+func (d *recordingDevice) GetSamples(samplingType nvml.SamplingType, lastSeenTimestamp uint64) (nvml.ValueType, []nvml.Sample, nvml.Return) {
+	valueType, samples, ret := d.Device.GetSamples(samplingType, lastSeenTimestamp)
+	d.rec.record(d.index, fmt.Sprintf("GetSamples(%d)", samplingType), ret, valueType, samples)
+	return valueType, samples, ret
+}
+
+func (d *recordingDevice) GetPowerManagementLimit() (uint32, nvml.Return) {
+	v, ret := d.Device.GetPowerManagementLimit()
+	d.rec.record(d.index, "GetPowerManagementLimit", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetTemperature(sensor nvml.TemperatureSensors) (uint32, nvml.Return) {
+	v, ret := d.Device.GetTemperature(sensor)
+	d.rec.record(d.index, fmt.Sprintf("GetTemperature(%d)", sensor), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetTemperatureThreshold(threshold nvml.TemperatureThresholds) (uint32, nvml.Return) {
+	v, ret := d.Device.GetTemperatureThreshold(threshold)
+	d.rec.record(d.index, fmt.Sprintf("GetTemperatureThreshold(%d)", threshold), ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetPersistenceMode() (nvml.EnableState, nvml.Return) {
+	v, ret := d.Device.GetPersistenceMode()
+	d.rec.record(d.index, "GetPersistenceMode", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetCurrentClocksEventReasons() (uint64, nvml.Return) {
+	v, ret := d.Device.GetCurrentClocksEventReasons()
+	d.rec.record(d.index, "GetCurrentClocksEventReasons", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetIndex() (int, nvml.Return) {
+	v, ret := d.Device.GetIndex()
+	d.rec.record(d.index, "GetIndex", ret, v)
+	return v, ret
+}
+
+func (d *recordingDevice) GetMinorNumber() (int, nvml.Return) {
+	v, ret := d.Device.GetMinorNumber()
+	d.rec.record(d.index, "GetMinorNumber", ret, v)
+	return v, ret
+}
+
+// GetTopologyCommonAncestor records the response under the index of peer,
+// which must be a device of the same recording library.
+func (d *recordingDevice) GetTopologyCommonAncestor(peer nvml.Device) (nvml.GpuTopologyLevel, nvml.Return) {
+	p, ok := peer.(*recordingDevice)
+	if !ok {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	v, ret := d.Device.GetTopologyCommonAncestor(p.Device)
+	d.rec.record(d.index, fmt.Sprintf("GetTopologyCommonAncestor(%d)", p.index), ret, v)
+	return v, ret
+}
+
+// replayLibrary serves NVML responses from a recorded snapshot. Calls that were
+// never recorded report ERROR_NOT_SUPPORTED, and no events are ever delivered.
+type replayLibrary struct {
+	nvml.Interface
+	snapshot *nvmlSnapshot
+}
+
+// replayCall decodes the recorded result of key from calls into out.
+func replayCall(calls map[string]nvmlCall, key string, out ...any) nvml.Return {
+	call, ok := calls[key]
+	if !ok {
+		return nvml.ERROR_NOT_SUPPORTED
+	}
+	if call.Return != nvml.SUCCESS {
+		return call.Return
+	}
+	for i, o := range out {
+		if i >= len(call.Values) || json.Unmarshal(call.Values[i], o) != nil {
+			return nvml.ERROR_UNKNOWN
+		}
+	}
+	return call.Return
+}
+
+func (l *replayLibrary) Init() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (l *replayLibrary) Shutdown() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (l *replayLibrary) DeviceGetCount() (int, nvml.Return) {
+	return len(l.snapshot.Devices), nvml.SUCCESS
+}
+
+func (l *replayLibrary) DeviceGetHandleByIndex(i int) (nvml.Device, nvml.Return) {
+	if i < 0 || i >= len(l.snapshot.Devices) {
+		return nil, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return &replayDevice{index: i, calls: l.snapshot.Devices[i]}, nvml.SUCCESS
+}
+
+func (l *replayLibrary) SystemGetDriverVersion() (v string, ret nvml.Return) {
+	ret = replayCall(l.snapshot.System, "SystemGetDriverVersion", &v)
+	return
+}
+
+func (l *replayLibrary) SystemGetNVMLVersion() (v string, ret nvml.Return) {
+	ret = replayCall(l.snapshot.System, "SystemGetNVMLVersion", &v)
+	return
+}
+
+func (l *replayLibrary) SystemGetCudaDriverVersion() (v int, ret nvml.Return) {
+	ret = replayCall(l.snapshot.System, "SystemGetCudaDriverVersion", &v)
+	return
+}
+
+func (l *replayLibrary) SystemGetDriverBranch() (v nvml.SystemDriverBranchInfo, ret nvml.Return) {
+	ret = replayCall(l.snapshot.System, "SystemGetDriverBranch", &v)
+	return
+}
+
+func (l *replayLibrary) EventSetCreate() (nvml.EventSet, nvml.Return) {
+	return replayEventSet{}, nvml.SUCCESS
+}
+
+// replayEventSet never delivers events; Wait blocks for the timeout.
+type replayEventSet struct{}
+
+func (replayEventSet) Wait(timeoutMs uint32) (nvml.EventData, nvml.Return) {
+	time.Sleep(time.Duration(timeoutMs) * time.Millisecond)
+	return nvml.EventData{}, nvml.ERROR_TIMEOUT
+}
+
+func (replayEventSet) Free() nvml.Return {
+	return nvml.SUCCESS
+}
+
+// replayDevice serves the recorded responses of a single device.
+type replayDevice struct {
+	nvml.Device
+	index int
+	calls map[string]nvmlCall
+}
+
+func (d *replayDevice) RegisterEvents(uint64, nvml.EventSet) nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (d *replayDevice) GetUUID() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetUUID", &v)
+	return
+}
+
+func (d *replayDevice) GetPciInfo() (v nvml.PciInfo, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetPciInfo", &v)
+	return
+}
+
+func (d *replayDevice) GetName() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetName", &v)
+	return
+}
+
+func (d *replayDevice) GetBrand() (v nvml.BrandType, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetBrand", &v)
+	return
+}
+
+func (d *replayDevice) GetSerial() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetSerial", &v)
+	return
+}
+
+func (d *replayDevice) GetBoardId() (v uint32, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetBoardId", &v)
+	return
+}
+
+func (d *replayDevice) GetVbiosVersion() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetVbiosVersion", &v)
+	return
+}
+
+func (d *replayDevice) GetBoardPartNumber() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetBoardPartNumber", &v)
+	return
+}
+
+func (d *replayDevice) GetInforomVersion(object nvml.InforomObject) (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetInforomVersion(%d)", object), &v)
+	return
+}
+
+func (d *replayDevice) GetInforomImageVersion() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetInforomImageVersion", &v)
+	return
+}
+
+func (d *replayDevice) GetPlatformInfo() (v nvml.PlatformInfo, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetPlatformInfo", &v)
+	return
+}
+
+func (d *replayDevice) GetGpuFabricInfoV2() (v nvml.GpuFabricInfo_v2, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetGpuFabricInfoV2", &v)
+	return
+}
+
+func (d *replayDevice) GetGspFirmwareMode() (enabled bool, defaultMode bool, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetGspFirmwareMode", &enabled, &defaultMode)
+	return
+}
+
+func (d *replayDevice) GetGspFirmwareVersion() (v string, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetGspFirmwareVersion", &v)
+	return
+}
+
+func (d *replayDevice) GetCudaComputeCapability() (major int, minor int, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetCudaComputeCapability", &major, &minor)
+	return
+}
+
+func (d *replayDevice) GetArchitecture() (v nvml.DeviceArchitecture, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetArchitecture", &v)
+	return
+}
+
+func (d *replayDevice) GetNvLinkState(link int) (v nvml.EnableState, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetNvLinkState(%d)", link), &v)
+	return
+}
+
+func (d *replayDevice) GetNvLinkRemotePciInfo(link int) (v nvml.PciInfo, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetNvLinkRemotePciInfo(%d)", link), &v)
+	return
+}
+
+func (d *replayDevice) GetNvLinkRemoteDeviceType(link int) (v nvml.IntNvLinkDeviceType, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetNvLinkRemoteDeviceType(%d)", link), &v)
+	return
+}
+
+func (d *replayDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	for i := range values {
+		var fv nvml.FieldValue
+		if ret := replayCall(d.calls, fieldValueKey(values[i].FieldId, values[i].ScopeId), &fv); ret != nvml.SUCCESS {
+			values[i].NvmlReturn = uint32(ret)
+			continue
+		}
+		values[i] = fv
+	}
+	return nvml.SUCCESS
+}
+
+// SetNvLinkUtilizationControl replays the recorded result without changing anything.
+func (d *replayDevice) SetNvLinkUtilizationControl(link int, counter int, _ *nvml.NvLinkUtilizationControl, _ bool) nvml.Return {
+	return replayCall(d.calls, fmt.Sprintf("SetNvLinkUtilizationControl(%d,%d)", link, counter))
+}
+
+func (d *replayDevice) GetNvLinkUtilizationCounter(link int, counter int) (rx uint64, tx uint64, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetNvLinkUtilizationCounter(%d,%d)", link, counter), &rx, &tx)
+	return
+}
+
+func (d *replayDevice) GetApplicationsClock(clockType nvml.ClockType) (v uint32, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetApplicationsClock(%d)", clockType), &v)
+	return
+}
+
+func (d *replayDevice) GetDefaultApplicationsClock(clockType nvml.ClockType) (v uint32, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetDefaultApplicationsClock(%d)", clockType), &v)
+	return
+}
+
+func (d *replayDevice) GetAutoBoostedClocksEnabled() (enabled nvml.EnableState, defaultEnabled nvml.EnableState, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetAutoBoostedClocksEnabled", &enabled, &defaultEnabled)
+	return
+}
+
+func (d *replayDevice) GetSramEccErrorStatus() (v nvml.EccSramErrorStatus, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetSramEccErrorStatus", &v)
+	return
+}
+
+func (d *replayDevice) GetComputeRunningProcesses() (v []nvml.ProcessInfo, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetComputeRunningProcesses", &v)
+	return
+}
+
+func (d *replayDevice) GetGraphicsRunningProcesses() (v []nvml.ProcessInfo, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetGraphicsRunningProcesses", &v)
+	return
+}
+
+func (d *replayDevice) GetMPSComputeRunningProcesses() (v []nvml.ProcessInfo, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetMPSComputeRunningProcesses", &v)
+	return
+}
+
+// GetProcessUtilization replays the recorded samples newer than
+// lastSeenTimestamp.
+func (d *replayDevice) GetProcessUtilization(lastSeenTimestamp uint64) (samples []nvml.ProcessUtilizationSample, ret nvml.Return) {
+	var recorded []nvml.ProcessUtilizationSample
+	ret = replayCall(d.calls, "GetProcessUtilization", &recorded)
+	for _, s := range recorded {
+		if s.TimeStamp > lastSeenTimestamp {
+			samples = append(samples, s)
+		}
+	}
+	return
+}
+
+func (d *replayDevice) GetRetiredPagesPendingStatus() (v nvml.EnableState, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetRetiredPagesPendingStatus", &v)
+	return
+}
+
+func (d *replayDevice) GetRetiredPages_v2(cause nvml.PageRetirementCause) (addresses []uint64, timestamps []uint64, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetRetiredPages_v2(%d)", cause), &addresses, &timestamps)
+	return
+}
+
+func (d *replayDevice) GetRemappedRows() (corrRows int, uncRows int, isPending bool, failureOccurred bool, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetRemappedRows", &corrRows, &uncRows, &isPending, &failureOccurred)
+	return
+}
+
+func (d *replayDevice) GetRowRemapperHistogram() (v nvml.RowRemapperHistogramValues, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetRowRemapperHistogram", &v)
+	return
+}
+
+func (d *replayDevice) GetGpuOperationMode() (current nvml.GpuOperationMode, pending nvml.GpuOperationMode, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetGpuOperationMode", &current, &pending)
+	return
+}
+
+func (d *replayDevice) GetDisplayActive() (v nvml.EnableState, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetDisplayActive", &v)
+	return
+}
+
+func (d *replayDevice) GetDisplayMode() (v nvml.EnableState, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetDisplayMode", &v)
+	return
+}
+
+func (d *replayDevice) GetDriverModel() (current nvml.DriverModel, pending nvml.DriverModel, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetDriverModel", &current, &pending)
+	return
+}
+
+func (d *replayDevice) WorkloadPowerProfileGetCurrentProfiles() (v nvml.WorkloadPowerProfileCurrentProfiles, ret nvml.Return) {
+	ret = replayCall(d.calls, "WorkloadPowerProfileGetCurrentProfiles", &v)
+	return
+}
+
+func (d *replayDevice) GetGpcClkVfOffset() (v int, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetGpcClkVfOffset", &v)
+	return
+}
+
+func (d *replayDevice) GetMemClkVfOffset() (v int, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetMemClkVfOffset", &v)
+	return
+}
+
+func (d *replayDevice) GetMemoryInfo() (v nvml.Memory, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetMemoryInfo", &v)
+	return
+}
+
+func (d *replayDevice) GetUtilizationRates() (v nvml.Utilization, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetUtilizationRates", &v)
+	return
+}
+
+func (d *replayDevice) GetCapabilities() (v nvml.DeviceCapabilities, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetCapabilities", &v)
+	return
+}
+
+// GetSamples replays the recorded samples newer than lastSeenTimestamp.
+func (d *replayDevice) GetSamples(samplingType nvml.SamplingType, lastSeenTimestamp uint64) (valueType nvml.ValueType, samples []nvml.Sample, ret nvml.Return) {
+	var recorded []nvml.Sample
+	ret = replayCall(d.calls, fmt.Sprintf("GetSamples(%d)", samplingType), &valueType, &recorded)
+	for _, s := range recorded {
+		if s.TimeStamp > lastSeenTimestamp {
+			samples = append(samples, s)
+		}
+	}
+	return
+}
+
+func (d *replayDevice) GetPowerManagementLimit() (v uint32, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetPowerManagementLimit", &v)
+	return
+}
+
+func (d *replayDevice) GetTemperature(sensor nvml.TemperatureSensors) (v uint32, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetTemperature(%d)", sensor), &v)
+	return
+}
+
+func (d *replayDevice) GetTemperatureThreshold(threshold nvml.TemperatureThresholds) (v uint32, ret nvml.Return) {
+	ret = replayCall(d.calls, fmt.Sprintf("GetTemperatureThreshold(%d)", threshold), &v)
+	return
+}
+
+func (d *replayDevice) GetPersistenceMode() (v nvml.EnableState, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetPersistenceMode", &v)
+	return
+}
+
+func (d *replayDevice) GetTopologyCommonAncestor(peer nvml.Device) (v nvml.GpuTopologyLevel, ret nvml.Return) {
+	p, ok := peer.(*replayDevice)
+	if !ok {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	ret = replayCall(d.calls, fmt.Sprintf("GetTopologyCommonAncestor(%d)", p.index), &v)
+	return
+}
+
+func (d *replayDevice) GetIndex() (v int, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetIndex", &v)
+	return
+}
+
+func (d *replayDevice) GetMinorNumber() (v int, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetMinorNumber", &v)
+	return
+}
+
+func (d *replayDevice) GetCurrentClocksEventReasons() (v uint64, ret nvml.Return) {
+	ret = replayCall(d.calls, "GetCurrentClocksEventReasons", &v)
+	return
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestNvmlRecordReplayRoundTrip(t *testing.T) {
+	assert := hammy.New(t)
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	rec := newNvmlRecorder(path)
+	rec.record(systemScope, "SystemGetDriverVersion", nvml.SUCCESS, "570.86.15")
+	rec.record(0, "GetUUID", nvml.SUCCESS, "GPU-0")
+	rec.record(0, "GetGspFirmwareMode", nvml.SUCCESS, true, false)
+	rec.record(0, "GetSerial", nvml.ERROR_NO_PERMISSION)
+	rec.record(0, fieldValueKey(nvml.FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_L0, 0), nvml.SUCCESS,
+		nvml.FieldValue{FieldId: nvml.FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_L0, ValueType: uint32(nvml.VALUE_TYPE_UNSIGNED_LONG_LONG), Value: [8]byte{7}})
+	rec.record(1, "GetUUID", nvml.SUCCESS, "GPU-1")
+	assert.Is(hammy.NilError(rec.flush()))
+
+	lib, err := newNvmlLibrary("replay:"+path, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Is(hammy.NilError(err))
+
+	count, ret := lib.DeviceGetCount()
+	assert.Is(hammy.True(ret == nvml.SUCCESS))
+	assert.Is(hammy.Number(count).EqualTo(2))
+
+	driver, ret := lib.SystemGetDriverVersion()
+	assert.Is(hammy.True(ret == nvml.SUCCESS))
+	assert.Is(hammy.String(driver).EqualTo("570.86.15"))
+
+	device, ret := lib.DeviceGetHandleByIndex(0)
+	assert.Is(hammy.True(ret == nvml.SUCCESS))
+
+	uuid, _ := device.GetUUID()
+	assert.Is(hammy.String(uuid).EqualTo("GPU-0"))
+
+	enabled, defaultMode, ret := device.GetGspFirmwareMode()
+	assert.Is(hammy.True(ret == nvml.SUCCESS))
+	assert.Is(hammy.True(enabled))
+	assert.Is(hammy.False(defaultMode))
+
+	_, ret = device.GetSerial()
+	assert.Is(hammy.True(ret == nvml.ERROR_NO_PERMISSION))
+
+	_, ret = device.GetName()
+	assert.Is(hammy.True(ret == nvml.ERROR_NOT_SUPPORTED))
+
+	values := []nvml.FieldValue{
+		{FieldId: nvml.FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_L0},
+		{FieldId: nvml.FI_DEV_NVLINK_CRC_DATA_ERROR_COUNT_L0},
+	}
+	assert.Is(hammy.True(device.GetFieldValues(values) == nvml.SUCCESS))
+	assert.Is(hammy.Number(values[0].NvmlReturn).EqualTo(uint32(nvml.SUCCESS)))
+	assert.Is(hammy.Number(values[0].Value[0]).EqualTo(byte(7)))
+	assert.Is(hammy.Number(values[1].NvmlReturn).EqualTo(uint32(nvml.ERROR_NOT_SUPPORTED)))
+}
+
+func TestNewNvmlLibraryRejectsUnknownMode(t *testing.T) {
+	assert := hammy.New(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := newNvmlLibrary("playback:foo.json", "", logger)
+	assert.Is(hammy.Error(err))
+
+	_, err = newNvmlLibrary("replay:", "", logger)
+	assert.Is(hammy.Error(err))
+}
@@ -0,0 +1,810 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+const (
+	// simulatedWavePeriod is the period of the synthetic load pattern.
+	simulatedWavePeriod = 20 * time.Minute
+	// simulatedFlapPeriod is how long the flapping NVLink stays in each state.
+	simulatedFlapPeriod = 3 * time.Minute
+	// simulatedXidInterval is the mean time between synthetic Xid events.
+	simulatedXidInterval = 15 * time.Minute
+	// simulatedNvLinkSpeedMBps is the per-direction speed of every NVLink.
+	simulatedNvLinkSpeedMBps = 25000
+	// simulatedSampleInterval is how often utilization is sampled.
+	simulatedSampleInterval = time.Second
+	// simulatedSampleBuffer is how far back utilization samples are kept.
+	simulatedSampleBuffer = 2 * time.Minute
+	// simulatedC2CLinks is the number of NVLink-C2C links to the Grace CPU.
+	simulatedC2CLinks = 10
+	// simulatedC2CLinkMBps is the maximum bandwidth of every NVLink-C2C link.
+	simulatedC2CLinkMBps = 45000
+)
+
+// simulatedModel describes a GPU SKU that can be simulated.
+type simulatedModel struct {
+	name         string
+	architecture nvml.DeviceArchitecture
+	ccMajor      int
+	ccMinor      int
+	nvlinks      int
+	memoryBytes  uint64
+	powerLimitW  uint32
+	platformInfo bool
+}
+
+var simulatedModels = map[string]simulatedModel{
+	"A100":  {name: "NVIDIA A100-SXM4-80GB", architecture: nvml.DEVICE_ARCH_AMPERE, ccMajor: 8, ccMinor: 0, nvlinks: 12, memoryBytes: 80 << 30, powerLimitW: 400},
+	"H100":  {name: "NVIDIA H100 80GB HBM3", architecture: nvml.DEVICE_ARCH_HOPPER, ccMajor: 9, ccMinor: 0, nvlinks: 18, memoryBytes: 80 << 30, powerLimitW: 700},
+	"H200":  {name: "NVIDIA H200", architecture: nvml.DEVICE_ARCH_HOPPER, ccMajor: 9, ccMinor: 0, nvlinks: 18, memoryBytes: 141 << 30, powerLimitW: 700},
+	"B200":  {name: "NVIDIA B200", architecture: nvml.DEVICE_ARCH_BLACKWELL, ccMajor: 10, ccMinor: 0, nvlinks: 18, memoryBytes: 180 << 30, powerLimitW: 1000},
+	"GB200": {name: "NVIDIA GB200", architecture: nvml.DEVICE_ARCH_BLACKWELL, ccMajor: 10, ccMinor: 0, nvlinks: 18, memoryBytes: 186 << 30, powerLimitW: 1200, platformInfo: true},
+}
+
+// simulatedXids are the Xids injected by the simulator, mostly benign
+// application faults with the occasional critical hardware error.
+var simulatedXids = []uint64{13, 13, 13, 31, 31, 43, 43, 63, 74, 79}
+
+// parseSimulateSpec parses a simulation spec such as "8xH100" or "GB200".
+func parseSimulateSpec(spec string) (int, simulatedModel, error) {
+	count := 1
+	modelName := spec
+	if n, m, ok := strings.Cut(spec, "x"); ok {
+		parsed, err := strconv.Atoi(n)
+		if err != nil || parsed < 1 || parsed > 64 {
+			return 0, simulatedModel{}, fmt.Errorf("invalid GPU count %q in -simulate=%s (expected 1-64)", n, spec)
+		}
+		count, modelName = parsed, m
+	}
+
+	model, ok := simulatedModels[strings.ToUpper(modelName)]
+	if !ok {
+		names := slices.Sorted(maps.Keys(simulatedModels))
+		return 0, simulatedModel{}, fmt.Errorf("unknown GPU model %q in -simulate=%s (supported: %s)", modelName, spec, strings.Join(names, ", "))
+	}
+	return count, model, nil
+}
+
+// newSimulatedLibrary returns an NVML implementation backed by synthetic
+// devices, for developing dashboards and alerts without GPUs.
+func newSimulatedLibrary(spec string) (nvml.Interface, error) {
+	count, model, err := parseSimulateSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	lib := &simulatedLibrary{}
+	for i := 0; i < count; i++ {
+		clockTime := make(map[uint32]float64, len(clockEventReasons))
+		for _, reason := range clockEventReasons {
+			if reason.fieldID != 0 {
+				clockTime[reason.fieldID] = 0
+			}
+		}
+
+		lib.devices = append(lib.devices, &simulatedDevice{
+			model:     model,
+			index:     i,
+			start:     start,
+			lastTick:  start,
+			flapLink:  i == 0,
+			clockTime: clockTime,
+		})
+	}
+	return lib, nil
+}
+
+// simulatedLibrary is an nvml.Interface serving synthetic devices.
+type simulatedLibrary struct {
+	nvml.Interface
+	devices []*simulatedDevice
+}
+
+func (l *simulatedLibrary) Init() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) Shutdown() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) DeviceGetCount() (int, nvml.Return) {
+	return len(l.devices), nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) DeviceGetHandleByIndex(i int) (nvml.Device, nvml.Return) {
+	if i < 0 || i >= len(l.devices) {
+		return nil, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return l.devices[i], nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) SystemGetDriverVersion() (string, nvml.Return) {
+	return "570.124.06", nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) SystemGetNVMLVersion() (string, nvml.Return) {
+	return "12.570.124.06", nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) SystemGetCudaDriverVersion() (int, nvml.Return) {
+	return 12080, nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) SystemGetDriverBranch() (nvml.SystemDriverBranchInfo, nvml.Return) {
+	var info nvml.SystemDriverBranchInfo
+	copy(info.Branch[:], "r570_00")
+	return info, nvml.SUCCESS
+}
+
+func (l *simulatedLibrary) EventSetCreate() (nvml.EventSet, nvml.Return) {
+	return &simulatedEventSet{}, nvml.SUCCESS
+}
+
+// simulatedEventSet delivers a random Xid on a random registered device
+// roughly every simulatedXidInterval.
+type simulatedEventSet struct {
+	mu      sync.Mutex
+	devices []nvml.Device
+}
+
+func (s *simulatedEventSet) Wait(timeoutMs uint32) (nvml.EventData, nvml.Return) {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	time.Sleep(timeout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.devices) == 0 || rand.Float64() >= float64(timeout)/float64(simulatedXidInterval) {
+		return nvml.EventData{}, nvml.ERROR_TIMEOUT
+	}
+
+	return nvml.EventData{
+		Device:    s.devices[rand.IntN(len(s.devices))],
+		EventType: nvml.EventTypeXidCriticalError,
+		EventData: simulatedXids[rand.IntN(len(simulatedXids))],
+	}, nvml.SUCCESS
+}
+
+func (s *simulatedEventSet) Free() nvml.Return {
+	return nvml.SUCCESS
+}
+
+// simulatedDevice fabricates plausible readings for a single GPU: a slow load
+// wave driving power capping and process memory, steadily growing NVLink
+// error counters, and (on the first GPU) an NVLink that flaps up and down.
+type simulatedDevice struct {
+	nvml.Device
+	model    simulatedModel
+	index    int
+	start    time.Time
+	flapLink bool
+
+	mu        sync.Mutex
+	lastTick  time.Time
+	clockTime map[uint32]float64
+	// nvlinkKiB is the data sent and received so far on each NVLink
+	nvlinkKiB float64
+}
+
+// load returns the synthetic utilization in [0, 1] at t. Devices are phase
+// shifted so that they do not move in lockstep.
+func (d *simulatedDevice) load(t time.Time) float64 {
+	phase := float64(t.Sub(d.start))/float64(simulatedWavePeriod) + float64(d.index)/8
+	return 0.5 + 0.5*math.Sin(2*math.Pi*phase)
+}
+
+// linkUp reports whether link is currently active.
+func (d *simulatedDevice) linkUp(link int, t time.Time) bool {
+	if link >= d.model.nvlinks {
+		return false
+	}
+	if d.flapLink && link == d.model.nvlinks-1 {
+		return int(t.Sub(d.start)/simulatedFlapPeriod)%2 == 0
+	}
+	return true
+}
+
+// advance accumulates clock event time up to now.
+func (d *simulatedDevice) advance(now time.Time) {
+	elapsed := float64(now.Sub(d.lastTick))
+	d.lastTick = now
+
+	// Power capping tracks load above 70%, thermal slowdown only at the peak
+	load := d.load(now)
+	d.clockTime[nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP] += elapsed * math.Max(0, load-0.7) / 0.3
+	d.clockTime[nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_THERM_SLOWDOWN] += elapsed * math.Max(0, load-0.95) / 0.05 * 0.2
+
+	// NVLink traffic peaks at 80% of the link speed
+	d.nvlinkKiB += elapsed / float64(time.Second) * 0.8 * load * simulatedNvLinkSpeedMBps * 1e6 / 1024
+}
+
+// nvlinkCounter returns the synthetic value of an NVLink error counter.
+func (d *simulatedDevice) nvlinkCounter(fieldId uint32, link int, t time.Time) (uint64, bool) {
+	minutes := t.Sub(d.start).Minutes()
+	rate := 0.0
+	switch fieldId {
+	case nvmlFieldIdNvLinkEffectiveErrors, nvmlFieldIdNvLinkLocalLinkIntegrityErrors,
+		nvml.FI_DEV_NVLINK_ERROR_DL_REPLAY, nvml.FI_DEV_NVLINK_ERROR_DL_CRC:
+		rate = 0.05
+	case nvmlFieldIdNvLinkSymbolErrors:
+		rate = 2
+	case nvmlFieldIdNvLinkReceiveErrors:
+		rate = 0.02
+	case nvmlFieldIdNvLinkTransmitDiscards:
+		// Packets are discarded while the flapping link is down
+		if d.flapLink && link == d.model.nvlinks-1 {
+			return uint64(t.Sub(d.start)/simulatedFlapPeriod) * 3, true
+		}
+	case nvmlFieldIdNvLinkRecoveryEvents, nvmlFieldIdNvLinkRecoverySuccessfulEvents:
+		// Every flap of the flapping link is a recovery
+		if d.flapLink && link == d.model.nvlinks-1 {
+			return uint64(t.Sub(d.start) / simulatedFlapPeriod), true
+		}
+	case nvmlFieldIdNvLinkMalformedPacketErrors, nvmlFieldIdNvLinkBufferOverrunErrors, nvmlFieldIdNvLinkRecoveryFailedEvents,
+		nvmlFieldIdNvLinkReceiveRemoteErrors, nvmlFieldIdNvLinkReceiveGeneralErrors:
+	case nvmlFieldIdNvLinkFECHistory0:
+		rate = 1e6
+	case nvmlFieldIdNvLinkFECHistory1:
+		rate = 1e3
+	case nvmlFieldIdNvLinkFECHistory2:
+		rate = 10
+	default:
+		if fieldId > nvmlFieldIdNvLinkFECHistory2 && fieldId <= nvmlFieldIdNvLinkFECHistory15 {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	// The flapping link is noticeably noisier than the others
+	if d.flapLink && link == d.model.nvlinks-1 {
+		rate *= 20
+	}
+	return uint64(minutes * rate), true
+}
+
+func setSimulatedField(fv *nvml.FieldValue, v uint64) {
+	fv.ValueType = uint32(nvml.VALUE_TYPE_UNSIGNED_LONG_LONG)
+	binary.LittleEndian.PutUint64(fv.Value[:], v)
+	fv.NvmlReturn = uint32(nvml.SUCCESS)
+}
+
+func (d *simulatedDevice) RegisterEvents(eventTypes uint64, set nvml.EventSet) nvml.Return {
+	s, ok := set.(*simulatedEventSet)
+	if !ok {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	s.mu.Lock()
+	s.devices = append(s.devices, d)
+	s.mu.Unlock()
+	return nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetUUID() (string, nvml.Return) {
+	return fmt.Sprintf("GPU-5e1a7ed0-0000-4000-8000-%012x", d.index), nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetPciInfo() (nvml.PciInfo, nvml.Return) {
+	info := nvml.PciInfo{Bus: uint32(0x18 + d.index*0x10)}
+	copy(info.BusIdLegacy[:], fmt.Sprintf("0000:%02X:00.0", info.Bus))
+	copy(info.BusId[:], fmt.Sprintf("00000000:%02X:00.0", info.Bus))
+	return info, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetName() (string, nvml.Return) {
+	return d.model.name, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetBrand() (nvml.BrandType, nvml.Return) {
+	return nvml.BRAND_NVIDIA, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetSerial() (string, nvml.Return) {
+	return fmt.Sprintf("SIM%010d", d.index), nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetBoardId() (uint32, nvml.Return) {
+	return uint32(0x100 + d.index), nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetVbiosVersion() (string, nvml.Return) {
+	return "96.00.99.00.01", nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetBoardPartNumber() (string, nvml.Return) {
+	return "699-2G520-0200-000", nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetInforomVersion(object nvml.InforomObject) (string, nvml.Return) {
+	switch object {
+	case nvml.INFOROM_OEM:
+		return "2.1", nvml.SUCCESS
+	case nvml.INFOROM_ECC:
+		return "7.16", nvml.SUCCESS
+	case nvml.INFOROM_POWER:
+		return "N/A", nvml.SUCCESS
+	default:
+		return "", nvml.ERROR_INVALID_ARGUMENT
+	}
+}
+
+func (d *simulatedDevice) GetInforomImageVersion() (string, nvml.Return) {
+	return "G520.0200.00.05", nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetPlatformInfo() (nvml.PlatformInfo, nvml.Return) {
+	if !d.model.platformInfo {
+		return nvml.PlatformInfo{}, nvml.ERROR_NOT_SUPPORTED
+	}
+
+	info := nvml.PlatformInfo{
+		SlotNumber: uint8(d.index / 4),
+		TrayIndex:  uint8(d.index / 4),
+		ModuleId:   uint8(d.index % 4),
+	}
+	copy(info.ChassisSerialNumber[:], "SIMCHASSIS0001")
+	info.IbGuid[15] = uint8(d.index)
+	return info, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetGpuFabricInfoV2() (nvml.GpuFabricInfo_v2, nvml.Return) {
+	info := nvml.GpuFabricInfo_v2{
+		Status: uint32(nvml.SUCCESS),
+		State:  nvml.GPU_FABRIC_STATE_COMPLETED,
+		HealthMask: nvml.GPU_FABRIC_HEALTH_MASK_DEGRADED_BW_FALSE<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_DEGRADED_BW |
+			nvml.GPU_FABRIC_HEALTH_MASK_ROUTE_RECOVERY_FALSE<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_ROUTE_RECOVERY |
+			nvml.GPU_FABRIC_HEALTH_MASK_ROUTE_UNHEALTHY_FALSE<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_ROUTE_UNHEALTHY |
+			nvml.GPU_FABRIC_HEALTH_MASK_ACCESS_TIMEOUT_RECOVERY_FALSE<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_ACCESS_TIMEOUT_RECOVERY |
+			nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_NONE<<nvml.GPU_FABRIC_HEALTH_MASK_SHIFT_INCORRECT_CONFIGURATION,
+	}
+	copy(info.ClusterUuid[:], "simulated-clique")
+
+	// The flapping link degrades bandwidth while it is down
+	if !d.linkUp(d.model.nvlinks-1, time.Now()) {
+		info.HealthMask = info.HealthMask&^0x3 | nvml.GPU_FABRIC_HEALTH_MASK_DEGRADED_BW_TRUE
+	}
+	return info, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetGspFirmwareMode() (bool, bool, nvml.Return) {
+	return true, true, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetGspFirmwareVersion() (string, nvml.Return) {
+	return "570.124.06", nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetCudaComputeCapability() (int, int, nvml.Return) {
+	return d.model.ccMajor, d.model.ccMinor, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetArchitecture() (nvml.DeviceArchitecture, nvml.Return) {
+	return d.model.architecture, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetNvLinkState(link int) (nvml.EnableState, nvml.Return) {
+	if link < 0 || link >= d.model.nvlinks {
+		return nvml.FEATURE_DISABLED, nvml.ERROR_INVALID_ARGUMENT
+	}
+	if d.linkUp(link, time.Now()) {
+		return nvml.FEATURE_ENABLED, nvml.SUCCESS
+	}
+	return nvml.FEATURE_DISABLED, nvml.SUCCESS
+}
+
+// GetNvLinkRemotePciInfo spreads the links over four baseboard NVSwitches.
+// GB200 switches live in separate trays and have no PCI address on the node.
+func (d *simulatedDevice) GetNvLinkRemotePciInfo(link int) (nvml.PciInfo, nvml.Return) {
+	var info nvml.PciInfo
+	if link < 0 || link >= d.model.nvlinks {
+		return info, nvml.ERROR_INVALID_ARGUMENT
+	}
+	if d.model.platformInfo {
+		return info, nvml.ERROR_NOT_SUPPORTED
+	}
+	info.Bus = uint32(0xA0 + link%4)
+	copy(info.BusIdLegacy[:], fmt.Sprintf("0000:%02X:00.0", info.Bus))
+	copy(info.BusId[:], fmt.Sprintf("00000000:%02X:00.0", info.Bus))
+	return info, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetNvLinkRemoteDeviceType(link int) (nvml.IntNvLinkDeviceType, nvml.Return) {
+	if link < 0 || link >= d.model.nvlinks {
+		return nvml.NVLINK_DEVICE_TYPE_UNKNOWN, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return nvml.NVLINK_DEVICE_TYPE_SWITCH, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetFieldValues(values []nvml.FieldValue) nvml.Return {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.advance(now)
+
+	for i := range values {
+		fv := &values[i]
+		fv.NvmlReturn = uint32(nvml.ERROR_NOT_SUPPORTED)
+
+		if ns, ok := d.clockTime[fv.FieldId]; ok {
+			setSimulatedField(fv, uint64(ns))
+			continue
+		}
+
+		switch fv.FieldId {
+		case nvml.FI_PWR_SMOOTHING_ENABLED, nvml.FI_PWR_SMOOTHING_ACTIVE_PRESET_PROFILE,
+			nvml.FI_PWR_SMOOTHING_APPLIED_TMP_CEIL, nvml.FI_PWR_SMOOTHING_APPLIED_TMP_FLOOR,
+			nvml.FI_PWR_SMOOTHING_HW_CIRCUITRY_PERCENT_LIFETIME_REMAINING:
+			if v, ok := d.powerSmoothingField(fv.FieldId); ok {
+				setSimulatedField(fv, v)
+			}
+		case nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX:
+			if d.linkUp(int(fv.ScopeId), now) {
+				setSimulatedField(fv, uint64(d.nvlinkKiB))
+			}
+		case nvml.FI_DEV_C2C_LINK_COUNT, nvml.FI_DEV_C2C_LINK_GET_STATUS, nvml.FI_DEV_C2C_LINK_GET_MAX_BW,
+			nvml.FI_DEV_C2C_LINK_POWER_STATE:
+			if v, ok := d.graceField(fv.FieldId, fv.ScopeId, now); ok {
+				setSimulatedField(fv, v)
+			}
+		case nvml.FI_DEV_POWER_INSTANT:
+			if v, ok := d.powerField(fv.ScopeId, now); ok {
+				setSimulatedField(fv, v)
+			}
+		case nvml.FI_DEV_ECC_DBE_VOL_TOTAL:
+			setSimulatedField(fv, 0)
+		case nvml.FI_DEV_GET_GPU_RECOVERY_ACTION:
+			setSimulatedField(fv, uint64(nvml.GPU_RECOVERY_ACTION_NONE))
+		case nvml.FI_DEV_NVLINK_GET_POWER_STATE:
+			// Links never sleep; the flapping link goes down instead
+			if int(fv.ScopeId) < d.model.nvlinks {
+				setSimulatedField(fv, nvml.NVLINK_POWER_STATE_HIGH_SPEED)
+			}
+		case nvml.FI_DEV_NVLINK_REMOTE_NVLINK_ID:
+			// Every GPU takes the port of its index on each switch
+			if int(fv.ScopeId) < d.model.nvlinks {
+				setSimulatedField(fv, uint64(d.index))
+			}
+		case nvml.FI_DEV_NVLINK_GET_SPEED:
+			if d.linkUp(int(fv.ScopeId), now) {
+				setSimulatedField(fv, simulatedNvLinkSpeedMBps)
+			}
+		case nvmlFieldIdNvLinkEffectiveBER, nvmlFieldIdNvLinkSymbolBER:
+			// mantissa 1..9 x 10^-15, worse on the flapping link
+			exponent := uint64(15)
+			if d.flapLink && int(fv.ScopeId) == d.model.nvlinks-1 {
+				exponent = 11
+			}
+			setSimulatedField(fv, uint64(1+d.index%9)<<8|exponent)
+		default:
+			if v, ok := d.nvlinkCounter(fv.FieldId, int(fv.ScopeId), now); ok {
+				setSimulatedField(fv, v)
+			}
+		}
+	}
+	return nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return) {
+	return d.GetDefaultApplicationsClock(clockType)
+}
+
+func (d *simulatedDevice) GetDefaultApplicationsClock(clockType nvml.ClockType) (uint32, nvml.Return) {
+	switch clockType {
+	case nvml.CLOCK_GRAPHICS, nvml.CLOCK_SM:
+		return 1755, nvml.SUCCESS
+	case nvml.CLOCK_MEM:
+		return 2619, nvml.SUCCESS
+	case nvml.CLOCK_VIDEO:
+		return 1545, nvml.SUCCESS
+	default:
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+}
+
+func (d *simulatedDevice) GetGpcClkVfOffset() (int, nvml.Return) {
+	return 0, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetMemClkVfOffset() (int, nvml.Return) {
+	return 0, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetAutoBoostedClocksEnabled() (nvml.EnableState, nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetSramEccErrorStatus() (nvml.EccSramErrorStatus, nvml.Return) {
+	return nvml.EccSramErrorStatus{}, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	load := d.load(time.Now())
+	if load < 0.1 {
+		return nil, nvml.SUCCESS
+	}
+
+	// Attribute the work to the exporter itself so it never looks like a ghost
+	return []nvml.ProcessInfo{{
+		Pid:           uint32(os.Getpid()),
+		UsedGpuMemory: uint64(float64(d.model.memoryBytes) * (0.2 + 0.7*load)),
+	}}, nvml.SUCCESS
+}
+
+// GetMemoryInfo reports the memory of the simulated workload plus a small
+// driver reservation.
+func (d *simulatedDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	used := uint64(512 << 20)
+	if processes, _ := d.GetComputeRunningProcesses(); len(processes) > 0 {
+		used += processes[0].UsedGpuMemory
+	}
+	return nvml.Memory{Total: d.model.memoryBytes, Used: used, Free: d.model.memoryBytes - used}, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	load := d.load(time.Now())
+	return nvml.Utilization{Gpu: uint32(100 * load), Memory: uint32(60 * load)}, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetGraphicsRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	return nil, nvml.SUCCESS
+}
+
+// GetMPSComputeRunningProcesses reports no clients, as the simulated GPUs do
+// not run an MPS server.
+func (d *simulatedDevice) GetMPSComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	return nil, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetProcessUtilization(lastSeenTimestamp uint64) ([]nvml.ProcessUtilizationSample, nvml.Return) {
+	return nil, nvml.ERROR_NOT_FOUND
+}
+
+func (d *simulatedDevice) GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetRetiredPages_v2(nvml.PageRetirementCause) ([]uint64, []uint64, nvml.Return) {
+	return nil, nil, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	return 0, 0, false, false, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetRowRemapperHistogram() (nvml.RowRemapperHistogramValues, nvml.Return) {
+	return nvml.RowRemapperHistogramValues{Max: 640}, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetGpuOperationMode() (nvml.GpuOperationMode, nvml.GpuOperationMode, nvml.Return) {
+	return nvml.GOM_ALL_ON, nvml.GOM_ALL_ON, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetDisplayActive() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetDisplayMode() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetDriverModel() (nvml.DriverModel, nvml.DriverModel, nvml.Return) {
+	return nvml.DRIVER_WDDM, nvml.DRIVER_WDDM, nvml.ERROR_NOT_SUPPORTED
+}
+
+// powerSmoothingField returns the power smoothing state of Blackwell GPUs,
+// which run preset profile 1 with smoothing enabled.
+func (d *simulatedDevice) powerSmoothingField(fieldId uint32) (uint64, bool) {
+	if d.model.architecture != nvml.DEVICE_ARCH_BLACKWELL {
+		return 0, false
+	}
+	switch fieldId {
+	case nvml.FI_PWR_SMOOTHING_ENABLED, nvml.FI_PWR_SMOOTHING_ACTIVE_PRESET_PROFILE:
+		return 1, true
+	case nvml.FI_PWR_SMOOTHING_APPLIED_TMP_CEIL:
+		return 1000, true
+	case nvml.FI_PWR_SMOOTHING_APPLIED_TMP_FLOOR:
+		return 600, true
+	case nvml.FI_PWR_SMOOTHING_HW_CIRCUITRY_PERCENT_LIFETIME_REMAINING:
+		return 98, true
+	}
+	return 0, false
+}
+
+// WorkloadPowerProfileGetCurrentProfiles reports the LLM training profile as
+// requested and enforced on Blackwell GPUs.
+func (d *simulatedDevice) WorkloadPowerProfileGetCurrentProfiles() (nvml.WorkloadPowerProfileCurrentProfiles, nvml.Return) {
+	var profiles nvml.WorkloadPowerProfileCurrentProfiles
+	if d.model.architecture != nvml.DEVICE_ARCH_BLACKWELL {
+		return profiles, nvml.ERROR_NOT_SUPPORTED
+	}
+	for _, p := range []nvml.PowerProfileType{nvml.POWER_PROFILE_MAX_P, nvml.POWER_PROFILE_MAX_Q, nvml.POWER_PROFILE_COMPUTE, nvml.POWER_PROFILE_LLM_TRAINING} {
+		profiles.PerfProfilesMask.Mask[0] |= 1 << p
+	}
+	profiles.RequestedProfilesMask.Mask[0] = 1 << nvml.POWER_PROFILE_LLM_TRAINING
+	profiles.EnforcedProfilesMask.Mask[0] = 1 << nvml.POWER_PROFILE_LLM_TRAINING
+	return profiles, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) SetNvLinkUtilizationControl(int, int, *nvml.NvLinkUtilizationControl, bool) nvml.Return {
+	return nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetNvLinkUtilizationCounter(int, int) (uint64, uint64, nvml.Return) {
+	return 0, 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+// graceField returns the C2C link readings of GB200 GPUs, which reach their
+// Grace CPU over simulatedC2CLinks full power links.
+func (d *simulatedDevice) graceField(fieldId, scopeId uint32, t time.Time) (uint64, bool) {
+	if !d.model.platformInfo {
+		return 0, false
+	}
+	switch fieldId {
+	case nvml.FI_DEV_C2C_LINK_COUNT:
+		return simulatedC2CLinks, true
+	case nvml.FI_DEV_C2C_LINK_GET_STATUS:
+		return 1, scopeId < simulatedC2CLinks
+	case nvml.FI_DEV_C2C_LINK_GET_MAX_BW:
+		return simulatedC2CLinkMBps, scopeId < simulatedC2CLinks
+	case nvml.FI_DEV_C2C_LINK_POWER_STATE:
+		return nvml.C2C_POWER_STATE_FULL_POWER, scopeId < simulatedC2CLinks
+	}
+	return 0, false
+}
+
+// powerField returns the power of a scope in milliwatts. The board draws
+// between 15% and 95% of its power limit with the load and the memory rail a
+// tenth of that; only GB200 modules report their module power.
+func (d *simulatedDevice) powerField(scopeId uint32, t time.Time) (uint64, bool) {
+	board := float64(d.model.powerLimitW) * (0.15 + 0.8*d.load(t))
+	switch scopeId {
+	case nvml.POWER_SCOPE_GPU:
+		return uint64(1e3 * board), true
+	case nvml.POWER_SCOPE_MEMORY:
+		return uint64(1e3 * board / 10), true
+	case nvml.POWER_SCOPE_MODULE:
+		if !d.model.platformInfo {
+			return 0, false
+		}
+		// Idling at 1.2 kW
+		return uint64(1e3 * (1200 + 1500*d.load(t))), true
+	}
+	return 0, false
+}
+
+// GetCapabilities reports EGM support on GB200 GPUs.
+func (d *simulatedDevice) GetCapabilities() (nvml.DeviceCapabilities, nvml.Return) {
+	if !d.model.platformInfo {
+		return nvml.DeviceCapabilities{}, nvml.ERROR_NOT_SUPPORTED
+	}
+	return nvml.DeviceCapabilities{CapMask: nvml.DEV_CAP_EGM}, nvml.SUCCESS
+}
+
+// GetSamples returns one utilization sample per simulatedSampleInterval since
+// lastSeenTimestamp, covering at most simulatedSampleBuffer like the driver's
+// ring buffer. Short bursts on top of the load wave make the peaks visible.
+func (d *simulatedDevice) GetSamples(samplingType nvml.SamplingType, lastSeenTimestamp uint64) (nvml.ValueType, []nvml.Sample, nvml.Return) {
+	var scale float64
+	switch samplingType {
+	case nvml.GPU_UTILIZATION_SAMPLES:
+		scale = 100
+	case nvml.MEMORY_UTILIZATION_SAMPLES:
+		scale = 60
+	default:
+		return nvml.VALUE_TYPE_UNSIGNED_INT, nil, nvml.ERROR_NOT_SUPPORTED
+	}
+
+	now := time.Now()
+	from := now.Add(-simulatedSampleBuffer)
+	if lastSeen := time.UnixMicro(int64(lastSeenTimestamp)); lastSeen.After(from) {
+		from = lastSeen
+	}
+	from = from.Truncate(simulatedSampleInterval)
+	var samples []nvml.Sample
+	for t := from.Add(simulatedSampleInterval); !t.After(now); t = t.Add(simulatedSampleInterval) {
+		load := d.load(t)
+		if t.Unix()%17 == 0 {
+			load = math.Min(1, load+0.4)
+		}
+		s := nvml.Sample{TimeStamp: uint64(t.UnixMicro())}
+		binary.LittleEndian.PutUint32(s.SampleValue[:], uint32(scale*load))
+		samples = append(samples, s)
+	}
+	if len(samples) == 0 {
+		return nvml.VALUE_TYPE_UNSIGNED_INT, nil, nvml.ERROR_NOT_FOUND
+	}
+	return nvml.VALUE_TYPE_UNSIGNED_INT, samples, nvml.SUCCESS
+}
+
+// GetPowerManagementLimit reports the default power limit of the model.
+func (d *simulatedDevice) GetPowerManagementLimit() (uint32, nvml.Return) {
+	return d.model.powerLimitW * 1000, nvml.SUCCESS
+}
+
+// GetTemperature follows the load between 35 and 80 degrees C.
+func (d *simulatedDevice) GetTemperature(sensor nvml.TemperatureSensors) (uint32, nvml.Return) {
+	if sensor != nvml.TEMPERATURE_GPU {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return uint32(35 + 45*d.load(time.Now())), nvml.SUCCESS
+}
+
+// GetTemperatureThreshold reports the limits of an SXM board, which has no
+// acoustic target temperature.
+func (d *simulatedDevice) GetTemperatureThreshold(threshold nvml.TemperatureThresholds) (uint32, nvml.Return) {
+	switch threshold {
+	case nvml.TEMPERATURE_THRESHOLD_SHUTDOWN:
+		return 92, nvml.SUCCESS
+	case nvml.TEMPERATURE_THRESHOLD_SLOWDOWN:
+		return 89, nvml.SUCCESS
+	case nvml.TEMPERATURE_THRESHOLD_MEM_MAX:
+		return 95, nvml.SUCCESS
+	case nvml.TEMPERATURE_THRESHOLD_GPU_MAX:
+		return 87, nvml.SUCCESS
+	}
+	return 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *simulatedDevice) GetPersistenceMode() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_ENABLED, nvml.SUCCESS
+}
+
+// GetTopologyCommonAncestor places pairs of GPUs behind a PCIe switch and
+// half of the GPUs on each of two NUMA nodes, like an HGX baseboard.
+func (d *simulatedDevice) GetTopologyCommonAncestor(peer nvml.Device) (nvml.GpuTopologyLevel, nvml.Return) {
+	p, ok := peer.(*simulatedDevice)
+	if !ok {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	switch {
+	case p.index == d.index:
+		return nvml.TOPOLOGY_INTERNAL, nvml.SUCCESS
+	case p.index/2 == d.index/2:
+		return nvml.TOPOLOGY_SINGLE, nvml.SUCCESS
+	case p.index/4 == d.index/4:
+		return nvml.TOPOLOGY_NODE, nvml.SUCCESS
+	default:
+		return nvml.TOPOLOGY_SYSTEM, nvml.SUCCESS
+	}
+}
+
+func (d *simulatedDevice) GetIndex() (int, nvml.Return) {
+	return d.index, nvml.SUCCESS
+}
+
+func (d *simulatedDevice) GetMinorNumber() (int, nvml.Return) {
+	return d.index, nvml.SUCCESS
+}
+
+// GetCurrentClocksEventReasons follows the load that drives the simulated
+// clock event durations; idle GPUs report gpu_idle.
+func (d *simulatedDevice) GetCurrentClocksEventReasons() (uint64, nvml.Return) {
+	load := d.load(time.Now())
+	var reasons uint64
+	if load > 0.7 {
+		reasons |= nvml.ClocksEventReasonSwPowerCap
+	}
+	if load > 0.95 {
+		reasons |= nvml.ClocksEventReasonSwThermalSlowdown
+	}
+	if load < 0.05 {
+		reasons |= nvml.ClocksEventReasonGpuIdle
+	}
+	return reasons, nvml.SUCCESS
+}
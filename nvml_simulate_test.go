@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestParseSimulateSpec(t *testing.T) {
+	tests := []struct {
+		spec      string
+		wantCount int
+		wantName  string
+		wantErr   bool
+	}{
+		{spec: "8xH100", wantCount: 8, wantName: "NVIDIA H100 80GB HBM3"},
+		{spec: "gb200", wantCount: 1, wantName: "NVIDIA GB200"},
+		{spec: "4xa100", wantCount: 4, wantName: "NVIDIA A100-SXM4-80GB"},
+		{spec: "0xH100", wantErr: true},
+		{spec: "manyxH100", wantErr: true},
+		{spec: "8xV100", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			assert := hammy.New(t)
+			count, model, err := parseSimulateSpec(tc.spec)
+			if tc.wantErr {
+				assert.Is(hammy.Error(err))
+				return
+			}
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.Number(count).EqualTo(tc.wantCount))
+			assert.Is(hammy.String(model.name).EqualTo(tc.wantName))
+		})
+	}
+}
+
+func TestSimulatedDevices(t *testing.T) {
+	assert := hammy.New(t)
+
+	lib, err := newSimulatedLibrary("2xH100")
+	assert.Is(hammy.NilError(err))
+
+	devices, _, err := New(newNvmlClient(lib), nil, nil)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(devices.Count()).EqualTo(2))
+
+	infos, err := loadGpuInfos(devices)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.String(infos[0].Architecture).EqualTo("hopper"))
+	assert.Is(hammy.True(infos[0].UUID != infos[1].UUID))
+	assert.Is(hammy.True(infos[0].PciBusId != infos[1].PciBusId))
+
+	// Only the last link of the first GPU flaps
+	flapping := devices.handles[0].(nvmlDevice).Device.(*simulatedDevice)
+	steady := devices.handles[1].(nvmlDevice).Device.(*simulatedDevice)
+	down := flapping.start.Add(simulatedFlapPeriod + time.Second)
+	assert.Is(hammy.True(flapping.linkUp(17, flapping.start)))
+	assert.Is(hammy.False(flapping.linkUp(17, down)))
+	assert.Is(hammy.True(flapping.linkUp(16, down)))
+	assert.Is(hammy.True(steady.linkUp(17, down)))
+	assert.Is(hammy.False(steady.linkUp(18, down)))
+
+	values := []nvml.FieldValue{{FieldId: nvmlFieldIdNvLinkSymbolErrors}, {FieldId: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP}, {FieldId: 1}}
+	assert.Is(hammy.True(steady.GetFieldValues(values) == nvml.SUCCESS))
+	assert.Is(hammy.Number(values[0].NvmlReturn).EqualTo(uint32(nvml.SUCCESS)))
+	assert.Is(hammy.Number(values[1].NvmlReturn).EqualTo(uint32(nvml.SUCCESS)))
+	assert.Is(hammy.Number(values[2].NvmlReturn).EqualTo(uint32(nvml.ERROR_NOT_SUPPORTED)))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	operationMode = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "operation_mode",
+			Help:      "Current GPU operation mode (1 for the active mode: all_on, compute, low_dp).",
+		},
+		[]string{"UUID", "pci_bus_id", "mode"},
+	)
+
+	operationModePending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "operation_mode_pending",
+			Help:      "GPU operation mode that takes effect after the next reboot (1 for the pending mode: all_on, compute, low_dp).",
+		},
+		[]string{"UUID", "pci_bus_id", "mode"},
+	)
+
+	displayActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "display_active",
+			Help:      "Whether a display is initialized on the GPU (1 = active, 0 = inactive).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	displayMode = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "display_mode",
+			Help:      "Whether a physical display is connected to the GPU (1 = connected, 0 = not connected).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	driverModel = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "driver_model",
+			Help:      "Current Windows driver model (1 for the active model: wddm, wdm, mcdm).",
+		},
+		[]string{"UUID", "pci_bus_id", "model"},
+	)
+
+	operationModes = []struct {
+		mode nvml.GpuOperationMode
+		name string
+	}{
+		{nvml.GOM_ALL_ON, "all_on"},
+		{nvml.GOM_COMPUTE, "compute"},
+		{nvml.GOM_LOW_DP, "low_dp"},
+	}
+
+	driverModels = []struct {
+		model nvml.DriverModel
+		name  string
+	}{
+		{nvml.DRIVER_WDDM, "wddm"},
+		{nvml.DRIVER_WDM, "wdm"},
+		{nvml.DRIVER_MCDM, "mcdm"},
+	}
+)
+
+// collectOperationModes collects GPU operation, display and driver modes so
+// that GPUs left in graphics-oriented modes can be detected.
+func collectOperationModes(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		// GOM is only supported on a few datacenter SKUs
+		current, pending, ret := device.GetGpuOperationMode()
+		if errors.Is(ret, nvml.SUCCESS) {
+			for _, m := range operationModes {
+				operationMode.WithLabelValues(uuid, pciBusId, m.name).Set(flagToGauge(current == m.mode))
+				operationModePending.WithLabelValues(uuid, pciBusId, m.name).Set(flagToGauge(pending == m.mode))
+			}
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get GPU operation mode", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		active, ret := device.GetDisplayActive()
+		if errors.Is(ret, nvml.SUCCESS) {
+			displayActive.WithLabelValues(uuid, pciBusId).Set(flagToGauge(active == nvml.FEATURE_ENABLED))
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get display active state", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		connected, ret := device.GetDisplayMode()
+		if errors.Is(ret, nvml.SUCCESS) {
+			displayMode.WithLabelValues(uuid, pciBusId).Set(flagToGauge(connected == nvml.FEATURE_ENABLED))
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get display mode", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		// Driver models only exist on Windows; Linux reports not supported
+		model, _, ret := device.GetDriverModel()
+		if errors.Is(ret, nvml.SUCCESS) {
+			for _, m := range driverModels {
+				driverModel.WithLabelValues(uuid, pciBusId, m.name).Set(flagToGauge(model == m.model))
+			}
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get driver model", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+	}
+}
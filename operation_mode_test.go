@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type operationModeDevice struct {
+	fakeDevice
+	gomRet nvml.Return
+}
+
+func (d *operationModeDevice) GetGpuOperationMode() (nvml.GpuOperationMode, nvml.GpuOperationMode, nvml.Return) {
+	return nvml.GOM_ALL_ON, nvml.GOM_COMPUTE, d.gomRet
+}
+
+func (d *operationModeDevice) GetDisplayActive() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_ENABLED, nvml.SUCCESS
+}
+
+func (d *operationModeDevice) GetDisplayMode() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.SUCCESS
+}
+
+func (d *operationModeDevice) GetDriverModel() (nvml.DriverModel, nvml.DriverModel, nvml.Return) {
+	return 0, 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+func TestCollectOperationModes(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		operationMode.Reset()
+		operationModePending.Reset()
+		displayActive.Reset()
+		displayMode.Reset()
+		driverModel.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	gom := &operationModeDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, gomRet: nvml.SUCCESS}
+	noGom := &operationModeDevice{fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0"}, gomRet: nvml.ERROR_NOT_SUPPORTED}
+
+	collectOperationModes([]Device{gom, noGom}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(operationMode.WithLabelValues("GPU-0", "0000:18:00.0", "all_on"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(operationMode.WithLabelValues("GPU-0", "0000:18:00.0", "compute"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(operationModePending.WithLabelValues("GPU-0", "0000:18:00.0", "compute"))).EqualTo(1))
+	// Unsupported GOM and driver model are omitted rather than zeroed
+	assert.Is(hammy.Number(testutil.CollectAndCount(operationMode)).EqualTo(3))
+	assert.Is(hammy.Number(testutil.CollectAndCount(driverModel)).EqualTo(0))
+
+	assert.Is(hammy.Number(testutil.ToFloat64(displayActive.WithLabelValues("GPU-1", "0000:28:00.0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(displayMode.WithLabelValues("GPU-1", "0000:28:00.0"))).EqualTo(0))
+}
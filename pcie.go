@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pcieAerErrors = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pcie_aer_errors_total",
+			Help:      "PCIe Advanced Error Reporting counters of the GPU's PCI device by severity (correctable, nonfatal, fatal) and error, read from sysfs.",
+		},
+		[]string{"UUID", "pci_bus_id", "severity", "error"},
+	)
+
+	pcieLinkSpeed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pcie_link_speed_gts",
+			Help:      "PCIe link speed in GT/s by type (current, max), read from sysfs.",
+		},
+		[]string{"UUID", "pci_bus_id", "type"},
+	)
+
+	pcieLinkWidth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pcie_link_width",
+			Help:      "PCIe link width in lanes by type (current, max), read from sysfs.",
+		},
+		[]string{"UUID", "pci_bus_id", "type"},
+	)
+
+	pcieLinkDowntrained = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pcie_link_downtrained",
+			Help:      "Whether the PCIe link trained to fewer lanes than it supports (1 = downtrained).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	pcieLinkDowntrainEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pcie_link_downtrain_events_total",
+			Help:      "Number of times the PCIe link was observed going from full width to downtrained.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	// pcieAerFiles maps the sysfs AER counter files to their severity label
+	pcieAerFiles = []struct {
+		file     string
+		severity string
+	}{
+		{"aer_dev_correctable", "correctable"},
+		{"aer_dev_nonfatal", "nonfatal"},
+		{"aer_dev_fatal", "fatal"},
+	}
+)
+
+// pcieCollector reads host-side PCIe state of each GPU from sysfs, which shows
+// link and AER problems that NVML does not report.
+type pcieCollector struct {
+	sysPath     string
+	downtrained map[string]bool
+}
+
+func newPCIeCollector(sysPath string) *pcieCollector {
+	return &pcieCollector{
+		sysPath:     sysPath,
+		downtrained: make(map[string]bool),
+	}
+}
+
+// collectPCIe collects AER counters and link state for every GPU that has a
+// PCI device under sysPath. GPUs without one (simulation, replay or non-Linux
+// hosts) are skipped.
+func (c *pcieCollector) collectPCIe(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		// NVML reports the bus ID in upper case, sysfs in lower case
+		dir := filepath.Join(c.sysPath, "bus", "pci", "devices", strings.ToLower(pciBusId))
+		if _, err := os.Stat(dir); err != nil {
+			logger.Debug("no sysfs PCI device for GPU", "uuid", uuid, "path", dir, "err", err)
+			continue
+		}
+
+		for _, aer := range pcieAerFiles {
+			counters, err := readAerCounters(filepath.Join(dir, aer.file))
+			if err != nil {
+				// Kernels built without AER have no counter files
+				if !errors.Is(err, fs.ErrNotExist) {
+					logger.Warn("failed to read PCIe AER counters", "uuid", uuid, "file", aer.file, "err", err)
+				}
+				continue
+			}
+			for name, value := range counters {
+				pcieAerErrors.WithLabelValues(uuid, pciBusId, aer.severity, name).Set(value)
+			}
+		}
+
+		c.collectLinkState(dir, uuid, pciBusId, logger)
+	}
+}
+
+// collectLinkState exports link speed and width and detects width downtraining.
+// Speed is not used for downtraining because GPUs lower the link speed when
+// idle to save power.
+func (c *pcieCollector) collectLinkState(dir, uuid, pciBusId string, logger *slog.Logger) {
+	for _, t := range []string{"current", "max"} {
+		if speed, err := readLinkSpeed(filepath.Join(dir, t+"_link_speed")); err == nil {
+			pcieLinkSpeed.WithLabelValues(uuid, pciBusId, t).Set(speed)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			logger.Debug("failed to read PCIe link speed", "uuid", uuid, "type", t, "err", err)
+		}
+	}
+
+	current, err := readSysfsUint(filepath.Join(dir, "current_link_width"))
+	if err != nil {
+		logger.Debug("failed to read PCIe link width", "uuid", uuid, "type", "current", "err", err)
+		return
+	}
+	maxWidth, err := readSysfsUint(filepath.Join(dir, "max_link_width"))
+	if err != nil {
+		logger.Debug("failed to read PCIe link width", "uuid", uuid, "type", "max", "err", err)
+		return
+	}
+	pcieLinkWidth.WithLabelValues(uuid, pciBusId, "current").Set(float64(current))
+	pcieLinkWidth.WithLabelValues(uuid, pciBusId, "max").Set(float64(maxWidth))
+
+	// A width of 0 means the link is down or the value is unknown
+	downtrained := current > 0 && current < maxWidth
+	pcieLinkDowntrained.WithLabelValues(uuid, pciBusId).Set(flagToGauge(downtrained))
+
+	events := pcieLinkDowntrainEvents.WithLabelValues(uuid, pciBusId)
+	if downtrained && !c.downtrained[uuid] {
+		events.Inc()
+		logger.Warn("PCIe link downtrained", "uuid", uuid, "pci_bus_id", pciBusId, "width", current, "max_width", maxWidth)
+	}
+	c.downtrained[uuid] = downtrained
+}
+
+// readAerCounters parses a sysfs AER counter file, which holds one
+// "<error> <count>" pair per line. The TOTAL_ERR_* lines are dropped since
+// they are the sum of the others.
+func readAerCounters(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counters := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "TOTAL_") {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		counters[fields[0]] = float64(value)
+	}
+	return counters, scanner.Err()
+}
+
+// readLinkSpeed parses a sysfs link speed such as "16.0 GT/s PCIe".
+func readLinkSpeed(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	speed, _, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	return strconv.ParseFloat(speed, 64)
+}
+
+// readSysfsUint parses a sysfs file holding a single unsigned integer.
+func readSysfsUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeSysfsPCIDevice(t *testing.T, sysPath, busId string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(sysPath, "bus", "pci", "devices", busId)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func resetPCIeMetrics() {
+	pcieAerErrors.Reset()
+	pcieLinkSpeed.Reset()
+	pcieLinkWidth.Reset()
+	pcieLinkDowntrained.Reset()
+	pcieLinkDowntrainEvents.Reset()
+}
+
+func TestCollectPCIe(t *testing.T) {
+	assert := hammy.New(t)
+	resetPCIeMetrics()
+	t.Cleanup(resetPCIeMetrics)
+
+	sysPath := t.TempDir()
+	files := map[string]string{
+		"aer_dev_correctable": "RxErr 3\nBadTLP 1\nTOTAL_ERR_COR 4\n",
+		"aer_dev_fatal":       "Undefined 0\nTOTAL_ERR_FATAL 0\n",
+		"current_link_speed":  "2.5 GT/s PCIe\n",
+		"max_link_speed":      "32.0 GT/s PCIe\n",
+		"current_link_width":  "8\n",
+		"max_link_width":      "16\n",
+	}
+	writeSysfsPCIDevice(t, sysPath, "0000:1b:00.0", files)
+
+	devices := []Device{
+		&fakeDevice{uuid: "GPU-0", pciBusId: "0000:1B:00.0"},
+		// No sysfs device, e.g. a simulated GPU
+		&fakeDevice{uuid: "GPU-1", pciBusId: "0000:2B:00.0"},
+	}
+	c := newPCIeCollector(sysPath)
+	c.collectPCIe(devices, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieAerErrors.WithLabelValues("GPU-0", "0000:1B:00.0", "correctable", "RxErr"))).EqualTo(3))
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieAerErrors.WithLabelValues("GPU-0", "0000:1B:00.0", "fatal", "Undefined"))).EqualTo(0))
+	// TOTAL_ lines are dropped and the missing nonfatal file is skipped
+	assert.Is(hammy.Number(testutil.CollectAndCount(pcieAerErrors)).EqualTo(3))
+
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkSpeed.WithLabelValues("GPU-0", "0000:1B:00.0", "current"))).EqualTo(2.5))
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkSpeed.WithLabelValues("GPU-0", "0000:1B:00.0", "max"))).EqualTo(32))
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkWidth.WithLabelValues("GPU-0", "0000:1B:00.0", "current"))).EqualTo(8))
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkDowntrained.WithLabelValues("GPU-0", "0000:1B:00.0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkDowntrainEvents.WithLabelValues("GPU-0", "0000:1B:00.0"))).EqualTo(1))
+
+	// Staying downtrained is not a new event, retraining to full width and
+	// dropping again is
+	c.collectPCIe(devices, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkDowntrainEvents.WithLabelValues("GPU-0", "0000:1B:00.0"))).EqualTo(1))
+
+	writeSysfsPCIDevice(t, sysPath, "0000:1b:00.0", map[string]string{"current_link_width": "16\n"})
+	c.collectPCIe(devices, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkDowntrained.WithLabelValues("GPU-0", "0000:1B:00.0"))).EqualTo(0))
+
+	writeSysfsPCIDevice(t, sysPath, "0000:1b:00.0", map[string]string{"current_link_width": "4\n"})
+	c.collectPCIe(devices, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(pcieLinkDowntrainEvents.WithLabelValues("GPU-0", "0000:1B:00.0"))).EqualTo(2))
+
+	assert.Is(hammy.Number(testutil.CollectAndCount(pcieLinkWidth)).EqualTo(2))
+}
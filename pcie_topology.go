@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// pcieTopologyAPIPath is the route of the PCIe tree.
+const pcieTopologyAPIPath = "GET /api/v1/topology/pcie"
+
+// pcieTopology is the PCIe tree of the GPUs and NICs of the host, pruned to
+// the bridges between them and their root complexes.
+type pcieTopology struct {
+	Roots []*pcieNode `json:"roots"`
+}
+
+// pcieNode is a root complex or a PCI device in the tree.
+type pcieNode struct {
+	// Bdf is the bus/device/function of a device, or the sysfs name of a
+	// root complex (pci0000:00)
+	Bdf string `json:"bdf"`
+	// Type is root_complex, root_port, switch_upstream, switch_downstream,
+	// bridge, gpu, nic or endpoint
+	Type     string `json:"type"`
+	VendorID string `json:"vendor_id,omitempty"`
+	DeviceID string `json:"device_id,omitempty"`
+	Class    string `json:"class,omitempty"`
+	// UUID is set on GPUs NVML reports
+	UUID string `json:"uuid,omitempty"`
+	// Interfaces are the network interfaces of a NIC
+	Interfaces []string    `json:"interfaces,omitempty"`
+	Children   []*pcieNode `json:"children,omitempty"`
+}
+
+var (
+	pcieBdf         = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+	pcieRootComplex = regexp.MustCompile(`^pci[0-9a-f]{4}:[0-9a-f]{2}$`)
+)
+
+// PCI class code prefixes of sysfs class files
+const (
+	pciClassBridge  = "0x0604"
+	pciClassNetwork = "0x02"
+)
+
+// readPCIeTopology walks sysPath for the path from the root complex to every
+// GPU in gpus, keyed by lower case bus ID, and every network controller.
+// Bridges are told apart by their place in the tree: a bridge on a root
+// complex is a root port, a bridge below a root port or a switch downstream
+// port is the upstream port of a switch, and the bridges below it are its
+// downstream ports.
+func readPCIeTopology(sysPath string, gpus map[string]string) (*pcieTopology, error) {
+	devicesDir := filepath.Join(sysPath, "bus", "pci", "devices")
+	entries, err := os.ReadDir(devicesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	topology := &pcieTopology{Roots: []*pcieNode{}}
+	nodes := make(map[string]*pcieNode)
+	for _, entry := range entries {
+		bdf := entry.Name()
+		class := readSysfsString(filepath.Join(devicesDir, bdf, "class"))
+		uuid, isGPU := gpus[bdf]
+		if !isGPU && !strings.HasPrefix(class, pciClassNetwork) {
+			continue
+		}
+
+		// The device entries link to the device under its root complex,
+		// e.g. devices/pci0000:00/0000:00:01.0/0000:01:00.0
+		path, err := filepath.EvalSymlinks(filepath.Join(devicesDir, bdf))
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(filepath.ToSlash(path), "/")
+		start := slices.IndexFunc(parts, pcieRootComplex.MatchString)
+		if start < 0 {
+			continue
+		}
+
+		parent, ok := nodes[parts[start]]
+		if !ok {
+			parent = &pcieNode{Bdf: parts[start], Type: "root_complex"}
+			nodes[parts[start]] = parent
+			topology.Roots = append(topology.Roots, parent)
+		}
+		for i := start + 1; i < len(parts); i++ {
+			part := parts[i]
+			if !pcieBdf.MatchString(part) {
+				continue
+			}
+			dir := filepath.FromSlash(strings.Join(parts[:i+1], "/"))
+			node, ok := nodes[part]
+			if !ok {
+				node = &pcieNode{
+					Bdf:      part,
+					Type:     "endpoint",
+					VendorID: readSysfsString(filepath.Join(dir, "vendor")),
+					DeviceID: readSysfsString(filepath.Join(dir, "device")),
+					Class:    readSysfsString(filepath.Join(dir, "class")),
+				}
+				nodes[part] = node
+				parent.Children = append(parent.Children, node)
+			}
+			parent = node
+		}
+
+		if isGPU {
+			parent.Type = "gpu"
+			parent.UUID = uuid
+		} else {
+			parent.Type = "nic"
+			if ifaces, err := os.ReadDir(filepath.Join(devicesDir, bdf, "net")); err == nil {
+				for _, iface := range ifaces {
+					parent.Interfaces = append(parent.Interfaces, iface.Name())
+				}
+			}
+		}
+	}
+
+	for _, root := range topology.Roots {
+		classifyPCIeBridges(root)
+	}
+	slices.SortFunc(topology.Roots, func(a, b *pcieNode) int { return strings.Compare(a.Bdf, b.Bdf) })
+	return topology, nil
+}
+
+// classifyPCIeBridges sets the type of the bridges below node and sorts the
+// children by BDF.
+func classifyPCIeBridges(node *pcieNode) {
+	slices.SortFunc(node.Children, func(a, b *pcieNode) int { return strings.Compare(a.Bdf, b.Bdf) })
+	for _, child := range node.Children {
+		if strings.HasPrefix(child.Class, pciClassBridge) {
+			switch node.Type {
+			case "root_complex":
+				child.Type = "root_port"
+			case "root_port", "switch_downstream":
+				child.Type = "switch_upstream"
+			case "switch_upstream":
+				child.Type = "switch_downstream"
+			default:
+				child.Type = "bridge"
+			}
+		}
+		classifyPCIeBridges(child)
+	}
+}
+
+// readSysfsString reads a sysfs attribute, empty when it cannot be read.
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// pcieTopologyHandler serves the PCIe tree, read from sysfs on every request
+// so that it reflects devices removed or rescanned since startup.
+func pcieTopologyHandler(devices []Device, sysPath string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gpus := make(map[string]string, len(devices))
+		for _, device := range devices {
+			uuid, ret := device.GetUUID()
+			if !errors.Is(ret, nvml.SUCCESS) {
+				continue
+			}
+			pciInfo, ret := device.GetPciInfo()
+			if !errors.Is(ret, nvml.SUCCESS) {
+				continue
+			}
+			// NVML reports the bus ID in upper case, sysfs in lower case
+			gpus[strings.ToLower(pciBusID(pciInfo))] = uuid
+		}
+
+		topology, err := readPCIeTopology(sysPath, gpus)
+		if err != nil {
+			logger.Warn("failed to read PCIe topology", "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(topology); err != nil {
+			logger.Debug("failed to write PCIe topology", "err", err)
+		}
+	}
+}
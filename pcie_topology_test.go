@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+// writeSysfsPCITree creates the devices under sysPath/devices, each given by
+// its path below the root complex, and links them from sysPath/bus/pci/devices.
+func writeSysfsPCITree(t *testing.T, sysPath string, devices map[string]map[string]string) {
+	t.Helper()
+	busDir := filepath.Join(sysPath, "bus", "pci", "devices")
+	if err := os.MkdirAll(busDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, files := range devices {
+		dir := filepath.Join(sysPath, "devices", filepath.FromSlash(path))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(dir, filepath.Join(busDir, filepath.Base(dir))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPCIeTopologyHandler(t *testing.T) {
+	assert := hammy.New(t)
+	sysPath := t.TempDir()
+	writeSysfsPCITree(t, sysPath, map[string]map[string]string{
+		"pci0000:00/0000:00:01.0":                                        {"class": "0x060400", "vendor": "0x8086"},
+		"pci0000:00/0000:00:01.0/0000:01:00.0":                           {"class": "0x060400", "vendor": "0x10b5", "device": "0xc040"},
+		"pci0000:00/0000:00:01.0/0000:01:00.0/0000:02:00.0":              {"class": "0x060400", "vendor": "0x10b5"},
+		"pci0000:00/0000:00:01.0/0000:01:00.0/0000:02:00.0/0000:03:00.0": {"class": "0x030200", "vendor": "0x10de"},
+		"pci0000:00/0000:00:01.0/0000:01:00.0/0000:02:01.0":              {"class": "0x060400", "vendor": "0x10b5"},
+		"pci0000:00/0000:00:01.0/0000:01:00.0/0000:02:01.0/0000:04:00.0": {"class": "0x020700", "vendor": "0x15b3", "net/ib0/type": "32"},
+		"pci0000:00/0000:00:14.0":                                        {"class": "0x0c0330"},
+		"pci0000:80/0000:80:01.0":                                        {"class": "0x060400"},
+		"pci0000:80/0000:80:01.0/0000:81:00.0":                           {"class": "0x030200", "vendor": "0x10de"},
+	})
+
+	devices := []Device{
+		&fakeDevice{uuid: "GPU-0", pciBusId: "0000:03:00.0"},
+		&fakeDevice{uuid: "GPU-1", pciBusId: "0000:81:00.0"},
+	}
+	rec := httptest.NewRecorder()
+	pcieTopologyHandler(devices, sysPath, discardLogger())(rec, httptest.NewRequest("GET", "/api/v1/topology/pcie", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(200))
+
+	var topology pcieTopology
+	assert.Is(hammy.NilError(json.Unmarshal(rec.Body.Bytes(), &topology)))
+	assert.Is(hammy.Number(len(topology.Roots)).EqualTo(2))
+
+	root := topology.Roots[0]
+	assert.Is(hammy.String(root.Bdf).EqualTo("pci0000:00"))
+	assert.Is(hammy.String(root.Type).EqualTo("root_complex"))
+	// The USB controller is not on the path to a GPU or NIC
+	assert.Is(hammy.Number(len(root.Children)).EqualTo(1))
+
+	rootPort := root.Children[0]
+	assert.Is(hammy.String(rootPort.Type).EqualTo("root_port"))
+	upstream := rootPort.Children[0]
+	assert.Is(hammy.String(upstream.Type).EqualTo("switch_upstream"))
+	assert.Is(hammy.String(upstream.Bdf).EqualTo("0000:01:00.0"))
+	assert.Is(hammy.String(upstream.VendorID).EqualTo("0x10b5"))
+	assert.Is(hammy.Number(len(upstream.Children)).EqualTo(2))
+
+	gpuPort, nicPort := upstream.Children[0], upstream.Children[1]
+	assert.Is(hammy.String(gpuPort.Type).EqualTo("switch_downstream"))
+	assert.Is(hammy.String(gpuPort.Children[0].Type).EqualTo("gpu"))
+	assert.Is(hammy.String(gpuPort.Children[0].UUID).EqualTo("GPU-0"))
+	assert.Is(hammy.String(nicPort.Type).EqualTo("switch_downstream"))
+	assert.Is(hammy.String(nicPort.Children[0].Type).EqualTo("nic"))
+	assert.Is(hammy.String(nicPort.Children[0].Interfaces[0]).EqualTo("ib0"))
+
+	gpu := topology.Roots[1].Children[0].Children[0]
+	assert.Is(hammy.String(gpu.UUID).EqualTo("GPU-1"))
+}
+
+func TestPCIeTopologyHandlerNoSysfs(t *testing.T) {
+	assert := hammy.New(t)
+	rec := httptest.NewRecorder()
+	pcieTopologyHandler(nil, t.TempDir(), discardLogger())(rec, httptest.NewRequest("GET", "/api/v1/topology/pcie", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(503))
+}
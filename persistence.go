@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// persistencedComm is the process name of nvidia-persistenced as truncated by
+// the kernel to 15 characters.
+const persistencedComm = "nvidia-persiste"
+
+var (
+	persistenceMode = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "persistence_mode",
+			Help:      "Whether the driver stays loaded on the GPU without clients (1 = persistence mode enabled, 0 = disabled).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	persistencedRunning = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "persistenced_running",
+			Help:      "Whether an nvidia-persistenced process runs on the node (1 = running, 0 = not running).",
+		},
+	)
+)
+
+// collectPersistence collects the persistence mode of every GPU and whether
+// nvidia-persistenced runs. Without persistence the driver tears the GPUs down
+// whenever the last client exits, so headless nodes see first-touch latency
+// and intermittent NVML initialization failures.
+func collectPersistence(devices []Device, procPath string, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		mode, ret := device.GetPersistenceMode()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get persistence mode", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+		persistenceMode.WithLabelValues(uuid, pciBusId).Set(flagToGauge(mode == nvml.FEATURE_ENABLED))
+	}
+
+	running, err := processRunning(procPath, persistencedComm)
+	if err != nil {
+		logger.Debug("failed to look for nvidia-persistenced", "proc_path", procPath, "err", err)
+		return
+	}
+	persistencedRunning.Set(flagToGauge(running))
+}
+
+// processRunning reports whether a process named comm has an entry in the proc
+// filesystem mounted at procPath.
+func processRunning(procPath, comm string) (bool, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.TrimLeft(entry.Name(), "0123456789") != "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procPath, entry.Name(), "comm"))
+		if err != nil {
+			// The process exited since the directory was read
+			continue
+		}
+		if strings.TrimSpace(string(data)) == comm {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type persistenceDevice struct {
+	fakeDevice
+	mode nvml.EnableState
+	ret  nvml.Return
+}
+
+func (d *persistenceDevice) GetPersistenceMode() (nvml.EnableState, nvml.Return) {
+	return d.mode, d.ret
+}
+
+// writeProc creates a fake proc filesystem with a process per comm.
+func writeProc(t *testing.T, comms ...string) string {
+	t.Helper()
+	procPath := t.TempDir()
+	for i, comm := range comms {
+		dir := filepath.Join(procPath, strconv.Itoa(100+i))
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Non-process entries are skipped
+	if err := os.Mkdir(filepath.Join(procPath, "sys"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return procPath
+}
+
+func TestCollectPersistence(t *testing.T) {
+	tests := []struct {
+		name        string
+		comms       []string
+		mode        nvml.EnableState
+		ret         nvml.Return
+		wantModes   int
+		wantMode    float64
+		wantRunning float64
+	}{
+		{"persistent", []string{"systemd", persistencedComm}, nvml.FEATURE_ENABLED, nvml.SUCCESS, 1, 1, 1},
+		{"daemon stopped", []string{"systemd"}, nvml.FEATURE_DISABLED, nvml.SUCCESS, 1, 0, 0},
+		{"not supported", []string{persistencedComm}, nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED, 0, 0, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			persistenceMode.Reset()
+			persistencedRunning.Set(-1)
+
+			device := &persistenceDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, mode: tc.mode, ret: tc.ret}
+			collectPersistence([]Device{device}, writeProc(t, tc.comms...), discardLogger())
+
+			assert.Is(hammy.Number(testutil.CollectAndCount(persistenceMode)).EqualTo(tc.wantModes))
+			if tc.wantModes > 0 {
+				assert.Is(hammy.Number(testutil.ToFloat64(persistenceMode.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(tc.wantMode))
+			}
+			assert.Is(hammy.Number(testutil.ToFloat64(persistencedRunning)).EqualTo(tc.wantRunning))
+		})
+	}
+}
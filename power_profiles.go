@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	powerProfile = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "power_profile",
+			Help:      "Workload power profiles supported by the GPU and whether each is requested or enforced (1 = set).",
+		},
+		[]string{"UUID", "pci_bus_id", "profile", "state"},
+	)
+
+	powerSmoothingEnabled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "power_smoothing_enabled",
+			Help:      "Whether board power smoothing is enabled (1 = enabled, 0 = disabled).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	powerSmoothingActiveProfile = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "power_smoothing_active_preset_profile",
+			Help:      "ID of the active power smoothing preset profile.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	powerSmoothingAppliedTmp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "power_smoothing_applied_tmp_watts",
+			Help:      "Applied total module power (TMP) bound of power smoothing in watts (ceiling, floor).",
+		},
+		[]string{"UUID", "pci_bus_id", "bound"},
+	)
+
+	powerSmoothingLifetimeRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "power_smoothing_hw_lifetime_remaining_percent",
+			Help:      "Remaining lifetime of the power smoothing hardware circuitry in percent.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	workloadPowerProfileNames = map[nvml.PowerProfileType]string{
+		nvml.POWER_PROFILE_MAX_P:         "max_p",
+		nvml.POWER_PROFILE_MAX_Q:         "max_q",
+		nvml.POWER_PROFILE_COMPUTE:       "compute",
+		nvml.POWER_PROFILE_MEMORY_BOUND:  "memory_bound",
+		nvml.POWER_PROFILE_NETWORK:       "network",
+		nvml.POWER_PROFILE_BALANCED:      "balanced",
+		nvml.POWER_PROFILE_LLM_INFERENCE: "llm_inference",
+		nvml.POWER_PROFILE_LLM_TRAINING:  "llm_training",
+		nvml.POWER_PROFILE_RBM:           "rbm",
+		nvml.POWER_PROFILE_DCPCIE:        "dcpcie",
+	}
+)
+
+// collectPowerProfiles collects the workload power profiles and power smoothing
+// state available on Blackwell and later GPUs. Older GPUs report not supported
+// and export nothing.
+func collectPowerProfiles(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		profiles, ret := device.WorkloadPowerProfileGetCurrentProfiles()
+		if errors.Is(ret, nvml.SUCCESS) {
+			for id := 0; id < 255; id++ {
+				if !maskBitSet(profiles.PerfProfilesMask, id) {
+					continue
+				}
+				name := workloadPowerProfileName(nvml.PowerProfileType(id))
+				powerProfile.WithLabelValues(uuid, pciBusId, name, "requested").Set(flagToGauge(maskBitSet(profiles.RequestedProfilesMask, id)))
+				powerProfile.WithLabelValues(uuid, pciBusId, name, "enforced").Set(flagToGauge(maskBitSet(profiles.EnforcedProfilesMask, id)))
+			}
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get workload power profiles", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		collectPowerSmoothing(device, uuid, pciBusId, logger)
+	}
+}
+
+// collectPowerSmoothing reads the power smoothing state through field values.
+func collectPowerSmoothing(device Device, uuid, pciBusId string, logger *slog.Logger) {
+	values := []nvml.FieldValue{
+		{FieldId: nvml.FI_PWR_SMOOTHING_ENABLED},
+		{FieldId: nvml.FI_PWR_SMOOTHING_ACTIVE_PRESET_PROFILE},
+		{FieldId: nvml.FI_PWR_SMOOTHING_APPLIED_TMP_CEIL},
+		{FieldId: nvml.FI_PWR_SMOOTHING_APPLIED_TMP_FLOOR},
+		{FieldId: nvml.FI_PWR_SMOOTHING_HW_CIRCUITRY_PERCENT_LIFETIME_REMAINING},
+	}
+	ret := device.GetFieldValues(values)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get power smoothing fields", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+		return
+	}
+
+	for _, fv := range values {
+		if nvml.Return(fv.NvmlReturn) != nvml.SUCCESS {
+			continue
+		}
+		v, err := fieldValueToFloat64(fv)
+		if err != nil {
+			logger.Debug("failed to decode power smoothing field", "uuid", uuid, "field_id", fv.FieldId, "err", err)
+			continue
+		}
+
+		switch fv.FieldId {
+		case nvml.FI_PWR_SMOOTHING_ENABLED:
+			powerSmoothingEnabled.WithLabelValues(uuid, pciBusId).Set(flagToGauge(v != 0))
+		case nvml.FI_PWR_SMOOTHING_ACTIVE_PRESET_PROFILE:
+			powerSmoothingActiveProfile.WithLabelValues(uuid, pciBusId).Set(v)
+		case nvml.FI_PWR_SMOOTHING_APPLIED_TMP_CEIL:
+			powerSmoothingAppliedTmp.WithLabelValues(uuid, pciBusId, "ceiling").Set(v)
+		case nvml.FI_PWR_SMOOTHING_APPLIED_TMP_FLOOR:
+			powerSmoothingAppliedTmp.WithLabelValues(uuid, pciBusId, "floor").Set(v)
+		case nvml.FI_PWR_SMOOTHING_HW_CIRCUITRY_PERCENT_LIFETIME_REMAINING:
+			powerSmoothingLifetimeRemaining.WithLabelValues(uuid, pciBusId).Set(v)
+		}
+	}
+}
+
+// workloadPowerProfileName returns the label value of profile.
+func workloadPowerProfileName(profile nvml.PowerProfileType) string {
+	if name, ok := workloadPowerProfileNames[profile]; ok {
+		return name
+	}
+	return fmt.Sprintf("profile_%d", profile)
+}
+
+// maskBitSet reports whether bit is set in an NVML 255-bit mask.
+func maskBitSet(mask nvml.Mask255, bit int) bool {
+	return mask.Mask[bit/32]&(1<<(bit%32)) != 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type powerProfileDevice struct {
+	fakeDevice
+	profiles    nvml.WorkloadPowerProfileCurrentProfiles
+	profilesRet nvml.Return
+}
+
+func (d *powerProfileDevice) WorkloadPowerProfileGetCurrentProfiles() (nvml.WorkloadPowerProfileCurrentProfiles, nvml.Return) {
+	return d.profiles, d.profilesRet
+}
+
+func setMaskBit(mask *nvml.Mask255, bit nvml.PowerProfileType) {
+	mask.Mask[bit/32] |= 1 << (bit % 32)
+}
+
+func TestCollectPowerProfiles(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		powerProfile.Reset()
+		powerSmoothingEnabled.Reset()
+		powerSmoothingActiveProfile.Reset()
+		powerSmoothingAppliedTmp.Reset()
+		powerSmoothingLifetimeRemaining.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	blackwell := &powerProfileDevice{
+		fakeDevice: fakeDevice{
+			uuid:     "GPU-0",
+			pciBusId: "0000:18:00.0",
+			fields: map[nvlinkFieldKey]uint64{
+				{fieldId: nvml.FI_PWR_SMOOTHING_ENABLED}:               1,
+				{fieldId: nvml.FI_PWR_SMOOTHING_ACTIVE_PRESET_PROFILE}: 2,
+				{fieldId: nvml.FI_PWR_SMOOTHING_APPLIED_TMP_FLOOR}:     600,
+			},
+		},
+		profilesRet: nvml.SUCCESS,
+	}
+	setMaskBit(&blackwell.profiles.PerfProfilesMask, nvml.POWER_PROFILE_MAX_P)
+	setMaskBit(&blackwell.profiles.PerfProfilesMask, nvml.POWER_PROFILE_LLM_TRAINING)
+	setMaskBit(&blackwell.profiles.RequestedProfilesMask, nvml.POWER_PROFILE_LLM_TRAINING)
+
+	hopper := &powerProfileDevice{
+		fakeDevice:  fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0"},
+		profilesRet: nvml.ERROR_NOT_SUPPORTED,
+	}
+
+	collectPowerProfiles([]Device{blackwell, hopper}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(powerProfile.WithLabelValues("GPU-0", "0000:18:00.0", "llm_training", "requested"))).EqualTo(1))
+	// Requested but not yet applied, e.g. while a conflicting profile holds
+	assert.Is(hammy.Number(testutil.ToFloat64(powerProfile.WithLabelValues("GPU-0", "0000:18:00.0", "llm_training", "enforced"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerProfile.WithLabelValues("GPU-0", "0000:18:00.0", "max_p", "requested"))).EqualTo(0))
+	// Only supported profiles are exported
+	assert.Is(hammy.Number(testutil.CollectAndCount(powerProfile)).EqualTo(4))
+
+	assert.Is(hammy.Number(testutil.ToFloat64(powerSmoothingEnabled.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerSmoothingActiveProfile.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerSmoothingAppliedTmp.WithLabelValues("GPU-0", "0000:18:00.0", "floor"))).EqualTo(600))
+	assert.Is(hammy.Number(testutil.CollectAndCount(powerSmoothingAppliedTmp)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(powerSmoothingEnabled)).EqualTo(1))
+}
+
+func TestWorkloadPowerProfileName(t *testing.T) {
+	assert := hammy.New(t)
+	assert.Is(hammy.String(workloadPowerProfileName(nvml.POWER_PROFILE_MAX_Q)).EqualTo("max_q"))
+	assert.Is(hammy.String(workloadPowerProfileName(42)).EqualTo("profile_42"))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var powerByScope = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "power_watts",
+		Help:      "Instantaneous power in watts by NVML power scope: gpu (the GPU board), module (the SXM or superchip module) and memory (the memory rail). Only scopes the GPU reports are exported.",
+	},
+	[]string{"UUID", "pci_bus_id", "scope"},
+)
+
+// powerScopes are the scopes of FI_DEV_POWER_INSTANT by their label.
+var powerScopes = []struct {
+	scope uint32
+	name  string
+}{
+	{nvml.POWER_SCOPE_GPU, "gpu"},
+	{nvml.POWER_SCOPE_MODULE, "module"},
+	{nvml.POWER_SCOPE_MEMORY, "memory"},
+}
+
+// collectPowerRails exports the power of every scope NVML reports separately.
+// The board total hides how the power is distributed, e.g. a memory rail
+// drawing too much or a module whose other consumers starve the GPU.
+func collectPowerRails(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		values := make([]nvml.FieldValue, len(powerScopes))
+		for i, s := range powerScopes {
+			values[i] = nvml.FieldValue{FieldId: nvml.FI_DEV_POWER_INSTANT, ScopeId: s.scope}
+		}
+		ret = device.GetFieldValues(values)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get power readings", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		for i, fv := range values {
+			if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
+				continue
+			}
+			mw, err := fieldValueToFloat64(fv)
+			if err != nil {
+				logger.Debug("failed to decode power reading", "uuid", uuid, "scope", powerScopes[i].name, "err", err)
+				continue
+			}
+			powerByScope.WithLabelValues(uuid, pciBusId, powerScopes[i].name).Set(mw / 1000)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectPowerRails(t *testing.T) {
+	assert := hammy.New(t)
+	powerByScope.Reset()
+	t.Cleanup(powerByScope.Reset)
+
+	sxm := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_POWER_INSTANT, link: nvml.POWER_SCOPE_GPU}:    650250,
+			{fieldId: nvml.FI_DEV_POWER_INSTANT, link: nvml.POWER_SCOPE_MODULE}: 1850500,
+			{fieldId: nvml.FI_DEV_POWER_INSTANT, link: nvml.POWER_SCOPE_MEMORY}: 80000,
+		},
+	}
+	// Older drivers only report the board
+	board := &fakeDevice{
+		uuid:     "GPU-1",
+		pciBusId: "0000:2A:00.0",
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_POWER_INSTANT, link: nvml.POWER_SCOPE_GPU}: 300000,
+		},
+	}
+	unsupported := &fakeDevice{uuid: "GPU-2", pciBusId: "0000:3A:00.0", fieldsRet: nvml.ERROR_NOT_SUPPORTED}
+
+	collectPowerRails([]Device{sxm, board, unsupported}, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(powerByScope.WithLabelValues("GPU-0", "0000:18:00.0", "gpu"))).EqualTo(650.25))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerByScope.WithLabelValues("GPU-0", "0000:18:00.0", "module"))).EqualTo(1850.5))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerByScope.WithLabelValues("GPU-0", "0000:18:00.0", "memory"))).EqualTo(80))
+	assert.Is(hammy.Number(testutil.ToFloat64(powerByScope.WithLabelValues("GPU-1", "0000:2A:00.0", "gpu"))).EqualTo(300))
+	assert.Is(hammy.Number(testutil.CollectAndCount(powerByScope)).EqualTo(4))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// probeAllowlist returns the metrics URLs /probe may scrape: those of
+// -probe-targets and -rack-targets. Scraping any URL a client passes would
+// turn the exporter into an open proxy into the network it runs in.
+func probeAllowlist(cfg *Config) (map[string]bool, error) {
+	allowed := make(map[string]bool)
+	for _, target := range append(slices.Clone(cfg.ProbeTargets), cfg.RackTargets...) {
+		targetURL, err := probeTargetURL(target)
+		if err != nil {
+			return nil, err
+		}
+		allowed[targetURL] = true
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("/probe requires -probe-targets or -rack-targets")
+	}
+	return allowed, nil
+}
+
+// probeHandler serves /probe?target=<host:port>. It scrapes the device metrics
+// of a remote nvgpu-exporter running as a lightweight agent on the target node
+// and re-exposes them alongside probe status metrics, so a single central
+// deployment can cover small sites without a DaemonSet per node. Only targets
+// in allowed are scraped.
+func probeHandler(client *http.Client, allowed map[string]bool, timeout time.Duration, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		targetURL, err := probeTargetURL(target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !allowed[targetURL] {
+			http.Error(w, fmt.Sprintf("target %q is not in -probe-targets or -rack-targets", target), http.StatusForbidden)
+			return
+		}
+
+		probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "probe_success",
+			Help:      "Whether the remote nvgpu agent was scraped successfully (1 = success, 0 = failure).",
+		})
+		probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "probe_duration_seconds",
+			Help:      "Time taken to scrape the remote nvgpu agent.",
+		})
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(probeSuccess, probeDuration)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		start := time.Now()
+		families, err := scrapeRemote(ctx, client, targetURL)
+		probeDuration.Set(time.Since(start).Seconds())
+		if err != nil {
+			logger.Warn("probe failed", "target", target, "err", err)
+		} else {
+			probeSuccess.Set(1)
+		}
+
+		remote := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return families, nil
+		})
+		promhttp.HandlerFor(prometheus.Gatherers{registry, remote}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// probeTargetURL builds the metrics URL for target, which may be a bare
+// host:port or a full http(s) URL.
+func probeTargetURL(target string) (string, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid target: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported target scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("target %q has no host", target)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/metrics"
+	}
+	return u.String(), nil
+}
+
+// maxRemoteMetricsSize bounds the exposition read from a remote exporter, so
+// that a misbehaving target cannot exhaust the memory of the central instance.
+// The metrics of a node with eight GPUs take a few MB.
+const maxRemoteMetricsSize = 32 << 20
+
+// scrapeRemote fetches and parses the text exposition served at targetURL.
+func scrapeRemote(ctx context.Context, client *http.Client, targetURL string) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteMetricsSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	if len(body) > maxRemoteMetricsSize {
+		return nil, fmt.Errorf("metrics exceed %d bytes", maxRemoteMetricsSize)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	parsed, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, mf := range parsed {
+		families = append(families, mf)
+	}
+	return families, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestProbeHandlerRelaysRemoteMetrics(t *testing.T) {
+	assert := hammy.New(t)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Is(hammy.String(r.URL.Path).EqualTo("/metrics"))
+		_, _ = io.WriteString(w, "# TYPE nvgpu_fabric_health_summary gauge\nnvgpu_fabric_health_summary{UUID=\"GPU-1\"} 1\n")
+	}))
+	defer remote.Close()
+
+	body := probe(t, strings.TrimPrefix(remote.URL, "http://"))
+
+	assert.Is(hammy.String(body).Contains(`nvgpu_fabric_health_summary{UUID="GPU-1"} 1`))
+	assert.Is(hammy.String(body).Contains("nvgpu_probe_success 1"))
+}
+
+func TestProbeHandlerReportsFailure(t *testing.T) {
+	assert := hammy.New(t)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer remote.Close()
+
+	body := probe(t, remote.URL)
+
+	assert.Is(hammy.String(body).Contains("nvgpu_probe_success 0"))
+}
+
+func TestProbeHandlerRejectsOversizedMetrics(t *testing.T) {
+	assert := hammy.New(t)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		line := "nvgpu_fabric_health_summary{UUID=\"GPU-1\"} 1\n"
+		for written := 0; written <= maxRemoteMetricsSize; written += len(line) {
+			_, _ = io.WriteString(w, line)
+		}
+	}))
+	defer remote.Close()
+
+	body := probe(t, remote.URL)
+
+	assert.Is(hammy.String(body).Contains("nvgpu_probe_success 0"))
+}
+
+func TestProbeHandlerRefusesUnlistedTargets(t *testing.T) {
+	assert := hammy.New(t)
+	scraped := false
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scraped = true
+	}))
+	defer remote.Close()
+
+	allowed, err := probeAllowlist(&Config{RackTargets: stringList{remote.URL}})
+	assert.Is(hammy.NilError(err))
+	handler := probeHandler(&http.Client{}, allowed, 5*time.Second, discardLogger())
+
+	// Another path on a listed host is not listed
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?target="+remote.URL+"/admin", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusForbidden))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?target=169.254.169.254:80", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusForbidden))
+	assert.Is(hammy.False(scraped))
+
+	// -rack-targets are allowed
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?target="+strings.TrimPrefix(remote.URL, "http://"), nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+	assert.Is(hammy.True(scraped))
+}
+
+func TestProbeAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    int
+		wantErr bool
+	}{
+		{name: "probe and rack targets", cfg: Config{ProbeTargets: stringList{"node-1:9400", "http://node-1:9400/metrics"}, RackTargets: stringList{"node-2:9400"}}, want: 2},
+		{name: "none", wantErr: true},
+		{name: "invalid", cfg: Config{ProbeTargets: stringList{"file:///etc/passwd"}}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			allowed, err := probeAllowlist(&tc.cfg)
+			if tc.wantErr {
+				assert.Is(hammy.Error(err))
+				return
+			}
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.Number(len(allowed)).EqualTo(tc.want))
+		})
+	}
+}
+
+func TestProbeTargetURL(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{target: "node-1:9400", want: "http://node-1:9400/metrics"},
+		{target: "https://node-1:9400", want: "https://node-1:9400/metrics"},
+		{target: "http://node-1:9400/custom", want: "http://node-1:9400/custom"},
+		{target: "file:///etc/passwd", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.target, func(t *testing.T) {
+			assert := hammy.New(t)
+			got, err := probeTargetURL(tc.target)
+			if tc.wantErr {
+				assert.Is(hammy.Error(err))
+				return
+			}
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.String(got).EqualTo(tc.want))
+		})
+	}
+}
+
+func probe(t *testing.T, target string) string {
+	t.Helper()
+	allowed, err := probeAllowlist(&Config{ProbeTargets: stringList{target}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := probeHandler(&http.Client{}, allowed, 5*time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	req := httptest.NewRequest(http.MethodGet, "/probe?target="+target, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gpuProcesses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "processes",
+			Help:      "Number of processes with a context on the GPU by type (compute, graphics).",
+		},
+		[]string{"UUID", "pci_bus_id", "type"},
+	)
+
+	ghostProcesses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ghost_processes",
+			Help:      "Number of GPU processes whose PID no longer exists on the host.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	ghostProcessMemory = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ghost_process_memory_bytes",
+			Help:      "GPU memory (bytes) held by processes whose PID no longer exists on the host.",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+)
+
+// collectProcesses counts running processes per GPU and detects memory held by
+// PIDs that are no longer present under procPath (leaked contexts).
+func collectProcesses(devices []Device, procPath string, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		compute, ret := device.GetComputeRunningProcesses()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get compute processes", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		graphics, ret := device.GetGraphicsRunningProcesses()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get graphics processes", "uuid", uuid, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		gpuProcesses.WithLabelValues(uuid, pciBusId, "compute").Set(float64(len(compute)))
+		gpuProcesses.WithLabelValues(uuid, pciBusId, "graphics").Set(float64(len(graphics)))
+
+		// A process with both compute and graphics contexts appears in both lists
+		seen := make(map[uint32]bool, len(compute)+len(graphics))
+		var ghosts int
+		var ghostMemory uint64
+		for _, p := range append(compute, graphics...) {
+			if seen[p.Pid] {
+				continue
+			}
+			seen[p.Pid] = true
+
+			if pidExists(procPath, p.Pid) {
+				continue
+			}
+
+			ghosts++
+			if p.UsedGpuMemory != math.MaxUint64 {
+				ghostMemory += p.UsedGpuMemory
+			}
+			logger.Debug("GPU process without host PID", "uuid", uuid, "pid", p.Pid, "used_gpu_memory", p.UsedGpuMemory)
+		}
+
+		ghostProcesses.WithLabelValues(uuid, pciBusId).Set(float64(ghosts))
+		ghostProcessMemory.WithLabelValues(uuid, pciBusId).Set(float64(ghostMemory))
+	}
+}
+
+// pidExists reports whether pid has an entry in the proc filesystem mounted at procPath
+func pidExists(procPath string, pid uint32) bool {
+	_, err := os.Stat(filepath.Join(procPath, fmt.Sprintf("%d", pid)))
+	return err == nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// processesAPIPattern is the route of the on-demand process list.
+const processesAPIPattern = "GET /api/v1/gpus/{uuid}/processes"
+
+// gpuProcessList is the response of the process list endpoint.
+type gpuProcessList struct {
+	UUID      string       `json:"uuid"`
+	PciBusId  string       `json:"pci_bus_id"`
+	Processes []gpuProcess `json:"processes"`
+}
+
+// gpuProcess is a process holding a context on a GPU.
+type gpuProcess struct {
+	Pid uint32 `json:"pid"`
+	// Name is the command name from the proc filesystem, empty for ghosts
+	Name string `json:"name,omitempty"`
+	// Types lists the contexts the process holds (compute, graphics)
+	Types []string `json:"types"`
+	// UsedGpuMemoryBytes is absent when NVML cannot attribute memory, e.g. under MIG or vGPU
+	UsedGpuMemoryBytes *uint64 `json:"used_gpu_memory_bytes,omitempty"`
+	// Ghost is set when the PID no longer exists on the host
+	Ghost bool `json:"ghost"`
+}
+
+// processesHandler serves the processes of a single GPU, queried from NVML on
+// every request rather than on the collection interval, so that runbooks get
+// fresh data during an incident.
+func processesHandler(devices []Device, procPath string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := r.PathValue("uuid")
+		device := findDevice(devices, uuid)
+		if device == nil {
+			http.Error(w, fmt.Sprintf("unknown GPU %q", uuid), http.StatusNotFound)
+			return
+		}
+
+		list, err := listProcesses(device, uuid, procPath)
+		if err != nil {
+			logger.Warn("failed to list GPU processes", "uuid", uuid, "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			logger.Debug("failed to write process list", "uuid", uuid, "err", err)
+		}
+	}
+}
+
+// findDevice returns the device with uuid, or nil.
+func findDevice(devices []Device, uuid string) Device {
+	for _, device := range devices {
+		if id, ret := device.GetUUID(); errors.Is(ret, nvml.SUCCESS) && id == uuid {
+			return device
+		}
+	}
+	return nil
+}
+
+// listProcesses merges the compute and graphics processes of device.
+func listProcesses(device Device, uuid, procPath string) (*gpuProcessList, error) {
+	pciInfo, ret := device.GetPciInfo()
+	if !errors.Is(ret, nvml.SUCCESS) {
+		return nil, fmt.Errorf("failed to get PCI info: %s", nvml.ErrorString(ret))
+	}
+
+	compute, ret := device.GetComputeRunningProcesses()
+	if !errors.Is(ret, nvml.SUCCESS) {
+		return nil, fmt.Errorf("failed to get compute processes: %s", nvml.ErrorString(ret))
+	}
+
+	graphics, ret := device.GetGraphicsRunningProcesses()
+	if !errors.Is(ret, nvml.SUCCESS) && !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+		return nil, fmt.Errorf("failed to get graphics processes: %s", nvml.ErrorString(ret))
+	}
+
+	list := &gpuProcessList{
+		UUID:      uuid,
+		PciBusId:  pciBusID(pciInfo),
+		Processes: []gpuProcess{},
+	}
+	byPid := make(map[uint32]int, len(compute)+len(graphics))
+	add := func(processes []nvml.ProcessInfo, contextType string) {
+		for _, p := range processes {
+			i, ok := byPid[p.Pid]
+			if !ok {
+				i = len(list.Processes)
+				byPid[p.Pid] = i
+				list.Processes = append(list.Processes, gpuProcess{
+					Pid:   p.Pid,
+					Name:  processName(procPath, p.Pid),
+					Ghost: !pidExists(procPath, p.Pid),
+				})
+			}
+
+			process := &list.Processes[i]
+			process.Types = append(process.Types, contextType)
+			if p.UsedGpuMemory != math.MaxUint64 {
+				used := p.UsedGpuMemory
+				if process.UsedGpuMemoryBytes != nil {
+					used = max(used, *process.UsedGpuMemoryBytes)
+				}
+				process.UsedGpuMemoryBytes = &used
+			}
+		}
+	}
+	add(compute, "compute")
+	add(graphics, "graphics")
+
+	return list, nil
+}
+
+// processName returns the command name of pid, or "" if it cannot be read.
+func processName(procPath string, pid uint32) string {
+	comm, err := os.ReadFile(filepath.Join(procPath, fmt.Sprintf("%d", pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return sanitizeLabelValue(string(comm))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+// processDevice is a GPU running a fixed set of processes.
+type processDevice struct {
+	fakeDevice
+	compute, graphics []nvml.ProcessInfo
+}
+
+func (d *processDevice) GetComputeRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	return d.compute, nvml.SUCCESS
+}
+
+func (d *processDevice) GetGraphicsRunningProcesses() ([]nvml.ProcessInfo, nvml.Return) {
+	return d.graphics, nvml.SUCCESS
+}
+
+func TestProcessesHandler(t *testing.T) {
+	assert := hammy.New(t)
+	procPath := t.TempDir()
+	assert.Is(hammy.NilError(os.Mkdir(filepath.Join(procPath, "100"), 0o755)))
+	assert.Is(hammy.NilError(os.WriteFile(filepath.Join(procPath, "100", "comm"), []byte("python3\n"), 0o644)))
+
+	device := &processDevice{
+		fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+		compute: []nvml.ProcessInfo{
+			{Pid: 100, UsedGpuMemory: 4 << 30},
+			{Pid: 200, UsedGpuMemory: math.MaxUint64},
+		},
+		graphics: []nvml.ProcessInfo{{Pid: 100, UsedGpuMemory: 4 << 30}},
+	}
+	mux := http.NewServeMux()
+	mux.Handle(processesAPIPattern, processesHandler([]Device{device}, procPath, discardLogger()))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/gpus/GPU-0/processes", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+
+	var list gpuProcessList
+	assert.Is(hammy.NilError(json.Unmarshal(rec.Body.Bytes(), &list)))
+	assert.Is(hammy.String(list.PciBusId).EqualTo("0000:18:00.0"))
+	assert.Is(hammy.Number(len(list.Processes)).EqualTo(2))
+
+	python := list.Processes[0]
+	assert.Is(hammy.String(python.Name).EqualTo("python3"))
+	assert.Is(hammy.Number(len(python.Types)).EqualTo(2))
+	assert.Is(hammy.Number(*python.UsedGpuMemoryBytes).EqualTo(4 << 30))
+	assert.Is(hammy.False(python.Ghost))
+
+	// PID 200 is gone from the host and NVML cannot attribute its memory
+	ghost := list.Processes[1]
+	assert.Is(hammy.True(ghost.Ghost))
+	assert.Is(hammy.True(ghost.UsedGpuMemoryBytes == nil))
+}
+
+func TestProcessesHandlerUnknownGPU(t *testing.T) {
+	assert := hammy.New(t)
+	mux := http.NewServeMux()
+	mux.Handle(processesAPIPattern, processesHandler([]Device{&processDevice{fakeDevice: fakeDevice{uuid: "GPU-0"}}}, t.TempDir(), discardLogger()))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/gpus/GPU-1/processes", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusNotFound))
+}
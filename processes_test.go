@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPidExists(t *testing.T) {
+	assert := hammy.New(t)
+	procPath := t.TempDir()
+	err := os.Mkdir(filepath.Join(procPath, "1234"), 0o755)
+	assert.Is(hammy.NilError(err))
+
+	assert.Is(hammy.True(pidExists(procPath, 1234)))
+	assert.Is(hammy.False(pidExists(procPath, 4321)))
+}
+
+func TestCollectProcesses(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		gpuProcesses.Reset()
+		ghostProcesses.Reset()
+		ghostProcessMemory.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	procPath := t.TempDir()
+	assert.Is(hammy.NilError(os.Mkdir(filepath.Join(procPath, "100"), 0o755)))
+
+	device := &processDevice{
+		fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+		compute: []nvml.ProcessInfo{
+			{Pid: 100, UsedGpuMemory: 4 << 30},
+			{Pid: 200, UsedGpuMemory: 2 << 30},
+			{Pid: 300, UsedGpuMemory: math.MaxUint64},
+		},
+		// PID 200 has a graphics context too and counts once as a ghost
+		graphics: []nvml.ProcessInfo{{Pid: 200, UsedGpuMemory: 2 << 30}},
+	}
+	collectProcesses([]Device{device}, procPath, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuProcesses.WithLabelValues("GPU-0", "0000:18:00.0", "compute"))).EqualTo(3))
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuProcesses.WithLabelValues("GPU-0", "0000:18:00.0", "graphics"))).EqualTo(1))
+	// PIDs 200 and 300 are missing from the proc filesystem
+	assert.Is(hammy.Number(testutil.ToFloat64(ghostProcesses.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(2))
+	// Memory NVML cannot attribute is left out
+	assert.Is(hammy.Number(testutil.ToFloat64(ghostProcessMemory.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(2 << 30))
+	assert.Is(hammy.Number(testutil.CollectAndCount(ghostProcesses)).EqualTo(1))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// rackHandler serves /rack. On every request it scrapes the exporters of all
+// nodes in a rack concurrently and exports the fabric health that no single
+// node can see on its own: NVLinks down per switch plane, NVLink cliques
+// missing GPUs and GPUs that failed fabric registration per cause. cliqueSize
+// is the number of GPUs a complete clique spans (72 on NVL72).
+func rackHandler(client *http.Client, targets []string, timeout time.Duration, cliqueSize int, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetUp := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rack_target_up",
+			Help:      "Whether the exporter of a rack node was scraped successfully (1 = success, 0 = failure).",
+		}, []string{"target"})
+		gpus := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rack_gpus",
+			Help:      "Number of GPUs reported by the rack nodes that were scraped successfully.",
+		})
+		nvlinks := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rack_nvlinks",
+			Help:      "Number of NVLinks in the rack per switch plane (link index) and state (up, down).",
+		}, []string{"plane", "state"})
+		cliqueGpus := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rack_clique_gpus",
+			Help:      "Number of GPUs in the rack that joined each NVLink clique.",
+		}, []string{"cluster_uuid", "clique_id"})
+		cliquesIncomplete := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rack_cliques_incomplete",
+			Help:      "Number of NVLink cliques with fewer GPUs than -rack-clique-size.",
+		})
+		incorrectConfig := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rack_fabric_incorrect_configuration",
+			Help:      "Number of GPUs in the rack that failed fabric registration because of an incorrect configuration, per cause.",
+		}, []string{"cause"})
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(targetUp, gpus, nvlinks, cliqueGpus, cliquesIncomplete, incorrectConfig)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		results := scrapeTargets(ctx, client, targets)
+
+		var nodes []map[string]*dto.MetricFamily
+		for i, target := range targets {
+			if results[i].err != nil {
+				logger.Warn("rack scrape failed", "target", target, "err", results[i].err)
+				targetUp.WithLabelValues(target).Set(0)
+				continue
+			}
+			targetUp.WithLabelValues(target).Set(1)
+			nodes = append(nodes, results[i].families)
+		}
+
+		cliques := make(map[[2]string]int)
+		for _, c := range incorrectConfigCauses {
+			incorrectConfig.WithLabelValues(c.cause)
+		}
+		for _, families := range nodes {
+			if mf := families["nvgpu_gpu_info"]; mf != nil {
+				gpus.Add(float64(len(mf.GetMetric())))
+			}
+			if mf := families["nvgpu_nvlink_up"]; mf != nil {
+				for _, m := range mf.GetMetric() {
+					state := "down"
+					if m.GetGauge().GetValue() == 1 {
+						state = "up"
+					}
+					nvlinks.WithLabelValues(metricLabel(m, "link"), state).Inc()
+				}
+			}
+			if mf := families["nvgpu_fabric_clique_member"]; mf != nil {
+				for _, m := range mf.GetMetric() {
+					cliques[[2]string{metricLabel(m, "cluster_uuid"), metricLabel(m, "clique_id")}]++
+				}
+			}
+			if mf := families["nvgpu_fabric_incorrect_configuration_cause"]; mf != nil {
+				for _, m := range mf.GetMetric() {
+					incorrectConfig.WithLabelValues(metricLabel(m, "cause")).Add(m.GetGauge().GetValue())
+				}
+			}
+		}
+
+		for clique, count := range cliques {
+			cliqueGpus.WithLabelValues(clique[0], clique[1]).Set(float64(count))
+			if count < cliqueSize {
+				cliquesIncomplete.Inc()
+			}
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// rackScrape is the outcome of scraping one rack node.
+type rackScrape struct {
+	families map[string]*dto.MetricFamily
+	err      error
+}
+
+// scrapeTargets scrapes every target concurrently, returning the results in
+// the order of targets.
+func scrapeTargets(ctx context.Context, client *http.Client, targets []string) []rackScrape {
+	results := make([]rackScrape, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			targetURL, err := probeTargetURL(target)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			families, err := scrapeRemote(ctx, client, targetURL)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+
+			results[i].families = make(map[string]*dto.MetricFamily, len(families))
+			for _, mf := range families {
+				results[i].families[mf.GetName()] = mf
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// metricLabel returns the value of label name of m, or "" if it is not set.
+func metricLabel(m *dto.Metric, name string) string {
+	for _, pair := range m.GetLabel() {
+		if pair.GetName() == name {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestRackHandler(t *testing.T) {
+	assert := hammy.New(t)
+	node := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, body)
+		}))
+	}
+	tray1 := node(`# TYPE nvgpu_gpu_info gauge
+nvgpu_gpu_info{UUID="GPU-0"} 1
+nvgpu_gpu_info{UUID="GPU-1"} 1
+# TYPE nvgpu_nvlink_up gauge
+nvgpu_nvlink_up{UUID="GPU-0",link="0"} 1
+nvgpu_nvlink_up{UUID="GPU-0",link="1"} 0
+nvgpu_nvlink_up{UUID="GPU-1",link="0"} 1
+nvgpu_nvlink_up{UUID="GPU-1",link="1"} 1
+# TYPE nvgpu_fabric_clique_member gauge
+nvgpu_fabric_clique_member{UUID="GPU-0",clique_id="1",cluster_uuid="c"} 1
+nvgpu_fabric_clique_member{UUID="GPU-1",clique_id="1",cluster_uuid="c"} 1
+# TYPE nvgpu_fabric_incorrect_configuration_cause gauge
+nvgpu_fabric_incorrect_configuration_cause{UUID="GPU-0",cause="no_partition"} 0
+nvgpu_fabric_incorrect_configuration_cause{UUID="GPU-0",cause="insufficient_nvlinks"} 1
+`)
+	defer tray1.Close()
+	tray2 := node(`# TYPE nvgpu_gpu_info gauge
+nvgpu_gpu_info{UUID="GPU-2"} 1
+# TYPE nvgpu_nvlink_up gauge
+nvgpu_nvlink_up{UUID="GPU-2",link="1"} 0
+# TYPE nvgpu_fabric_clique_member gauge
+nvgpu_fabric_clique_member{UUID="GPU-2",clique_id="2",cluster_uuid="c"} 0
+# TYPE nvgpu_fabric_incorrect_configuration_cause gauge
+nvgpu_fabric_incorrect_configuration_cause{UUID="GPU-2",cause="insufficient_nvlinks"} 1
+`)
+	defer tray2.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	targets := []string{strings.TrimPrefix(tray1.URL, "http://"), tray2.URL, down.URL}
+	handler := rackHandler(&http.Client{}, targets, 5*time.Second, 2, discardLogger())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rack", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`nvgpu_rack_target_up{target="` + targets[0] + `"} 1`,
+		`nvgpu_rack_target_up{target="` + down.URL + `"} 0`,
+		"nvgpu_rack_gpus 3",
+		`nvgpu_rack_nvlinks{plane="0",state="up"} 2`,
+		`nvgpu_rack_nvlinks{plane="1",state="down"} 2`,
+		`nvgpu_rack_nvlinks{plane="1",state="up"} 1`,
+		`nvgpu_rack_clique_gpus{clique_id="1",cluster_uuid="c"} 2`,
+		`nvgpu_rack_clique_gpus{clique_id="2",cluster_uuid="c"} 1`,
+		// Clique 2 has one of the two expected GPUs
+		"nvgpu_rack_cliques_incomplete 1",
+		`nvgpu_rack_fabric_incorrect_configuration{cause="insufficient_nvlinks"} 2`,
+		`nvgpu_rack_fabric_incorrect_configuration{cause="incorrect_sysguid"} 0`,
+	} {
+		assert.Is(hammy.String(body).Contains(want))
+	}
+}
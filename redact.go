@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Asset label redaction modes accepted by -redact-asset-labels.
+const (
+	redactNone = ""
+	redactHash = "hash"
+	redactOmit = "omit"
+)
+
+// redactMode selects how asset identifiers are exported, usable as a flag.Value.
+type redactMode string
+
+func (m *redactMode) String() string {
+	return string(*m)
+}
+
+func (m *redactMode) Set(value string) error {
+	switch value {
+	case redactNone, redactHash, redactOmit:
+		*m = redactMode(value)
+		return nil
+	default:
+		return fmt.Errorf("invalid redaction mode %q: must be %q or %q", value, redactHash, redactOmit)
+	}
+}
+
+// redactGpuInfos rewrites the asset identifiers (board serial, chassis serial
+// and IB GUID) of infos according to mode. The UUID is kept so that series can
+// still be joined. Hashing is deterministic, so the same asset maps to the same
+// value across hosts and restarts without revealing the identifier itself.
+func redactGpuInfos(infos []*GpuInfo, mode redactMode) {
+	if mode == redactNone {
+		return
+	}
+
+	for _, info := range infos {
+		for _, field := range []*string{&info.Serial, &info.ChassisSerialNumber, &info.IbGuid} {
+			*field = redactValue(*field, mode)
+		}
+	}
+}
+
+// redactValue returns the redacted form of value. Placeholders such as
+// "unknown" carry no asset information and are left untouched.
+func redactValue(value string, mode redactMode) string {
+	switch value {
+	case "", "unknown", "unsupported":
+		return value
+	}
+
+	switch mode {
+	case redactHash:
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	case redactOmit:
+		return ""
+	default:
+		return value
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestRedactModeSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"", false},
+		{"hash", false},
+		{"omit", false},
+		{"drop", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			assert := hammy.New(t)
+			var mode redactMode
+			err := mode.Set(tc.value)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+		})
+	}
+}
+
+func TestRedactGpuInfos(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        redactMode
+		wantSerial  string
+		wantChassis string
+		wantIbGuid  string
+	}{
+		{"none", redactNone, "1650123456789", "1820425190259", "unknown"},
+		{"hash", redactHash, "sha256:46f641867cc98870", "sha256:66ff559ebd9b1f1d", "unknown"},
+		{"omit", redactOmit, "", "", "unknown"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			info := &GpuInfo{
+				UUID:                "GPU-1",
+				Serial:              "1650123456789",
+				ChassisSerialNumber: "1820425190259",
+				IbGuid:              "unknown",
+			}
+
+			redactGpuInfos([]*GpuInfo{info}, tc.mode)
+
+			assert.Is(hammy.String(info.UUID).EqualTo("GPU-1"))
+			assert.Is(hammy.String(info.Serial).EqualTo(tc.wantSerial))
+			assert.Is(hammy.String(info.ChassisSerialNumber).EqualTo(tc.wantChassis))
+			assert.Is(hammy.String(info.IbGuid).EqualTo(tc.wantIbGuid))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	retiredPagesPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "retired_pages_pending",
+			Help:      "Whether memory pages are pending retirement (blacklisting) until the next GPU reset or reboot (1 = pending).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	retiredPages = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "retired_pages",
+			Help:      "Number of retired memory pages by cause (multiple_sbe, dbe).",
+		},
+		[]string{"UUID", "pci_bus_id", "cause"},
+	)
+
+	retiredPagesLastTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "retired_pages_last_timestamp_seconds",
+			Help:      "Unix time of the most recent page retirement by cause (multiple_sbe, dbe).",
+		},
+		[]string{"UUID", "pci_bus_id", "cause"},
+	)
+
+	remappedRows = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "remapped_rows",
+			Help:      "Number of memory rows remapped by cause (correctable, uncorrectable).",
+		},
+		[]string{"UUID", "pci_bus_id", "cause"},
+	)
+
+	rowRemapPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "row_remap_pending",
+			Help:      "Whether row remappings are pending until the next GPU reset (1 = pending).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	rowRemapFailed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "row_remap_failed",
+			Help:      "Whether a row remapping has failed, which makes the GPU eligible for RMA (1 = failed).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+
+	pageRetirementCauses = []struct {
+		cause nvml.PageRetirementCause
+		name  string
+	}{
+		{nvml.PAGE_RETIREMENT_CAUSE_MULTIPLE_SINGLE_BIT_ECC_ERRORS, "multiple_sbe"},
+		{nvml.PAGE_RETIREMENT_CAUSE_DOUBLE_BIT_ECC_ERROR, "dbe"},
+	}
+)
+
+// collectRetiredPages reports memory page retirements (pre-Ampere) and row
+// remappings (Ampere and newer), including whether they are waiting for a GPU
+// reset to take effect. Row remapping readings are handed to remaps, which
+// reports the remapping behind the next memory Xid.
+func collectRetiredPages(devices []Device, health *gpuHealthTracker, remaps *xidRowRemapTracker, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		pending := false
+		supported := false
+
+		pagesPending, ret := device.GetRetiredPagesPendingStatus()
+		if errors.Is(ret, nvml.SUCCESS) {
+			supported = true
+			pending = pagesPending == nvml.FEATURE_ENABLED
+			retiredPagesPending.WithLabelValues(uuid, pciBusId).Set(flagToGauge(pending))
+			collectRetiredPageCauses(device, uuid, pciBusId, logger)
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get retired pages pending status", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		remap, ret := readRowRemap(device)
+		if errors.Is(ret, nvml.SUCCESS) {
+			supported = true
+			pending = pending || remap.pending
+			remappedRows.WithLabelValues(uuid, pciBusId, "correctable").Set(float64(remap.correctable))
+			remappedRows.WithLabelValues(uuid, pciBusId, "uncorrectable").Set(float64(remap.uncorrectable))
+			rowRemapPending.WithLabelValues(uuid, pciBusId).Set(flagToGauge(remap.pending))
+			rowRemapFailed.WithLabelValues(uuid, pciBusId).Set(flagToGauge(remap.failed))
+			if remap.histogram != nil {
+				for i, banks := range histogramBuckets(*remap.histogram) {
+					rowRemapBankAvailability.WithLabelValues(uuid, pciBusId, bankAvailabilities[i]).Set(float64(banks))
+				}
+			}
+			remaps.observe(uuid, remap)
+		} else if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get remapped rows", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+
+		if supported {
+			health.reportRetirementPending(uuid, pending)
+		}
+	}
+}
+
+// collectRetiredPageCauses exports the number of retired pages and the time of
+// the latest retirement per cause.
+func collectRetiredPageCauses(device Device, uuid, pciBusId string, logger *slog.Logger) {
+	for _, c := range pageRetirementCauses {
+		addresses, timestamps, ret := device.GetRetiredPages_v2(c.cause)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get retired pages", "uuid", uuid, "cause", c.name, "error", nvml.ErrorString(ret))
+			}
+			continue
+		}
+
+		retiredPages.WithLabelValues(uuid, pciBusId, c.name).Set(float64(len(addresses)))
+		if len(timestamps) > 0 {
+			retiredPagesLastTimestamp.WithLabelValues(uuid, pciBusId, c.name).Set(float64(slices.Max(timestamps)))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// retiredPagesDevice is a pre-Ampere GPU with retired pages and no row
+// remapping support.
+type retiredPagesDevice struct {
+	fakeDevice
+	pending    nvml.EnableState
+	timestamps map[nvml.PageRetirementCause][]uint64
+}
+
+func (d *retiredPagesDevice) GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return) {
+	return d.pending, nvml.SUCCESS
+}
+
+func (d *retiredPagesDevice) GetRetiredPages_v2(cause nvml.PageRetirementCause) ([]uint64, []uint64, nvml.Return) {
+	timestamps := d.timestamps[cause]
+	return make([]uint64, len(timestamps)), timestamps, nvml.SUCCESS
+}
+
+func (d *retiredPagesDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	return 0, 0, false, false, nvml.ERROR_NOT_SUPPORTED
+}
+
+// remappedRowsDevice is an Ampere or newer GPU with row remapping.
+type remappedRowsDevice struct {
+	fakeDevice
+}
+
+func (d *remappedRowsDevice) GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *remappedRowsDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	return 2, 1, true, false, nvml.SUCCESS
+}
+
+func TestCollectRetiredPages(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		retiredPagesPending.Reset()
+		retiredPages.Reset()
+		retiredPagesLastTimestamp.Reset()
+		remappedRows.Reset()
+		rowRemapPending.Reset()
+		rowRemapFailed.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	volta := &retiredPagesDevice{
+		fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+		pending:    nvml.FEATURE_ENABLED,
+		timestamps: map[nvml.PageRetirementCause][]uint64{
+			nvml.PAGE_RETIREMENT_CAUSE_DOUBLE_BIT_ECC_ERROR: {1700000000, 1700000500},
+		},
+	}
+	hopper := &remappedRowsDevice{fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0"}}
+
+	health := newGpuHealthTracker(nil, nil, nil, time.Hour)
+	collectRetiredPages([]Device{volta, hopper}, health, nil, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(retiredPagesPending.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(retiredPages.WithLabelValues("GPU-0", "0000:18:00.0", "dbe"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(retiredPages.WithLabelValues("GPU-0", "0000:18:00.0", "multiple_sbe"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.ToFloat64(retiredPagesLastTimestamp.WithLabelValues("GPU-0", "0000:18:00.0", "dbe"))).EqualTo(1700000500))
+	// No timestamp without retirements
+	assert.Is(hammy.Number(testutil.CollectAndCount(retiredPagesLastTimestamp)).EqualTo(1))
+
+	assert.Is(hammy.Number(testutil.ToFloat64(remappedRows.WithLabelValues("GPU-1", "0000:28:00.0", "correctable"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(remappedRows.WithLabelValues("GPU-1", "0000:28:00.0", "uncorrectable"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(rowRemapPending.WithLabelValues("GPU-1", "0000:28:00.0"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(rowRemapFailed.WithLabelValues("GPU-1", "0000:28:00.0"))).EqualTo(0))
+	assert.Is(hammy.Number(testutil.CollectAndCount(retiredPagesPending)).EqualTo(1))
+
+	for _, uuid := range []string{"GPU-0", "GPU-1"} {
+		level, _ := health.evaluate(health.gpus[uuid])
+		assert.Is(hammy.Number(level).EqualTo(gpuHealthDegraded))
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ruleGroup is a Prometheus rule group.
+type ruleGroup struct {
+	name  string
+	rules []rule
+}
+
+// rule is a Prometheus recording (record set) or alerting (alert set) rule.
+type rule struct {
+	record      string
+	alert       string
+	expr        string
+	forDuration string
+	severity    string
+	summary     string
+}
+
+// runRules implements the rules subcommand, which prints recording and
+// alerting rules for the metrics of this binary.
+func runRules(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("rules", flag.ContinueOnError)
+	format := fs.String("format", "prometheus", "Output format (prometheus)")
+	criticalXids := append(xidList(nil), defaultCriticalXids...)
+	fs.Var(&criticalXids, "critical-xids", "Comma separated Xids that raise NvgpuCriticalXid")
+	berThreshold := fs.Float64("nvlink-ber-threshold", 1e-12, "Effective NVLink BER above which NvgpuNVLinkHighBER fires")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "prometheus" {
+		return fmt.Errorf("unsupported rules format %q (supported: prometheus)", *format)
+	}
+	return writePrometheusRules(stdout, generateRules(criticalXids, *berThreshold))
+}
+
+// generateRules returns the curated rules. Metric names are taken from the
+// collectors themselves so that the rules cannot drift from the exporter.
+func generateRules(criticalXids []uint64, berThreshold float64) []ruleGroup {
+	xids := make([]string, 0, len(criticalXids))
+	for _, xid := range criticalXids {
+		xids = append(xids, formatXid(xid))
+	}
+	criticalXidSelector := fmt.Sprintf(`%s{xid=~"%s"}`, metricName(xidErrors), strings.Join(xids, "|"))
+
+	return []ruleGroup{
+		{
+			name: "nvgpu.recording",
+			rules: []rule{
+				{
+					record: "nvgpu:xid_critical:increase1h",
+					expr:   fmt.Sprintf("sum by (instance, UUID, xid) (increase(%s[1h]))", criticalXidSelector),
+				},
+				{
+					record: "nvgpu:nvlink_effective_ber:max",
+					expr:   fmt.Sprintf(`max by (instance, UUID) (%s{type="effective"})`, metricName(nvlinkBer)),
+				},
+				{
+					record: "nvgpu:gpus_unhealthy:count",
+					expr:   fmt.Sprintf("count by (instance) (max by (instance, UUID) (%s) > %d)", descName(gpuHealthSummaryDesc), gpuHealthOK),
+				},
+			},
+		},
+		{
+			name: "nvgpu.alerts",
+			rules: []rule{
+				{
+					alert:    "NvgpuCriticalXid",
+					expr:     fmt.Sprintf("increase(%s[10m]) > 0", criticalXidSelector),
+					severity: "critical",
+					summary:  "GPU {{ $labels.UUID }} on {{ $labels.instance }} raised critical Xid {{ $labels.xid }}.",
+				},
+				{
+					alert:       "NvgpuNVLinkHighBER",
+					expr:        fmt.Sprintf(`%s{type="effective"} > %s`, metricName(nvlinkBer), strconv.FormatFloat(berThreshold, 'g', -1, 64)),
+					forDuration: "15m",
+					severity:    "warning",
+					summary:     "NVLink {{ $labels.link }} of GPU {{ $labels.UUID }} on {{ $labels.instance }} has an effective BER of {{ $value }}.",
+				},
+				{
+					alert:       "NvgpuNVLinkDown",
+					expr:        fmt.Sprintf("%s == 0", metricName(nvlinkUp)),
+					forDuration: "5m",
+					severity:    "warning",
+					summary:     "NVLink {{ $labels.link }} of GPU {{ $labels.UUID }} on {{ $labels.instance }} is down.",
+				},
+				{
+					alert:       "NvgpuFabricUnhealthy",
+					expr:        fmt.Sprintf("%s == 2", metricName(fabricHealthSummary)),
+					forDuration: "5m",
+					severity:    "critical",
+					summary:     "NVLink fabric of GPU {{ $labels.UUID }} on {{ $labels.instance }} is unhealthy.",
+				},
+				{
+					alert:       "NvgpuFabricLimitedCapacity",
+					expr:        fmt.Sprintf("%s == 3", metricName(fabricHealthSummary)),
+					forDuration: "15m",
+					severity:    "warning",
+					summary:     "NVLink fabric of GPU {{ $labels.UUID }} on {{ $labels.instance }} runs at limited capacity.",
+				},
+				{
+					alert:    "NvgpuEccRmaCriteria",
+					expr:     fmt.Sprintf("%s == 1 or %s == 1", metricName(eccSramThresholdExceeded), metricName(rowRemapFailed)),
+					severity: "critical",
+					summary:  "GPU {{ $labels.UUID }} on {{ $labels.instance }} meets the ECC RMA criteria.",
+				},
+				{
+					alert:       "NvgpuMemoryRetirementPending",
+					expr:        fmt.Sprintf("%s == 1 or %s == 1", metricName(retiredPagesPending), metricName(rowRemapPending)),
+					forDuration: "1h",
+					severity:    "warning",
+					summary:     "GPU {{ $labels.UUID }} on {{ $labels.instance }} needs a reset to retire or remap memory.",
+				},
+			},
+		},
+	}
+}
+
+// writePrometheusRules writes groups as a Prometheus rule file.
+func writePrometheusRules(w io.Writer, groups []ruleGroup) error {
+	var b strings.Builder
+	b.WriteString("# Generated by nvgpu-exporter rules --format=prometheus. Do not edit.\n")
+	b.WriteString("groups:\n")
+	for _, g := range groups {
+		fmt.Fprintf(&b, "  - name: %s\n", g.name)
+		b.WriteString("    rules:\n")
+		for _, r := range g.rules {
+			if r.record != "" {
+				fmt.Fprintf(&b, "      - record: %s\n", r.record)
+			} else {
+				fmt.Fprintf(&b, "      - alert: %s\n", r.alert)
+			}
+			fmt.Fprintf(&b, "        expr: %s\n", strconv.Quote(r.expr))
+			if r.forDuration != "" {
+				fmt.Fprintf(&b, "        for: %s\n", r.forDuration)
+			}
+			if r.severity != "" {
+				b.WriteString("        labels:\n")
+				fmt.Fprintf(&b, "          severity: %s\n", r.severity)
+			}
+			if r.summary != "" {
+				b.WriteString("        annotations:\n")
+				fmt.Fprintf(&b, "          summary: %s\n", strconv.Quote(r.summary))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestRunRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantErr  bool
+		contains []string
+	}{
+		{"defaults", nil, false, []string{
+			"groups:\n",
+			"      - alert: NvgpuCriticalXid\n",
+			`nvgpu_xid_errors_total{xid=~\"48|74|79|94|95|119|120|140\"}`,
+			`nvgpu_nvlink_ber{type=\"effective\"} > 1e-12`,
+			"nvgpu_fabric_health_summary == 2",
+			"nvgpu_ecc_sram_threshold_exceeded == 1 or nvgpu_row_remap_failed == 1",
+			"max by (instance, UUID) (nvgpu_gpu_health_summary)",
+		}},
+		{"overrides", []string{"--format=prometheus", "--critical-xids=79", "--nvlink-ber-threshold=1e-9"}, false, []string{
+			`nvgpu_xid_errors_total{xid=~\"79\"}`,
+			`nvgpu_nvlink_ber{type=\"effective\"} > 1e-09`,
+		}},
+		{"unsupported format", []string{"--format=json"}, true, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			var out strings.Builder
+			err := runRules(tc.args, &out)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+			for _, want := range tc.contains {
+				assert.Is(hammy.String(out.String()).Contains(want))
+			}
+		})
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	assert := hammy.New(t)
+	assert.Is(hammy.String(metricName(nvlinkUp)).EqualTo("nvgpu_nvlink_up"))
+	assert.Is(hammy.String(descName(gpuHealthSummaryDesc)).EqualTo("nvgpu_gpu_health_summary"))
+}
@@ -1,47 +1,388 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Run initializes metrics, starts collectors, and exposes the Prometheus HTTP handler.
-func Run(addr *string, collectionInterval *time.Duration, devices Devices, logger *slog.Logger) error {
+func Run(cfg *Config, devices Devices, systemd *systemdNotifier, warnings *recordRing[logRecord], logger *slog.Logger) error {
 	logger.Info("starting nvgpu collector", "version", version, "commit", commit)
 
+	// Device metrics and exporter-internal metrics live in separate registries
+	// so they can be served on different endpoints.
+	deviceRegistry := prometheus.NewRegistry()
+	internalRegistry := newInternalRegistry(cfg)
+
+	if cfg.CollectionJitter < 0 || cfg.CollectionJitter >= cfg.CollectionInterval {
+		return fmt.Errorf("-collection-jitter must be at least 0 and below -collection-interval (%s), got %s", cfg.CollectionInterval, cfg.CollectionJitter)
+	}
+
+	if cfg.BurstInterval < 0 || (cfg.BurstInterval > 0 && cfg.BurstDuration <= 0) {
+		return fmt.Errorf("-burst-interval must be at least 0, and -burst-duration positive when it is set")
+	}
+
+	if cfg.AdaptiveUtilizationThreshold < 0 || cfg.AdaptiveUtilizationThreshold >= 1 || cfg.AdaptiveSlowdown < 1 {
+		return fmt.Errorf("-adaptive-utilization-threshold must be at least 0 and below 1, and -adaptive-slowdown at least 1")
+	}
+
+	if cfg.MaxRequests < 0 || cfg.ScrapeTimeout < 0 || cfg.MaxSeries < 0 {
+		return fmt.Errorf("-web.max-requests, -scrape-timeout and -max-series must not be negative")
+	}
+
+	if cfg.NVLinkEffectiveBERThreshold <= 0 || cfg.NVLinkSymbolBERThreshold <= 0 {
+		return fmt.Errorf("-nvlink-effective-ber-threshold and -nvlink-symbol-ber-threshold must be positive")
+	}
+
 	gpuInfos, err := loadGpuInfos(devices)
 	if err != nil {
 		return fmt.Errorf("failed to preload gpu info: %w", err)
 	}
+	redactGpuInfos(gpuInfos, cfg.RedactAssetLabels)
 
-	if err := initExporterInfo(devices, version, commit); err != nil {
+	if err := initExporterInfo(devices, version, commit, deviceRegistry); err != nil {
 		return fmt.Errorf("failed to initialize exporter metrics: %w", err)
 	}
+	exporterInfo, err := devices.ExporterInfo()
+	if err != nil {
+		return fmt.Errorf("failed to read driver info: %w", err)
+	}
+	registerInitStatus(deviceRegistry, true)
 
-	if err := initGpuInfoWithCache(gpuInfos); err != nil {
+	if err := initGpuInfoWithCache(gpuInfos, deviceRegistry); err != nil {
 		return fmt.Errorf("failed to initialize gpu metrics: %w", err)
 	}
+	initFieldSupport(devices.handles, deviceRegistry, logger)
+
+	var labeler *nodeLabeler
+	var events *nodeEventRecorder
+	if cfg.K8sNodeLabels || cfg.K8sNodeEvents {
+		if cfg.K8sNodeName == "" {
+			return fmt.Errorf("-k8s-node-name (or $NODE_NAME) is required when -k8s-node-labels or -k8s-node-events is set")
+		}
+		client, err := newInClusterKubeClient()
+		if err != nil {
+			return fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		if cfg.K8sNodeLabels {
+			labeler = newNodeLabeler(client, cfg.K8sNodeName, cfg.CriticalXids, logger)
+			goCollector("k8s_node_labels", func() { labeler.run(cfg.CollectionInterval) })
+			logger.Info("started node labeler", "node", cfg.K8sNodeName, "critical_xids", cfg.CriticalXids.String())
+		}
+		if cfg.K8sNodeEvents {
+			events = newNodeEventRecorder(client, cfg.K8sNodeName, cfg.CriticalXids, cfg.K8sEventInterval, logger)
+			goCollector("k8s_node_events", events.run)
+			logger.Info("started node event recorder", "node", cfg.K8sNodeName, "interval", cfg.K8sEventInterval)
+		}
+	}
+
+	locations, err := newLocationLabels(cfg.LocationLabels, gpuInfos)
+	if err != nil {
+		return fmt.Errorf("invalid -location-labels: %w", err)
+	}
+
+	var history *nvlinkHistory
+	if cfg.NVLinkHistory > 0 {
+		history = newNVLinkHistory(cfg.NVLinkHistory, cfg.CollectionInterval, cfg.NVLinkHistoryFile)
+		if cfg.NVLinkHistoryFile != "" {
+			if err := history.load(); err != nil {
+				logger.Warn("failed to restore NVLink history", "path", cfg.NVLinkHistoryFile, "err", err)
+			}
+		}
+	}
+
+	var dump *fieldDump
+	if len(cfg.DebugDumpFields) > 0 {
+		dump = newFieldDump(cfg.DebugDumpFields)
+	}
+
+	var profiles map[string]expectedProfile
+	if cfg.ExpectedProfiles != "" {
+		profiles, err = loadExpectedProfiles(cfg.ExpectedProfiles)
+		if err != nil {
+			return fmt.Errorf("invalid -expected-profiles: %w", err)
+		}
+		logger.Info("loaded expected profiles", "path", cfg.ExpectedProfiles, "models", len(profiles))
+	}
+
+	var custom []customField
+	if cfg.CustomFields != "" {
+		custom, err = loadCustomFields(cfg.CustomFields)
+		if err != nil {
+			return fmt.Errorf("invalid -custom-fields: %w", err)
+		}
+		logger.Info("loaded custom fields", "path", cfg.CustomFields, "fields", len(custom))
+	}
+
+	health := newGpuHealthTracker(gpuInfos, labeler, cfg.CriticalXids, cfg.HealthXidWindow)
+	health.events = events
+	health.burst = newCollectionBurst(cfg.BurstInterval, cfg.BurstDuration)
+	if cfg.HealthWatches != "" {
+		watches, err := loadHealthWatches(cfg.HealthWatches)
+		if err != nil {
+			return fmt.Errorf("invalid -health-watches: %w", err)
+		}
+		health.watcher = newHealthWatcher(watches, cfg.HealthWatchHook, logger)
+		logger.Info("loaded health watches", "path", cfg.HealthWatches, "watches", len(watches))
+	}
+
+	remaps := newXidRowRemapTracker()
+	xids := newRecordRing[xidRecord](supportBundleRecords)
+
+	internalRegistry.MustRegister(seriesCount, seriesDropped, memoryLimit)
+
+	var deviceGatherer prometheus.Gatherer = deviceRegistry
+	var cache *gatherCache
+	if cfg.MetricsCache {
+		cache = newGatherCache(deviceRegistry)
+		deviceGatherer = cache
+	}
+	if cfg.MetricsTimestamps {
+		deviceGatherer = timestampGatherer{deviceGatherer}
+	}
 
 	// Start fabric health collector
-	startCollectors(devices, *collectionInterval, gpuInfos, logger)
+	collectorNames := startCollectors(devices, cfg, gpuInfos, health, remaps, locations, history, dump, profiles, custom, cache, deviceRegistry, internalRegistry, logger)
+
+	var lock *instanceLock
+	if cfg.InstanceLock != "" {
+		id := cfg.InstanceID
+		if id == "" {
+			id = defaultInstanceID()
+		}
+		lock, err = openInstanceLock(cfg.InstanceLock, id)
+		if err != nil {
+			return fmt.Errorf("failed to open -instance-lock: %w", err)
+		}
+		internalRegistry.MustRegister(duplicateInstance)
+	}
 
-	// Start Xid event collector
-	if err := startXidEventCollector(devices, logger); err != nil {
+	// Start Xid event collector, once no other instance on the node collects
+	// them. Its flags are checked first, so that they fail startup at once
+	// even when the collector has to wait for the lock.
+	if err := checkXidEventConfig(cfg); err != nil {
 		return fmt.Errorf("failed to start xid event collector: %w", err)
 	}
+	locked, err := lock.tryLock()
+	if !locked && err != nil {
+		return fmt.Errorf("failed to take -instance-lock: %w", err)
+	}
+	if err != nil {
+		logger.Warn("failed to write instance lock", "path", cfg.InstanceLock, "err", err)
+	}
+	if locked {
+		if err := startXidEventCollector(devices, cfg, health, remaps, xids, locations, deviceRegistry, logger); err != nil {
+			return fmt.Errorf("failed to start xid event collector: %w", err)
+		}
+	} else {
+		logger.Error("another exporter instance holds the instance lock, Xid events are not collected until it exits", "path", cfg.InstanceLock, "holder", lock.holder())
+		go func() {
+			if err := lock.wait(instanceLockRetryInterval, logger); err != nil {
+				logger.Error("failed to take instance lock, Xid events are not collected", "path", cfg.InstanceLock, "err", err)
+				return
+			}
+			logger.Info("acquired instance lock", "path", cfg.InstanceLock)
+			if err := startXidEventCollector(devices, cfg, health, remaps, xids, locations, deviceRegistry, logger); err != nil {
+				logger.Error("failed to start xid event collector", "err", err)
+			}
+		}()
+	}
 
 	logDeviceList(devices, logger)
 
-	http.Handle("/metrics", promhttp.Handler())
+	// Routes are served from a mux of their own, so that nothing another
+	// package registers on http.DefaultServeMux is exposed
+	mux := http.NewServeMux()
+
+	// Only /metrics is capped; /metrics-lite serves the families the cap keeps
+	metricsGatherer := newSeriesCapGatherer(deviceGatherer, cfg.MaxSeries, logger)
+	if cfg.InternalAddr == "" {
+		// Serve everything on a single endpoint
+		mux.Handle("/metrics", metricsHandler(prometheus.Gatherers{metricsGatherer, internalRegistry}, internalRegistry, cfg, logger))
+	} else {
+		mux.Handle("/metrics", metricsHandler(metricsGatherer, internalRegistry, cfg, logger))
+
+		internalHandler := metricsHandler(internalRegistry, internalRegistry, cfg, logger)
+		if cfg.InternalAddr == cfg.Addr {
+			if cfg.InternalPath == "/metrics" {
+				return fmt.Errorf("-internal-metrics-path must differ from /metrics when -internal-metrics-addr equals -addr")
+			}
+			mux.Handle(cfg.InternalPath, internalHandler)
+		} else {
+			internalMux := http.NewServeMux()
+			internalMux.Handle(cfg.InternalPath, internalHandler)
+			go func() {
+				logger.Info("starting internal metrics HTTP server", "addr", cfg.InternalAddr, "path", cfg.InternalPath)
+				if err := newHTTPServer(cfg.InternalAddr, internalMux, cfg).ListenAndServe(); err != nil {
+					logger.Error("internal metrics server terminated", "err", err)
+				}
+			}()
+		}
+	}
 
-	logger.Info("starting HTTP server", "addr", *addr)
-	if err := http.ListenAndServe(*addr, nil); err != nil {
+	mux.Handle(liteMetricsPath, metricsHandler(newLiteGatherer(deviceGatherer), internalRegistry, cfg, logger))
+	mux.Handle(infoAPIPath, infoHandler(newExporterInfoDocument(flag.CommandLine, collectorNames, exporterInfo, devices.handles, gpuInfos), logger))
+	mux.Handle(supportBundlePath, supportBundleHandler(prometheus.Gatherers{deviceGatherer, internalRegistry}, exporterInfo, gpuInfos, xids, warnings, logger))
+	mux.Handle(processesAPIPattern, processesHandler(devices.handles, cfg.ProcPath, logger))
+	if history != nil {
+		mux.Handle(nvlinkHistoryAPIPattern, nvlinkHistoryHandler(history, devices.handles, logger))
+	}
+
+	if dump != nil {
+		mux.Handle(fieldDumpAPIPath, fieldDumpHandler(dump, logger))
+	}
+	if cfg.PCIeTopology {
+		mux.Handle(pcieTopologyAPIPath, pcieTopologyHandler(devices.handles, cfg.SysPath, logger))
+	}
+
+	if cfg.Probe {
+		allowed, err := probeAllowlist(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid -probe: %w", err)
+		}
+		mux.Handle("/probe", probeHandler(&http.Client{}, allowed, cfg.ProbeTimeout, logger))
+	}
+	if len(cfg.RackTargets) > 0 {
+		mux.Handle("/rack", rackHandler(&http.Client{}, cfg.RackTargets, cfg.ProbeTimeout, cfg.RackCliqueSize, logger))
+	}
+	if cfg.Pprof {
+		handlePprof(mux)
+	}
+
+	return serve(cfg, mux, fmt.Sprintf("collecting %d GPUs", devices.Count()), systemd, logger)
+}
+
+// RunProbe serves only the /probe (and, with -rack-targets, /rack) endpoints
+// and exporter-internal metrics, without touching NVML, for central
+// deployments that scrape remote agents.
+func RunProbe(cfg *Config, systemd *systemdNotifier, logger *slog.Logger) error {
+	logger.Info("starting nvgpu probe", "version", version, "commit", commit)
+
+	allowed, err := probeAllowlist(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid -probe-only: %w", err)
+	}
+
+	internalRegistry := newInternalRegistry(cfg)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(internalRegistry, internalRegistry, cfg, logger))
+	mux.Handle("/probe", probeHandler(&http.Client{}, allowed, cfg.ProbeTimeout, logger))
+	if len(cfg.RackTargets) > 0 {
+		mux.Handle("/rack", rackHandler(&http.Client{}, cfg.RackTargets, cfg.ProbeTimeout, cfg.RackCliqueSize, logger))
+	}
+
+	if cfg.Pprof {
+		handlePprof(mux)
+	}
+
+	return serve(cfg, mux, "serving probes", systemd, logger)
+}
+
+// serve serves handler on -addr, telling systemd that the exporter is ready
+// with status once it listens.
+func serve(cfg *Config, handler http.Handler, status string, systemd *systemdNotifier, logger *slog.Logger) error {
+	logger.Info("starting HTTP server", "addr", cfg.Addr)
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	systemd.ready(status, logger)
+	if err := newHTTPServer(cfg.Addr, handler, cfg).Serve(ln); err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
 	return nil
 }
+
+// newInternalRegistry returns a registry holding the exporter's own runtime
+// metrics; the Go runtime and process metrics are left out with -go-metrics
+// and -process-metrics set to false.
+func newInternalRegistry(cfg *Config) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	if cfg.GoMetrics {
+		reg.MustRegister(collectors.NewGoCollector())
+	}
+	if cfg.ProcessMetrics {
+		reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	reg.MustRegister(scrapesTotal)
+	return reg
+}
+
+// metricsHandler serves metrics from gatherer, recording handler telemetry in
+// internal. Concurrent and slow requests are limited by -web.max-requests and
+// -scrape-timeout, so that a misbehaving scraper cannot pile up goroutines on
+// the GPU node. Requests the limit admits are counted per scraper and, with
+// -access-log, logged. OpenMetrics is offered with -xid-exemplar, as only it
+// carries exemplars.
+func metricsHandler(gatherer prometheus.Gatherer, internal prometheus.Registerer, cfg *Config, logger *slog.Logger) http.Handler {
+	return promhttp.InstrumentMetricHandler(internal, limitRequests(scrapeLogger(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		Timeout:           cfg.ScrapeTimeout,
+		EnableOpenMetrics: cfg.XidExemplar != "",
+	}), cfg.AccessLog, logger), cfg.MaxRequests))
+}
+
+// limitRequests answers requests beyond max concurrent ones with 503 Service
+// Unavailable, as promhttp does with MaxRequestsInFlight. 0 means no limit.
+func limitRequests(next http.Handler, max int) http.Handler {
+	if max <= 0 {
+		return next
+	}
+
+	inFlight := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+		default:
+			http.Error(w, fmt.Sprintf("Limit of concurrent requests reached (%d), try again later.", max), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+const (
+	// httpReadHeaderTimeout bounds how long a client may take to send its
+	// request headers.
+	httpReadHeaderTimeout = 10 * time.Second
+	// httpReadTimeout bounds how long a client may take to send its request;
+	// no route takes a request body.
+	httpReadTimeout = 30 * time.Second
+	// httpIdleTimeout is how long a keep-alive connection is kept between
+	// requests.
+	httpIdleTimeout = 2 * time.Minute
+)
+
+// newHTTPServer returns a server for handler on addr that drops clients which
+// are too slow to send their request or send headers larger than
+// -http-max-header-size. Responses have no write timeout: -scrape-timeout
+// bounds how long /metrics takes.
+func newHTTPServer(addr string, handler http.Handler, cfg *Config) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		IdleTimeout:       httpIdleTimeout,
+		MaxHeaderBytes:    int(cfg.MaxHeaderSize),
+	}
+}
+
+// handlePprof serves the Go profiler under /debug/pprof/ on mux.
+func handlePprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
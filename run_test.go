@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// blockingGatherer blocks every Gather until release is closed.
+type blockingGatherer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *blockingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.started <- struct{}{}
+	<-g.release
+	return nil, nil
+}
+
+func TestMetricsHandlerLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		// concurrent requests the handler should still answer with 200
+		admitted int
+	}{
+		{"max requests", Config{MaxRequests: 1}, 1},
+		{"scrape timeout", Config{ScrapeTimeout: 10 * time.Millisecond}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			gatherer := &blockingGatherer{started: make(chan struct{}, 2), release: make(chan struct{})}
+			handler := metricsHandler(gatherer, prometheus.NewRegistry(), &tc.cfg, discardLogger())
+
+			held := make(chan int)
+			if tc.admitted > 0 {
+				go func() {
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+					held <- rec.Code
+				}()
+				<-gatherer.started
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusServiceUnavailable))
+
+			close(gatherer.release)
+			if tc.admitted > 0 {
+				assert.Is(hammy.Number(<-held).EqualTo(http.StatusOK))
+			}
+		})
+	}
+}
+
+func TestMetricsHandlerAccessLog(t *testing.T) {
+	assert := hammy.New(t)
+	scrapesTotal.Reset()
+	t.Cleanup(scrapesTotal.Reset)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	handler := metricsHandler(prometheus.NewRegistry(), prometheus.NewRegistry(), &Config{AccessLog: true}, logger)
+
+	for _, remote := range []string{"10.0.0.1:40000", "10.0.0.1:40001", "10.0.0.2:40000"} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("User-Agent", "Prometheus/3.0.0")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Is(hammy.Number(testutil.ToFloat64(scrapesTotal.WithLabelValues("10.0.0.1"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(scrapesTotal.WithLabelValues("10.0.0.2"))).EqualTo(1))
+	assert.Is(hammy.String(logs.String()).Contains("remote=10.0.0.2 path=/metrics user_agent=Prometheus/3.0.0 status=200"))
+}
+
+func TestMetricsHandlerCountsAdmittedRequests(t *testing.T) {
+	assert := hammy.New(t)
+	scrapesTotal.Reset()
+	t.Cleanup(scrapesTotal.Reset)
+
+	gatherer := &blockingGatherer{started: make(chan struct{}, 1), release: make(chan struct{})}
+	handler := metricsHandler(gatherer, prometheus.NewRegistry(), &Config{MaxRequests: 1}, discardLogger())
+
+	held := make(chan struct{})
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = "10.0.0.1:40000"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		close(held)
+	}()
+	<-gatherer.started
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = "10.0.0.2:40000"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusServiceUnavailable))
+	close(gatherer.release)
+	<-held
+
+	// The rejected request is not counted
+	assert.Is(hammy.Number(testutil.CollectAndCount(scrapesTotal)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(scrapesTotal.WithLabelValues("10.0.0.1"))).EqualTo(1))
+}
+
+func TestRemoteCounterFoldsExcessRemotes(t *testing.T) {
+	assert := hammy.New(t)
+	counter := newRemoteCounter(prometheus.CounterOpts{Name: "test_scrapes_total", Help: "Test scrapes."}, 2)
+
+	for _, remote := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.1"} {
+		counter.inc(remote)
+	}
+
+	assert.Is(hammy.Number(testutil.CollectAndCount(counter)).EqualTo(3))
+	assert.Is(hammy.Number(testutil.ToFloat64(counter.WithLabelValues("10.0.0.1"))).EqualTo(2))
+	assert.Is(hammy.Number(testutil.ToFloat64(counter.WithLabelValues(otherScrapeRemote))).EqualTo(2))
+
+	// Reset forgets the addresses
+	counter.Reset()
+	counter.inc("10.0.0.3")
+	assert.Is(hammy.Number(testutil.ToFloat64(counter.WithLabelValues("10.0.0.3"))).EqualTo(1))
+}
+
+func TestNewInternalRegistryRuntimeMetrics(t *testing.T) {
+	tests := []struct {
+		name           string
+		goMetrics      bool
+		processMetrics bool
+	}{
+		{"both", true, true},
+		{"go only", true, false},
+		{"none", false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			reg := newInternalRegistry(&Config{GoMetrics: tc.goMetrics, ProcessMetrics: tc.processMetrics})
+			families, err := reg.Gather()
+			assert.Is(hammy.NilError(err))
+
+			names := make(map[string]bool)
+			for _, f := range families {
+				names[f.GetName()] = true
+			}
+			assert.Is(hammy.True(names["go_goroutines"] == tc.goMetrics))
+			// The process collector only reports on platforms with procfs
+			if !tc.processMetrics {
+				assert.Is(hammy.False(names["process_open_fds"]))
+			}
+		})
+	}
+}
+
+func TestNewHTTPServerMaxHeaderSize(t *testing.T) {
+	assert := hammy.New(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {})
+	srv := newHTTPServer("", mux, &Config{MaxHeaderSize: 4 << 10})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Is(hammy.NilError(err))
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	request := func(header string) int {
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/metrics", nil)
+		assert.Is(hammy.NilError(err))
+		req.Header.Set("X-Padding", header)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Is(hammy.NilError(err))
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Is(hammy.Number(request("small")).EqualTo(http.StatusOK))
+	assert.Is(hammy.Number(request(strings.Repeat("x", 16<<10))).EqualTo(http.StatusRequestHeaderFieldsTooLarge))
+}
+
+func TestHandlePprof(t *testing.T) {
+	assert := hammy.New(t)
+	mux := http.NewServeMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusNotFound))
+
+	handlePprof(mux)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+}
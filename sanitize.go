@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLabelValueLength is the length in bytes past which NVML strings are cut.
+// NVML strings are at most 96 bytes; a longer one is corrupt.
+const maxLabelValueLength = 128
+
+// sanitizeLabelValue cleans a string read from NVML before it becomes a label
+// value. NVML strings are NUL-terminated C buffers, and drivers have returned
+// buffers with embedded NULs and trailing garbage, which break remote-write
+// receivers downstream. The string is cut at the first NUL, control
+// characters and invalid UTF-8 are dropped, surrounding space is trimmed and
+// the result is clamped to maxLabelValueLength.
+func sanitizeLabelValue(s string) string {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+	if len(s) > maxLabelValueLength {
+		cut := maxLabelValueLength
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return s
+}
+
+// sanitizePciBusID cleans a PCI address like sanitizeLabelValue and upper
+// cases it, the way pciBusID formats addresses, so that addresses from
+// different NVML calls and sysfs compare equal.
+func sanitizePciBusID(s string) string {
+	return strings.ToUpper(sanitizeLabelValue(s))
+}
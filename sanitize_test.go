@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestSanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "clean", in: "NVIDIA H100 80GB HBM3", want: "NVIDIA H100 80GB HBM3"},
+		{name: "embedded NUL", in: "1653922001234\x00\x00garbage", want: "1653922001234"},
+		{name: "control characters", in: "96.00.\x1b99.00\n.01", want: "96.00.99.00.01"},
+		{name: "invalid UTF-8", in: "GPU-\xff1", want: "GPU-1"},
+		{name: "surrounding space", in: "  nvidia-smi\n", want: "nvidia-smi"},
+		{name: "clamped", in: strings.Repeat("a", 200), want: strings.Repeat("a", maxLabelValueLength)},
+		{name: "clamped at rune boundary", in: strings.Repeat("a", maxLabelValueLength-1) + "é", want: strings.Repeat("a", maxLabelValueLength-1)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			assert.Is(hammy.String(sanitizeLabelValue(tc.in)).EqualTo(tc.want))
+		})
+	}
+}
+
+func TestSanitizePciBusID(t *testing.T) {
+	assert := hammy.New(t)
+	assert.Is(hammy.String(sanitizePciBusID("0000:2a:00.0\x00")).EqualTo("0000:2A:00.0"))
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// snapshotEccFields are the ECC counters recorded in a snapshot.
+var snapshotEccFields = []struct {
+	fieldId uint32
+	name    string
+}{
+	{nvml.FI_DEV_ECC_SBE_VOL_TOTAL, "sbe_volatile"},
+	{nvml.FI_DEV_ECC_DBE_VOL_TOTAL, "dbe_volatile"},
+	{nvml.FI_DEV_ECC_SBE_AGG_TOTAL, "sbe_aggregate"},
+	{nvml.FI_DEV_ECC_DBE_AGG_TOTAL, "dbe_aggregate"},
+}
+
+// nodeSnapshot is the inventory and health state of a node as written by
+// nvgpu-exporter snapshot, so that maintenance crews can compare a node before
+// and after work on it with nvgpu-exporter diff.
+type nodeSnapshot struct {
+	Time     time.Time
+	Hostname string
+	Exporter *ExporterInfo
+	GPUs     []gpuSnapshot
+}
+
+// gpuSnapshot is the state of a single GPU. Fields the GPU does not support
+// are left out.
+type gpuSnapshot struct {
+	Info *GpuInfo
+	// NVLinks maps each link that is not unused to whether it is active
+	NVLinks map[string]bool   `json:",omitempty"`
+	ECC     map[string]uint64 `json:",omitempty"`
+	// RemappedRows holds the correctable and uncorrectable remapped rows
+	RemappedRows       map[string]int `json:",omitempty"`
+	RowRemapPending    bool           `json:",omitempty"`
+	RowRemapFailed     bool           `json:",omitempty"`
+	RetirementsPending bool           `json:",omitempty"`
+	FabricState        *uint8         `json:",omitempty"`
+	FabricStatus       *uint32        `json:",omitempty"`
+	FabricHealthMask   *uint32        `json:",omitempty"`
+}
+
+// runSnapshot implements the snapshot subcommand.
+func runSnapshot(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the snapshot to this file instead of stdout")
+	var cfg Config
+	registerSourceFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	devices, shutdown, err := openDevices(&cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	snapshot, err := takeSnapshot(devices, logger)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdout = f
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
+
+// registerSourceFlags registers the flags that choose the NVML source of a
+// subcommand.
+func registerSourceFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.NVML, "nvml", "", "NVML source: empty for the system library, or replay:<file> to replay a recording")
+	fs.StringVar(&cfg.NVMLLibrary, "nvml-library", os.Getenv("NVML_LIBRARY"), "Path to libnvidia-ml.so (defaults to $NVML_LIBRARY)")
+	fs.StringVar(&cfg.Simulate, "simulate", "", "Use synthetic GPUs, e.g. 8xH100")
+}
+
+// openDevices initializes the NVML source chosen by registerSourceFlags.
+func openDevices(cfg *Config, logger *slog.Logger) (Devices, func(), error) {
+	var lib nvml.Interface
+	var err error
+	if cfg.Simulate != "" {
+		lib, err = newSimulatedLibrary(cfg.Simulate)
+	} else {
+		lib, err = newNvmlLibrary(cfg.NVML, cfg.NVMLLibrary, logger)
+	}
+	if err != nil {
+		return Devices{}, nil, err
+	}
+	return New(newNvmlClient(lib), nil, logger)
+}
+
+// takeSnapshot records the state of every GPU.
+func takeSnapshot(devices Devices, logger *slog.Logger) (nodeSnapshot, error) {
+	s := nodeSnapshot{Time: time.Now().UTC()}
+	s.Hostname, _ = os.Hostname()
+
+	var err error
+	if s.Exporter, err = devices.ExporterInfo(); err != nil {
+		return s, err
+	}
+	infos, err := loadGpuInfos(devices)
+	if err != nil {
+		return s, err
+	}
+	for i, info := range infos {
+		s.GPUs = append(s.GPUs, snapshotGPU(devices.handles[i], info, logger))
+	}
+	return s, nil
+}
+
+func snapshotGPU(device Device, info *GpuInfo, logger *slog.Logger) gpuSnapshot {
+	g := gpuSnapshot{Info: info, NVLinks: make(map[string]bool), ECC: make(map[string]uint64)}
+
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		state, ret := device.GetNvLinkState(link)
+		if errors.Is(ret, nvml.SUCCESS) {
+			g.NVLinks[strconv.Itoa(link)] = state == nvml.FEATURE_ENABLED
+		}
+	}
+
+	values := make([]nvml.FieldValue, len(snapshotEccFields))
+	for i, field := range snapshotEccFields {
+		values[i].FieldId = field.fieldId
+	}
+	if ret := device.GetFieldValues(values); errors.Is(ret, nvml.SUCCESS) {
+		for i, fv := range values {
+			if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
+				continue
+			}
+			if v, err := fieldValueToUint64(fv); err == nil {
+				g.ECC[snapshotEccFields[i].name] = v
+			}
+		}
+	} else {
+		logger.Warn("failed to read ECC counters", "uuid", info.UUID, "error", nvml.ErrorString(ret))
+	}
+
+	if corr, unc, pending, failed, ret := device.GetRemappedRows(); errors.Is(ret, nvml.SUCCESS) {
+		g.RemappedRows = map[string]int{"correctable": corr, "uncorrectable": unc}
+		g.RowRemapPending = pending
+		g.RowRemapFailed = failed
+	}
+	if pending, ret := device.GetRetiredPagesPendingStatus(); errors.Is(ret, nvml.SUCCESS) {
+		g.RetirementsPending = pending == nvml.FEATURE_ENABLED
+	}
+	if fabric, ret := device.GetGpuFabricInfoV2(); errors.Is(ret, nvml.SUCCESS) && fabric.State != nvml.GPU_FABRIC_STATE_NOT_SUPPORTED {
+		status := uint32(fabric.Status)
+		g.FabricState = &fabric.State
+		g.FabricStatus = &status
+		g.FabricHealthMask = &fabric.HealthMask
+	}
+	return g
+}
+
+// runDiff implements the diff subcommand. It reports whether the snapshots
+// differ.
+func runDiff(args []string, stdout io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if fs.NArg() != 2 {
+		return false, errors.New("usage: nvgpu-exporter diff <before.json> <after.json>")
+	}
+
+	var snapshots [2]nodeSnapshot
+	for i, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(data, &snapshots[i]); err != nil {
+			return false, fmt.Errorf("invalid snapshot %s: %w", path, err)
+		}
+	}
+
+	changes := diffSnapshots(snapshots[0], snapshots[1])
+	for _, change := range changes {
+		fmt.Fprintln(stdout, change)
+	}
+	return len(changes) > 0, nil
+}
+
+// diffSnapshots describes what changed from before to after, one change per
+// line. GPUs are matched by UUID.
+func diffSnapshots(before, after nodeSnapshot) []string {
+	var changes []string
+	if before.Exporter != nil && after.Exporter != nil && before.Exporter.DriverVersion != after.Exporter.DriverVersion {
+		changes = append(changes, fmt.Sprintf("driver: %s -> %s", before.Exporter.DriverVersion, after.Exporter.DriverVersion))
+	}
+
+	old := make(map[string]gpuSnapshot, len(before.GPUs))
+	for _, g := range before.GPUs {
+		old[g.Info.UUID] = g
+	}
+	for _, g := range after.GPUs {
+		prev, ok := old[g.Info.UUID]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("%s (%s): added", g.Info.UUID, g.Info.PciBusId))
+			continue
+		}
+		delete(old, g.Info.UUID)
+		for _, change := range diffGPU(prev, g) {
+			changes = append(changes, fmt.Sprintf("%s (%s): %s", g.Info.UUID, g.Info.PciBusId, change))
+		}
+	}
+	for _, g := range before.GPUs {
+		if _, removed := old[g.Info.UUID]; removed {
+			changes = append(changes, fmt.Sprintf("%s (%s): removed", g.Info.UUID, g.Info.PciBusId))
+		}
+	}
+	return changes
+}
+
+func diffGPU(before, after gpuSnapshot) []string {
+	var changes []string
+
+	// Inventory: VBIOS, InfoROM, serials, location
+	b, a := reflect.ValueOf(*before.Info), reflect.ValueOf(*after.Info)
+	for i := range b.NumField() {
+		if !reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", b.Type().Field(i).Name, b.Field(i).Interface(), a.Field(i).Interface()))
+		}
+	}
+
+	for _, link := range sortedKeys(before.NVLinks, after.NVLinks) {
+		was, hadLink := before.NVLinks[link]
+		is, hasLink := after.NVLinks[link]
+		switch {
+		case !hasLink:
+			changes = append(changes, fmt.Sprintf("NVLink %s: gone", link))
+		case !hadLink:
+			changes = append(changes, fmt.Sprintf("NVLink %s: new, %s", link, linkState(is)))
+		case was != is:
+			changes = append(changes, fmt.Sprintf("NVLink %s: %s -> %s", link, linkState(was), linkState(is)))
+		}
+	}
+
+	for _, counter := range sortedKeys(before.ECC, after.ECC) {
+		if was, is := before.ECC[counter], after.ECC[counter]; was != is {
+			changes = append(changes, fmt.Sprintf("ECC %s: %d -> %d (%+d)", counter, was, is, int64(is)-int64(was)))
+		}
+	}
+	for _, cause := range sortedKeys(before.RemappedRows, after.RemappedRows) {
+		if was, is := before.RemappedRows[cause], after.RemappedRows[cause]; was != is {
+			changes = append(changes, fmt.Sprintf("remapped rows %s: %d -> %d", cause, was, is))
+		}
+	}
+	flags := []struct {
+		name    string
+		was, is bool
+	}{
+		{"row remap pending", before.RowRemapPending, after.RowRemapPending},
+		{"row remap failed", before.RowRemapFailed, after.RowRemapFailed},
+		{"retirements pending", before.RetirementsPending, after.RetirementsPending},
+	}
+	for _, f := range flags {
+		if f.was != f.is {
+			changes = append(changes, fmt.Sprintf("%s: %t -> %t", f.name, f.was, f.is))
+		}
+	}
+
+	if !reflect.DeepEqual(before.FabricState, after.FabricState) {
+		changes = append(changes, fmt.Sprintf("fabric state: %s -> %s", optional(before.FabricState), optional(after.FabricState)))
+	}
+	if !reflect.DeepEqual(before.FabricStatus, after.FabricStatus) {
+		changes = append(changes, fmt.Sprintf("fabric status: %s -> %s", optional(before.FabricStatus), optional(after.FabricStatus)))
+	}
+	if !reflect.DeepEqual(before.FabricHealthMask, after.FabricHealthMask) {
+		changes = append(changes, fmt.Sprintf("fabric health mask: %s -> %s", optional(before.FabricHealthMask), optional(after.FabricHealthMask)))
+	}
+	return changes
+}
+
+func linkState(active bool) string {
+	if active {
+		return "up"
+	}
+	return "down"
+}
+
+// optional formats an optional snapshot value.
+func optional[T uint8 | uint32](v *T) string {
+	if v == nil {
+		return "unsupported"
+	}
+	return fmt.Sprintf("%#x", *v)
+}
+
+// sortedKeys returns the keys of both maps in order.
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(x, y string) int {
+		// Link numbers sort numerically
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		if errX == nil && errY == nil {
+			return nx - ny
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+		return 0
+	})
+	return keys
+}
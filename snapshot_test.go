@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestRunSnapshotAndDiff(t *testing.T) {
+	assert := hammy.New(t)
+	dir := t.TempDir()
+	before := filepath.Join(dir, "before.json")
+	assert.Is(hammy.NilError(runSnapshot([]string{"-simulate", "2xH100", "-o", before}, os.Stdout)))
+
+	data, err := os.ReadFile(before)
+	assert.Is(hammy.NilError(err))
+	var s nodeSnapshot
+	assert.Is(hammy.NilError(json.Unmarshal(data, &s)))
+	assert.Is(hammy.Number(len(s.GPUs)).EqualTo(2))
+	assert.Is(hammy.True(len(s.GPUs[0].NVLinks) > 0))
+
+	var out bytes.Buffer
+	changed, err := runDiff([]string{before, before}, &out)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.False(changed))
+	assert.Is(hammy.String(out.String()).EqualTo(""))
+
+	s.GPUs[1].Info.VbiosVersion = "96.00.A0.00.01"
+	after := filepath.Join(dir, "after.json")
+	data, err = json.Marshal(s)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.NilError(os.WriteFile(after, data, 0o644)))
+
+	changed, err = runDiff([]string{before, after}, &out)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(changed))
+	assert.Is(hammy.String(out.String()).Contains("VbiosVersion: "))
+	assert.Is(hammy.String(out.String()).Contains("-> 96.00.A0.00.01"))
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	state := func(v uint8) *uint8 { return &v }
+	gpu := func(uuid string) gpuSnapshot {
+		return gpuSnapshot{
+			Info:         &GpuInfo{UUID: uuid, PciBusId: "00000000:01:00.0", VbiosVersion: "96.00.74.00.01"},
+			NVLinks:      map[string]bool{"0": true, "1": true, "10": true},
+			ECC:          map[string]uint64{"sbe_aggregate": 4, "dbe_aggregate": 0},
+			RemappedRows: map[string]int{"correctable": 1, "uncorrectable": 0},
+			FabricState:  state(3),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(*gpuSnapshot)
+		want   []string
+	}{
+		{"unchanged", func(*gpuSnapshot) {}, nil},
+		{
+			"link down",
+			func(g *gpuSnapshot) { g.NVLinks["10"] = false },
+			[]string{"GPU-1 (00000000:01:00.0): NVLink 10: up -> down"},
+		},
+		{
+			"ECC delta",
+			func(g *gpuSnapshot) { g.ECC["sbe_aggregate"] = 10; g.ECC["dbe_aggregate"] = 1 },
+			[]string{
+				"GPU-1 (00000000:01:00.0): ECC dbe_aggregate: 0 -> 1 (+1)",
+				"GPU-1 (00000000:01:00.0): ECC sbe_aggregate: 4 -> 10 (+6)",
+			},
+		},
+		{
+			"VBIOS update and row remap",
+			func(g *gpuSnapshot) {
+				g.Info.VbiosVersion = "96.00.99.00.01"
+				g.RemappedRows["uncorrectable"] = 1
+				g.RowRemapPending = true
+			},
+			[]string{
+				"GPU-1 (00000000:01:00.0): VbiosVersion: 96.00.74.00.01 -> 96.00.99.00.01",
+				"GPU-1 (00000000:01:00.0): remapped rows uncorrectable: 0 -> 1",
+				"GPU-1 (00000000:01:00.0): row remap pending: false -> true",
+			},
+		},
+		{
+			"fabric lost",
+			func(g *gpuSnapshot) { g.FabricState = nil },
+			[]string{"GPU-1 (00000000:01:00.0): fabric state: 0x3 -> unsupported"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			after := gpu("GPU-1")
+			tc.change(&after)
+			changes := diffSnapshots(nodeSnapshot{GPUs: []gpuSnapshot{gpu("GPU-1")}}, nodeSnapshot{GPUs: []gpuSnapshot{after}})
+			assert.Is(hammy.Number(len(changes)).EqualTo(len(tc.want)))
+			for i := range tc.want {
+				assert.Is(hammy.String(changes[i]).EqualTo(tc.want[i]))
+			}
+		})
+	}
+}
+
+func TestDiffSnapshotsMatchesGPUsByUUID(t *testing.T) {
+	assert := hammy.New(t)
+	before := nodeSnapshot{GPUs: []gpuSnapshot{
+		{Info: &GpuInfo{UUID: "GPU-1", PciBusId: "a"}},
+		{Info: &GpuInfo{UUID: "GPU-2", PciBusId: "b"}},
+	}}
+	after := nodeSnapshot{GPUs: []gpuSnapshot{
+		{Info: &GpuInfo{UUID: "GPU-3", PciBusId: "b"}},
+		{Info: &GpuInfo{UUID: "GPU-1", PciBusId: "a"}},
+	}}
+
+	changes := diffSnapshots(before, after)
+
+	assert.Is(hammy.Number(len(changes)).EqualTo(2))
+	assert.Is(hammy.String(changes[0]).EqualTo("GPU-3 (b): added"))
+	assert.Is(hammy.String(changes[1]).EqualTo("GPU-2 (b): removed"))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// supportBundlePath is the route of the support bundle.
+const supportBundlePath = "GET /-/support-bundle"
+
+// supportBundleRecords is the number of recent warnings and Xid events kept
+// for the support bundle.
+const supportBundleRecords = 100
+
+// xidRecord is an Xid event as received from NVML.
+type xidRecord struct {
+	Time     time.Time `json:"time"`
+	UUID     string    `json:"uuid"`
+	PciBusId string    `json:"pci_bus_id"`
+	Xid      uint64    `json:"xid"`
+}
+
+// logRecord is a logged warning or error.
+type logRecord struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// recordRing keeps the last records added to it. A nil *recordRing drops
+// every record.
+type recordRing[T any] struct {
+	mu      sync.Mutex
+	records []T
+	next    int
+	full    bool
+}
+
+func newRecordRing[T any](size int) *recordRing[T] {
+	return &recordRing[T]{records: make([]T, size)}
+}
+
+func (r *recordRing[T]) add(record T) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the records, oldest first.
+func (r *recordRing[T]) list() []T {
+	if r == nil {
+		return []T{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]T{}, r.records[:r.next]...)
+	}
+	return append(append([]T{}, r.records[r.next:]...), r.records[:r.next]...)
+}
+
+// warningRecorder is a slog.Handler that keeps the warnings and errors it
+// passes on in warnings, so that the support bundle carries them even when the
+// exporter's log was not collected.
+type warningRecorder struct {
+	slog.Handler
+	warnings *recordRing[logRecord]
+	attrs    []slog.Attr
+}
+
+func newWarningRecorder(handler slog.Handler, warnings *recordRing[logRecord]) *warningRecorder {
+	return &warningRecorder{Handler: handler, warnings: warnings}
+}
+
+func (h *warningRecorder) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		record := logRecord{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+		add := func(a slog.Attr) bool {
+			if record.Attrs == nil {
+				record.Attrs = make(map[string]string)
+			}
+			record.Attrs[a.Key] = a.Value.String()
+			return true
+		}
+		for _, a := range h.attrs {
+			add(a)
+		}
+		r.Attrs(add)
+		h.warnings.add(record)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *warningRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &warningRecorder{Handler: h.Handler.WithAttrs(attrs), warnings: h.warnings, attrs: append(append([]slog.Attr{}, h.attrs...), attrs...)}
+}
+
+func (h *warningRecorder) WithGroup(name string) slog.Handler {
+	return &warningRecorder{Handler: h.Handler.WithGroup(name), warnings: h.warnings, attrs: h.attrs}
+}
+
+// supportVersions is the version matrix of the support bundle.
+type supportVersions struct {
+	Exporter      string `json:"exporter"`
+	Commit        string `json:"commit"`
+	GoVersion     string `json:"go_version"`
+	DriverVersion string `json:"driver_version"`
+	NVMLVersion   string `json:"nvml_version"`
+	CudaVersion   string `json:"cuda_version"`
+}
+
+// supportBundleHandler serves a gzipped tarball with the state NVIDIA support
+// asks for, so that it can be attached to a ticket in one step:
+//
+//	versions.json  exporter, driver, NVML and CUDA versions
+//	gpus.json      the GPU info of every GPU
+//	metrics.prom   the metrics of the exporter, as served on /metrics
+//	xids.json      the last Xid events, from xids
+//	warnings.json  the last warnings and errors logged, from warnings
+func supportBundleHandler(gatherer prometheus.Gatherer, exporter *ExporterInfo, infos []*GpuInfo, xids *recordRing[xidRecord], warnings *recordRing[logRecord], logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bundle, err := buildSupportBundle(gatherer, exporter, infos, xids.list(), warnings.list(), time.Now())
+		if err != nil {
+			logger.Warn("failed to build support bundle", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "nvgpu-support-bundle.tar.gz"))
+		if _, err := w.Write(bundle); err != nil {
+			logger.Debug("failed to write support bundle", "err", err)
+		}
+	}
+}
+
+func buildSupportBundle(gatherer prometheus.Gatherer, exporter *ExporterInfo, infos []*GpuInfo, xids []xidRecord, warnings []logRecord, now time.Time) ([]byte, error) {
+	var metrics bytes.Buffer
+	families, err := gatherer.Gather()
+	if err != nil {
+		// Partial results are still worth attaching
+		fmt.Fprintf(&metrics, "# gather error: %v\n", err)
+	}
+	encoder := expfmt.NewEncoder(&metrics, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := encoder.Encode(mf); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", mf.GetName(), err)
+		}
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"versions.json", supportVersions{
+			Exporter:      version,
+			Commit:        commit,
+			GoVersion:     runtime.Version(),
+			DriverVersion: exporter.DriverVersion,
+			NVMLVersion:   exporter.NVMLVersion,
+			CudaVersion:   exporter.CudaVersion,
+		}},
+		{"gpus.json", infos},
+		{"metrics.prom", metrics.Bytes()},
+		{"xids.json", xids},
+		{"warnings.json", warnings},
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		data, ok := f.data.([]byte)
+		if !ok {
+			if data, err = json.MarshalIndent(f.data, "", "  "); err != nil {
+				return nil, fmt.Errorf("failed to encode %s: %w", f.name, err)
+			}
+		}
+		header := &tar.Header{Name: "nvgpu-support-bundle/" + f.name, Mode: 0o644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRecordRing(t *testing.T) {
+	assert := hammy.New(t)
+	r := newRecordRing[int](3)
+	assert.Is(hammy.Number(len(r.list())).EqualTo(0))
+
+	r.add(1)
+	r.add(2)
+	got := r.list()
+	assert.Is(hammy.Number(len(got)).EqualTo(2))
+	assert.Is(hammy.Number(got[0]).EqualTo(1))
+
+	for i := 3; i <= 5; i++ {
+		r.add(i)
+	}
+	got = r.list()
+	assert.Is(hammy.Number(len(got)).EqualTo(3))
+	assert.Is(hammy.Number(got[0]).EqualTo(3))
+	assert.Is(hammy.Number(got[2]).EqualTo(5))
+}
+
+func TestWarningRecorderKeepsWarnings(t *testing.T) {
+	assert := hammy.New(t)
+	warnings := newRecordRing[logRecord](supportBundleRecords)
+	logger := slog.New(newWarningRecorder(slog.NewTextHandler(io.Discard, nil), warnings)).With("collector", "nvlink")
+
+	logger.Info("started collectors")
+	logger.Warn("failed to get NVLink state", "uuid", "GPU-0")
+
+	got := warnings.list()
+	assert.Is(hammy.Number(len(got)).EqualTo(1))
+	assert.Is(hammy.String(got[0].Message).EqualTo("failed to get NVLink state"))
+	assert.Is(hammy.String(got[0].Level).EqualTo("WARN"))
+	assert.Is(hammy.String(got[0].Attrs["collector"]).EqualTo("nvlink"))
+	assert.Is(hammy.String(got[0].Attrs["uuid"]).EqualTo("GPU-0"))
+}
+
+func TestSupportBundleHandler(t *testing.T) {
+	assert := hammy.New(t)
+	recent := newRecordRing[xidRecord](supportBundleRecords)
+	recent.add(xidRecord{Time: time.Unix(0, 0), UUID: "GPU-0", PciBusId: "0000:18:00.0", Xid: 79})
+
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: "test_gauge", Help: "Test gauge."})
+	gauge.Set(3)
+	reg.MustRegister(gauge)
+	exporter := &ExporterInfo{DriverVersion: "570.124.06", NVMLVersion: "12.570.124.06", CudaVersion: "12.8"}
+	infos := []*GpuInfo{{UUID: "GPU-0", PciBusId: "0000:18:00.0", VbiosVersion: "96.00.99.00.01"}}
+
+	rec := httptest.NewRecorder()
+	supportBundleHandler(reg, exporter, infos, recent, nil, discardLogger()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/support-bundle", nil))
+	assert.Is(hammy.Number(rec.Code).EqualTo(http.StatusOK))
+
+	gz, err := gzip.NewReader(rec.Body)
+	assert.Is(hammy.NilError(err))
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Is(hammy.NilError(err))
+		data, err := io.ReadAll(tr)
+		assert.Is(hammy.NilError(err))
+		files[header.Name] = string(data)
+	}
+
+	assert.Is(hammy.Number(len(files)).EqualTo(5))
+	assert.Is(hammy.String(files["nvgpu-support-bundle/metrics.prom"]).Contains("nvgpu_test_gauge 3"))
+	assert.Is(hammy.String(files["nvgpu-support-bundle/gpus.json"]).Contains(`"VbiosVersion": "96.00.99.00.01"`))
+	assert.Is(hammy.String(files["nvgpu-support-bundle/versions.json"]).Contains(`"driver_version": "570.124.06"`))
+	var xids []xidRecord
+	assert.Is(hammy.NilError(json.Unmarshal([]byte(files["nvgpu-support-bundle/xids.json"]), &xids)))
+	assert.Is(hammy.Number(len(xids)).EqualTo(1))
+	assert.Is(hammy.Number(xids[0].Xid).EqualTo(79))
+	assert.Is(hammy.String(files["nvgpu-support-bundle/warnings.json"]).Contains("["))
+}
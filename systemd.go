@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// watchdogMinCollectionAge is the shortest time without a completed
+// collection round after which the systemd watchdog is no longer fed, so that
+// a slow round on a short -collection-interval does not restart the exporter.
+const watchdogMinCollectionAge = time.Minute
+
+// systemdNotifier implements the sd_notify protocol: state changes are sent as
+// datagrams to the socket systemd passes in $NOTIFY_SOCKET. A nil
+// *systemdNotifier sends nothing.
+type systemdNotifier struct {
+	addr *net.UnixAddr
+	// watchdog is the WatchdogSec= of the service, 0 when it has none
+	watchdog time.Duration
+}
+
+// newSystemdNotifier returns a notifier for the environment systemd sets up,
+// or nil when $NOTIFY_SOCKET is unset.
+func newSystemdNotifier(getenv func(string) string) *systemdNotifier {
+	socket := getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract sockets are passed with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	n := &systemdNotifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+
+	// The watchdog applies to the main process only
+	if pid := getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+	if usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	return n
+}
+
+// notify sends state, e.g. READY=1, to systemd.
+func (n *systemdNotifier) notify(state string) error {
+	if n == nil {
+		return nil
+	}
+	conn, err := net.DialUnix(n.addr.Net, nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// ready tells systemd that the exporter is serving, with status as the
+// service status shown by systemctl.
+func (n *systemdNotifier) ready(status string, logger *slog.Logger) {
+	if err := n.notify("READY=1\nSTATUS=" + status); err != nil {
+		logger.Warn("failed to notify systemd", "err", err)
+	}
+}
+
+// runWatchdog sends a keepalive every half WatchdogSec= for as long as alive
+// returns nil. Once it returns an error the keepalives stop and systemd
+// restarts the service when WatchdogSec= elapses. It returns at once when the
+// service has no watchdog.
+func (n *systemdNotifier) runWatchdog(alive func() error, logger *slog.Logger) {
+	if n == nil || n.watchdog == 0 {
+		return
+	}
+	logger.Info("sending systemd watchdog keepalives", "watchdog", n.watchdog)
+
+	for range time.Tick(n.watchdog / 2) {
+		if err := alive(); err != nil {
+			logger.Error("collection loop is stuck, stopping systemd watchdog keepalives", "err", err)
+			if err := n.notify("STATUS=collection stuck: " + err.Error()); err != nil {
+				logger.Warn("failed to notify systemd", "err", err)
+			}
+			return
+		}
+		if err := n.notify("WATCHDOG=1"); err != nil {
+			logger.Warn("failed to send systemd watchdog keepalive", "err", err)
+		}
+	}
+}
+
+// collectionLiveness fails when no collection round completed for maxAge,
+// e.g. because a collector hangs in an NVML call. It passes until collection
+// starts, while NVML initializes or the exporter only serves probes.
+type collectionLiveness struct {
+	maxAge time.Duration
+	now    func() time.Time
+	// started is the Unix time in nanoseconds collection started, 0 before
+	started atomic.Int64
+}
+
+func newCollectionLiveness(maxAge time.Duration) *collectionLiveness {
+	return &collectionLiveness{maxAge: maxAge, now: time.Now}
+}
+
+// start records that NVML is initialized and collection rounds are expected.
+func (l *collectionLiveness) start() {
+	l.started.Store(l.now().UnixNano())
+}
+
+// check returns an error once the last collection round, or the start of
+// collection when no round completed yet, is older than maxAge.
+func (l *collectionLiveness) check() error {
+	started := l.started.Load()
+	if started == 0 {
+		return nil
+	}
+	if last := lastCollectionRound.Load(); last != 0 {
+		if age := l.now().Sub(time.Unix(0, last)); age > l.maxAge {
+			return fmt.Errorf("last collection round completed %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+	if age := l.now().Sub(time.Unix(0, started)); age > l.maxAge {
+		return fmt.Errorf("no collection round completed %s after collection started", age.Round(time.Second))
+	}
+	return nil
+}
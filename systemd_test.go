@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+// listenNotifySocket returns a socket standing in for systemd and the
+// environment pointing the exporter at it.
+func listenNotifySocket(t *testing.T, env map[string]string) (*net.UnixConn, func(string) string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, func(key string) string {
+		if key == "NOTIFY_SOCKET" {
+			return path
+		}
+		return env[key]
+	}
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSystemdNotifierReady(t *testing.T) {
+	assert := hammy.New(t)
+	conn, getenv := listenNotifySocket(t, nil)
+	n := newSystemdNotifier(getenv)
+
+	n.ready("collecting 8 GPUs", discardLogger())
+	assert.Is(hammy.String(readNotification(t, conn)).EqualTo("READY=1\nSTATUS=collecting 8 GPUs"))
+	assert.Is(hammy.Number(n.watchdog).EqualTo(0))
+}
+
+func TestSystemdNotifierWithoutSocket(t *testing.T) {
+	assert := hammy.New(t)
+	n := newSystemdNotifier(func(string) string { return "" })
+	assert.Is(hammy.True(n == nil))
+	assert.Is(hammy.NilError(n.notify("READY=1")))
+	n.runWatchdog(func() error { return nil }, discardLogger())
+}
+
+func TestSystemdWatchdogStopsWhenCollectionIsStuck(t *testing.T) {
+	assert := hammy.New(t)
+	conn, getenv := listenNotifySocket(t, map[string]string{"WATCHDOG_USEC": "20000"})
+	n := newSystemdNotifier(getenv)
+	assert.Is(hammy.Number(n.watchdog).EqualTo(20 * time.Millisecond))
+
+	checks := 0
+	n.runWatchdog(func() error {
+		checks++
+		if checks > 2 {
+			return errors.New("last collection round completed 5m0s ago")
+		}
+		return nil
+	}, discardLogger())
+
+	assert.Is(hammy.String(readNotification(t, conn)).EqualTo("WATCHDOG=1"))
+	assert.Is(hammy.String(readNotification(t, conn)).EqualTo("WATCHDOG=1"))
+	assert.Is(hammy.True(strings.HasPrefix(readNotification(t, conn), "STATUS=collection stuck")))
+}
+
+func TestSystemdWatchdogOfAnotherProcess(t *testing.T) {
+	assert := hammy.New(t)
+	_, getenv := listenNotifySocket(t, map[string]string{"WATCHDOG_USEC": "20000", "WATCHDOG_PID": "1"})
+	assert.Is(hammy.Number(newSystemdNotifier(getenv).watchdog).EqualTo(0))
+}
+
+func TestCollectionLiveness(t *testing.T) {
+	assert := hammy.New(t)
+	saved := lastCollectionRound.Load()
+	t.Cleanup(func() { lastCollectionRound.Store(saved) })
+	lastCollectionRound.Store(0)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	liveness := newCollectionLiveness(time.Minute)
+	liveness.now = func() time.Time { return now }
+
+	// Waiting for NVML
+	now = now.Add(time.Hour)
+	assert.Is(hammy.NilError(liveness.check()))
+
+	// A first round that never completes
+	liveness.start()
+	now = now.Add(30 * time.Second)
+	assert.Is(hammy.NilError(liveness.check()))
+	now = now.Add(time.Minute)
+	assert.Is(hammy.True(liveness.check() != nil))
+
+	lastCollectionRound.Store(now.Add(-30 * time.Second).UnixNano())
+	assert.Is(hammy.NilError(liveness.check()))
+	lastCollectionRound.Store(now.Add(-2 * time.Minute).UnixNano())
+	assert.Is(hammy.True(liveness.check() != nil))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	temperatureThreshold = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "temperature_threshold_celsius",
+			Help:      "Temperature thresholds of the GPU in degrees C: shutdown, slowdown (hardware clock slowdown), gpu_max and mem_max (maximum operating temperature of the GPU and its memory), and the acoustic_min, acoustic_max and acoustic_current target temperatures.",
+		},
+		[]string{"UUID", "pci_bus_id", "threshold"},
+	)
+
+	temperatureTargetModified = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "temperature_target_modified",
+			Help:      "Whether the target temperature of the GPU was lowered from its default, the top of the acoustic range (1) or not (0).",
+		},
+		[]string{"UUID", "pci_bus_id"},
+	)
+)
+
+// temperatureThresholds are the exported thresholds by their label.
+var temperatureThresholds = []struct {
+	threshold nvml.TemperatureThresholds
+	name      string
+}{
+	{nvml.TEMPERATURE_THRESHOLD_SHUTDOWN, "shutdown"},
+	{nvml.TEMPERATURE_THRESHOLD_SLOWDOWN, "slowdown"},
+	{nvml.TEMPERATURE_THRESHOLD_MEM_MAX, "mem_max"},
+	{nvml.TEMPERATURE_THRESHOLD_GPU_MAX, "gpu_max"},
+	{nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_MIN, "acoustic_min"},
+	{nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_CURR, "acoustic_current"},
+	{nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_MAX, "acoustic_max"},
+}
+
+// collectTemperatureThresholds exports the temperature thresholds of every
+// GPU, so that thermal alerts can compare the GPU temperature against the
+// limits of its SKU instead of a fleet-wide constant. Thresholds a GPU does
+// not report are left out.
+func collectTemperatureThresholds(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		values := make(map[nvml.TemperatureThresholds]uint32)
+		for _, t := range temperatureThresholds {
+			celsius, ret := device.GetTemperatureThreshold(t.threshold)
+			if !errors.Is(ret, nvml.SUCCESS) {
+				if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+					logger.Warn("failed to get temperature threshold", "uuid", uuid, "threshold", t.name, "error", nvml.ErrorString(ret))
+				}
+				continue
+			}
+			values[t.threshold] = celsius
+			temperatureThreshold.WithLabelValues(uuid, pciBusId, t.name).Set(float64(celsius))
+		}
+
+		// The target temperature defaults to the top of the acoustic range and
+		// can only be lowered, e.g. with nvidia-smi -gtt
+		current, hasCurrent := values[nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_CURR]
+		maxTarget, hasMax := values[nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_MAX]
+		if hasCurrent && hasMax {
+			temperatureTargetModified.WithLabelValues(uuid, pciBusId).Set(flagToGauge(current != maxTarget))
+		}
+	}
+}
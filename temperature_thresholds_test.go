@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// thresholdDevice reports fixed temperature thresholds; others are not
+// supported.
+type thresholdDevice struct {
+	fakeDevice
+	thresholds map[nvml.TemperatureThresholds]uint32
+}
+
+func (d *thresholdDevice) GetTemperatureThreshold(threshold nvml.TemperatureThresholds) (uint32, nvml.Return) {
+	celsius, ok := d.thresholds[threshold]
+	if !ok {
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+	return celsius, nvml.SUCCESS
+}
+
+func TestCollectTemperatureThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds map[nvml.TemperatureThresholds]uint32
+		series     int
+		modified   float64
+	}{
+		{
+			name: "datacenter board",
+			thresholds: map[nvml.TemperatureThresholds]uint32{
+				nvml.TEMPERATURE_THRESHOLD_SHUTDOWN: 92,
+				nvml.TEMPERATURE_THRESHOLD_SLOWDOWN: 89,
+				nvml.TEMPERATURE_THRESHOLD_GPU_MAX:  87,
+			},
+			series:   3,
+			modified: -1,
+		},
+		{
+			name: "default target",
+			thresholds: map[nvml.TemperatureThresholds]uint32{
+				nvml.TEMPERATURE_THRESHOLD_SHUTDOWN:      98,
+				nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_MIN:  65,
+				nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_CURR: 91,
+				nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_MAX:  91,
+			},
+			series:   4,
+			modified: 0,
+		},
+		{
+			name: "lowered target",
+			thresholds: map[nvml.TemperatureThresholds]uint32{
+				nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_CURR: 75,
+				nvml.TEMPERATURE_THRESHOLD_ACOUSTIC_MAX:  91,
+			},
+			series:   2,
+			modified: 1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			temperatureThreshold.Reset()
+			temperatureTargetModified.Reset()
+			t.Cleanup(temperatureThreshold.Reset)
+			t.Cleanup(temperatureTargetModified.Reset)
+
+			device := &thresholdDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, thresholds: tc.thresholds}
+			collectTemperatureThresholds([]Device{device}, discardLogger())
+
+			assert.Is(hammy.Number(testutil.CollectAndCount(temperatureThreshold)).EqualTo(tc.series))
+			if tc.modified < 0 {
+				assert.Is(hammy.Number(testutil.CollectAndCount(temperatureTargetModified)).EqualTo(0))
+			} else {
+				assert.Is(hammy.Number(testutil.ToFloat64(temperatureTargetModified.WithLabelValues("GPU-0", "0000:18:00.0"))).EqualTo(tc.modified))
+			}
+		})
+	}
+}
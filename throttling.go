@@ -2,48 +2,200 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	clockEventDurations = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	clockEventDurations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "clocks_event_duration_cumulative_total",
-			Help:      "Accumulated time (nanoseconds) spent throttled per NVML clock event reason.",
+			Help:      "Accumulated time (nanoseconds) spent throttled per NVML clock event reason, monotonic across driver reloads.",
 		},
 		[]string{"UUID", "pci_bus_id", "reason"},
 	)
 
-	clockEventReasonFields = []struct {
-		fieldID uint32
-		reason  string
-	}{
-		{fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP, reason: "sw_power_capping"},
-		{fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_SYNC_BOOST, reason: "sync_boost"},
-		{fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_THERM_SLOWDOWN, reason: "sw_thermal_slowdown"},
-		{fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_HW_THERM_SLOWDOWN, reason: "hw_thermal_slowdown"},
-		{fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_HW_POWER_BRAKE_SLOWDOWN, reason: "hw_power_braking"},
-	}
+	clockEventDomainDurations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clocks_event_domain_duration_cumulative_total",
+			Help:      "Accumulated time (nanoseconds) spent throttled per NVML clock event reason and affected clock domain (sm, memory), only for reasons given a per-domain field ID in -clock-event-reasons, as NVML defines none. Monotonic across driver reloads.",
+		},
+		[]string{"UUID", "pci_bus_id", "reason", "domain"},
+	)
+
+	clockEventCounterResets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clocks_event_counter_resets_total",
+			Help:      "Number of times the NVML clock event duration went backwards (driver reload, GPU reset) and was re-baselined.",
+		},
+		[]string{"UUID", "pci_bus_id", "reason"},
+	)
+
+	clockEventActiveRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clocks_event_active_ratio",
+			Help:      "Fraction of the last collection interval (0-1) spent throttled per NVML clock event reason.",
+		},
+		[]string{"UUID", "pci_bus_id", "reason"},
+	)
+
+	clockEventActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clocks_event_active",
+			Help:      "Whether the NVML clock event reason held the clocks down when last collected (1) or not (0).",
+		},
+		[]string{"UUID", "pci_bus_id", "reason"},
+	)
 )
 
+// clockEventReason is an NVML clock event reason. Reasons with a field ID
+// also report the accumulated time they were active; the others are only
+// available as the current reasons bit mask. A reason with a domain counts
+// the time it throttled one clock domain only.
+type clockEventReason struct {
+	name    string
+	fieldID uint32
+	mask    uint64
+	domain  string
+}
+
+// key identifies the reason among the selected ones.
+func (r clockEventReason) key() string {
+	if r.domain == "" {
+		return r.name
+	}
+	return r.name + "@" + r.domain
+}
+
+// clockEventDomains are the clock domains a reason can be attributed to.
+var clockEventDomains = []string{"sm", "memory"}
+
+// clockEventReasons are the reasons known to -clock-event-reasons.
+var clockEventReasons = []clockEventReason{
+	{name: "sw_power_capping", fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP, mask: nvml.ClocksEventReasonSwPowerCap},
+	{name: "sync_boost", fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_SYNC_BOOST, mask: nvml.ClocksEventReasonSyncBoost},
+	{name: "sw_thermal_slowdown", fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_THERM_SLOWDOWN, mask: nvml.ClocksEventReasonSwThermalSlowdown},
+	{name: "hw_thermal_slowdown", fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_HW_THERM_SLOWDOWN, mask: nvml.ClocksThrottleReasonHwThermalSlowdown},
+	{name: "hw_power_braking", fieldID: nvml.FI_DEV_CLOCKS_EVENT_REASON_HW_POWER_BRAKE_SLOWDOWN, mask: nvml.ClocksThrottleReasonHwPowerBrakeSlowdown},
+	{name: "hw_slowdown", mask: nvml.ClocksThrottleReasonHwSlowdown},
+	{name: "gpu_idle", mask: nvml.ClocksEventReasonGpuIdle},
+	{name: "applications_clocks_setting", mask: nvml.ClocksEventReasonApplicationsClocksSetting},
+	{name: "display_clocks_setting", mask: nvml.ClocksEventReasonDisplayClockSetting},
+}
+
+// defaultClockEventReasons are the throttling reasons collected by default.
+// GPU idle and the clock settings lower the clocks on purpose and would
+// dominate throttling dashboards.
+var defaultClockEventReasons = []string{"sw_power_capping", "sync_boost", "sw_thermal_slowdown", "hw_thermal_slowdown", "hw_power_braking"}
+
+// clockEventReasonList selects the clock event reasons to collect, usable as
+// a flag.Value. Entries are reason names, all for every known reason, or
+// name=<field ID> for a duration field of a newer driver that this exporter
+// does not know yet. name@<domain>=<field ID> reads a field that only counts
+// the time the reason throttled the sm or memory clock.
+type clockEventReasonList []clockEventReason
+
+func newClockEventReasonList(names ...string) clockEventReasonList {
+	var list clockEventReasonList
+	if err := list.Set(strings.Join(names, ",")); err != nil {
+		panic(err)
+	}
+	return list
+}
+
+func (l *clockEventReasonList) String() string {
+	parts := make([]string, 0, len(*l))
+	for _, reason := range *l {
+		parts = append(parts, reason.key())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *clockEventReasonList) Set(value string) error {
+	var list clockEventReasonList
+	seen := make(map[string]bool)
+	add := func(reason clockEventReason) {
+		if !seen[reason.key()] {
+			seen[reason.key()] = true
+			list = append(list, reason)
+		}
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+		case part == "all":
+			for _, reason := range clockEventReasons {
+				add(reason)
+			}
+		case strings.Contains(part, "="):
+			name, id, _ := strings.Cut(part, "=")
+			fieldID, err := strconv.ParseUint(id, 10, 32)
+			if name == "" || err != nil {
+				return fmt.Errorf("invalid clock event reason %q: must be name=<field ID>", part)
+			}
+			name, domain, _ := strings.Cut(name, "@")
+			if domain != "" && !slices.Contains(clockEventDomains, domain) {
+				return fmt.Errorf("invalid clock domain %q of clock event reason %q: must be one of %s", domain, part, strings.Join(clockEventDomains, ", "))
+			}
+			add(clockEventReason{name: name, fieldID: uint32(fieldID), domain: domain})
+		default:
+			i := slices.IndexFunc(clockEventReasons, func(r clockEventReason) bool { return r.name == part })
+			if i < 0 {
+				return fmt.Errorf("unknown clock event reason %q", part)
+			}
+			add(clockEventReasons[i])
+		}
+	}
+	*l = list
+	return nil
+}
+
 type clockEventCollector struct {
+	reasons  []clockEventReason
+	counters *counterTracker
+	// exported holds the value of nvgpu_clocks_event_duration_cumulative_total
+	// per GPU and reason, which only moves forward by the delta of each reading
+	exported   map[string]float64
 	mu         sync.Mutex
 	logCounter map[string]int
 	iterations int
+	previous   map[string]clockEventSample
+	now        func() time.Time
 }
 
-func newClockEventCollector() *clockEventCollector {
+// clockEventSample is a cumulative clock event duration read at a point in time.
+type clockEventSample struct {
+	nanoseconds float64
+	at          time.Time
+}
+
+func newClockEventCollector(reasons []clockEventReason) *clockEventCollector {
 	return &clockEventCollector{
+		reasons:    reasons,
+		counters:   newCounterTracker(),
+		exported:   make(map[string]float64),
 		logCounter: make(map[string]int),
+		previous:   make(map[string]clockEventSample),
+		now:        time.Now,
 	}
 }
 
-func (c *clockEventCollector) collectClockEventReasons(devices []nvml.Device, logger *slog.Logger) {
+func (c *clockEventCollector) collectClockEventReasons(devices []Device, logger *slog.Logger) {
 	c.mu.Lock()
 	c.iterations++
 	if c.iterations%1440 == 0 {
@@ -51,6 +203,8 @@ func (c *clockEventCollector) collectClockEventReasons(devices []nvml.Device, lo
 	}
 	c.mu.Unlock()
 
+	now := c.now()
+
 	for _, device := range devices {
 		uuid, ret := device.GetUUID()
 		if !errors.Is(ret, nvml.SUCCESS) {
@@ -63,9 +217,14 @@ func (c *clockEventCollector) collectClockEventReasons(devices []nvml.Device, lo
 			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
 			continue
 		}
-		pciBusId := pciBusIdToString(pciInfo.BusIdLegacy)
+		pciBusId := pciBusID(pciInfo)
 
-		fieldValues, index := buildClockEventRequests()
+		c.collectActiveReasons(device, uuid, pciBusId, logger)
+
+		fieldValues, index := buildClockEventRequests(c.reasons)
+		if len(fieldValues) == 0 {
+			continue
+		}
 
 		ret = device.GetFieldValues(fieldValues)
 		if !errors.Is(ret, nvml.SUCCESS) {
@@ -75,12 +234,15 @@ func (c *clockEventCollector) collectClockEventReasons(devices []nvml.Device, lo
 			continue
 		}
 
-		for _, field := range clockEventReasonFields {
-			fv := fieldValues[index[field.fieldID]]
+		for _, reason := range c.reasons {
+			if reason.fieldID == 0 {
+				continue
+			}
+			fv := fieldValues[index[reason.fieldID]]
 			if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.SUCCESS) {
 				if !errors.Is(nvml.Return(fv.NvmlReturn), nvml.ERROR_NOT_SUPPORTED) {
-					if c.shouldLogClockEventError(field.reason, uuid, nvml.Return(fv.NvmlReturn)) {
-						logger.Warn("clock event field unavailable", "reason", field.reason, "uuid", uuid, "error", nvml.ErrorString(nvml.Return(fv.NvmlReturn)))
+					if c.shouldLogClockEventError(reason.name, uuid, nvml.Return(fv.NvmlReturn)) {
+						logger.Warn("clock event field unavailable", "reason", reason.name, "uuid", uuid, "error", nvml.ErrorString(nvml.Return(fv.NvmlReturn)))
 					}
 				}
 				continue
@@ -88,19 +250,89 @@ func (c *clockEventCollector) collectClockEventReasons(devices []nvml.Device, lo
 
 			durationNanoseconds, err := clockEventFieldValueToNanoseconds(fv)
 			if err != nil {
-				logger.Warn("failed to decode clock event field", "reason", field.reason, "uuid", uuid, "error", err)
+				logger.Warn("failed to decode clock event field", "reason", reason.name, "uuid", uuid, "error", err)
+				continue
+			}
+
+			c.addDuration(uuid, pciBusId, reason, durationNanoseconds, logger)
+			if reason.domain != "" {
 				continue
 			}
 
-			clockEventDurations.WithLabelValues(
-				uuid,
-				pciBusId,
-				field.reason,
-			).Set(durationNanoseconds)
+			if ratio, ok := c.activeRatio(uuid+"|"+reason.name, durationNanoseconds, now); ok {
+				clockEventActiveRatio.WithLabelValues(uuid, pciBusId, reason.name).Set(ratio)
+			}
 		}
 	}
 }
 
+// addDuration advances nvgpu_clocks_event_duration_cumulative_total of reason,
+// or nvgpu_clocks_event_domain_duration_cumulative_total of a reason with a
+// domain, to the raw cumulative reading nanoseconds. A reading below the
+// previous one means the driver restarted the count, which continues from the
+// last value.
+func (c *clockEventCollector) addDuration(uuid, pciBusId string, reason clockEventReason, nanoseconds float64, logger *slog.Logger) {
+	key := uuid + "|" + reason.key()
+	value, reset := c.counters.observe(key, nanoseconds)
+	if reset {
+		// The resets counter has no domain label; the whole-GPU reading of
+		// the reason counts the driver reload
+		if reason.domain == "" {
+			clockEventCounterResets.WithLabelValues(uuid, pciBusId, reason.name).Inc()
+		}
+		logger.Info("clock event counter reset detected", "uuid", uuid, "reason", reason.key())
+	}
+
+	c.mu.Lock()
+	delta := value - c.exported[key]
+	c.exported[key] = value
+	c.mu.Unlock()
+	if reason.domain == "" {
+		clockEventDurations.WithLabelValues(uuid, pciBusId, reason.name).Add(delta)
+	} else {
+		clockEventDomainDurations.WithLabelValues(uuid, pciBusId, reason.name, reason.domain).Add(delta)
+	}
+}
+
+// collectActiveReasons exports which of the reasons currently hold the clocks
+// of device down. Reasons configured by field ID have no bit in the mask.
+func (c *clockEventCollector) collectActiveReasons(device Device, uuid, pciBusId string, logger *slog.Logger) {
+	active, ret := device.GetCurrentClocksEventReasons()
+	if !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get current clock event reasons", "uuid", uuid, "error", nvml.ErrorString(ret))
+		}
+		return
+	}
+
+	for _, reason := range c.reasons {
+		if reason.mask == 0 {
+			continue
+		}
+		clockEventActive.WithLabelValues(uuid, pciBusId, reason.name).Set(flagToGauge(active&reason.mask != 0))
+	}
+}
+
+// activeRatio returns the fraction of wall time spent throttled since the
+// previous reading of key. It reports false for the first reading and after a
+// counter reset, when no meaningful delta exists.
+func (c *clockEventCollector) activeRatio(key string, nanoseconds float64, now time.Time) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.previous[key]
+	c.previous[key] = clockEventSample{nanoseconds: nanoseconds, at: now}
+	if !ok || nanoseconds < prev.nanoseconds {
+		return 0, false
+	}
+
+	elapsed := float64(now.Sub(prev.at).Nanoseconds())
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return min((nanoseconds-prev.nanoseconds)/elapsed, 1), true
+}
+
 func clockEventFieldValueToNanoseconds(fv nvml.FieldValue) (float64, error) {
 	value, err := fieldValueToFloat64(fv)
 	if err != nil {
@@ -118,14 +350,17 @@ func (c *clockEventCollector) shouldLogClockEventError(reason, uuid string, ret
 	return count%60 == 0
 }
 
-func buildClockEventRequests() ([]nvml.FieldValue, map[uint32]int) {
-	values := make([]nvml.FieldValue, 0, len(clockEventReasonFields))
-	index := make(map[uint32]int, len(clockEventReasonFields))
+func buildClockEventRequests(reasons []clockEventReason) ([]nvml.FieldValue, map[uint32]int) {
+	values := make([]nvml.FieldValue, 0, len(reasons))
+	index := make(map[uint32]int, len(reasons))
 
-	for _, field := range clockEventReasonFields {
-		index[field.fieldID] = len(values)
+	for _, reason := range reasons {
+		if reason.fieldID == 0 {
+			continue
+		}
+		index[reason.fieldID] = len(values)
 		values = append(values, nvml.FieldValue{
-			FieldId: field.fieldID,
+			FieldId: reason.fieldID,
 		})
 	}
 
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectClockEventReasonsActiveRatio(t *testing.T) {
+	assert := hammy.New(t)
+	clockEventDurations.Reset()
+	clockEventActiveRatio.Reset()
+	t.Cleanup(clockEventDurations.Reset)
+	t.Cleanup(clockEventActiveRatio.Reset)
+
+	powerCap := nvlinkFieldKey{fieldId: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP}
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		fields:   map[nvlinkFieldKey]uint64{powerCap: uint64(5 * time.Second)},
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	collector := newClockEventCollector(newClockEventReasonList(defaultClockEventReasons...))
+	collector.now = func() time.Time { return now }
+
+	// The first reading has no delta to derive a ratio from
+	collector.collectClockEventReasons([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(testutil.CollectAndCount(clockEventActiveRatio)).EqualTo(0))
+
+	// 15s throttled out of a 60s interval
+	now = now.Add(time.Minute)
+	device.fields[powerCap] = uint64(20 * time.Second)
+	collector.collectClockEventReasons([]Device{device}, discardLogger())
+	ratio := testutil.ToFloat64(clockEventActiveRatio.WithLabelValues("GPU-0", "0000:18:00.0", "sw_power_capping"))
+	assert.Is(hammy.Number(ratio).EqualTo(0.25))
+	assert.Is(hammy.Number(testutil.CollectAndCount(clockEventActiveRatio)).EqualTo(1))
+
+	// A counter reset keeps the previous ratio until the next delta
+	now = now.Add(time.Minute)
+	device.fields[powerCap] = uint64(time.Second)
+	collector.collectClockEventReasons([]Device{device}, discardLogger())
+	ratio = testutil.ToFloat64(clockEventActiveRatio.WithLabelValues("GPU-0", "0000:18:00.0", "sw_power_capping"))
+	assert.Is(hammy.Number(ratio).EqualTo(0.25))
+
+	now = now.Add(time.Minute)
+	device.fields[powerCap] = uint64(time.Minute + time.Second)
+	collector.collectClockEventReasons([]Device{device}, discardLogger())
+	ratio = testutil.ToFloat64(clockEventActiveRatio.WithLabelValues("GPU-0", "0000:18:00.0", "sw_power_capping"))
+	assert.Is(hammy.Number(ratio).EqualTo(1))
+}
+
+func TestClockEventReasonListSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "trimmed", value: "sw_power_capping, gpu_idle", want: "sw_power_capping,gpu_idle"},
+		{name: "all", value: "all", want: "sw_power_capping,sync_boost,sw_thermal_slowdown,hw_thermal_slowdown,hw_power_braking,hw_slowdown,gpu_idle,applications_clocks_setting,display_clocks_setting"},
+		{name: "duplicates", value: "gpu_idle,all", want: "gpu_idle,sw_power_capping,sync_boost,sw_thermal_slowdown,hw_thermal_slowdown,hw_power_braking,hw_slowdown,applications_clocks_setting,display_clocks_setting"},
+		{name: "field ID", value: "sync_boost,future_reason=260", want: "sync_boost,future_reason"},
+		{name: "domains", value: "hw_thermal_slowdown,hw_thermal_slowdown@memory=270,hw_thermal_slowdown@sm=271", want: "hw_thermal_slowdown,hw_thermal_slowdown@memory,hw_thermal_slowdown@sm"},
+		{name: "unknown", value: "gpu_asleep", wantErr: true},
+		{name: "unknown domain", value: "hw_thermal_slowdown@video=270", wantErr: true},
+		{name: "invalid field ID", value: "future_reason=x", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			var list clockEventReasonList
+			err := list.Set(tc.value)
+			if tc.wantErr {
+				assert.Is(hammy.True(err != nil))
+				return
+			}
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.String(list.String()).EqualTo(tc.want))
+		})
+	}
+}
+
+func TestCollectClockEventReasonsActive(t *testing.T) {
+	assert := hammy.New(t)
+	clockEventDurations.Reset()
+	clockEventActive.Reset()
+	t.Cleanup(clockEventDurations.Reset)
+	t.Cleanup(clockEventActive.Reset)
+
+	device := &fakeDevice{
+		uuid:         "GPU-0",
+		pciBusId:     "0000:18:00.0",
+		fields:       map[nvlinkFieldKey]uint64{{fieldId: 260}: uint64(time.Second)},
+		clockReasons: nvml.ClocksEventReasonGpuIdle | nvml.ClocksEventReasonSwPowerCap,
+	}
+
+	reasons := newClockEventReasonList("gpu_idle", "sync_boost", "sw_power_capping", "future_reason=260")
+	newClockEventCollector(reasons).collectClockEventReasons([]Device{device}, discardLogger())
+
+	active := func(reason string) float64 {
+		return testutil.ToFloat64(clockEventActive.WithLabelValues("GPU-0", "0000:18:00.0", reason))
+	}
+	assert.Is(hammy.Number(active("gpu_idle")).EqualTo(1))
+	assert.Is(hammy.Number(active("sw_power_capping")).EqualTo(1))
+	assert.Is(hammy.Number(active("sync_boost")).EqualTo(0))
+	// Reasons configured by field ID have no bit in the mask
+	assert.Is(hammy.Number(testutil.CollectAndCount(clockEventActive)).EqualTo(3))
+	duration := testutil.ToFloat64(clockEventDurations.WithLabelValues("GPU-0", "0000:18:00.0", "future_reason"))
+	assert.Is(hammy.Number(duration).EqualTo(float64(time.Second)))
+}
+
+func TestCollectClockEventReasonsDurationCounter(t *testing.T) {
+	assert := hammy.New(t)
+	clockEventDurations.Reset()
+	clockEventCounterResets.Reset()
+	t.Cleanup(clockEventDurations.Reset)
+	t.Cleanup(clockEventCounterResets.Reset)
+
+	powerCap := nvlinkFieldKey{fieldId: nvml.FI_DEV_CLOCKS_EVENT_REASON_SW_POWER_CAP}
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0", fields: map[nvlinkFieldKey]uint64{}}
+	collector := newClockEventCollector(newClockEventReasonList("sw_power_capping"))
+
+	// The driver reloads after 20s and counts again from zero
+	for _, raw := range []time.Duration{5 * time.Second, 20 * time.Second, time.Second, 3 * time.Second} {
+		device.fields[powerCap] = uint64(raw)
+		collector.collectClockEventReasons([]Device{device}, discardLogger())
+	}
+
+	duration := testutil.ToFloat64(clockEventDurations.WithLabelValues("GPU-0", "0000:18:00.0", "sw_power_capping"))
+	assert.Is(hammy.Number(duration).EqualTo(float64(23 * time.Second)))
+	resets := testutil.ToFloat64(clockEventCounterResets.WithLabelValues("GPU-0", "0000:18:00.0", "sw_power_capping"))
+	assert.Is(hammy.Number(resets).EqualTo(1))
+}
+
+func TestCollectClockEventReasonsDomainDuration(t *testing.T) {
+	assert := hammy.New(t)
+	clockEventDurations.Reset()
+	clockEventDomainDurations.Reset()
+	clockEventActiveRatio.Reset()
+	t.Cleanup(clockEventDurations.Reset)
+	t.Cleanup(clockEventDomainDurations.Reset)
+	t.Cleanup(clockEventActiveRatio.Reset)
+
+	device := &fakeDevice{
+		uuid:     "GPU-0",
+		pciBusId: "0000:18:00.0",
+		fields: map[nvlinkFieldKey]uint64{
+			{fieldId: nvml.FI_DEV_CLOCKS_EVENT_REASON_HW_THERM_SLOWDOWN}: uint64(3 * time.Second),
+			{fieldId: 270}: uint64(2 * time.Second),
+			{fieldId: 271}: uint64(time.Second),
+		},
+	}
+	reasons := newClockEventReasonList("hw_thermal_slowdown", "hw_thermal_slowdown@memory=270", "hw_thermal_slowdown@sm=271")
+	collector := newClockEventCollector(reasons)
+	collector.collectClockEventReasons([]Device{device}, discardLogger())
+	collector.collectClockEventReasons([]Device{device}, discardLogger())
+
+	domain := func(name string) float64 {
+		return testutil.ToFloat64(clockEventDomainDurations.WithLabelValues("GPU-0", "0000:18:00.0", "hw_thermal_slowdown", name))
+	}
+	assert.Is(hammy.Number(domain("memory")).EqualTo(float64(2 * time.Second)))
+	assert.Is(hammy.Number(domain("sm")).EqualTo(float64(time.Second)))
+	// The whole-GPU reading keeps its own series
+	total := testutil.ToFloat64(clockEventDurations.WithLabelValues("GPU-0", "0000:18:00.0", "hw_thermal_slowdown"))
+	assert.Is(hammy.Number(total).EqualTo(float64(3 * time.Second)))
+	assert.Is(hammy.Number(testutil.CollectAndCount(clockEventDurations)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(clockEventActiveRatio)).EqualTo(1))
+}
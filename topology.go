@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gpuTopology = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "gpu_topology",
+			Help:      "Connection between two GPUs as shown by nvidia-smi topo -m, always 1: NV<n> for n NVLinks, otherwise the closest common PCIe ancestor (PIX, PXB, PHB, NODE, SYS).",
+		},
+		[]string{"gpu_id", "peer_gpu_id", "connection"},
+	)
+
+	gpuTopologyID = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "gpu_topology_id",
+			Help:      "Maps the gpu_id of nvgpu_gpu_topology to the UUID of the GPU, always 1.",
+		},
+		[]string{"UUID", "pci_bus_id", "gpu_id"},
+	)
+
+	topologyChanges = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "topology_changes_total",
+			Help:      "Number of times the GPU topology changed since the exporter started, e.g. NVLinks retraining to a different layout.",
+		},
+	)
+)
+
+// Identities of GPUs in nvgpu_gpu_topology accepted by -topology-gpu-id.
+const (
+	topologyIDIndex  = "index"
+	topologyIDPci    = "pci"
+	topologyIDModule = "module"
+)
+
+// topologyIDMode selects the gpu_id of nvgpu_gpu_topology, usable as a
+// flag.Value.
+type topologyIDMode string
+
+func (m *topologyIDMode) String() string {
+	return string(*m)
+}
+
+func (m *topologyIDMode) Set(value string) error {
+	switch value {
+	case topologyIDIndex, topologyIDPci, topologyIDModule:
+		*m = topologyIDMode(value)
+		return nil
+	default:
+		return fmt.Errorf("invalid GPU identity %q: must be %q, %q or %q", value, topologyIDIndex, topologyIDPci, topologyIDModule)
+	}
+}
+
+// topologyLevels names the NVML common ancestor levels like nvidia-smi.
+var topologyLevels = map[nvml.GpuTopologyLevel]string{
+	nvml.TOPOLOGY_SINGLE:     "PIX",
+	nvml.TOPOLOGY_MULTIPLE:   "PXB",
+	nvml.TOPOLOGY_HOSTBRIDGE: "PHB",
+	nvml.TOPOLOGY_NODE:       "NODE",
+	nvml.TOPOLOGY_SYSTEM:     "SYS",
+}
+
+// topologyGPU is a GPU and the NVLinks it has to other GPUs.
+type topologyGPU struct {
+	device   Device
+	id       string
+	uuid     string
+	pciBusId string
+	// peers counts the NVLinks to each local GPU by PCI bus ID
+	peers map[string]int
+	// switchLinks counts the NVLinks to NVSwitches, which connect the GPU to
+	// every other GPU attached to them
+	switchLinks int
+}
+
+// topologyLink is one series of nvgpu_gpu_topology.
+type topologyLink struct {
+	gpuID, peerGpuID, connection string
+}
+
+// topologyCollector exports the GPU topology matrix. The PCIe common
+// ancestors of a pair of GPUs cannot change while both stay on the bus, so
+// they are queried once per pair; the NVLink layout is read every round. The
+// series are only replaced when the topology changes.
+type topologyCollector struct {
+	idMode topologyIDMode
+	// modules maps the UUID of every GPU to its platform module ID
+	modules   map[string]string
+	ancestors map[[2]string]nvml.GpuTopologyLevel
+	hash      uint64
+	collected bool
+}
+
+func newTopologyCollector(idMode topologyIDMode, infos []*GpuInfo) *topologyCollector {
+	modules := make(map[string]string, len(infos))
+	for _, info := range infos {
+		modules[info.UUID] = info.ModuleId
+	}
+
+	return &topologyCollector{
+		idMode:    idMode,
+		modules:   modules,
+		ancestors: make(map[[2]string]nvml.GpuTopologyLevel),
+	}
+}
+
+// gpuID identifies the GPU at enumeration index with uuid and pciBusId in
+// nvgpu_gpu_topology. The index changes when a GPU before it falls off the
+// bus; the PCI bus ID and module ID do not. GPUs without a module ID, i.e.
+// without platform info, fall back to the PCI bus ID.
+func (c *topologyCollector) gpuID(index int, uuid, pciBusId string) string {
+	switch c.idMode {
+	case topologyIDPci:
+		return pciBusId
+	case topologyIDModule:
+		if module, ok := c.modules[uuid]; ok && module != "unknown" {
+			return "module" + module
+		}
+		return pciBusId
+	default:
+		return fmt.Sprintf("GPU%d", index)
+	}
+}
+
+// collectTopology reads the topology of devices and, when it differs from the
+// last round, replaces nvgpu_gpu_topology and counts the change.
+func (c *topologyCollector) collectTopology(devices []Device, logger *slog.Logger) {
+	var gpus []*topologyGPU
+	for i, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		gpu := &topologyGPU{
+			device:   device,
+			id:       c.gpuID(i, uuid, pciBusID(pciInfo)),
+			uuid:     uuid,
+			pciBusId: pciBusID(pciInfo),
+			peers:    make(map[string]int),
+		}
+		readTopologyLinks(gpu)
+		gpus = append(gpus, gpu)
+	}
+
+	var links []topologyLink
+	for _, a := range gpus {
+		for _, b := range gpus {
+			if a == b {
+				continue
+			}
+			if connection, ok := c.connection(a, b, logger); ok {
+				links = append(links, topologyLink{a.id, b.id, connection})
+			}
+		}
+	}
+
+	h := fnv.New64a()
+	for _, gpu := range gpus {
+		fmt.Fprintf(h, "%s|%s|%s\n", gpu.uuid, gpu.pciBusId, gpu.id)
+	}
+	for _, link := range links {
+		fmt.Fprintf(h, "%s|%s|%s\n", link.gpuID, link.peerGpuID, link.connection)
+	}
+	hash := h.Sum64()
+	if c.collected && hash == c.hash {
+		return
+	}
+	if c.collected {
+		topologyChanges.Inc()
+		logger.Info("GPU topology changed", "gpus", len(gpus), "links", len(links))
+	}
+	c.hash, c.collected = hash, true
+
+	gpuTopology.Reset()
+	gpuTopologyID.Reset()
+	for _, gpu := range gpus {
+		gpuTopologyID.WithLabelValues(gpu.uuid, gpu.pciBusId, gpu.id).Set(1)
+	}
+	for _, link := range links {
+		gpuTopology.WithLabelValues(link.gpuID, link.peerGpuID, link.connection).Set(1)
+	}
+}
+
+// connection describes how a reaches b: over NVLink, directly or through
+// NVSwitches, or else through the closest common PCIe ancestor.
+func (c *topologyCollector) connection(a, b *topologyGPU, logger *slog.Logger) (string, bool) {
+	links := a.peers[strings.ToUpper(b.pciBusId)]
+	if a.switchLinks > 0 && b.switchLinks > 0 {
+		links += min(a.switchLinks, b.switchLinks)
+	}
+	if links > 0 {
+		return fmt.Sprintf("NV%d", links), true
+	}
+
+	key := [2]string{a.pciBusId, b.pciBusId}
+	level, ok := c.ancestors[key]
+	if !ok {
+		var ret nvml.Return
+		level, ret = a.device.GetTopologyCommonAncestor(b.device)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+				logger.Warn("failed to get topology common ancestor", "uuid", a.uuid, "peer", b.uuid, "error", nvml.ErrorString(ret))
+			}
+			return "", false
+		}
+		c.ancestors[key] = level
+	}
+
+	name, ok := topologyLevels[level]
+	return name, ok
+}
+
+// readTopologyLinks counts the active NVLinks of gpu per remote GPU and to
+// NVSwitches.
+func readTopologyLinks(gpu *topologyGPU) {
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		state, ret := gpu.device.GetNvLinkState(link)
+		if !errors.Is(ret, nvml.SUCCESS) || state != nvml.FEATURE_ENABLED {
+			continue
+		}
+
+		deviceType, ret := gpu.device.GetNvLinkRemoteDeviceType(link)
+		if !errors.Is(ret, nvml.SUCCESS) {
+			continue
+		}
+		switch deviceType {
+		case nvml.NVLINK_DEVICE_TYPE_SWITCH:
+			gpu.switchLinks++
+		case nvml.NVLINK_DEVICE_TYPE_GPU:
+			pciInfo, ret := gpu.device.GetNvLinkRemotePciInfo(link)
+			if errors.Is(ret, nvml.SUCCESS) {
+				gpu.peers[strings.ToUpper(pciBusID(pciInfo))]++
+			}
+		}
+	}
+}
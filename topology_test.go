@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectTopology(t *testing.T) {
+	assert := hammy.New(t)
+	reset := func() {
+		gpuTopology.Reset()
+		gpuTopologyID.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+	changes := testutil.ToFloat64(topologyChanges)
+
+	gpu := func(id string) fakePeer {
+		return fakePeer{pciBusId: id, deviceType: nvml.NVLINK_DEVICE_TYPE_GPU}
+	}
+	gpu0 := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0",
+		links:     map[int]bool{0: true, 1: true},
+		peers:     map[int]fakePeer{0: gpu("0000:28:00.0"), 1: gpu("0000:28:00.0")},
+		ancestors: map[string]nvml.GpuTopologyLevel{"GPU-1": nvml.TOPOLOGY_SINGLE, "GPU-2": nvml.TOPOLOGY_SYSTEM},
+	}
+	gpu1 := &fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0",
+		links:     map[int]bool{0: true, 1: true},
+		peers:     map[int]fakePeer{0: gpu("0000:18:00.0"), 1: gpu("0000:18:00.0")},
+		ancestors: map[string]nvml.GpuTopologyLevel{"GPU-0": nvml.TOPOLOGY_SINGLE, "GPU-2": nvml.TOPOLOGY_NODE},
+	}
+	gpu2 := &fakeDevice{uuid: "GPU-2", pciBusId: "0000:38:00.0",
+		ancestors: map[string]nvml.GpuTopologyLevel{"GPU-0": nvml.TOPOLOGY_SYSTEM, "GPU-1": nvml.TOPOLOGY_NODE},
+	}
+	devices := []Device{gpu0, gpu1, gpu2}
+
+	collector := newTopologyCollector(topologyIDIndex, nil)
+	collector.collectTopology(devices, discardLogger())
+	collector.collectTopology(devices, discardLogger())
+
+	for _, series := range [][3]string{
+		{"GPU0", "GPU1", "NV2"},
+		{"GPU1", "GPU0", "NV2"},
+		{"GPU0", "GPU2", "SYS"},
+		{"GPU2", "GPU1", "NODE"},
+	} {
+		assert.Is(hammy.Number(testutil.ToFloat64(gpuTopology.WithLabelValues(series[:]...))).EqualTo(1))
+	}
+	assert.Is(hammy.Number(testutil.CollectAndCount(gpuTopology)).EqualTo(6))
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuTopologyID.WithLabelValues("GPU-2", "0000:38:00.0", "GPU2"))).EqualTo(1))
+	// An unchanged topology is not counted as a change
+	assert.Is(hammy.Number(testutil.ToFloat64(topologyChanges) - changes).EqualTo(0))
+
+	// An NVLink between GPU0 and GPU1 goes down
+	gpu0.links[1] = false
+	gpu1.links[1] = false
+	collector.collectTopology(devices, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuTopology.WithLabelValues("GPU0", "GPU1", "NV1"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(gpuTopology)).EqualTo(6))
+	assert.Is(hammy.Number(testutil.ToFloat64(topologyChanges) - changes).EqualTo(1))
+
+	// Without NVLinks the pair falls back to its PCIe common ancestor
+	gpu0.links = nil
+	gpu1.links = nil
+	collector.collectTopology(devices, discardLogger())
+
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuTopology.WithLabelValues("GPU0", "GPU1", "PIX"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(gpuTopology)).EqualTo(6))
+}
+
+func TestCollectTopologyNVSwitch(t *testing.T) {
+	assert := hammy.New(t)
+	gpuTopology.Reset()
+	t.Cleanup(gpuTopology.Reset)
+
+	nvswitch := fakePeer{deviceType: nvml.NVLINK_DEVICE_TYPE_SWITCH}
+	gpu := func(uuid, pciBusId string, links int) *fakeDevice {
+		d := &fakeDevice{uuid: uuid, pciBusId: pciBusId, links: map[int]bool{}, peers: map[int]fakePeer{}}
+		for link := 0; link < links; link++ {
+			d.links[link] = true
+			d.peers[link] = nvswitch
+		}
+		return d
+	}
+
+	newTopologyCollector(topologyIDIndex, nil).collectTopology([]Device{gpu("GPU-0", "0000:18:00.0", 18), gpu("GPU-1", "0000:28:00.0", 17)}, discardLogger())
+
+	// GPUs reach each other through the switches over the links both have
+	assert.Is(hammy.Number(testutil.ToFloat64(gpuTopology.WithLabelValues("GPU0", "GPU1", "NV17"))).EqualTo(1))
+	assert.Is(hammy.Number(testutil.CollectAndCount(gpuTopology)).EqualTo(2))
+}
+
+func TestCollectTopologyGpuID(t *testing.T) {
+	tests := []struct {
+		mode topologyIDMode
+		want [2]string
+	}{
+		{topologyIDIndex, [2]string{"GPU0", "GPU1"}},
+		{topologyIDPci, [2]string{"0000:28:00.0", "0000:38:00.0"}},
+		// GPU-2 has no platform info
+		{topologyIDModule, [2]string{"module2", "0000:38:00.0"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			assert := hammy.New(t)
+			gpuTopology.Reset()
+			t.Cleanup(gpuTopology.Reset)
+
+			// GPU-0 fell off the bus, shifting the enumeration index of the others
+			devices := []Device{
+				&fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0", ancestors: map[string]nvml.GpuTopologyLevel{"GPU-2": nvml.TOPOLOGY_NODE}},
+				&fakeDevice{uuid: "GPU-2", pciBusId: "0000:38:00.0", ancestors: map[string]nvml.GpuTopologyLevel{"GPU-1": nvml.TOPOLOGY_NODE}},
+			}
+			infos := []*GpuInfo{{UUID: "GPU-1", ModuleId: "2"}, {UUID: "GPU-2", ModuleId: "unknown"}}
+			newTopologyCollector(tc.mode, infos).collectTopology(devices, discardLogger())
+
+			assert.Is(hammy.Number(testutil.ToFloat64(gpuTopology.WithLabelValues(tc.want[0], tc.want[1], "NODE"))).EqualTo(1))
+			assert.Is(hammy.Number(testutil.CollectAndCount(gpuTopology)).EqualTo(2))
+		})
+	}
+}
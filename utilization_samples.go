@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	utilizationInterval = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "utilization_interval_ratio",
+			Help:      "Minimum, maximum and mean (stat) GPU or memory (type) utilization (0-1) over the samples the driver took since the previous collection.",
+		},
+		[]string{"UUID", "pci_bus_id", "type", "stat"},
+	)
+
+	utilizationSampleTypes = []struct {
+		samplingType nvml.SamplingType
+		name         string
+	}{
+		{nvml.GPU_UTILIZATION_SAMPLES, "gpu"},
+		{nvml.MEMORY_UTILIZATION_SAMPLES, "memory"},
+	}
+)
+
+// utilizationSampleCollector reads the driver's utilization sample buffer,
+// which is refreshed every few hundred milliseconds, so that spikes and idle
+// gaps between collections are not flattened into one average.
+type utilizationSampleCollector struct {
+	// lastSeen holds the timestamp of the newest sample read per uuid|type
+	lastSeen map[string]uint64
+	// histogram also observes every sample in nvgpu_utilization_samples_ratio
+	histogram bool
+}
+
+func newUtilizationSampleCollector(histogram bool) *utilizationSampleCollector {
+	return &utilizationSampleCollector{lastSeen: make(map[string]uint64), histogram: histogram}
+}
+
+// collectUtilizationSamples exports min/max/avg utilization over the samples
+// taken since the previous collection. On the first collection that is the
+// whole buffer the driver holds.
+func (c *utilizationSampleCollector) collectUtilizationSamples(devices []Device, logger *slog.Logger) {
+	for _, device := range devices {
+		uuid, ret := device.GetUUID()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get UUID for device", "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		pciInfo, ret := device.GetPciInfo()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("failed to get PCI info", "uuid", uuid, "error", nvml.ErrorString(ret))
+			continue
+		}
+		pciBusId := pciBusID(pciInfo)
+
+		for _, st := range utilizationSampleTypes {
+			key := uuid + "|" + st.name
+			since := c.lastSeen[key]
+			valueType, samples, ret := device.GetSamples(st.samplingType, since)
+			if !errors.Is(ret, nvml.SUCCESS) {
+				// NOT_FOUND means no sample was taken since lastSeen
+				if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) && !errors.Is(ret, nvml.ERROR_NOT_FOUND) {
+					logger.Warn("failed to get utilization samples", "type", st.name, "uuid", uuid, "error", nvml.ErrorString(ret))
+				}
+				continue
+			}
+
+			var lo, hi, sum float64
+			var count int
+			for _, s := range samples {
+				if s.TimeStamp <= since {
+					continue
+				}
+				v, err := fieldValueToFloat64(nvml.FieldValue{ValueType: uint32(valueType), Value: s.SampleValue})
+				if err != nil {
+					logger.Debug("failed to decode utilization sample", "type", st.name, "uuid", uuid, "err", err)
+					continue
+				}
+				v /= 100
+				if c.histogram {
+					utilizationSamples.WithLabelValues(uuid, pciBusId, st.name).Observe(v)
+				}
+
+				if count == 0 || v < lo {
+					lo = v
+				}
+				if count == 0 || v > hi {
+					hi = v
+				}
+				sum += v
+				count++
+				c.lastSeen[key] = max(c.lastSeen[key], s.TimeStamp)
+			}
+			if count == 0 {
+				continue
+			}
+
+			utilizationInterval.WithLabelValues(uuid, pciBusId, st.name, "min").Set(lo)
+			utilizationInterval.WithLabelValues(uuid, pciBusId, st.name, "max").Set(hi)
+			utilizationInterval.WithLabelValues(uuid, pciBusId, st.name, "avg").Set(sum / float64(count))
+		}
+	}
+}
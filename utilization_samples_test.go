@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleDevice serves GPU utilization samples from a fixed buffer.
+type sampleDevice struct {
+	fakeDevice
+	samples []nvml.Sample
+}
+
+func (d *sampleDevice) GetSamples(samplingType nvml.SamplingType, lastSeenTimestamp uint64) (nvml.ValueType, []nvml.Sample, nvml.Return) {
+	if samplingType != nvml.GPU_UTILIZATION_SAMPLES {
+		return nvml.VALUE_TYPE_UNSIGNED_INT, nil, nvml.ERROR_NOT_SUPPORTED
+	}
+	var newer []nvml.Sample
+	for _, s := range d.samples {
+		if s.TimeStamp > lastSeenTimestamp {
+			newer = append(newer, s)
+		}
+	}
+	if len(newer) == 0 {
+		return nvml.VALUE_TYPE_UNSIGNED_INT, nil, nvml.ERROR_NOT_FOUND
+	}
+	return nvml.VALUE_TYPE_UNSIGNED_INT, newer, nvml.SUCCESS
+}
+
+func (d *sampleDevice) addSample(timestamp uint64, percent uint32) {
+	s := nvml.Sample{TimeStamp: timestamp}
+	binary.LittleEndian.PutUint32(s.SampleValue[:], percent)
+	d.samples = append(d.samples, s)
+}
+
+func TestCollectUtilizationSamples(t *testing.T) {
+	assert := hammy.New(t)
+	utilizationInterval.Reset()
+	t.Cleanup(utilizationInterval.Reset)
+
+	device := &sampleDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}}
+	device.addSample(1000, 90)
+	device.addSample(2000, 10)
+	device.addSample(3000, 50)
+
+	c := newUtilizationSampleCollector(false)
+	c.collectUtilizationSamples([]Device{device}, discardLogger())
+
+	stat := func(name string) float64 {
+		return testutil.ToFloat64(utilizationInterval.WithLabelValues("GPU-0", "0000:18:00.0", "gpu", name))
+	}
+	assert.Is(hammy.Number(stat("min")).EqualTo(0.1))
+	assert.Is(hammy.Number(stat("max")).EqualTo(0.9))
+	assert.Is(hammy.Number(stat("avg")).EqualTo(0.5))
+	// Memory samples are not supported
+	assert.Is(hammy.Number(testutil.CollectAndCount(utilizationInterval)).EqualTo(3))
+
+	// Only samples taken since the previous collection count
+	device.addSample(4000, 100)
+	device.addSample(5000, 80)
+	c.collectUtilizationSamples([]Device{device}, discardLogger())
+
+	assert.Is(hammy.Number(stat("min")).EqualTo(0.8))
+	assert.Is(hammy.Number(stat("max")).EqualTo(1))
+	assert.Is(hammy.Number(stat("avg")).EqualTo(0.9))
+
+	// Without new samples the previous values are kept
+	c.collectUtilizationSamples([]Device{device}, discardLogger())
+	assert.Is(hammy.Number(stat("max")).EqualTo(1))
+}
+
+func TestCollectUtilizationSamplesHistogram(t *testing.T) {
+	assert := hammy.New(t)
+	utilizationInterval.Reset()
+	utilizationSamples.Reset()
+	t.Cleanup(utilizationInterval.Reset)
+	t.Cleanup(utilizationSamples.Reset)
+
+	device := &sampleDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}}
+	device.addSample(1000, 90)
+	device.addSample(2000, 10)
+
+	c := newUtilizationSampleCollector(true)
+	c.collectUtilizationSamples([]Device{device}, discardLogger())
+
+	m := &dto.Metric{}
+	err := utilizationSamples.WithLabelValues("GPU-0", "0000:18:00.0", "gpu").(prometheus.Histogram).Write(m)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(m.GetHistogram().GetSampleCount()).EqualTo(2))
+	assert.Is(hammy.Number(m.GetHistogram().GetSampleSum()).EqualTo(1))
+	// Native only: no classic buckets
+	assert.Is(hammy.Number(len(m.GetHistogram().GetBucket())).EqualTo(0))
+}
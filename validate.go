@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// hardwareProfile is the hardware a node is expected to have, as given to
+// nvgpu-exporter validate -expect.
+type hardwareProfile struct {
+	// gpus is the number of GPUs, 0 for any
+	gpus int
+	// model is a word of the GPU name, e.g. H100, empty for any
+	model string
+	// nvlinks is the number of active NVLinks of every GPU, -1 for any
+	nvlinks int
+	// healthyFabric requires every GPU to have completed fabric registration
+	// and to report a healthy fabric
+	healthyFabric bool
+}
+
+// parseHardwareProfile parses a profile such as "8xH100,nvlink=18,fabric=healthy".
+// The GPUs are given as <count>x<model>, <count> or <model>.
+func parseHardwareProfile(s string) (hardwareProfile, error) {
+	p := hardwareProfile{nvlinks: -1}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			if item == "" || p.gpus != 0 || p.model != "" {
+				return p, fmt.Errorf("invalid GPUs %q in profile %q, expected one <count>x<model>", item, s)
+			}
+			count, model, hasModel := strings.Cut(item, "x")
+			if !hasModel {
+				count, model = item, ""
+			}
+			n, err := strconv.Atoi(count)
+			switch {
+			case err == nil && n > 0:
+				p.gpus, p.model = n, model
+			case !hasModel:
+				p.model = item
+			default:
+				return p, fmt.Errorf("invalid GPU count %q in profile %q", count, s)
+			}
+			continue
+		}
+
+		switch key {
+		case "nvlink":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > nvml.NVLINK_MAX_LINKS {
+				return p, fmt.Errorf("invalid NVLink count %q in profile %q (expected 0-%d)", value, s, nvml.NVLINK_MAX_LINKS)
+			}
+			p.nvlinks = n
+		case "fabric":
+			if value != "healthy" {
+				return p, fmt.Errorf("invalid fabric %q in profile %q (supported: healthy)", value, s)
+			}
+			p.healthyFabric = true
+		default:
+			return p, fmt.Errorf("unknown check %q in profile %q (supported: nvlink, fabric)", key, s)
+		}
+	}
+	return p, nil
+}
+
+// runValidate implements the validate subcommand. It reports whether the node
+// matches the expected profile.
+func runValidate(args []string, stdout io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	expect := fs.String("expect", "", "Expected hardware, e.g. 8xH100,nvlink=18,fabric=healthy")
+	snapshotFile := fs.String("snapshot", "", "Validate a snapshot written by nvgpu-exporter snapshot instead of the GPUs of this node")
+	var cfg Config
+	registerSourceFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if *expect == "" {
+		return false, errors.New("usage: nvgpu-exporter validate -expect=<count>x<model>[,nvlink=<links>][,fabric=healthy]")
+	}
+	profile, err := parseHardwareProfile(*expect)
+	if err != nil {
+		return false, err
+	}
+
+	var snapshot nodeSnapshot
+	if *snapshotFile != "" {
+		data, err := os.ReadFile(*snapshotFile)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return false, fmt.Errorf("invalid snapshot %s: %w", *snapshotFile, err)
+		}
+	} else {
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+		devices, shutdown, err := openDevices(&cfg, logger)
+		if err != nil {
+			return false, err
+		}
+		defer shutdown()
+		if snapshot, err = takeSnapshot(devices, logger); err != nil {
+			return false, err
+		}
+	}
+
+	mismatches := validateSnapshot(snapshot, profile)
+	for _, mismatch := range mismatches {
+		fmt.Fprintln(stdout, mismatch)
+	}
+	if len(mismatches) == 0 {
+		fmt.Fprintf(stdout, "%d GPUs match %s\n", len(snapshot.GPUs), *expect)
+	}
+	return len(mismatches) == 0, nil
+}
+
+// validateSnapshot describes how the node differs from profile, one mismatch
+// per line.
+func validateSnapshot(s nodeSnapshot, profile hardwareProfile) []string {
+	var mismatches []string
+	if profile.gpus > 0 && len(s.GPUs) != profile.gpus {
+		mismatches = append(mismatches, fmt.Sprintf("GPUs: expected %d, found %d", profile.gpus, len(s.GPUs)))
+	}
+	for _, g := range s.GPUs {
+		for _, mismatch := range validateGPU(g, profile) {
+			mismatches = append(mismatches, fmt.Sprintf("%s (%s): %s", g.Info.UUID, g.Info.PciBusId, mismatch))
+		}
+	}
+	return mismatches
+}
+
+func validateGPU(g gpuSnapshot, profile hardwareProfile) []string {
+	var mismatches []string
+	if profile.model != "" && !isModel(g.Info.Name, profile.model) {
+		mismatches = append(mismatches, fmt.Sprintf("model: expected %s, found %s", profile.model, g.Info.Name))
+	}
+
+	if profile.nvlinks >= 0 {
+		var down []string
+		active := 0
+		for _, link := range sortedKeys(g.NVLinks, nil) {
+			if g.NVLinks[link] {
+				active++
+			} else {
+				down = append(down, link)
+			}
+		}
+		if active != profile.nvlinks {
+			mismatch := fmt.Sprintf("active NVLinks: expected %d, found %d", profile.nvlinks, active)
+			if len(down) > 0 {
+				mismatch += fmt.Sprintf(" (down: %s)", strings.Join(down, ", "))
+			}
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	if profile.healthyFabric {
+		switch {
+		case g.FabricState == nil:
+			mismatches = append(mismatches, "fabric: not supported")
+		case *g.FabricState != nvml.GPU_FABRIC_STATE_COMPLETED:
+			mismatches = append(mismatches, fmt.Sprintf("fabric: registration not completed (state %d)", *g.FabricState))
+		case g.FabricStatus != nil && nvml.Return(*g.FabricStatus) != nvml.SUCCESS:
+			mismatches = append(mismatches, fmt.Sprintf("fabric: registration failed: %s", nvml.ErrorString(nvml.Return(*g.FabricStatus))))
+		case g.FabricHealthMask != nil:
+			switch healthSummaryOfMask(*g.FabricHealthMask) {
+			case nvml.GPU_FABRIC_HEALTH_SUMMARY_UNHEALTHY:
+				mismatches = append(mismatches, fmt.Sprintf("fabric: unhealthy (health mask %#x)", *g.FabricHealthMask))
+			case nvml.GPU_FABRIC_HEALTH_SUMMARY_LIMITED_CAPACITY:
+				mismatches = append(mismatches, fmt.Sprintf("fabric: limited capacity (health mask %#x)", *g.FabricHealthMask))
+			}
+		}
+	}
+	return mismatches
+}
+
+// isModel reports whether the GPU name has model as a word, so that H100
+// matches "NVIDIA H100 80GB HBM3" and A100 matches "NVIDIA A100-SXM4-80GB",
+// but B200 does not match "NVIDIA GB200".
+func isModel(name, model string) bool {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == ' ' || r == '-' })
+	return slices.ContainsFunc(words, func(w string) bool { return strings.EqualFold(w, model) })
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestParseHardwareProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    hardwareProfile
+		wantErr bool
+	}{
+		{name: "full", profile: "8xH100,nvlink=18,fabric=healthy", want: hardwareProfile{gpus: 8, model: "H100", nvlinks: 18, healthyFabric: true}},
+		{name: "count only", profile: "4", want: hardwareProfile{gpus: 4, nvlinks: -1}},
+		{name: "model only", profile: "GB200,nvlink=18", want: hardwareProfile{model: "GB200", nvlinks: 18}},
+		{name: "no links", profile: "nvlink=0", want: hardwareProfile{nvlinks: 0}},
+		{name: "invalid count", profile: "0xH100", wantErr: true},
+		{name: "two GPU specs", profile: "8xH100,H100", wantErr: true},
+		{name: "invalid links", profile: "8xH100,nvlink=many", wantErr: true},
+		{name: "unknown fabric", profile: "8xH100,fabric=degraded", wantErr: true},
+		{name: "unknown check", profile: "8xH100,ecc=clean", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			got, err := parseHardwareProfile(tc.profile)
+			if tc.wantErr {
+				assert.Is(hammy.True(err != nil))
+				return
+			}
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.True(got == tc.want))
+		})
+	}
+}
+
+func TestValidateSnapshot(t *testing.T) {
+	state := func(v uint8) *uint8 { return &v }
+	mask := func(v uint32) *uint32 { return &v }
+	healthy := healthMask(nvml.GPU_FABRIC_HEALTH_MASK_DEGRADED_BW_FALSE, nvml.GPU_FABRIC_HEALTH_MASK_ROUTE_RECOVERY_FALSE,
+		nvml.GPU_FABRIC_HEALTH_MASK_ROUTE_UNHEALTHY_FALSE, nvml.GPU_FABRIC_HEALTH_MASK_ACCESS_TIMEOUT_RECOVERY_FALSE,
+		nvml.GPU_FABRIC_HEALTH_MASK_INCORRECT_CONFIGURATION_NONE)
+	gpu := func(uuid string) gpuSnapshot {
+		return gpuSnapshot{
+			Info:             &GpuInfo{UUID: uuid, PciBusId: "0000:18:00.0", Name: "NVIDIA H100 80GB HBM3"},
+			NVLinks:          map[string]bool{"0": true, "1": true, "2": true},
+			FabricState:      state(nvml.GPU_FABRIC_STATE_COMPLETED),
+			FabricStatus:     mask(uint32(nvml.SUCCESS)),
+			FabricHealthMask: mask(healthy),
+		}
+	}
+	profile := hardwareProfile{gpus: 2, model: "H100", nvlinks: 3, healthyFabric: true}
+
+	tests := []struct {
+		name   string
+		change func(*nodeSnapshot)
+		want   []string
+	}{
+		{"matches", func(*nodeSnapshot) {}, nil},
+		{
+			"missing GPU",
+			func(s *nodeSnapshot) { s.GPUs = s.GPUs[:1] },
+			[]string{"GPUs: expected 2, found 1"},
+		},
+		{
+			"wrong model",
+			func(s *nodeSnapshot) { s.GPUs[1].Info.Name = "NVIDIA A100-SXM4-80GB" },
+			[]string{"GPU-1 (0000:18:00.0): model: expected H100, found NVIDIA A100-SXM4-80GB"},
+		},
+		{
+			"link down",
+			func(s *nodeSnapshot) { s.GPUs[0].NVLinks["2"] = false },
+			[]string{"GPU-0 (0000:18:00.0): active NVLinks: expected 3, found 2 (down: 2)"},
+		},
+		{
+			"fabric not registered",
+			func(s *nodeSnapshot) { s.GPUs[0].FabricState = state(nvml.GPU_FABRIC_STATE_IN_PROGRESS) },
+			[]string{"GPU-0 (0000:18:00.0): fabric: registration not completed (state 2)"},
+		},
+		{
+			"fabric degraded",
+			func(s *nodeSnapshot) {
+				*s.GPUs[1].FabricHealthMask = healthy&^0x3 | nvml.GPU_FABRIC_HEALTH_MASK_DEGRADED_BW_TRUE
+			},
+			[]string{"GPU-1 (0000:18:00.0): fabric: limited capacity (health mask 0x1a9)"},
+		},
+		{
+			"no fabric",
+			func(s *nodeSnapshot) { s.GPUs[0].FabricState = nil },
+			[]string{"GPU-0 (0000:18:00.0): fabric: not supported"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			s := nodeSnapshot{GPUs: []gpuSnapshot{gpu("GPU-0"), gpu("GPU-1")}}
+			tc.change(&s)
+			mismatches := validateSnapshot(s, profile)
+			assert.Is(hammy.Number(len(mismatches)).EqualTo(len(tc.want)))
+			for i := range tc.want {
+				assert.Is(hammy.String(mismatches[i]).EqualTo(tc.want[i]))
+			}
+		})
+	}
+}
+
+func TestIsModel(t *testing.T) {
+	assert := hammy.New(t)
+	assert.Is(hammy.True(isModel("NVIDIA H100 80GB HBM3", "h100")))
+	assert.Is(hammy.True(isModel("NVIDIA A100-SXM4-80GB", "A100")))
+	assert.Is(hammy.False(isModel("NVIDIA GB200", "B200")))
+}
+
+func TestRunValidate(t *testing.T) {
+	assert := hammy.New(t)
+	var out bytes.Buffer
+	ok, err := runValidate([]string{"-simulate", "2xH100", "-expect", "2xH100,fabric=healthy"}, &out)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.True(ok))
+	assert.Is(hammy.String(out.String()).EqualTo("2 GPUs match 2xH100,fabric=healthy\n"))
+
+	out.Reset()
+	ok, err = runValidate([]string{"-simulate", "2xH100", "-expect", "4xB200"}, &out)
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.False(ok))
+	assert.Is(hammy.String(out.String()).Contains("GPUs: expected 4, found 2\n"))
+	assert.Is(hammy.String(out.String()).Contains("model: expected B200, found NVIDIA H100 80GB HBM3"))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// visibilityEnvVars are the variables that restrict the GPUs of a container,
+// in order of precedence.
+var visibilityEnvVars = []string{"NVIDIA_VISIBLE_DEVICES", "CUDA_VISIBLE_DEVICES"}
+
+// deviceVisibility is the set of GPUs a container may use, as NVML indices or
+// UUIDs (or unique UUID prefixes). A nil *deviceVisibility includes every GPU.
+type deviceVisibility struct {
+	indices map[int]bool
+	uuids   []string
+}
+
+// visibilityFromEnv returns the GPUs selected by the first of
+// visibilityEnvVars that is set, along with its name and the entries that
+// cannot be honored. It returns nil when no variable restricts the GPUs.
+func visibilityFromEnv(lookup func(string) (string, bool)) (*deviceVisibility, string, []string, error) {
+	for _, name := range visibilityEnvVars {
+		value, ok := lookup(name)
+		// void leaves the container runtime's default, i.e. no restriction
+		if !ok || value == "void" || (value == "" && name == "NVIDIA_VISIBLE_DEVICES") {
+			continue
+		}
+		visibility, ignored, err := parseVisibleDevices(value)
+		if err != nil {
+			return nil, name, nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return visibility, name, ignored, nil
+	}
+	return nil, "", nil, nil
+}
+
+// parseVisibleDevices parses a comma separated list of GPU indices and UUIDs,
+// or all or none. MIG devices are returned as ignored, since the exporter
+// reports whole GPUs; a MIG index such as 0:1 selects its parent GPU.
+func parseVisibleDevices(value string) (*deviceVisibility, []string, error) {
+	switch strings.TrimSpace(value) {
+	case "all":
+		return nil, nil, nil
+	case "none", "":
+		return &deviceVisibility{indices: map[int]bool{}}, nil, nil
+	}
+
+	v := &deviceVisibility{indices: make(map[int]bool)}
+	var ignored []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		parent, _, _ := strings.Cut(entry, ":")
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "MIG-"):
+			ignored = append(ignored, entry)
+		case strings.HasPrefix(entry, "GPU-"):
+			v.uuids = append(v.uuids, entry)
+		default:
+			index, err := strconv.Atoi(parent)
+			if err != nil || index < 0 {
+				return nil, nil, fmt.Errorf("unknown device %q", entry)
+			}
+			v.indices[index] = true
+		}
+	}
+	return v, ignored, nil
+}
+
+// includes reports whether the GPU at NVML index with uuid is visible.
+func (v *deviceVisibility) includes(index int, uuid string) bool {
+	if v == nil || v.indices[index] {
+		return true
+	}
+	for _, prefix := range v.uuids {
+		if strings.HasPrefix(uuid, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gogunit/gunit/hammy"
+)
+
+func TestParseVisibleDevices(t *testing.T) {
+	uuid := "GPU-5e1a7ed0-0000-4000-8000-000000000002"
+	tests := []struct {
+		name        string
+		value       string
+		wantVisible []bool // of GPUs 0..3, GPU 2 having uuid
+		wantIgnored int
+		wantErr     bool
+	}{
+		{name: "all", value: "all", wantVisible: []bool{true, true, true, true}},
+		{name: "none", value: "none", wantVisible: []bool{false, false, false, false}},
+		{name: "indices", value: "0,3", wantVisible: []bool{true, false, false, true}},
+		{name: "uuid", value: uuid, wantVisible: []bool{false, false, true, false}},
+		{name: "uuid prefix", value: "GPU-5e1a7ed0-0000-4000-8000-00000000000", wantVisible: []bool{false, false, true, false}},
+		{name: "mig", value: "1:0,MIG-8d4c6b2e", wantVisible: []bool{false, true, false, false}, wantIgnored: 1},
+		{name: "invalid", value: "gpu0", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			visibility, ignored, err := parseVisibleDevices(tc.value)
+			assert.Is(hammy.True((err != nil) == tc.wantErr))
+			assert.Is(hammy.Number(len(ignored)).EqualTo(tc.wantIgnored))
+			for i, want := range tc.wantVisible {
+				gpuUUID := "GPU-other"
+				if i == 2 {
+					gpuUUID = uuid
+				}
+				assert.Is(hammy.True(visibility.includes(i, gpuUUID) == want))
+			}
+		})
+	}
+}
+
+func TestVisibilityFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantSource string
+		wantNil    bool
+	}{
+		{name: "unset", env: map[string]string{}, wantNil: true},
+		{name: "void", env: map[string]string{"NVIDIA_VISIBLE_DEVICES": "void"}, wantNil: true},
+		{name: "nvidia first", env: map[string]string{"NVIDIA_VISIBLE_DEVICES": "1", "CUDA_VISIBLE_DEVICES": "0"}, wantSource: "NVIDIA_VISIBLE_DEVICES"},
+		{name: "cuda", env: map[string]string{"CUDA_VISIBLE_DEVICES": "0"}, wantSource: "CUDA_VISIBLE_DEVICES"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			visibility, source, _, err := visibilityFromEnv(func(name string) (string, bool) {
+				value, ok := tc.env[name]
+				return value, ok
+			})
+			assert.Is(hammy.NilError(err))
+			assert.Is(hammy.String(source).EqualTo(tc.wantSource))
+			assert.Is(hammy.True((visibility == nil) == tc.wantNil))
+		})
+	}
+}
+
+func TestNewRespectsVisibility(t *testing.T) {
+	assert := hammy.New(t)
+	client := &fakeClient{devices: []Device{
+		&fakeDevice{uuid: "GPU-0"},
+		// Not accessible from the container
+		nil,
+		&fakeDevice{uuid: "GPU-2"},
+	}}
+
+	visibility, _, err := parseVisibleDevices("GPU-2")
+	assert.Is(hammy.NilError(err))
+	devices, _, err := New(client, visibility, discardLogger())
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(devices.Count()).EqualTo(1))
+	uuid, _ := devices.handles[0].GetUUID()
+	assert.Is(hammy.String(uuid).EqualTo("GPU-2"))
+
+	// Without visibility an inaccessible GPU is an error
+	_, _, err = New(client, nil, discardLogger())
+	assert.Is(hammy.True(err != nil))
+}
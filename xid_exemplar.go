@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// xidExemplarData is the data the -xid-exemplar template is executed with.
+type xidExemplarData struct {
+	UUID     string
+	PciBusID string
+	Xid      uint64
+	// Time is when the exporter received the event
+	Time time.Time
+	// Uptime is the seconds since boot from /proc/uptime, the clock of the
+	// kernel log timestamps, or empty when it cannot be read
+	Uptime string
+}
+
+// xidExemplar renders the exemplar attached to nvgpu_xid_errors_total
+// increments, so that a spike in a dashboard links to the log window or
+// incident of the Xid. The template renders comma separated name=value pairs;
+// pairs with an empty value are left out.
+type xidExemplar struct {
+	tmpl     *template.Template
+	procPath string
+	now      func() time.Time
+}
+
+// newXidExemplar parses text, returning nil when it is empty. Besides the
+// fields of xidExemplarData, the template may call env to read an environment
+// variable, e.g. {{env "INCIDENT_ID"}}.
+func newXidExemplar(text, procPath string) (*xidExemplar, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("xid-exemplar").Option("missingkey=error").Funcs(template.FuncMap{"env": os.Getenv}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &xidExemplar{tmpl: tmpl, procPath: procPath, now: time.Now}, nil
+}
+
+// labels renders the exemplar labels of an Xid. They are checked here because
+// client_golang panics on an invalid exemplar.
+func (e *xidExemplar) labels(uuid, pciBusId string, xid uint64) (prometheus.Labels, error) {
+	data := xidExemplarData{UUID: uuid, PciBusID: pciBusId, Xid: xid, Time: e.now(), Uptime: readUptime(e.procPath)}
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	labels := make(prometheus.Labels)
+	runes := 0
+	for _, pair := range strings.Split(buf.String(), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if name == "" || value == "" {
+			continue
+		}
+		if !model.LabelName(name).IsValidLegacy() {
+			return nil, fmt.Errorf("invalid exemplar label name %q", name)
+		}
+		labels[name] = value
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if runes > prometheus.ExemplarMaxRunes {
+		return nil, fmt.Errorf("exemplar labels have %d runes, more than %d", runes, prometheus.ExemplarMaxRunes)
+	}
+	return labels, nil
+}
+
+// readUptime returns the first field of the uptime file under procPath.
+func readUptime(procPath string) string {
+	data, err := os.ReadFile(filepath.Join(procPath, "uptime"))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
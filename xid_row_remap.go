@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Xids the driver raises around memory row remapping on Ampere and newer: 48
+// for a double bit ECC error, 63 when it recorded a row remapping and 64 when
+// the remapping failed.
+var rowRemapXids = map[uint64]bool{48: true, 63: true, 64: true}
+
+var (
+	xidRowRemapInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "xid_row_remap_info",
+			Help:      "Row remapping of the GPU read right after its last Xid 48, 63 or 64, always 1: rows remapped since the previous reading by cause, whether remapping is pending or failed, and the availability the bank that took the remap was left with.",
+		},
+		[]string{"UUID", "pci_bus_id", "xid", "correctable_delta", "uncorrectable_delta", "pending", "failed", "bank_availability"},
+	)
+
+	rowRemapBankAvailability = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "row_remap_bank_availability",
+			Help:      "Number of memory banks by the spare rows they have left for remapping (max, high, partial, low, none).",
+		},
+		[]string{"UUID", "pci_bus_id", "availability"},
+	)
+)
+
+// rowRemapReading is the row remapping state of a GPU at one point in time.
+type rowRemapReading struct {
+	correctable   int
+	uncorrectable int
+	pending       bool
+	failed        bool
+	// histogram is nil when the GPU does not report bank availability
+	histogram *nvml.RowRemapperHistogramValues
+}
+
+// bankAvailabilities are the buckets of the row remapper histogram, from the
+// most to the fewest spare rows.
+var bankAvailabilities = []string{"max", "high", "partial", "low", "none"}
+
+func histogramBuckets(h nvml.RowRemapperHistogramValues) []uint32 {
+	return []uint32{h.Max, h.High, h.Partial, h.Low, h.None}
+}
+
+// xidRowRemapTracker links the Xids of memory errors to the row remapping they
+// caused. The collection rounds record the remapping of every GPU, and each
+// Xid reads it again right away, so the difference is the remapping the Xid
+// stands for. NVML does not identify the bank; a bank taking a remap moves to
+// a bucket with fewer spare rows, which is reported instead. A nil
+// *xidRowRemapTracker ignores every reading.
+type xidRowRemapTracker struct {
+	mu   sync.Mutex
+	last map[string]rowRemapReading
+	// labels holds the series of the last Xid per GPU and Xid, replaced by the
+	// next one
+	labels map[[2]string][]string
+}
+
+func newXidRowRemapTracker() *xidRowRemapTracker {
+	return &xidRowRemapTracker{last: make(map[string]rowRemapReading), labels: make(map[[2]string][]string)}
+}
+
+// readRowRemap reads the row remapping state of device.
+func readRowRemap(device Device) (rowRemapReading, nvml.Return) {
+	var r rowRemapReading
+	var ret nvml.Return
+	r.correctable, r.uncorrectable, r.pending, r.failed, ret = device.GetRemappedRows()
+	if !errors.Is(ret, nvml.SUCCESS) {
+		return r, ret
+	}
+	if h, ret := device.GetRowRemapperHistogram(); errors.Is(ret, nvml.SUCCESS) {
+		r.histogram = &h
+	}
+	return r, nvml.SUCCESS
+}
+
+// observe records the reading of a collection round.
+func (t *xidRowRemapTracker) observe(uuid string, r rowRemapReading) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[uuid] = r
+}
+
+// reportXid reads the row remapping of device after an Xid and exports how it
+// changed since the previous reading.
+func (t *xidRowRemapTracker) reportXid(device Device, uuid, pciBusId string, xid uint64, logger *slog.Logger) {
+	if t == nil || !rowRemapXids[xid] {
+		return
+	}
+
+	r, ret := readRowRemap(device)
+	if !errors.Is(ret, nvml.SUCCESS) {
+		if !errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			logger.Warn("failed to get remapped rows after Xid", "uuid", uuid, "xid", xid, "error", nvml.ErrorString(ret))
+		}
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, known := t.last[uuid]
+	t.last[uuid] = r
+	correctable, uncorrectable, bank := "unknown", "unknown", "unknown"
+	if known {
+		correctable = strconv.Itoa(r.correctable - prev.correctable)
+		uncorrectable = strconv.Itoa(r.uncorrectable - prev.uncorrectable)
+		if prev.histogram != nil && r.histogram != nil {
+			bank = bankAvailabilityShift(*prev.histogram, *r.histogram)
+		}
+	}
+
+	labels := []string{uuid, pciBusId, formatXid(xid), correctable, uncorrectable, strconv.FormatBool(r.pending), strconv.FormatBool(r.failed), bank}
+	key := [2]string{uuid, formatXid(xid)}
+	if old, ok := t.labels[key]; ok {
+		xidRowRemapInfo.DeleteLabelValues(old...)
+	}
+	t.labels[key] = labels
+	xidRowRemapInfo.WithLabelValues(labels...).Set(1)
+
+	logger.Info("row remapping after Xid", "uuid", uuid, "xid", xid, "correctable_delta", correctable,
+		"uncorrectable_delta", uncorrectable, "pending", r.pending, "failed", r.failed, "bank_availability", bank)
+}
+
+// bankAvailabilityShift returns the bucket a bank moved to between two
+// histograms, the one with the fewest spare rows that gained banks, or
+// unchanged.
+func bankAvailabilityShift(prev, cur nvml.RowRemapperHistogramValues) string {
+	before, after := histogramBuckets(prev), histogramBuckets(cur)
+	for i := len(after) - 1; i >= 0; i-- {
+		if after[i] > before[i] {
+			return bankAvailabilities[i]
+		}
+	}
+	return "unchanged"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// rowRemapDevice is a GPU whose row remapping state the test changes.
+type rowRemapDevice struct {
+	fakeDevice
+	correctable, uncorrectable int
+	pending                    bool
+	histogram                  nvml.RowRemapperHistogramValues
+}
+
+func (d *rowRemapDevice) GetRetiredPagesPendingStatus() (nvml.EnableState, nvml.Return) {
+	return nvml.FEATURE_DISABLED, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (d *rowRemapDevice) GetRemappedRows() (int, int, bool, bool, nvml.Return) {
+	return d.correctable, d.uncorrectable, d.pending, false, nvml.SUCCESS
+}
+
+func (d *rowRemapDevice) GetRowRemapperHistogram() (nvml.RowRemapperHistogramValues, nvml.Return) {
+	return d.histogram, nvml.SUCCESS
+}
+
+func TestXidRowRemapTracker(t *testing.T) {
+	assert := hammy.New(t)
+	xidRowRemapInfo.Reset()
+	rowRemapBankAvailability.Reset()
+	t.Cleanup(xidRowRemapInfo.Reset)
+	t.Cleanup(rowRemapBankAvailability.Reset)
+	remaps := newXidRowRemapTracker()
+
+	device := &rowRemapDevice{
+		fakeDevice:  fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"},
+		correctable: 1,
+		histogram:   nvml.RowRemapperHistogramValues{Max: 638, High: 2},
+	}
+	collectRetiredPages([]Device{device}, nil, remaps, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(rowRemapBankAvailability.WithLabelValues("GPU-0", "0000:18:00.0", "high"))).EqualTo(2))
+
+	// Not a memory Xid
+	remaps.reportXid(device, "GPU-0", "0000:18:00.0", 13, discardLogger())
+	assert.Is(hammy.Number(testutil.CollectAndCount(xidRowRemapInfo)).EqualTo(0))
+
+	// A bank with many spare rows took an uncorrectable remap
+	device.uncorrectable = 1
+	device.pending = true
+	device.histogram = nvml.RowRemapperHistogramValues{Max: 637, High: 3}
+	remaps.reportXid(device, "GPU-0", "0000:18:00.0", 63, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(xidRowRemapInfo.WithLabelValues("GPU-0", "0000:18:00.0", "63", "0", "1", "true", "false", "high"))).EqualTo(1))
+
+	// The next Xid 63 replaces the series of the previous one
+	device.uncorrectable = 2
+	device.histogram = nvml.RowRemapperHistogramValues{Max: 637, High: 2, Partial: 1}
+	remaps.reportXid(device, "GPU-0", "0000:18:00.0", 63, discardLogger())
+	assert.Is(hammy.Number(testutil.CollectAndCount(xidRowRemapInfo)).EqualTo(1))
+	assert.Is(hammy.Number(testutil.ToFloat64(xidRowRemapInfo.WithLabelValues("GPU-0", "0000:18:00.0", "63", "0", "1", "true", "false", "partial"))).EqualTo(1))
+
+	// A GPU never read before has no baseline
+	other := &rowRemapDevice{fakeDevice: fakeDevice{uuid: "GPU-1", pciBusId: "0000:28:00.0"}}
+	remaps.reportXid(other, "GPU-1", "0000:28:00.0", 48, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(xidRowRemapInfo.WithLabelValues("GPU-1", "0000:28:00.0", "48", "unknown", "unknown", "false", "false", "unknown"))).EqualTo(1))
+
+	// Pre-Ampere GPUs have no row remapping
+	remaps.reportXid(&fakeDevice{uuid: "GPU-2"}, "GPU-2", "0000:38:00.0", 48, discardLogger())
+	assert.Is(hammy.Number(testutil.CollectAndCount(xidRowRemapInfo)).EqualTo(2))
+}
+
+func TestBankAvailabilityShift(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur nvml.RowRemapperHistogramValues
+		want      string
+	}{
+		{name: "unchanged", prev: nvml.RowRemapperHistogramValues{Max: 640}, cur: nvml.RowRemapperHistogramValues{Max: 640}, want: "unchanged"},
+		{name: "max to high", prev: nvml.RowRemapperHistogramValues{Max: 640}, cur: nvml.RowRemapperHistogramValues{Max: 639, High: 1}, want: "high"},
+		{name: "low to none", prev: nvml.RowRemapperHistogramValues{Max: 639, Low: 1}, cur: nvml.RowRemapperHistogramValues{Max: 639, None: 1}, want: "none"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			assert.Is(hammy.String(bankAvailabilityShift(tc.prev, tc.cur)).EqualTo(tc.want))
+		})
+	}
+}
+
+func TestNilXidRowRemapTracker(t *testing.T) {
+	assert := hammy.New(t)
+	xidRowRemapInfo.Reset()
+	t.Cleanup(xidRowRemapInfo.Reset)
+
+	var remaps *xidRowRemapTracker
+	device := &rowRemapDevice{fakeDevice: fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}, uncorrectable: 1}
+	remaps.observe("GPU-0", rowRemapReading{})
+	remaps.reportXid(device, "GPU-0", "0000:18:00.0", 63, discardLogger())
+	assert.Is(hammy.Number(testutil.CollectAndCount(xidRowRemapInfo)).EqualTo(0))
+}
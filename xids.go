@@ -4,11 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"time"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// xidCollectorName identifies the Xid event handler in the collector metrics;
+// with several event sets each is suffixed with its shard index.
+const xidCollectorName = "xid_events"
+
 var (
 	xidErrors = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -20,54 +26,109 @@ var (
 	)
 )
 
-// startXidEventCollector starts a goroutine that subscribes to NVML events and collects Xid errors
-func startXidEventCollector(devices []nvml.Device, logger *slog.Logger) error {
-	// Register the Xid errors metric
-	prometheus.MustRegister(xidErrors)
+// checkXidEventConfig validates the Xid event flags, so that a bad value fails
+// startup even while another instance holds -instance-lock and the collector
+// only starts later.
+func checkXidEventConfig(cfg *Config) error {
+	if cfg.XidEventShards < 1 {
+		return fmt.Errorf("-xid-event-shards must be at least 1, got %d", cfg.XidEventShards)
+	}
+	timeoutMs := cfg.XidWaitTimeout.Milliseconds()
+	if timeoutMs < 1 || timeoutMs > math.MaxUint32 {
+		return fmt.Errorf("-xid-wait-timeout must be between 1ms and %dms, got %s", uint32(math.MaxUint32), cfg.XidWaitTimeout)
+	}
+	if _, err := newXidExemplar(cfg.XidExemplar, cfg.ProcPath); err != nil {
+		return fmt.Errorf("invalid -xid-exemplar: %w", err)
+	}
+	return nil
+}
 
-	// Create event set
-	eventSet, ret := nvml.EventSetCreate()
-	if !errors.Is(ret, nvml.SUCCESS) {
-		return errors.New("failed to create event set: " + nvml.ErrorString(ret))
+// startXidEventCollector subscribes every device to Xid events. Devices are
+// spread round-robin over cfg.XidEventShards event sets, each drained by its
+// own goroutine, so that a burst of events on one GPU does not delay the others.
+func startXidEventCollector(devices Devices, cfg *Config, health *gpuHealthTracker, remaps *xidRowRemapTracker, xids *recordRing[xidRecord], locations *locationLabels, reg prometheus.Registerer, logger *slog.Logger) error {
+	if err := checkXidEventConfig(cfg); err != nil {
+		return err
 	}
+	timeoutMs := cfg.XidWaitTimeout.Milliseconds()
+	exemplar, err := newXidExemplar(cfg.XidExemplar, cfg.ProcPath)
+	if err != nil {
+		return fmt.Errorf("invalid -xid-exemplar: %w", err)
+	}
+
+	// Register the Xid errors and ECC events metrics
+	reg.MustRegister(locations.wrap(xidErrors))
+	reg.MustRegister(locations.wrap(xidRowRemapInfo))
+	reg.MustRegister(eccEvents)
 
-	// Register all devices for Xid events
+	shards := max(min(cfg.XidEventShards, devices.Count()), 1)
+	eventSets := make([]EventSet, 0, shards)
+	for i := 0; i < shards; i++ {
+		eventSet, ret := devices.lib.EventSetCreate()
+		if !errors.Is(ret, nvml.SUCCESS) {
+			return errors.New("failed to create event set: " + nvml.ErrorString(ret))
+		}
+		eventSets = append(eventSets, eventSet)
+	}
+
+	// Register all devices for Xid and ECC events. GPUs without ECC reject the
+	// whole mask, so fall back to Xids alone for them.
 	eventTypes := uint64(nvml.EventTypeXidCriticalError)
-	for _, device := range devices {
-		ret = nvml.DeviceRegisterEvents(device, eventTypes, eventSet)
+	for i, device := range devices.handles {
+		ret := device.RegisterEvents(eventTypes|eccEventTypes, eventSets[i%shards])
+		if errors.Is(ret, nvml.ERROR_NOT_SUPPORTED) {
+			ret = device.RegisterEvents(eventTypes, eventSets[i%shards])
+		}
 		if !errors.Is(ret, nvml.SUCCESS) {
 			logger.Warn("failed to register Xid events", "error", nvml.ErrorString(ret))
 			continue
 		}
 	}
 
-	// Start event collection goroutine
-	go func() {
-		logger.Info("started Xid event collector")
-		for {
-			// Wait for events (timeout in milliseconds)
-			event, ret := nvml.EventSetWait(eventSet, 5000)
-			if errors.Is(ret, nvml.ERROR_TIMEOUT) {
-				// Timeout is normal, just continue waiting
-				continue
-			}
-			if !errors.Is(ret, nvml.SUCCESS) {
-				logger.Warn("error waiting for NVML events", "error", nvml.ErrorString(ret))
-				continue
-			}
-
-			// Process the event if it's an Xid error
-			if event.EventType&nvml.EventTypeXidCriticalError != 0 {
-				handleXidEvent(event, logger)
-			}
+	for i, eventSet := range eventSets {
+		name := xidCollectorName
+		if shards > 1 {
+			name = fmt.Sprintf("%s_%d", xidCollectorName, i)
 		}
-	}()
+		collectorPanics.WithLabelValues(name)
+		goCollector(name, func() { waitForXidEvents(eventSet, name, uint32(timeoutMs), health, remaps, xids, exemplar, logger) })
+	}
 
+	logger.Info("started Xid event collector", "event_sets", shards, "wait_timeout", cfg.XidWaitTimeout)
 	return nil
 }
 
-// handleXidEvent processes a Xid event and increments the appropriate counter
-func handleXidEvent(event nvml.EventData, logger *slog.Logger) {
+// waitForXidEvents drains eventSet forever, handling Xid and ECC events as
+// collector name.
+func waitForXidEvents(eventSet EventSet, name string, timeoutMs uint32, health *gpuHealthTracker, remaps *xidRowRemapTracker, xids *recordRing[xidRecord], exemplar *xidExemplar, logger *slog.Logger) {
+	for {
+		event, ret := eventSet.Wait(timeoutMs)
+		// Returning from Wait at all shows the event loop is not stuck
+		collectorLastSuccess.WithLabelValues(name).SetToCurrentTime()
+		if errors.Is(ret, nvml.ERROR_TIMEOUT) {
+			// Timeout is normal, just continue waiting
+			continue
+		}
+		if !errors.Is(ret, nvml.SUCCESS) {
+			logger.Warn("error waiting for NVML events", "collector", name, "error", nvml.ErrorString(ret))
+			continue
+		}
+
+		// Process the event if it's an Xid error
+		if event.EventType&nvml.EventTypeXidCriticalError != 0 {
+			runCollector(name, func() { handleXidEvent(event, health, remaps, xids, exemplar, logger) }, logger)
+		}
+		if event.EventType&eccEventTypes != 0 {
+			runCollector(name, func() { handleEccEvent(event, logger) }, logger)
+		}
+	}
+}
+
+// handleXidEvent processes a Xid event and increments the appropriate counter,
+// with an exemplar when exemplar is not nil. Memory Xids also report the row
+// remapping they caused through remaps, and every Xid is kept in xids for the
+// support bundle.
+func handleXidEvent(event Event, health *gpuHealthTracker, remaps *xidRowRemapTracker, xids *recordRing[xidRecord], exemplar *xidExemplar, logger *slog.Logger) {
 
 	// Get device UUID
 	uuid, ret := event.Device.GetUUID()
@@ -82,12 +143,23 @@ func handleXidEvent(event nvml.EventData, logger *slog.Logger) {
 		logger.Warn("failed to get PCI info for device in Xid event", "error", nvml.ErrorString(ret))
 		return
 	}
-	pciBusId := pciBusIdToString(pciInfo.BusIdLegacy)
+	pciBusId := pciBusID(pciInfo)
 
 	xid := event.EventData
 
 	// Increment Prometheus counter
-	xidErrors.WithLabelValues(uuid, pciBusId, formatXid(xid)).Inc()
+	counter := xidErrors.WithLabelValues(uuid, pciBusId, formatXid(xid))
+	if exemplar == nil {
+		counter.Inc()
+	} else if labels, err := exemplar.labels(uuid, pciBusId, xid); err != nil {
+		logger.Warn("failed to render Xid exemplar", "uuid", uuid, "xid", xid, "err", err)
+		counter.Inc()
+	} else {
+		counter.(prometheus.ExemplarAdder).AddWithExemplar(1, labels)
+	}
+	health.reportXid(uuid, xid)
+	remaps.reportXid(event.Device, uuid, pciBusId, xid, logger)
+	xids.add(xidRecord{Time: time.Now(), UUID: uuid, PciBusId: pciBusId, Xid: xid})
 
 	logger.Warn("Xid error detected", "uuid", uuid, "pci_bus_id", pciBusId, "xid", xid)
 }
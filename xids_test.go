@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/gogunit/gunit/hammy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestHandleXidEvent(t *testing.T) {
+	assert := hammy.New(t)
+	resetXidMetric(t)
+
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}
+	labeler := newNodeLabeler(nil, "node-a", []uint64{79}, discardLogger())
+	health := newGpuHealthTracker(nil, labeler, []uint64{79}, time.Hour)
+
+	handleXidEvent(Event{Device: device, EventType: nvml.EventTypeXidCriticalError, EventData: 13}, health, nil, nil, nil, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", "13"))).EqualTo(1))
+	assert.Is(hammy.False(labeler.desired()[labelXidCritical]))
+
+	handleXidEvent(Event{Device: device, EventType: nvml.EventTypeXidCriticalError, EventData: 79}, health, nil, nil, nil, discardLogger())
+	assert.Is(hammy.Number(testutil.ToFloat64(xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", "79"))).EqualTo(1))
+	assert.Is(hammy.True(labeler.desired()[labelXidCritical]))
+}
+
+func TestHandleXidEventExemplar(t *testing.T) {
+	assert := hammy.New(t)
+	resetXidMetric(t)
+
+	procPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(procPath, "uptime"), []byte("8123.45 64000.10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INCIDENT_ID", "INC-42")
+	exemplar, err := newXidExemplar(`uptime={{.Uptime}},incident={{env "INCIDENT_ID"}},ticket={{env "UNSET"}},xid={{.Xid}}`, procPath)
+	assert.Is(hammy.NilError(err))
+
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}
+	health := newGpuHealthTracker(nil, nil, nil, time.Hour)
+	handleXidEvent(Event{Device: device, EventType: nvml.EventTypeXidCriticalError, EventData: 79}, health, nil, nil, exemplar, discardLogger())
+
+	var pb dto.Metric
+	assert.Is(hammy.NilError(xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", "79").Write(&pb)))
+	labels := map[string]string{}
+	for _, pair := range pb.GetCounter().GetExemplar().GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	assert.Is(hammy.Number(pb.GetCounter().GetValue()).EqualTo(1))
+	assert.Is(hammy.Number(len(labels)).EqualTo(3))
+	assert.Is(hammy.String(labels["uptime"]).EqualTo("8123.45"))
+	assert.Is(hammy.String(labels["incident"]).EqualTo("INC-42"))
+	assert.Is(hammy.String(labels["xid"]).EqualTo("79"))
+}
+
+func TestXidExemplarRejectsInvalidLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "invalid name", text: "incident-id=1"},
+		{name: "too long", text: "incident=" + strings.Repeat("x", 128)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+			exemplar, err := newXidExemplar(tc.text, t.TempDir())
+			assert.Is(hammy.NilError(err))
+
+			_, err = exemplar.labels("GPU-0", "0000:18:00.0", 79)
+			assert.Is(hammy.Error(err))
+		})
+	}
+}
+
+func TestStartXidEventCollector(t *testing.T) {
+	assert := hammy.New(t)
+	resetXidMetric(t)
+
+	device := &fakeDevice{uuid: "GPU-0", pciBusId: "0000:18:00.0"}
+	client := &fakeClient{devices: []Device{device}}
+	devices, _, err := New(client, nil, discardLogger())
+	assert.Is(hammy.NilError(err))
+
+	cfg := &Config{XidWaitTimeout: 10 * time.Millisecond, XidEventShards: 1}
+	err = startXidEventCollector(devices, cfg, nil, nil, nil, nil, prometheus.NewRegistry(), discardLogger())
+	assert.Is(hammy.NilError(err))
+	assert.Is(hammy.Number(len(client.eventSets)).EqualTo(1))
+	assert.Is(hammy.Number(len(client.eventSets[0].registered)).EqualTo(1))
+
+	client.eventSets[0].events <- Event{Device: device, EventType: nvml.EventTypeXidCriticalError, EventData: 48}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.CollectAndCount(xidErrors) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Is(hammy.Number(testutil.ToFloat64(xidErrors.WithLabelValues("GPU-0", "0000:18:00.0", "48"))).EqualTo(1))
+}
+
+func TestStartXidEventCollectorShardsDevices(t *testing.T) {
+	tests := []struct {
+		name       string
+		devices    int
+		shards     int
+		wantShards []int
+	}{
+		{name: "single event set", devices: 3, shards: 1, wantShards: []int{3}},
+		{name: "round robin", devices: 5, shards: 2, wantShards: []int{3, 2}},
+		{name: "capped at device count", devices: 2, shards: 8, wantShards: []int{1, 1}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := hammy.New(t)
+
+			client := &fakeClient{}
+			for i := 0; i < tc.devices; i++ {
+				client.devices = append(client.devices, &fakeDevice{uuid: fmt.Sprintf("GPU-%d", i)})
+			}
+			devices, _, err := New(client, nil, discardLogger())
+			assert.Is(hammy.NilError(err))
+
+			cfg := &Config{XidWaitTimeout: 10 * time.Millisecond, XidEventShards: tc.shards}
+			err = startXidEventCollector(devices, cfg, nil, nil, nil, nil, prometheus.NewRegistry(), discardLogger())
+			assert.Is(hammy.NilError(err))
+
+			assert.Is(hammy.Number(len(client.eventSets)).EqualTo(len(tc.wantShards)))
+			for i, want := range tc.wantShards {
+				assert.Is(hammy.Number(len(client.eventSets[i].registered)).EqualTo(want))
+			}
+		})
+	}
+}
+
+func TestStartXidEventCollectorRejectsInvalidConfig(t *testing.T) {
+	assert := hammy.New(t)
+	devices, _, err := New(&fakeClient{}, nil, discardLogger())
+	assert.Is(hammy.NilError(err))
+
+	err = startXidEventCollector(devices, &Config{XidWaitTimeout: time.Second}, nil, nil, nil, nil, prometheus.NewRegistry(), discardLogger())
+	assert.Is(hammy.Error(err))
+
+	err = startXidEventCollector(devices, &Config{XidEventShards: 1}, nil, nil, nil, nil, prometheus.NewRegistry(), discardLogger())
+	assert.Is(hammy.Error(err))
+
+	err = startXidEventCollector(devices, &Config{XidWaitTimeout: time.Second, XidEventShards: 1, XidExemplar: "uptime={{.Uptime"}, nil, nil, nil, nil, prometheus.NewRegistry(), discardLogger())
+	assert.Is(hammy.Error(err))
+}
+
+func resetXidMetric(t *testing.T) {
+	t.Helper()
+	xidErrors.Reset()
+	t.Cleanup(xidErrors.Reset)
+}